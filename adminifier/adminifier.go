@@ -19,6 +19,7 @@ var tmpl *template.Template
 var mux *http.ServeMux
 var conf *wikifier.Page
 var sessMgr *scs.SessionManager
+var loginCaptcha webserver.Captcha
 var host, root, dirResource, dirAdminifier string
 
 // Configure sets up adminifier on webserver.ServeMux using webserver.Conf.
@@ -48,6 +49,22 @@ func Configure() {
 	dirAdminifier = filepath.Join(dirResource, "adminifier")
 	root += "/"
 
+	// captcha for the login form, if enabled
+	var captchaOpt wikifier.PageOptCaptcha
+	for key, ptr := range map[string]*string{
+		"adminifier.captcha.provider":   &captchaOpt.Provider,
+		"adminifier.captcha.site_key":   &captchaOpt.SiteKey,
+		"adminifier.captcha.secret_key": &captchaOpt.SecretKey,
+	} {
+		*ptr, _ = conf.GetStr(key)
+	}
+	if captchaOpt.Provider != "" {
+		var err error
+		if loginCaptcha, err = webserver.NewCaptcha(captchaOpt); err != nil {
+			log.Fatal(errors.Wrap(err, "adminifier.captcha"))
+		}
+	}
+
 	// configure session manager
 	sessMgr = webserver.SessMgr
 	sessMgr.Cookie.SameSite = http.SameSiteStrictMode
@@ -66,6 +83,11 @@ func Configure() {
 	// create template
 	tmpl = template.Must(tmpl.ParseGlob(filepath.Join(dirAdminifier, "template", "*.tpl")))
 
+	// frames provided by extensions
+	if err := setupExtensionFrames(); err != nil {
+		log.Fatal(errors.Wrap(err, "setup extension frames"))
+	}
+
 	// main handler
 	mux.HandleFunc(host+root, handleRoot)
 	log.Println("registered adminifier root: " + host + root)
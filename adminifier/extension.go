@@ -0,0 +1,53 @@
+package adminifier
+
+import (
+	"github.com/cooper/quiki/extension"
+	"github.com/pkg/errors"
+)
+
+// extensionFrame is a sidebar link to a frame provided by an extension
+type extensionFrame struct {
+	Name  string // frame name
+	Title string // title shown in the sidebar
+	Icon  string // Font Awesome icon class
+}
+
+var extensionFrames []extensionFrame
+
+// add frames provided by extensions to the templates and frame handlers
+func setupExtensionFrames() error {
+	for _, ext := range extension.Extensions() {
+		for name, frame := range ext.AdminFrames {
+
+			// built-in frames cannot be replaced
+			if _, exist := frameHandlers[name]; exist {
+				return errors.New(ext.Name + ": frame '" + name + "' already exists")
+			}
+
+			// parse the frame template
+			if _, err := tmpl.New("frame-" + name + ".tpl").Parse(frame.Template); err != nil {
+				return errors.Wrap(err, ext.Name+": frame '"+name+"'")
+			}
+
+			// add the handler
+			frame := frame
+			frameHandlers[name] = func(wr *wikiRequest) {
+				if frame.Handler == nil {
+					return
+				}
+				wr.dot, wr.err = frame.Handler(wr.wi.Wiki, wr.r)
+			}
+
+			// add to sidebar
+			title, icon := frame.Title, frame.Icon
+			if title == "" {
+				title = name
+			}
+			if icon == "" {
+				icon = "fa-puzzle-piece"
+			}
+			extensionFrames = append(extensionFrames, extensionFrame{name, title, icon})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package adminifier
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/cooper/quiki/wiki"
+)
+
+// number of pages listed as hubs beside the link graph
+const graphHubs = 10
+
+// a page placed in the drawing of the link graph
+type graphNode struct {
+	wiki.GraphNode
+	X, Y, R float64 // center and radius
+	Color   string  // color of the cluster
+}
+
+// a link drawn between two pages
+type graphEdge struct {
+	X1, Y1, X2, Y2 float64
+}
+
+// a group of pages in the same category, drawn around a circle
+type graphCluster struct {
+	Name    string
+	X, Y, R float64
+	Color   string
+	nodes   []*graphNode
+}
+
+// the link graph as drawn in the graph frame
+type graphDrawing struct {
+	Nodes    []*graphNode
+	Edges    []graphEdge
+	Clusters []*graphCluster
+	ViewBox  string
+	Hubs     []*graphNode // pages with the most backlinks
+	Orphans  []*graphNode // pages with no backlinks
+}
+
+// lays out a link graph. each cluster of pages is arranged on a circle, and
+// the clusters are arranged on a larger circle, largest first. pages are
+// sized by their number of backlinks
+func drawGraph(graph wiki.LinkGraph) graphDrawing {
+	var d graphDrawing
+	byFile := make(map[string]*graphNode, len(graph.Nodes))
+	byCluster := make(map[string]*graphCluster)
+	for _, n := range graph.Nodes {
+		node := &graphNode{GraphNode: n, R: 4 + 2*math.Sqrt(float64(n.Backlinks))}
+		d.Nodes = append(d.Nodes, node)
+		byFile[n.File] = node
+
+		cluster := byCluster[n.Cluster]
+		if cluster == nil {
+			cluster = &graphCluster{Name: n.Cluster}
+			byCluster[n.Cluster] = cluster
+			d.Clusters = append(d.Clusters, cluster)
+		}
+		cluster.nodes = append(cluster.nodes, node)
+
+		if n.Backlinks == 0 {
+			d.Orphans = append(d.Orphans, node)
+		}
+	}
+	if len(d.Nodes) == 0 {
+		return d
+	}
+	sort.SliceStable(d.Clusters, func(i, j int) bool {
+		return len(d.Clusters[i].nodes) > len(d.Clusters[j].nodes)
+	})
+
+	// size each cluster to fit its pages
+	circumference := 0.0
+	for i, cluster := range d.Clusters {
+		perimeter := 0.0
+		for _, node := range cluster.nodes {
+			perimeter += 2*node.R + 8
+		}
+		cluster.R = math.Max(perimeter/(2*math.Pi), 20)
+		cluster.Color = "hsl(" + strconv.Itoa(int(float64(i)*137.5)%360) + ", 60%, 50%)"
+		circumference += 2*cluster.R + 60
+	}
+
+	// place the clusters, and then the pages around each
+	radius := 0.0
+	if len(d.Clusters) > 1 {
+		radius = circumference / (2 * math.Pi)
+	}
+	angle := 0.0
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, cluster := range d.Clusters {
+		share := (2*cluster.R + 60) / circumference * 2 * math.Pi
+		angle += share / 2
+		cluster.X, cluster.Y = radius*math.Cos(angle), radius*math.Sin(angle)
+		angle += share / 2
+
+		for i, node := range cluster.nodes {
+			a := 2 * math.Pi * float64(i) / float64(len(cluster.nodes))
+			node.X = cluster.X + cluster.R*math.Cos(a)
+			node.Y = cluster.Y + cluster.R*math.Sin(a)
+			node.Color = cluster.Color
+			if len(cluster.nodes) == 1 {
+				node.X, node.Y = cluster.X, cluster.Y
+			}
+		}
+		pad := cluster.R + 40
+		minX, minY = math.Min(minX, cluster.X-pad), math.Min(minY, cluster.Y-pad)
+		maxX, maxY = math.Max(maxX, cluster.X+pad), math.Max(maxY, cluster.Y+pad)
+	}
+	d.ViewBox = strconv.Itoa(int(minX)) + " " + strconv.Itoa(int(minY)) + " " +
+		strconv.Itoa(int(maxX-minX)) + " " + strconv.Itoa(int(maxY-minY))
+
+	for _, link := range graph.Links {
+		source, target := byFile[link.Source], byFile[link.Target]
+		if source == nil || target == nil {
+			continue
+		}
+		d.Edges = append(d.Edges, graphEdge{source.X, source.Y, target.X, target.Y})
+	}
+
+	// most linked pages
+	hubs := append([]*graphNode(nil), d.Nodes...)
+	sort.SliceStable(hubs, func(i, j int) bool {
+		return hubs[i].Backlinks > hubs[j].Backlinks
+	})
+	for _, node := range hubs {
+		if len(d.Hubs) == graphHubs || node.Backlinks == 0 {
+			break
+		}
+		d.Hubs = append(d.Hubs, node)
+	}
+
+	return d
+}
@@ -1,6 +1,7 @@
 package adminifier
 
 import (
+	"html"
 	"net/http"
 	"strings"
 
@@ -12,8 +13,14 @@ import (
 var funcHandlers = map[string]func(w http.ResponseWriter, r *http.Request){
 	"func/login": handleLogin,
 	"logout":     handleLogout,
+	"api":        handleAPIDocs,
 }
 
+// default location of Swagger UI, which can be overridden with
+// adminifier.swagger_ui to serve it locally. the version is pinned exactly,
+// so that the scripts loaded into the logged-in panel cannot change under it
+const defaultSwaggerUI = "https://unpkg.com/swagger-ui-dist@5.17.14"
+
 func handleRoot(w http.ResponseWriter, r *http.Request) {
 
 	// if not logged in, temp redirect to login page
@@ -39,6 +46,25 @@ func handleRoot(w http.ResponseWriter, r *http.Request) {
 	// and deny access to the server admin panel
 }
 
+// API documentation with Swagger UI
+func handleAPIDocs(w http.ResponseWriter, r *http.Request) {
+	if !sessMgr.GetBool(r.Context(), "loggedIn") {
+		http.Redirect(w, r, root+"login", http.StatusTemporaryRedirect)
+		return
+	}
+	swaggerUI, _ := conf.GetStr("adminifier.swagger_ui")
+	if swaggerUI == "" {
+		swaggerUI = defaultSwaggerUI
+	}
+	tmpl.ExecuteTemplate(w, "api.tpl", struct {
+		SwaggerUI string
+		Spec      string
+	}{
+		SwaggerUI: strings.TrimSuffix(html.UnescapeString(swaggerUI), "/"),
+		Spec:      "/api/openapi.json",
+	})
+}
+
 func handleLogin(w http.ResponseWriter, r *http.Request) {
 
 	// missing parameters or malformed request
@@ -49,6 +75,12 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 	// any login attempt voids the current session
 	sessMgr.Destroy(r.Context())
 
+	// check the captcha first, so passwords cannot be guessed without it
+	if loginCaptcha != nil && !loginCaptcha.Verify(r) {
+		w.Write([]byte("Captcha not solved"))
+		return
+	}
+
 	// attempt login
 	user, err := webserver.Auth.Login(r.Form.Get("username"), r.Form.Get("password"))
 	if err != nil {
@@ -1,6 +1,7 @@
 package adminifier
 
 import (
+	"html/template"
 	"net/http"
 	"strings"
 )
@@ -10,7 +11,13 @@ var tmplHandlers = []string{"login"}
 
 func handleTemplate(w http.ResponseWriter, r *http.Request) {
 	relPath := strings.TrimPrefix(r.URL.Path, root)
-	err := tmpl.ExecuteTemplate(w, relPath+".tpl", nil)
+	var dot struct {
+		Captcha template.HTML // login captcha, if enabled
+	}
+	if loginCaptcha != nil {
+		dot.Captcha = loginCaptcha.HTML()
+	}
+	err := tmpl.ExecuteTemplate(w, relPath+".tpl", dot)
 	if err != nil {
 		// TODO: internal server error
 		panic(err)
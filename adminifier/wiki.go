@@ -5,9 +5,12 @@ import (
 	"html/template"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cooper/quiki/authenticator"
 	"github.com/cooper/quiki/webserver"
@@ -26,10 +29,17 @@ var frameHandlers = map[string]func(*wikiRequest){
 	"images":        handleImagesFrame,
 	"models":        handleModelsFrame,
 	"settings":      handleSettingsFrame,
+	"variables":     handleVariablesFrame,
 	"edit-page":     handleEditPageFrame,
+	"create-page":   handleCreatePageFrame,
 	"edit-category": handleEditCategoryFrame,
 	"edit-model":    handleEditModelFrame,
 	"switch-branch": handleSwitchBranchFrame,
+	"review":        handleReviewFrame,
+	"notes":         handleNotesFrame,
+	"graph":         handleGraphFrame,
+	"dead-links":    handleDeadLinksFrame,
+	"similar-pages": handleSimilarPagesFrame,
 	"help":          handleHelpFrame,
 	"help/":         handleHelpFrame,
 }
@@ -38,6 +48,10 @@ var wikiFuncHandlers = map[string]func(*wikiRequest){
 	"switch-branch/": handleSwitchBranch,
 	"create-branch":  handleCreateBranch,
 	"write-page":     handleWritePage,
+	"create-page":    handleCreatePage,
+	"approve-review": handleApproveReview,
+	"reject-review":  handleRejectReview,
+	"backup":         handleBackup,
 	"image/":         handleImage,
 }
 
@@ -52,6 +66,8 @@ type wikiTemplate struct {
 	QStatic           string              // webserver static root
 	AdminRoot         string              // adminifier root
 	Root              string              // wiki root
+	ExtensionFrames   []extensionFrame    // frames provided by extensions
+	Review            bool                // whether moderation is enabled
 }
 
 type wikiRequest struct {
@@ -71,6 +87,10 @@ type editorOpts struct {
 	config bool        // true if editing the config
 	cat    bool        // true if editing a category
 	info   interface{} // PageInfo or ModelInfo
+
+	// content for a file which does not exist yet, if it is created when
+	// saved
+	newContent string
 }
 
 // TODO: verify session on ALL wiki handlers
@@ -247,14 +267,39 @@ func handleDashboardFrame(wr *wikiRequest) {
 		}
 	}
 
+	user := sessMgr.Get(wr.r.Context(), "user").(*authenticator.User)
 	wr.dot = struct {
 		Logs     string
 		Errors   []wikifier.PageInfo
 		Warnings []wikifier.PageInfo
+		Jobs     []webserver.JobStatus
+		Editor   bool
+		wikiTemplate
 	}{
-		Logs:     string(logs),
-		Errors:   errors,
-		Warnings: warnings,
+		Logs:         string(logs),
+		Errors:       errors,
+		Warnings:     warnings,
+		Jobs:         wr.wi.Jobs(),
+		Editor:       wr.wi.IsEditor(user.Username),
+		wikiTemplate: getGenericTemplate(wr),
+	}
+}
+
+func handleBackup(wr *wikiRequest) {
+
+	// the backup includes the configuration, so only editors can download it
+	user := sessMgr.Get(wr.r.Context(), "user").(*authenticator.User)
+	if !wr.wi.IsEditor(user.Username) {
+		http.Error(wr.w, "Only editors can download backups", http.StatusForbidden)
+		return
+	}
+
+	fileName := wr.shortcode + "-" + time.Now().Format("20060102-150405") + ".tar.gz"
+	wr.w.Header().Set("Content-Type", "application/gzip")
+	wr.w.Header().Set("Content-Disposition", `attachment; filename="`+fileName+`"`)
+	if err := wr.wi.Backup(wr.w); err != nil {
+		// headers are already sent, so the download is cut short
+		wr.wi.Log("backup failed:", err)
 	}
 }
 
@@ -288,6 +333,22 @@ func handlePagesFrame(wr *wikiRequest) {
 func handleImagesFrame(wr *wikiRequest) {
 	descending, sortFunc := getSortFunc(wr)
 	images := wr.wi.ImagesSorted(descending, sortFunc, wiki.SortTitle)
+
+	// only images which no page uses
+	if _, ok := wr.r.URL.Query()["unused"]; ok {
+		unused := make(map[string]bool)
+		for _, name := range wr.wi.UnusedImages() {
+			unused[name] = true
+		}
+		var filtered []wiki.ImageInfo
+		for _, info := range images {
+			if unused[info.File] {
+				filtered = append(filtered, info)
+			}
+		}
+		images = filtered
+	}
+
 	handleFileFrames(wr, images, "d")
 }
 
@@ -322,15 +383,18 @@ func handleFileFrames(wr *wikiRequest, results interface{}, extras ...string) {
 		s = "m-"
 	}
 
+	_, unused := wr.r.URL.Query()["unused"]
 	wr.dot = struct {
-		JSON  template.HTML
-		Order string // sort
-		List  bool   // for images, show file list rather than grid
+		JSON   template.HTML
+		Order  string // sort
+		List   bool   // for images, show file list rather than grid
+		Unused bool   // for images, show only those which no page uses
 		wikiTemplate
 	}{
 		JSON:         template.HTML("<!--JSON\n" + string(res) + "\n-->"),
 		Order:        s,
 		List:         wr.r.URL.Query().Get("mode") == "list",
+		Unused:       unused,
 		wikiTemplate: getGenericTemplate(wr),
 	}
 }
@@ -340,6 +404,22 @@ func handleSettingsFrame(wr *wikiRequest) {
 	handleEditor(wr, wr.wi.ConfigFile, "wiki.conf", "Configuration file", editorOpts{config: true})
 }
 
+// starting content of the variables file
+const siteVarsTemplate = `/* variables available to every page as @site, such as:
+
+@product:       My Product;
+@support.email: support@example.com;
+
+which are used like [@site.product] */
+`
+
+func handleVariablesFrame(wr *wikiRequest) {
+	// serve editor for the variables file. if it does not exist, it is
+	// created when saved
+	path := wr.wi.Dir(wiki.SiteVarsFile)
+	handleEditor(wr, path, wiki.SiteVarsFile, "Site variables", editorOpts{config: true, newContent: siteVarsTemplate})
+}
+
 func handleEditPageFrame(wr *wikiRequest) {
 	q := wr.r.URL.Query()
 
@@ -361,6 +441,77 @@ func handleEditPageFrame(wr *wikiRequest) {
 	handleEditor(wr, info.Path, info.File, info.Title, editorOpts{page: true, info: info})
 }
 
+func handleCreatePageFrame(wr *wikiRequest) {
+	q := wr.r.URL.Query()
+	dot := struct {
+		Page      string              // page filename
+		Templates []wiki.PageTemplate // templates to choose from
+		Chosen    bool                // true if a template, or none, was chosen
+		Template  *wiki.PageTemplate  // chosen template, if any
+		wikiTemplate
+	}{
+		Page:         q.Get("page"),
+		Templates:    wr.wi.PageTemplates(),
+		wikiTemplate: getGenericTemplate(wr),
+	}
+
+	// a template was chosen, so prompt for its placeholders
+	if _, chosen := q["template"]; chosen && dot.Page != "" {
+		dot.Chosen = true
+		if name := q.Get("template"); name != "" {
+			t, err := wr.wi.PageTemplate(name)
+			if err != nil {
+				wr.err = err
+				return
+			}
+			dot.Template = &t
+		}
+	}
+
+	wr.dot = dot
+}
+
+func handleCreatePage(wr *wikiRequest) {
+	if !parsePost(wr.w, wr.r, "page") {
+		return
+	}
+	pageName := wikifier.PageName(wr.r.Form.Get("page"))
+	if info := wr.wi.PageInfo(pageName); info.File != "" {
+		wr.err = errors.New("page already exists: " + info.File)
+		return
+	}
+
+	// fill in the template, if any. values are given as ph.name
+	content := ""
+	if name := wr.r.Form.Get("template"); name != "" {
+		values := make(map[string]string)
+		for key, value := range wr.r.PostForm {
+			if strings.HasPrefix(key, "ph.") && strings.TrimSpace(value[0]) != "" {
+				values[strings.TrimPrefix(key, "ph.")] = value[0]
+			}
+		}
+		if content, wr.err = wr.wi.NewPageFromTemplate(name, values); wr.err != nil {
+			return
+		}
+	}
+	message := "Create " + pageName
+
+	// propose the page for review if the user is not an editor
+	user := sessMgr.Get(wr.r.Context(), "user").(*authenticator.User)
+	if !wr.wi.IsEditor(user.Username) {
+		if _, wr.err = wr.wi.ProposeFile(filepath.Join("pages", pageName), []byte(content), getCommitOpts(wr, message)); wr.err != nil {
+			return
+		}
+		http.Redirect(wr.w, wr.r, wr.wikiRoot+"/review", http.StatusSeeOther)
+		return
+	}
+
+	if wr.err = wr.wi.WritePage(pageName, []byte(content), true, getCommitOpts(wr, message)); wr.err != nil {
+		return
+	}
+	http.Redirect(wr.w, wr.r, wr.wikiRoot+"/edit-page?page="+url.QueryEscape(pageName), http.StatusSeeOther)
+}
+
 func handleEditModelFrame(wr *wikiRequest) {
 	q := wr.r.URL.Query()
 
@@ -409,15 +560,19 @@ func handleEditor(wr *wikiRequest, path, file, title string, o editorOpts) {
 
 	// call DisplayFile to get the content
 	var fileRes wiki.DisplayFile
-	switch r := wr.wi.DisplayFile(path).(type) {
-	case wiki.DisplayFile:
-		fileRes = r
-	case wiki.DisplayError:
-		wr.err = errors.New(r.DetailedError)
-		return
-	default:
-		wr.err = errors.New("unknown error occurred in DisplayFile")
-		return
+	if _, err := os.Stat(path); os.IsNotExist(err) && o.newContent != "" {
+		fileRes = wiki.DisplayFile{File: file, Path: path, Content: o.newContent}
+	} else {
+		switch r := wr.wi.DisplayFile(path).(type) {
+		case wiki.DisplayFile:
+			fileRes = r
+		case wiki.DisplayError:
+			wr.err = errors.New(r.DetailedError)
+			return
+		default:
+			wr.err = errors.New("unknown error occurred in DisplayFile")
+			return
+		}
 	}
 
 	// json stuff
@@ -592,13 +747,163 @@ func handleWritePage(wr *wikiRequest) {
 	// TODO: double check the path is OK
 	pageName, content, message := wr.r.Form.Get("page"), wr.r.Form.Get("content"), wr.r.Form.Get("message")
 
+	// the variables file is the only configuration which can be saved here
+	if _, ok := wr.r.URL.Query()["config"]; ok {
+		handleWriteSiteVars(wr, pageName, content, message)
+		return
+	}
+
+	// if moderation is enabled and the user is not an editor,
+	// propose the change for review instead
+	user := sessMgr.Get(wr.r.Context(), "user").(*authenticator.User)
+	if !wr.wi.IsEditor(user.Username) {
+		_, wr.err = wr.wi.ProposeFile(filepath.Join("pages", pageName), []byte(content), getCommitOpts(wr, message))
+		return
+	}
+
 	// write the file & commit
-	if err := wr.wi.WriteFile(filepath.Join("pages", pageName), []byte(content), true, getCommitOpts(wr, message)); err != nil {
+	if err := wr.wi.WritePage(pageName, []byte(content), true, getCommitOpts(wr, message)); err != nil {
 		wr.err = err
 		return
 	}
 }
 
+func handleWriteSiteVars(wr *wikiRequest, file, content, message string) {
+	if file != wiki.SiteVarsFile {
+		wr.err = errors.New("only " + wiki.SiteVarsFile + " can be saved")
+		return
+	}
+
+	// propose the change for review if the user is not an editor
+	user := sessMgr.Get(wr.r.Context(), "user").(*authenticator.User)
+	if !wr.wi.IsEditor(user.Username) {
+		_, wr.err = wr.wi.ProposeFile(file, []byte(content), getCommitOpts(wr, message))
+		return
+	}
+
+	wr.err = wr.wi.WriteFile(file, []byte(content), true, getCommitOpts(wr, message))
+}
+
+func handleReviewFrame(wr *wikiRequest) {
+	user := sessMgr.Get(wr.r.Context(), "user").(*authenticator.User)
+	wr.dot = struct {
+		Queue  []*wiki.Review
+		Editor bool
+		wikiTemplate
+	}{
+		Queue:        wr.wi.ReviewQueue(),
+		Editor:       wr.wi.IsEditor(user.Username),
+		wikiTemplate: getGenericTemplate(wr),
+	}
+}
+
+func handleNotesFrame(wr *wikiRequest) {
+	wr.dot = struct {
+		Pages []wiki.PageNotes
+		wikiTemplate
+	}{
+		Pages:        wr.wi.EditorNotes(),
+		wikiTemplate: getGenericTemplate(wr),
+	}
+}
+
+func handleGraphFrame(wr *wikiRequest) {
+	wr.dot = struct {
+		Graph graphDrawing
+		wikiTemplate
+	}{
+		Graph:        drawGraph(wr.wi.LinkGraph()),
+		wikiTemplate: getGenericTemplate(wr),
+	}
+}
+
+func handleDeadLinksFrame(wr *wikiRequest) {
+	scheduled := false
+	for _, job := range wr.wi.Jobs() {
+		if job.Name == "check_external_links" && job.Schedule != "" {
+			scheduled = true
+		}
+	}
+	wr.dot = struct {
+		Links     []wiki.ExternalLink
+		Scheduled bool
+		wikiTemplate
+	}{
+		Links:        wr.wi.DeadExternalLinks(),
+		Scheduled:    scheduled,
+		wikiTemplate: getGenericTemplate(wr),
+	}
+}
+
+// similarity thresholds offered by the similar pages frame, as percentages
+var similarityThresholds = []int{30, 50, 70, 90}
+
+func handleSimilarPagesFrame(wr *wikiRequest) {
+	min, err := strconv.Atoi(wr.r.URL.Query().Get("min"))
+	if err != nil || min < 1 || min > 100 {
+		min = 50
+	}
+	type similarPair struct {
+		Pages   [2]string
+		Percent int
+	}
+	var pairs []similarPair
+	for _, sim := range wr.wi.DuplicatePages(float64(min) / 100) {
+		pairs = append(pairs, similarPair{sim.Pages, int(sim.Similarity * 100)})
+	}
+	wr.dot = struct {
+		Pairs      []similarPair
+		Min        int
+		Thresholds []int
+		wikiTemplate
+	}{
+		Pairs:        pairs,
+		Min:          min,
+		Thresholds:   similarityThresholds,
+		wikiTemplate: getGenericTemplate(wr),
+	}
+}
+
+func handleApproveReview(wr *wikiRequest) {
+	if !parsePost(wr.w, wr.r, "id") {
+		return
+	}
+
+	// only editors can approve
+	user := sessMgr.Get(wr.r.Context(), "user").(*authenticator.User)
+	if !wr.wi.IsEditor(user.Username) {
+		wr.err = errors.New("only editors can approve changes")
+		return
+	}
+
+	if wr.err = wr.wi.ApproveReview(wr.r.Form.Get("id"), getCommitOpts(wr, "")); wr.err != nil {
+		return
+	}
+
+	// redirect back to review queue
+	http.Redirect(wr.w, wr.r, wr.wikiRoot+"/review", http.StatusSeeOther)
+}
+
+func handleRejectReview(wr *wikiRequest) {
+	if !parsePost(wr.w, wr.r, "id") {
+		return
+	}
+
+	// only editors can reject
+	user := sessMgr.Get(wr.r.Context(), "user").(*authenticator.User)
+	if !wr.wi.IsEditor(user.Username) {
+		wr.err = errors.New("only editors can reject changes")
+		return
+	}
+
+	if wr.err = wr.wi.RejectReview(wr.r.Form.Get("id"), user.DisplayName, wr.r.Form.Get("reason")); wr.err != nil {
+		return
+	}
+
+	// redirect back to review queue
+	http.Redirect(wr.w, wr.r, wr.wikiRoot+"/review", http.StatusSeeOther)
+}
+
 func handleImage(wr *wikiRequest) {
 	imageName := strings.TrimPrefix(wr.r.URL.Path, wr.wikiRoot+"/func/image/")
 	si := wiki.SizedImageFromName(imageName)
@@ -656,6 +961,8 @@ func getGenericTemplate(wr *wikiRequest) wikiTemplate {
 		Static:            root + "static",
 		QStatic:           root + "qstatic",
 		Root:              root + wr.shortcode,
+		ExtensionFrames:   extensionFrames,
+		Review:            wr.wi.Opt.Review.Enable,
 	}
 }
 
@@ -665,5 +972,6 @@ func getCommitOpts(wr *wikiRequest, comment string) wiki.CommitOpts {
 		Comment: comment,
 		Name:    user.DisplayName,
 		Email:   user.Email,
+		User:    user.Username,
 	}
 }
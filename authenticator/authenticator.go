@@ -48,6 +48,11 @@ func Open(path string) (*Authenticator, error) {
 	return auth, auth.write()
 }
 
+// Path returns the path to the user data file.
+func (auth *Authenticator) Path() string {
+	return auth.path
+}
+
 // Write overwrites the data file with the current contents of the Authenticator.
 func (auth *Authenticator) write() error {
 	auth.mu.Lock()
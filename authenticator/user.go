@@ -76,3 +76,28 @@ func (user *User) GobDecode(data []byte) error {
 func (user *User) GobEncode() ([]byte, error) {
 	return json.Marshal(user)
 }
+
+// UpdateUser changes the username, display name, and email address of the
+// user by the given username. The password is unchanged.
+func (auth *Authenticator) UpdateUser(username string, info User) error {
+	lcun := strings.ToLower(username)
+	user, exist := auth.Users[lcun]
+	if !exist {
+		return errors.New("user does not exist")
+	}
+
+	// new username is taken
+	newLcun := strings.ToLower(info.Username)
+	if _, exist := auth.Users[newLcun]; exist && newLcun != lcun {
+		return errors.New("user exists")
+	}
+
+	user.Username = info.Username
+	user.DisplayName = info.DisplayName
+	user.Email = info.Email
+	delete(auth.Users, lcun)
+	auth.Users[newLcun] = user
+
+	// write to file
+	return auth.write()
+}
@@ -0,0 +1,136 @@
+// Package extension provides a way to distribute quiki functionality separately
+// from quiki itself.
+//
+// An extension is described by an Extension manifest listing the formatting
+// tags, block types, page hooks, HTTP routes, and adminifier frames it
+// provides. Programs embedding quiki can call Register directly. Extensions
+// compiled as Go plugins export a variable named Extension of type
+// *extension.Extension and are loaded with Load, typically by listing them in
+// the webserver configuration.
+package extension
+
+import (
+	"errors"
+	"net/http"
+	"plugin"
+	"sync"
+
+	"github.com/cooper/quiki/wiki"
+	"github.com/cooper/quiki/wikifier"
+)
+
+// Extension is a manifest of the functionality provided by an extension.
+type Extension struct {
+	Name        string // extension name, must be unique
+	Version     string // extension version
+	Description string // human-readable description
+
+	// custom inline formatting tags, e.g. "yt" for [yt:VIDEOID]
+	Formats map[string]wikifier.FormatFunc
+
+	// custom block types, e.g. "pricing" for pricing { ... }
+	Blocks map[string]wikifier.BlockFunc
+
+	// page generation hooks, called for all wikis
+	PageHooks map[wiki.PageHookStage][]wiki.PageHook
+
+	// HTTP handlers registered on the webserver, keyed by pattern
+	// as accepted by http.ServeMux
+	Routes map[string]http.Handler
+
+	// adminifier frames, keyed by frame name
+	AdminFrames map[string]AdminFrame
+}
+
+// AdminFrame describes a frame displayed within the adminifier wiki panel.
+type AdminFrame struct {
+	Title    string // title shown in the navigation sidebar
+	Icon     string // Font Awesome icon class, such as "fa-chart-bar"
+	Template string // html/template source for the frame
+
+	// Handler returns the data passed to the template.
+	// It is called with the wiki selected in the panel and the request.
+	Handler func(w *wiki.Wiki, r *http.Request) (interface{}, error)
+}
+
+var (
+	extensions []*Extension
+	extLock    sync.Mutex
+)
+
+// Register registers an extension.
+//
+// Formats, blocks, and page hooks take effect immediately. Routes and adminifier
+// frames are set up when the webserver and adminifier are configured, so
+// extensions providing them must be registered beforehand.
+func Register(ext *Extension) error {
+	if ext == nil || ext.Name == "" {
+		return errors.New("extension has no name")
+	}
+
+	extLock.Lock()
+	defer extLock.Unlock()
+
+	// check for duplicates
+	for _, existing := range extensions {
+		if existing.Name == ext.Name {
+			return errors.New("extension '" + ext.Name + "' is already registered")
+		}
+	}
+
+	// formatting tags
+	for name, handler := range ext.Formats {
+		if err := wikifier.RegisterFormat(name, handler); err != nil {
+			return errors.New(ext.Name + ": " + err.Error())
+		}
+	}
+
+	// block types
+	for name, handler := range ext.Blocks {
+		if err := wikifier.RegisterBlockType(name, handler); err != nil {
+			return errors.New(ext.Name + ": " + err.Error())
+		}
+	}
+
+	// page hooks
+	for stage, hooks := range ext.PageHooks {
+		for _, hook := range hooks {
+			wiki.AddPageHook(stage, hook)
+		}
+	}
+
+	extensions = append(extensions, ext)
+	return nil
+}
+
+// Load loads and registers an extension compiled as a Go plugin.
+//
+// The plugin must export a variable named Extension of type *Extension.
+func Load(path string) error {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return err
+	}
+
+	sym, err := p.Lookup("Extension")
+	if err != nil {
+		return err
+	}
+
+	// the symbol is a pointer to the exported variable
+	switch ext := sym.(type) {
+	case **Extension:
+		return Register(*ext)
+	case *Extension:
+		return Register(ext)
+	}
+
+	return errors.New(path + ": Extension is not an *extension.Extension")
+}
+
+// Extensions returns all registered extensions, in the order they were registered.
+func Extensions() []*Extension {
+	extLock.Lock()
+	defer extLock.Unlock()
+	return append([]*Extension(nil), extensions...)
+}
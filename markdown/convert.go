@@ -0,0 +1,70 @@
+package markdown
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+
+	"github.com/russross/blackfriday/v2"
+)
+
+// blackfriday extensions used by Run and Convert
+const extensions = blackfriday.NoEmptyLineBeforeBlock | blackfriday.CommonExtensions | blackfriday.Footnotes
+
+// An Option configures Convert.
+type Option func(params *QuikiRendererParameters)
+
+// WithFlags replaces the default renderer flags, which are TableOfContents,
+// FootnoteReturnLinks, and FrontMatter.
+func WithFlags(flags QuikiFlags) Option {
+	return func(params *QuikiRendererParameters) {
+		params.Flags = flags
+	}
+}
+
+// WithAbsolutePrefix sets the path prepended to relative URLs.
+func WithAbsolutePrefix(prefix string) Option {
+	return func(params *QuikiRendererParameters) {
+		params.AbsolutePrefix = prefix
+	}
+}
+
+// WithTitle sets the page title, rather than using the first level-1 heading.
+func WithTitle(title string) Option {
+	return func(params *QuikiRendererParameters) {
+		params.Title = title
+	}
+}
+
+// Convert reads Markdown from in and writes quiki source code to out.
+//
+// The quiki source is written to out as each Markdown node is rendered, so
+// the translated document is never held in memory; but the Markdown input
+// must be read completely before it can be parsed.
+func Convert(in io.Reader, out io.Writer, opts ...Option) error {
+	r := newRenderer(opts)
+	input, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	input = r.FrontMatter(input)
+	ast := blackfriday.New(blackfriday.WithRenderer(r), blackfriday.WithExtensions(extensions)).Parse(input)
+
+	// the renderer ignores write errors, but bufio remembers them
+	w := bufio.NewWriter(out)
+	r.RenderHeader(w, ast)
+	ast.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		return r.RenderNode(w, node, entering)
+	})
+	r.RenderFooter(w, ast)
+	return w.Flush()
+}
+
+// creates a renderer with the default flags and the given options
+func newRenderer(opts []Option) *QuikiRenderer {
+	params := QuikiRendererParameters{Flags: TableOfContents | FootnoteReturnLinks | FrontMatter}
+	for _, opt := range opts {
+		opt(&params)
+	}
+	return NewQuikiRenderer(params)
+}
@@ -0,0 +1,267 @@
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var frontMatterKeyRegex = regexp.MustCompile(`[^\w\.]`)
+
+// a front matter variable. value is a string, bool, or []string
+type frontMatterVar struct {
+	key   string
+	value interface{}
+}
+
+// front matter keys which map to quiki variables
+var frontMatterKeys = map[string]string{
+	"author":      "page.author",
+	"date":        "page.created",
+	"created":     "page.created",
+	"description": "page.desc",
+	"desc":        "page.desc",
+	"keywords":    "page.keywords",
+	"draft":       "page.draft",
+}
+
+// front matter keys whose values are category names
+var frontMatterCategoryKeys = map[string]bool{
+	"categories": true,
+	"category":   true,
+	"tags":       true,
+}
+
+// splits YAML (---) or TOML (+++) front matter from the start of input,
+// returning the variables and the remaining input
+func splitFrontMatter(input []byte) ([]frontMatterVar, []byte) {
+	input = bytes.TrimPrefix(input, []byte("\ufeff"))
+	for _, delim := range []string{"---", "+++"} {
+		if !bytes.HasPrefix(input, []byte(delim+"\n")) && !bytes.HasPrefix(input, []byte(delim+"\r\n")) {
+			continue
+		}
+
+		// find closing delimiter
+		lines := strings.SplitAfter(string(input), "\n")
+		for i := 1; i < len(lines); i++ {
+			if strings.TrimSpace(lines[i]) != delim {
+				continue
+			}
+			source := lines[1:i]
+			rest := []byte(strings.Join(lines[i+1:], ""))
+			if delim == "---" {
+				return parseYAMLFrontMatter(source), rest
+			}
+			return parseTOMLFrontMatter(source), rest
+		}
+	}
+	return nil, input
+}
+
+// parses a subset of YAML: scalars, inline [lists], block lists, and nested
+// maps, which become dotted keys
+func parseYAMLFrontMatter(lines []string) []frontMatterVar {
+	var vars []frontMatterVar
+	type level struct {
+		indent int
+		prefix string
+	}
+	var stack []level
+	var listKey string
+	var list []string
+
+	finishList := func() {
+		if listKey != "" && len(list) != 0 {
+			vars = append(vars, frontMatterVar{listKey, list})
+		}
+		listKey, list = "", nil
+	}
+
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\r\n")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+
+		// block list item
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			if listKey != "" {
+				list = append(list, yamlScalar(strings.TrimPrefix(trimmed, "-")))
+			}
+			continue
+		}
+		finishList()
+
+		// key: value
+		split := strings.SplitN(trimmed, ":", 2)
+		if len(split) != 2 {
+			continue
+		}
+		for len(stack) != 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		key := strings.Trim(strings.TrimSpace(split[0]), `"'`)
+		if len(stack) != 0 {
+			key = stack[len(stack)-1].prefix + "." + key
+		}
+		value := stripYAMLComment(strings.TrimSpace(split[1]))
+
+		switch {
+
+		// nested map or block list follows
+		case value == "":
+			stack = append(stack, level{indent, key})
+			listKey = key
+
+		// inline list
+		case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+			vars = append(vars, frontMatterVar{key, inlineList(value)})
+
+		default:
+			vars = append(vars, frontMatterVar{key, yamlValue(value)})
+		}
+	}
+	finishList()
+	return vars
+}
+
+// parses a subset of TOML: key = value pairs, arrays, and [tables], which
+// become dotted keys
+func parseTOMLFrontMatter(lines []string) []frontMatterVar {
+	var vars []frontMatterVar
+	prefix := ""
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// [table]
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			prefix = strings.Trim(line, "[] ") + "."
+			continue
+		}
+
+		split := strings.SplitN(line, "=", 2)
+		if len(split) != 2 {
+			continue
+		}
+		key := prefix + strings.Trim(strings.TrimSpace(split[0]), `"'`)
+		value := strings.TrimSpace(split[1])
+		if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+			vars = append(vars, frontMatterVar{key, inlineList(value)})
+			continue
+		}
+		if value == "true" || value == "false" {
+			vars = append(vars, frontMatterVar{key, value == "true"})
+			continue
+		}
+		vars = append(vars, frontMatterVar{key, unquote(value)})
+	}
+	return vars
+}
+
+func yamlValue(value string) interface{} {
+	switch strings.ToLower(value) {
+	case "true", "yes", "on":
+		return true
+	case "false", "no", "off":
+		return false
+	}
+	return yamlScalar(value)
+}
+
+func yamlScalar(value string) string {
+	return unquote(stripYAMLComment(strings.TrimSpace(value)))
+}
+
+// removes a trailing # comment, unless the value is quoted
+func stripYAMLComment(value string) string {
+	if strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'") {
+		return value
+	}
+	if i := strings.Index(value, " #"); i != -1 {
+		return strings.TrimSpace(value[:i])
+	}
+	return value
+}
+
+// [a, "b", c] -> a, b, c
+func inlineList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(strings.Trim(value, "[]"), ",") {
+		if item = unquote(strings.TrimSpace(item)); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		if s, err := strconv.Unquote(value); err == nil {
+			return s
+		}
+	}
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		return strings.Replace(value[1:len(value)-1], "''", "'", -1)
+	}
+	return value
+}
+
+// returns quiki variable assignments for front matter. the title is
+// omitted, since the renderer emits it after the content
+func frontMatterSource(vars []frontMatterVar) string {
+	var b strings.Builder
+	for _, v := range vars {
+		key := frontMatterKeyRegex.ReplaceAllString(v.key, "_")
+		if key == "" || strings.EqualFold(key, "title") {
+			continue
+		}
+
+		// categories
+		if frontMatterCategoryKeys[strings.ToLower(key)] {
+			names, ok := v.value.([]string)
+			if str, isStr := v.value.(string); isStr {
+				names, ok = strings.Split(str, ","), true
+			}
+			if !ok {
+				continue
+			}
+			for _, name := range names {
+				if name = categoryVarName(name); name != "" {
+					b.WriteString("@category." + name + ";\n")
+				}
+			}
+			continue
+		}
+
+		if mapped, ok := frontMatterKeys[strings.ToLower(key)]; ok {
+			key = mapped
+		}
+
+		switch value := v.value.(type) {
+		case bool:
+			if value {
+				b.WriteString("@" + key + ";\n")
+			} else {
+				b.WriteString("-@" + key + ";\n")
+			}
+		case []string:
+			b.WriteString("@" + key + ": " + quikiEscListMapValue(strings.Join(value, ", ")) + ";\n")
+		case string:
+			b.WriteString("@" + key + ": " + quikiEscListMapValue(value) + ";\n")
+		}
+	}
+	return b.String()
+}
+
+// category names are used as variable names
+func categoryVarName(name string) string {
+	name = strings.TrimSpace(name)
+	name = strings.Replace(name, " ", "_", -1)
+	return frontMatterKeyRegex.ReplaceAllString(strings.Replace(name, ".", "_", -1), "")
+}
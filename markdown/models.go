@@ -0,0 +1,153 @@
+package markdown
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/russross/blackfriday/v2"
+)
+
+// ModelPrefix is the prefix of the names of models created by ExtractModels.
+const ModelPrefix = "fragment-"
+
+// a top-level fragment of a translated document
+type fragment struct {
+	start, end  int  // offsets in the quiki source
+	extractable bool // true if the fragment can be moved to a model
+}
+
+// ExtractModels translates several Markdown documents to quiki source code,
+// moving fragments which appear identically in at least minRepeat places into
+// models. Each occurrence is replaced with a model call like
+// `$fragment-1a2b3c4d {}`.
+//
+// This is intended for bulk conversions, where boilerplate such as badges and
+// call-out boxes is often repeated on many pages. Fragments considered are
+// top-level HTML blocks, block quotes, and paragraphs containing only images,
+// links around images, and inline HTML. Fragments with headings or footnote
+// references are not extracted.
+//
+// It returns the quiki source of each document, keyed like docs, and the
+// source of each model, keyed by model name without the .model extension.
+func ExtractModels(docs map[string][]byte, minRepeat int, opts ...Option) (pages, models map[string][]byte) {
+	if minRepeat < 2 {
+		minRepeat = 2
+	}
+
+	// translate each document, noting where its fragments are
+	sources := make(map[string][]byte, len(docs))
+	fragments := make(map[string][]fragment, len(docs))
+	counts := make(map[string]int)
+	for name, input := range docs {
+		var buf bytes.Buffer
+		var frags []fragment
+		r := newRenderer(opts)
+		ast := blackfriday.New(blackfriday.WithRenderer(r), blackfriday.WithExtensions(extensions)).Parse(r.FrontMatter(input))
+
+		r.RenderHeader(&buf, ast)
+		ast.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+			if node.Parent != nil && node.Parent.Type == blackfriday.Document && entering {
+				if len(frags) != 0 {
+					frags[len(frags)-1].end = buf.Len()
+				}
+				frags = append(frags, fragment{start: buf.Len(), extractable: isExtractable(node)})
+			} else if node.Type == blackfriday.Document && !entering && len(frags) != 0 {
+				frags[len(frags)-1].end = buf.Len()
+			}
+			return r.RenderNode(&buf, node, entering)
+		})
+		r.RenderFooter(&buf, ast)
+
+		// trim whitespace around each fragment so identical ones match
+		src := buf.Bytes()
+		for i, frag := range frags {
+			for frag.start < frag.end && isspace(src[frag.start]) {
+				frag.start++
+			}
+			for frag.end > frag.start && isspace(src[frag.end-1]) {
+				frag.end--
+			}
+			frags[i] = frag
+			if frag.extractable && frag.start != frag.end {
+				counts[string(src[frag.start:frag.end])]++
+			}
+		}
+		sources[name] = src
+		fragments[name] = frags
+	}
+
+	// create a model for each fragment which repeats enough
+	models = make(map[string][]byte)
+	modelNames := make(map[string]string)
+	for frag, count := range counts {
+		if count < minRepeat {
+			continue
+		}
+		sum := sha256.Sum256([]byte(frag))
+		name := ModelPrefix + hex.EncodeToString(sum[:4])
+		modelNames[frag] = name
+		models[name] = []byte(frag + "\n")
+	}
+
+	// replace the fragments with model calls
+	pages = make(map[string][]byte, len(docs))
+	for name, src := range sources {
+		var out bytes.Buffer
+		last := 0
+		for _, frag := range fragments[name] {
+			model, ok := modelNames[string(src[frag.start:frag.end])]
+			if !ok || !frag.extractable {
+				continue
+			}
+			out.Write(src[last:frag.start])
+			out.WriteString("~$" + model + " {}")
+			last = frag.end
+		}
+		out.Write(src[last:])
+		pages[name] = out.Bytes()
+	}
+
+	return
+}
+
+// true if a top-level node can be extracted into a model
+func isExtractable(node *blackfriday.Node) bool {
+	switch node.Type {
+	case blackfriday.HTMLBlock, blackfriday.BlockQuote:
+	case blackfriday.Paragraph:
+		for child := node.FirstChild; child != nil; child = child.Next {
+			if !isBadge(child) {
+				return false
+			}
+		}
+	default:
+		return false
+	}
+
+	// no headings, which would open sections, or footnotes, which are
+	// numbered per document
+	ok := true
+	node.Walk(func(n *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if n.Type == blackfriday.Heading || (n.Type == blackfriday.Link && n.NoteID != 0) {
+			ok = false
+			return blackfriday.Terminate
+		}
+		return blackfriday.GoToNext
+	})
+	return ok
+}
+
+// true if an inline node is an image, a link around an image, inline HTML,
+// or whitespace, as is typical of badges
+func isBadge(node *blackfriday.Node) bool {
+	switch node.Type {
+	case blackfriday.Image, blackfriday.HTMLSpan, blackfriday.Softbreak, blackfriday.Hardbreak:
+		return true
+	case blackfriday.Link:
+		return isImageLink(node)
+	case blackfriday.Text:
+		return len(bytes.TrimSpace(node.Literal)) == 0
+	}
+	return false
+}
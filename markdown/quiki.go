@@ -5,18 +5,20 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"net/url"
 	"regexp"
 	"strings"
 
 	"github.com/russross/blackfriday/v2"
 )
 
-var punctuationRegex = regexp.MustCompile(`[^\w\- ]`)
+var punctuationRegex = regexp.MustCompile(`[^\p{L}\p{M}\p{Nd}\p{Pc}\- ]`)
 
 // Run parses Markdown and renders quiki soure code.
 func Run(input []byte) []byte {
-	r := NewQuikiRenderer(QuikiRendererParameters{Flags: TableOfContents})
-	return blackfriday.Run(input, blackfriday.WithRenderer(r), blackfriday.WithExtensions(blackfriday.NoEmptyLineBeforeBlock|blackfriday.CommonExtensions))
+	r := NewQuikiRenderer(QuikiRendererParameters{Flags: TableOfContents | FootnoteReturnLinks | FrontMatter})
+	input = r.FrontMatter(input)
+	return blackfriday.Run(input, blackfriday.WithRenderer(r), blackfriday.WithExtensions(extensions))
 }
 
 // QuikiFlags is renderer configuration options.
@@ -31,6 +33,8 @@ const (
 	PartialPage                                // If true, no @page vars at start
 	TableOfContents                            // If true, include TOC
 	FootnoteReturnLinks                        // Generate a link at the end of a footnote to return to the source
+	FrontMatter                                // If true, YAML or TOML front matter is translated to @page vars
+	SkipTitleHeading                           // If true, the heading used as the page title is not repeated as a section title
 )
 
 // QuikiRendererParameters allows you to tweak the behavior of a QuikiRenderer.
@@ -48,6 +52,9 @@ type QuikiRendererParameters struct {
 	//
 	FootnoteReturnLinkContents string
 
+	// Heading of the section containing footnotes. If blank, Notes is used.
+	FootnotesHeading string
+
 	// If set, add this text to the front of each Heading ID, to ensure
 	// uniqueness.
 	HeadingIDPrefix string
@@ -59,7 +66,7 @@ type QuikiRendererParameters struct {
 	// Resulting levels are clipped between 1 and 6.
 	HeadingLevelOffset int
 
-	// page title. defaults to the first heading in the document
+	// page title. defaults to the first level-1 heading in the document
 	Title string
 
 	// flags to customize the renderer's behavior
@@ -75,10 +82,12 @@ type QuikiRenderer struct {
 	// Track heading IDs to prevent ID collision in a single generation.
 	headingIDs map[string]int
 
-	heading     string // heading text stored until end of heading
+	titleFound  bool   // true after the heading used as the page title
 	headerLevel int    // section depth
 	indent      int    // indent level
 	linkDest    string // link destination stored until end of link text
+	imageLink   string // link destination of an image which is a link's only content
+	frontMatter string // variables from front matter
 
 	lastOutputLen int
 }
@@ -90,6 +99,9 @@ func NewQuikiRenderer(params QuikiRendererParameters) *QuikiRenderer {
 	if params.FootnoteReturnLinkContents == "" {
 		params.FootnoteReturnLinkContents = `<sup>[return]</sup>`
 	}
+	if params.FootnotesHeading == "" {
+		params.FootnotesHeading = "Notes"
+	}
 
 	return &QuikiRenderer{
 		QuikiRendererParameters: params,
@@ -126,6 +138,28 @@ func isRelativeLink(link []byte) (yes bool) {
 	return false
 }
 
+// plain text of a heading, including text within formatting and code spans
+func headingText(heading *blackfriday.Node) string {
+	var text strings.Builder
+	heading.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if entering && (node.Type == blackfriday.Text || node.Type == blackfriday.Code) {
+			text.Write(node.Literal)
+		}
+		return blackfriday.GoToNext
+	})
+	return text.String()
+}
+
+// GitHub-compatible heading anchor: downcase, remove punctuation, and replace
+// spaces with dashes. letters and numbers in any script are kept.
+// https://github.com/jch/html-pipeline/blob/master/lib/html/pipeline/toc_filter.rb
+func headingSlug(text string) string {
+	text = strings.ToLower(text)
+	text = punctuationRegex.ReplaceAllString(text, "")
+	return strings.Replace(text, " ", "-", -1)
+}
+
+// adds -1, -2, etc. to a heading ID which has already been used
 func (r *QuikiRenderer) ensureUniqueHeadingID(id string) string {
 	for count, found := r.headingIDs[id]; found; count, found = r.headingIDs[id] {
 		tmp := fmt.Sprintf("%s-%d", id, count+1)
@@ -165,16 +199,6 @@ func codeLanguage(info []byte) string {
 	return string(info[:endOfLang])
 }
 
-func (r *QuikiRenderer) tag(w io.Writer, name []byte, attrs []string) {
-	w.Write(name)
-	if len(attrs) > 0 {
-		w.Write(spaceBytes)
-		w.Write([]byte(strings.Join(attrs, " ")))
-	}
-	w.Write(gtBytes)
-	r.lastOutputLen = 1
-}
-
 func footnoteRef(prefix string, node *blackfriday.Node) []byte {
 	urlFrag := prefix + string(slugify(node.Destination))
 	anchor := fmt.Sprintf(`<a href="#fn:%s">%d</a>`, urlFrag, node.NoteID)
@@ -182,7 +206,7 @@ func footnoteRef(prefix string, node *blackfriday.Node) []byte {
 }
 
 func footnoteItem(prefix string, slug []byte) []byte {
-	return []byte(fmt.Sprintf(`<li id="fn:%s%s">`, prefix, slug))
+	return []byte(fmt.Sprintf(`<span id="fn:%s%s"></span>`, prefix, slug))
 }
 
 func footnoteReturnLink(prefix, returnLink string, slug []byte) []byte {
@@ -199,6 +223,16 @@ func skipParagraphTags(node *blackfriday.Node) bool {
 	return grandparent.Type == blackfriday.List && tightOrTerm
 }
 
+// true if the node is within a definition list term
+func isDefinitionTerm(node *blackfriday.Node) bool {
+	for n := node.Parent; n != nil; n = n.Parent {
+		if n.Type == blackfriday.Item {
+			return n.ListFlags&blackfriday.ListTypeTerm != 0
+		}
+	}
+	return false
+}
+
 func cellAlignment(align blackfriday.CellAlignFlags) string {
 	switch align {
 	case blackfriday.TableAlignmentLeft:
@@ -212,6 +246,38 @@ func cellAlignment(align blackfriday.CellAlignFlags) string {
 	}
 }
 
+// if the node is the text at the start of a list item beginning with
+// [ ] or [x], returns a checkbox and the remaining text
+func taskListMarker(node *blackfriday.Node) ([]byte, string, bool) {
+	if node.Prev != nil {
+		return nil, "", false
+	}
+
+	// the text must be the first thing in the item, possibly in a paragraph
+	item := node.Parent
+	if item.Type == blackfriday.Paragraph {
+		if item.Prev != nil {
+			return nil, "", false
+		}
+		item = item.Parent
+	}
+	if item == nil || item.Type != blackfriday.Item || item.ListData.RefLink != nil {
+		return nil, "", false
+	}
+
+	text := string(node.Literal)
+	if len(text) < 4 || text[0] != '[' || text[2] != ']' || text[3] != ' ' {
+		return nil, "", false
+	}
+	switch text[1] {
+	case ' ':
+		return taskUncheckedTag, text[4:], true
+	case 'x', 'X':
+		return taskCheckedTag, text[4:], true
+	}
+	return nil, "", false
+}
+
 func (r *QuikiRenderer) out(w io.Writer, text []byte) {
 	w.Write(text)
 	r.lastOutputLen = len(text)
@@ -229,6 +295,25 @@ func (r *QuikiRenderer) addText(w io.Writer, text string) {
 	r.out(w, []byte(text))
 }
 
+// adds inline HTML, escaped as needed for the node's context. brackets are
+// replaced with entities, as they cannot be escaped within [html:]
+func (r *QuikiRenderer) addHTML(w io.Writer, node *blackfriday.Node, html []byte) {
+	s := quikiEsc(strings.NewReplacer("[", "&#91;", "]", "&#93;").Replace(string(html)))
+	for n := node; n != nil; n = n.Parent {
+		if n.Type == blackfriday.Item {
+			s = strings.Replace(s, ";", "\\;", -1)
+			break
+		}
+	}
+
+	// the colon in [html:] would end a definition term
+	if isDefinitionTerm(node) {
+		r.addText(w, strings.Replace("[html:"+s+"]", ":", "\\:", -1))
+		return
+	}
+	r.addText(w, "[html:"+s+"]")
+}
+
 func (r *QuikiRenderer) cr(w io.Writer) {
 	if r.lastOutputLen > 0 {
 		r.out(w, nlBytes)
@@ -236,28 +321,13 @@ func (r *QuikiRenderer) cr(w io.Writer) {
 }
 
 var (
-	nlBytes    = []byte{'\n'}
-	gtBytes    = []byte{'>'}
-	spaceBytes = []byte{' '}
+	nlBytes = []byte{'\n'}
 )
 
 var (
-	hrTag         = []byte("<hr />")
-	tableTag      = []byte(`<table class="q-table">`)
-	tableCloseTag = []byte("</table>")
-	tdTag         = []byte("<td")
-	tdCloseTag    = []byte("</td>")
-	thTag         = []byte("<th")
-	thCloseTag    = []byte("</th>")
-	theadTag      = []byte("<thead>")
-	theadCloseTag = []byte("</thead>")
-	tbodyTag      = []byte("<tbody>")
-	tbodyCloseTag = []byte("</tbody>")
-	trTag         = []byte("<tr>")
-	trCloseTag    = []byte("</tr>")
-
-	footnotesDivBytes      = []byte("\n<div class=\"footnotes\">\n\n")
-	footnotesCloseDivBytes = []byte("\n</div>\n")
+	hrTag            = []byte("<hr />")
+	taskUncheckedTag = []byte(`<input type="checkbox" class="task-list-item-checkbox" disabled /> `)
+	taskCheckedTag   = []byte(`<input type="checkbox" class="task-list-item-checkbox" disabled checked /> `)
 )
 
 // RenderNode is a default renderer of a single node of a syntax tree. For
@@ -271,20 +341,25 @@ var (
 // The typical behavior is to return GoToNext, which asks for the usual
 // traversal to the next node.
 func (r *QuikiRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
-	attrs := []string{}
 	switch node.Type {
 
 	case blackfriday.Text:
 		s := string(node.Literal)
+
+		// task list item checkbox
+		if marker, rest, ok := taskListMarker(node); ok {
+			r.addHTML(w, node, marker)
+			s = rest
+		}
+
 		if node.Parent.Type == blackfriday.Link {
 			r.addText(w, quikiEscLink(s))
+		} else if isDefinitionTerm(node) {
+			r.addText(w, quikiEscMapKey(s))
 		} else if node.Parent.Type == blackfriday.Paragraph && node.Parent.Parent.Type == blackfriday.Item {
 			r.addText(w, quikiEscListMapValue(s))
 		} else if node.Parent.Type == blackfriday.Item {
 			r.addText(w, quikiEscListMapValue(s))
-		} else if node.Parent.Type == blackfriday.Heading {
-			r.heading += s
-			r.addText(w, quikiEscFmt(s))
 		} else {
 			r.addText(w, quikiEscFmt(s))
 		}
@@ -328,11 +403,23 @@ func (r *QuikiRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering
 		if r.Flags&SkipHTML != 0 {
 			break
 		}
-		html := quikiEscFmt(string(node.Literal))
-		r.addText(w, "[html:"+html+"]")
+		html := "[html:" + quikiEscFmt(string(node.Literal)) + "]"
+		if isDefinitionTerm(node) {
+			html = strings.Replace(html, ":", "\\:", -1)
+		}
+		r.addText(w, html)
 
 	// link
 	case blackfriday.Link:
+
+		// footnote reference
+		if node.NoteID != 0 {
+			if entering {
+				r.addHTML(w, node, footnoteRef(r.FootnoteAnchorPrefix, node))
+			}
+			break
+		}
+
 		// mark it but don't link it if it is not a safe link
 		dest := node.LinkData.Destination
 		if r.Flags&SkipLinks != 0 {
@@ -341,15 +428,16 @@ func (r *QuikiRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering
 			} else {
 				r.addText(w, "[/c]")
 			}
+		} else if isImageLink(node) {
+			// the image{} is linked instead
+			if entering {
+				r.imageLink = r.linkTarget(dest)
+			} else {
+				r.imageLink = ""
+			}
 		} else {
 			if entering {
-				link := string(r.addAbsPrefix(dest))
-				link = quikiEscLink(link)
-				if hashIdx := strings.IndexByte(link, '#'); hashIdx != -1 {
-					r.linkDest = strings.TrimSuffix(link[:hashIdx], ".md") + link[hashIdx:]
-				} else {
-					r.linkDest = strings.TrimSuffix(link, ".md")
-				}
+				r.linkDest = quikiEscLink(r.linkTarget(dest))
 				r.addText(w, "[[ ")
 
 				// TODO: anything we can do with node.LinkData.Title?
@@ -376,14 +464,31 @@ func (r *QuikiRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering
 			// FIXME: if dest is not relative, we can't display this image
 			r.addText(w, "~image {\n    file: "+quikiEsc(string(dest))+";\n    alt: ")
 		} else {
-			// FIXME: can we do anything with node.LinkData.Title?
-			r.out(w, []byte(";\n}"))
+			desc, attrs := imageAttributes(string(node.LinkData.Title))
+			if attrs.link == "" {
+				attrs.link = r.imageLink
+			}
+			out := ";\n"
+			for _, kv := range [][2]string{
+				{"desc", desc},
+				{"width", attrs.width},
+				{"height", attrs.height},
+				{"align", attrs.align},
+				{"link", attrs.link},
+			} {
+				if kv[1] != "" {
+					out += "    " + kv[0] + ": " + quikiEscListMapValue(kv[1]) + ";\n"
+				}
+			}
+			r.out(w, []byte(out+"}"))
 		}
 
 	// inline code
 	case blackfriday.Code:
 		var code string
-		if node.Parent != nil && node.Parent.Parent != nil &&
+		if isDefinitionTerm(node) {
+			code = quikiEscMapKey(string(node.Literal))
+		} else if node.Parent != nil && node.Parent.Parent != nil &&
 			node.Parent.Type == blackfriday.Paragraph && node.Parent.Parent.Type == blackfriday.Item {
 			code = quikiEscListMapValue(string(node.Literal))
 		} else {
@@ -477,11 +582,26 @@ func (r *QuikiRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering
 
 			// set level, start the section with name opening tag.
 			r.headerLevel = level
-			r.addText(w, "~sec [")
 
-			// if node.IsTitleblock {
-			// 	attrs = append(attrs, `class="title"`)
-			// }
+			// the first # heading is the page title, unless another title
+			// was provided. if it is the same as the provided title, it is
+			// still considered the title heading
+			if node.Level == 1 && !r.titleFound {
+				text := headingText(node)
+				if r.Title == "" {
+					r.Title = text
+				}
+				r.titleFound = r.Title == text
+
+				// the section is untitled, so the page title is used
+				// in its place if it is the first one
+				if r.titleFound && r.Flags&SkipTitleHeading != 0 {
+					r.indent++
+					r.addText(w, "~sec {\n")
+					return blackfriday.SkipChildren
+				}
+			}
+			r.addText(w, "~sec [")
 
 		} else {
 			// r.out(w, closeTag)
@@ -494,20 +614,10 @@ func (r *QuikiRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering
 			//     $add_text->("$current_text] {\n");
 			r.addText(w, "]")
 
-			// assume page title as first heading
-			if r.Title == "" {
-				r.Title = r.heading
-			}
-
 			// figure the anchor for github compatibility
 			id := node.HeadingID
-			if node.HeadingID == "" {
-				// https://github.com/jch/html-pipeline/blob/master/lib/html/pipeline/toc_filter.rb
-				// $section_id =~ tr/A-Z/a-z/;                 # ASCII downcase
-				id = strings.ToLower(r.heading)                // downcase
-				id = punctuationRegex.ReplaceAllString(id, "") // remove punctuation
-				id = strings.Replace(id, " ", "-", -1)         // replace spaces with dashes
-				r.heading = ""
+			if id == "" {
+				id = headingSlug(headingText(node))
 			}
 
 			// heading ID
@@ -532,9 +642,8 @@ func (r *QuikiRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering
 
 		if entering {
 			if node.IsFootnotesList {
-				r.out(w, footnotesDivBytes)
-				r.out(w, hrTag)
 				r.cr(w)
+				r.addText(w, "~sec ["+quikiEscFmt(r.FootnotesHeading)+"] {")
 			}
 			r.cr(w)
 			if node.Parent.Type == blackfriday.Item && node.Parent.Parent.Tight {
@@ -544,7 +653,7 @@ func (r *QuikiRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering
 			if node.ListFlags&blackfriday.ListTypeOrdered != 0 {
 				r.addText(w, "numlist {")
 			} else if node.ListFlags&blackfriday.ListTypeDefinition != 0 {
-				r.addText(w, "definitions {")
+				r.addText(w, "deflist {")
 			} else {
 				r.addText(w, "list {")
 			}
@@ -560,19 +669,45 @@ func (r *QuikiRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering
 			// 	r.cr(w)
 			// }
 			if node.IsFootnotesList {
-				r.out(w, footnotesCloseDivBytes)
+				r.addText(w, "\n}")
+				r.cr(w)
 			}
 		}
 	case blackfriday.Item:
+
+		// definition list term or definition
+		if node.ListFlags&blackfriday.ListTypeDefinition != 0 {
+			term := node.ListFlags&blackfriday.ListTypeTerm != 0
+			afterTerm := node.Prev != nil && node.Prev.ListFlags&blackfriday.ListTypeTerm != 0
+			if entering && term {
+				r.cr(w)
+			} else if entering && afterTerm {
+				r.addText(w, " ")
+			} else if entering {
+				r.cr(w)
+				r.addText(w, ": ")
+			} else if term {
+				r.addText(w, ":")
+			} else {
+				r.addText(w, ";")
+			}
+			break
+		}
+
 		if entering {
 			r.cr(w)
+
+			// footnote anchor
+			if node.ListData.RefLink != nil {
+				r.addHTML(w, node, footnoteItem(r.FootnoteAnchorPrefix, slugify(node.ListData.RefLink)))
+			}
 		} else {
-			// if node.ListData.RefLink != nil {
-			// 	slug := slugify(node.ListData.RefLink)
-			// 	if r.Flags&FootnoteReturnLinks != 0 {
-			// 		r.out(w, footnoteReturnLink(r.FootnoteAnchorPrefix, r.FootnoteReturnLinkContents, slug))
-			// 	}
-			// }
+
+			// footnote return link
+			if node.ListData.RefLink != nil && r.Flags&FootnoteReturnLinks != 0 {
+				slug := slugify(node.ListData.RefLink)
+				r.addHTML(w, node, footnoteReturnLink(r.FootnoteAnchorPrefix, r.FootnoteReturnLinkContents, slug))
+			}
 			r.addText(w, ";")
 		}
 
@@ -597,72 +732,40 @@ func (r *QuikiRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering
 		}
 
 	// table
-	// just wrap in html
 	case blackfriday.Table:
 		if entering {
 			r.cr(w)
-			r.addText(w, "~html {")
-			r.out(w, tableTag)
+			r.addText(w, "~table {")
 		} else {
-			r.out(w, tableCloseTag)
-			r.addText(w, "}")
+			r.addText(w, "\n}")
 			r.cr(w)
 		}
 
 	// table cell
 	case blackfriday.TableCell:
-		openTag := tdTag
-		closeTag := tdCloseTag
-		if node.IsHeader {
-			openTag = thTag
-			closeTag = thCloseTag
-		}
 		if entering {
-			align := cellAlignment(node.Align)
-			if align != "" {
-				attrs = append(attrs, fmt.Sprintf(`align="%s"`, align))
+			typ := "tc"
+			if node.IsHeader {
+				typ = "th"
 			}
-			if node.Prev == nil {
-				r.cr(w)
+			if align := cellAlignment(node.Align); align != "" {
+				typ += " [align=" + align + "]"
 			}
-			r.tag(w, openTag, attrs)
+			r.addText(w, "\n        ~"+typ+" { ")
 		} else {
-			r.out(w, closeTag)
-			r.cr(w)
+			r.addText(w, " }")
 		}
 
-	// table head
-	case blackfriday.TableHead:
-		if entering {
-			r.cr(w)
-			r.out(w, theadTag)
-		} else {
-			r.out(w, theadCloseTag)
-			r.cr(w)
-		}
-
-	// table body
-	case blackfriday.TableBody:
-		if entering {
-			r.cr(w)
-			r.out(w, tbodyTag)
-			// XXX: this is to adhere to a rather silly test. Should fix test.
-			if node.FirstChild == nil {
-				r.cr(w)
-			}
-		} else {
-			r.out(w, tbodyCloseTag)
-			r.cr(w)
-		}
+	// table head and body
+	// rows are emitted directly within the table
+	case blackfriday.TableHead, blackfriday.TableBody:
 
 	// table row
 	case blackfriday.TableRow:
 		if entering {
-			r.cr(w)
-			r.out(w, trTag)
+			r.addText(w, "\n    ~tr {")
 		} else {
-			r.out(w, trCloseTag)
-			r.cr(w)
+			r.addText(w, "\n    }")
 		}
 
 	// unknown
@@ -673,6 +776,28 @@ func (r *QuikiRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering
 	return blackfriday.GoToNext
 }
 
+// FrontMatter extracts YAML (---) or TOML (+++) front matter from the start of
+// Markdown input, if the FrontMatter flag is set, and returns the remaining
+// input to be rendered.
+//
+// Known keys such as title, author, date, description, and keywords are
+// translated to the corresponding @page vars; categories and tags to
+// @category vars; and any others to variables of the same name. They are
+// emitted by RenderHeader, so this must be called before rendering.
+func (r *QuikiRenderer) FrontMatter(input []byte) []byte {
+	if r.Flags&FrontMatter == 0 {
+		return input
+	}
+	vars, rest := splitFrontMatter(input)
+	for _, v := range vars {
+		if title, ok := v.value.(string); ok && strings.EqualFold(v.key, "title") {
+			r.Title = title
+		}
+	}
+	r.frontMatter = frontMatterSource(vars)
+	return rest
+}
+
 // RenderFooter renders the page footer.
 func (r *QuikiRenderer) RenderFooter(w io.Writer, ast *blackfriday.Node) {
 	// title must be done after the heading is extracted
@@ -688,7 +813,9 @@ func (r *QuikiRenderer) RenderHeader(w io.Writer, ast *blackfriday.Node) {
 	}
 	io.WriteString(w, "@page.author:    Markdown;\n")
 	io.WriteString(w, "@page.generator: quiki/markdown;\n")
-	io.WriteString(w, "@page.generated;\n\n")
+	io.WriteString(w, "@page.generated;\n")
+	io.WriteString(w, r.frontMatter)
+	io.WriteString(w, "\n")
 	if r.Flags&TableOfContents != 0 {
 		io.WriteString(w, "toc{}\n\n")
 	}
@@ -717,6 +844,70 @@ func quikiEscFmt(s string) string {
 	return s
 }
 
+// converts a Markdown link destination to a quiki link target
+func (r *QuikiRenderer) linkTarget(dest []byte) string {
+	link := string(r.addAbsPrefix(dest))
+	anchor := ""
+	if hashIdx := strings.IndexByte(link, '#'); hashIdx != -1 {
+		// anchors may be percent-encoded, but heading IDs are not
+		link, anchor = link[:hashIdx], link[hashIdx:]
+		if unescaped, err := url.PathUnescape(anchor); err == nil {
+			anchor = unescaped
+		}
+	}
+	return strings.TrimSuffix(link, ".md") + anchor
+}
+
+// true if the node is a link containing only an image
+func isImageLink(node *blackfriday.Node) bool {
+	var image *blackfriday.Node
+	for child := node.FirstChild; child != nil; child = child.Next {
+		if child.Type == blackfriday.Text && len(child.Literal) == 0 {
+			continue
+		}
+		if image != nil || child.Type != blackfriday.Image {
+			return false
+		}
+		image = child
+	}
+	return image != nil
+}
+
+// image{} options from the title of a Markdown image
+type imageAttrs struct {
+	width, height, align, link string
+}
+
+var (
+	imageAttrsRegex = regexp.MustCompile(`(?:^|\s)=`)
+	imageSizeRegex  = regexp.MustCompile(`^(\d*)(?:x(\d*))?$`)
+)
+
+// separates image{} options from the title of a Markdown image. options
+// follow the title, beginning with "=", such as "Earth =300x200 right".
+// they are a size (WIDTHxHEIGHT, either of which may be omitted), left or
+// right, and link=TARGET. if anything else is found, the whole title is
+// returned as the description
+func imageAttributes(title string) (string, imageAttrs) {
+	var attrs imageAttrs
+	loc := imageAttrsRegex.FindStringIndex(title)
+	if loc == nil {
+		return title, attrs
+	}
+	for _, word := range strings.Fields(title[loc[1]:]) {
+		if m := imageSizeRegex.FindStringSubmatch(word); m != nil && word != "" {
+			attrs.width, attrs.height = m[1], m[2]
+		} else if word == "left" || word == "right" {
+			attrs.align = word
+		} else if strings.HasPrefix(word, "link=") && len(word) > 5 {
+			attrs.link = strings.TrimPrefix(word, "link=")
+		} else {
+			return title, imageAttrs{}
+		}
+	}
+	return strings.TrimSpace(title[:loc[0]]), attrs
+}
+
 // like quikiEscFmt except also escapes pipe for [[ links ]]
 func quikiEscLink(s string) string {
 	s = quikiEscFmt(s)
@@ -6,6 +6,8 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/cooper/quiki/wiki"
 	"github.com/fsnotify/fsnotify"
@@ -48,6 +50,8 @@ func WatchWiki(w *wiki.Wiki) {
 		w.Opt.Dir.Page:  handlePageEvent,
 		w.Opt.Dir.Image: handleImageEvent,
 		w.Opt.Dir.Model: handleModelEvent,
+
+		filepath.Join(w.Opt.Dir.Wiki, "data"): handleDataEvent,
 	}
 
 	// watch each of the content dirs
@@ -123,6 +127,18 @@ func WatchWiki(w *wiki.Wiki) {
 
 func handlePageEvent(mon wikiMonitor, event fsnotify.Event, abs string) {
 
+	// only page source files, such as .page and .md
+	isPage := false
+	for _, ext := range mon.w.Opt.PageExtensions() {
+		if strings.HasSuffix(abs, ext) {
+			isPage = true
+			break
+		}
+	}
+	if !isPage {
+		return
+	}
+
 	// trim the page dir to get the actual name with prefix
 	osName := abs
 	dirPage, _ := filepath.Abs(mon.w.Opt.Dir.Page)
@@ -144,13 +160,70 @@ func handlePageEvent(mon wikiMonitor, event fsnotify.Event, abs string) {
 		// page name will be normalized including os-specific path separator
 		mon.w.DisplayPageDraft(osName, true)
 
+		// links to a new page are no longer missing. pages regenerated
+		// since the file was written, such as by WritePage, are current
+		if fi, err := os.Stat(abs); err == nil && event.Op == fsnotify.Create {
+			regenerateLinkingPages(mon, osName, fi.ModTime())
+		}
+
 	case fsnotify.Rename, fsnotify.Remove:
 		// TODO: w.PurgePage() or similar
 		os.Remove(filepath.Join(mon.w.Opt.Dir.Cache, "page", osName+".cache"))
 		os.Remove(filepath.Join(mon.w.Opt.Dir.Cache, "page", osName+".txt"))
+		mon.w.InvalidatePage(filepath.ToSlash(osName))
+
+		// links to the page are now missing
+		regenerateLinkingPages(mon, osName, time.Now())
+	}
+}
+
+func regenerateLinkingPages(mon wikiMonitor, osName string, since time.Time) {
+	name := filepath.ToSlash(osName)
+	if n := mon.w.RegenerateLinkingPages(name, since); n != 0 {
+		mon.w.Logf("page %s created or deleted; regenerated %d linking pages", name, n)
 	}
 }
 
 func handleImageEvent(mon wikiMonitor, event fsnotify.Event, abs string) {}
 
-func handleModelEvent(mon wikiMonitor, event fsnotify.Event, abs string) {}
+func handleModelEvent(mon wikiMonitor, event fsnotify.Event, abs string) {
+
+	// trim the model dir to get the actual name with prefix
+	osName := abs
+	dirModel, _ := filepath.Abs(mon.w.Opt.Dir.Model)
+	if relPath, err := filepath.Rel(dirModel, abs); err == nil {
+		osName = relPath
+	}
+
+	switch event.Op {
+
+	// regenerate only the pages which use the model
+	case fsnotify.Create, fsnotify.Write:
+		name := filepath.ToSlash(osName)
+		n := mon.w.RegenerateDependents(wiki.Dependency{Type: wiki.CategoryTypeModel, Name: name})
+		if n != 0 {
+			mon.w.Logf("model %s changed; regenerated %d dependent pages", name, n)
+		}
+	}
+}
+
+func handleDataEvent(mon wikiMonitor, event fsnotify.Event, abs string) {
+
+	// trim the data dir to get the actual name with prefix
+	osName := abs
+	dirData, _ := filepath.Abs(filepath.Join(mon.w.Opt.Dir.Wiki, "data"))
+	if relPath, err := filepath.Rel(dirData, abs); err == nil {
+		osName = relPath
+	}
+
+	switch event.Op {
+
+	// regenerate only the pages which load the file
+	case fsnotify.Create, fsnotify.Write:
+		name := filepath.ToSlash(osName)
+		n := mon.w.RegenerateDependents(wiki.Dependency{Type: wiki.CategoryTypeData, Name: name})
+		if n != 0 {
+			mon.w.Logf("data %s changed; regenerated %d dependent pages", name, n)
+		}
+	}
+}
@@ -1,12 +1,21 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
 
 	"github.com/cooper/quiki/adminifier"
 	"github.com/cooper/quiki/webserver"
+	"github.com/cooper/quiki/wiki"
 )
 
 func main() {
@@ -15,6 +24,25 @@ func main() {
 		log.Fatal("usage: " + os.Args[0] + " " + filepath.Join("path", "to", "quiki.conf"))
 	}
 
+	// backup, restore, export, import, and imgsz commands
+	switch os.Args[1] {
+	case "backup":
+		backup(os.Args[2:])
+		return
+	case "restore":
+		restore(os.Args[2:])
+		return
+	case "export":
+		export(os.Args[2:])
+		return
+	case "import":
+		importArchive(os.Args[2:])
+		return
+	case "imgsz":
+		imgsz(os.Args[2:])
+		return
+	}
+
 	// configure webserver using conf file
 	webserver.Configure(os.Args[1])
 
@@ -25,3 +53,166 @@ func main() {
 	// listen indefinitely
 	webserver.Listen()
 }
+
+// quiki backup path/to/wiki [backup.tar.gz]
+//
+// if no file is given, the backup is written to [wiki]-[date].tar.gz in the
+// current directory. if the file is -, it is written to stdout.
+func backup(args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		log.Fatal("usage: " + os.Args[0] + " backup " + filepath.Join("path", "to", "wiki") + " [backup.tar.gz]")
+	}
+	w, err := wiki.NewWiki(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var out io.Writer = os.Stdout
+	if len(args) == 1 || args[1] != "-" {
+		fileName := filepath.Base(w.Dir()) + "-" + time.Now().Format("20060102-150405") + ".tar.gz"
+		if len(args) == 2 {
+			fileName = args[1]
+		}
+		f, err := os.Create(fileName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		out = f
+		log.Println("writing backup to " + fileName)
+	}
+
+	if err := w.Backup(out); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// quiki restore backup.tar.gz path/to/wiki
+//
+// if the file is -, the backup is read from stdin.
+func restore(args []string) {
+	if len(args) != 2 {
+		log.Fatal("usage: " + os.Args[0] + " restore backup.tar.gz " + filepath.Join("path", "to", "wiki"))
+	}
+
+	var in io.Reader = os.Stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	if err := wiki.Restore(in, args[1]); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("restored backup to " + args[1])
+}
+
+// quiki export [-history] [-accounts] path/to/wiki [archive.tar.gz]
+//
+// if no file is given, the archive is written to [wiki]-[date].quiki.tar.gz
+// in the current directory. if the file is -, it is written to stdout.
+func export(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	history := flags.Bool("history", false, "include revision history")
+	accounts := flags.Bool("accounts", false, "include user accounts")
+	flags.Parse(args)
+	args = flags.Args()
+	if len(args) < 1 || len(args) > 2 {
+		log.Fatal("usage: " + os.Args[0] + " export [-history] [-accounts] " + filepath.Join("path", "to", "wiki") + " [archive.tar.gz]")
+	}
+	w, err := wiki.NewWiki(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var out io.Writer = os.Stdout
+	if len(args) == 1 || args[1] != "-" {
+		fileName := filepath.Base(w.Dir()) + "-" + time.Now().Format("20060102-150405") + ".quiki.tar.gz"
+		if len(args) == 2 {
+			fileName = args[1]
+		}
+		f, err := os.Create(fileName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		out = f
+		log.Println("writing archive to " + fileName)
+	}
+
+	if err := w.Export(out, wiki.ExportOpts{History: *history, Accounts: *accounts}); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// quiki import [-users users.json] archive.tar.gz path/to/wiki
+//
+// if the file is -, the archive is read from stdin. the users file maps
+// usernames or email addresses in the archive to new users, like
+// {"alice": {"username": "alice2", "name": "Alice", "email": "alice@example.com"}}
+func importArchive(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	usersFile := flags.String("users", "", "JSON file mapping users in the archive to new users")
+	flags.Parse(args)
+	args = flags.Args()
+	if len(args) != 2 {
+		log.Fatal("usage: " + os.Args[0] + " import [-users users.json] archive.tar.gz " + filepath.Join("path", "to", "wiki"))
+	}
+
+	var opts wiki.ImportOpts
+	if *usersFile != "" {
+		jsonData, err := ioutil.ReadFile(*usersFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := json.Unmarshal(jsonData, &opts.Users); err != nil {
+			log.Fatal(*usersFile + ": " + err.Error())
+		}
+	}
+
+	var in io.Reader = os.Stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	manifest, err := wiki.Import(in, args[1], opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("imported %d files from %s to %s", len(manifest.Files), manifest.Name, args[1])
+}
+
+// quiki imgsz path/to/wiki 300x200 image.png
+//
+// prints the signed URL of an image resized on demand. either dimension may
+// be 0 to preserve the aspect ratio.
+func imgsz(args []string) {
+	usage := "usage: " + os.Args[0] + " imgsz " + filepath.Join("path", "to", "wiki") + " 300x200 image.png"
+	if len(args) != 3 {
+		log.Fatal(usage)
+	}
+	matches := regexp.MustCompile(`^(\d+)x(\d+)$`).FindStringSubmatch(args[1])
+	if matches == nil {
+		log.Fatal(usage)
+	}
+	width, _ := strconv.Atoi(matches[1])
+	height, _ := strconv.Atoi(matches[2])
+
+	w, err := wiki.NewWiki(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if w.Opt.Image.ResizeSecret == "" {
+		log.Fatal("@image.resize.secret is not configured")
+	}
+	fmt.Println(w.ResizeURL(width, height, args[2]))
+}
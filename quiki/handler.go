@@ -0,0 +1,91 @@
+package quiki
+
+import (
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/cooper/quiki/wiki"
+)
+
+// Handler returns an http.Handler which serves the wiki's pages and images
+// at the HTTP roots specified in the wiki configuration.
+//
+// Pages are served as minimal standalone HTML documents. The wiki main page
+// is served at the wiki root, if configured. Anything else results in a
+// 404 response.
+func (w *Wiki) Handler() http.Handler {
+	return http.HandlerFunc(w.serveHTTP)
+}
+
+func (w *Wiki) serveHTTP(rw http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	root := w.Opt.Root
+
+	// not within the wiki root
+	if root.Wiki != "" && path != root.Wiki && !strings.HasPrefix(path, root.Wiki+"/") {
+		http.NotFound(rw, r)
+		return
+	}
+
+	// main page
+	if path == root.Wiki || path == root.Wiki+"/" {
+		if w.Opt.MainPage == "" {
+			http.NotFound(rw, r)
+			return
+		}
+		w.serveResult(rw, r, w.DisplayPage(w.Opt.MainPage))
+		return
+	}
+
+	// image
+	if root.Image != "" && strings.HasPrefix(path, root.Image+"/") {
+		w.serveResult(rw, r, w.DisplayImage(strings.TrimPrefix(path, root.Image+"/")))
+		return
+	}
+
+	// page
+	pageRoot := root.Page
+	if pageRoot == "" {
+		pageRoot = root.Wiki
+	}
+	if strings.HasPrefix(path, pageRoot+"/") {
+		w.serveResult(rw, r, w.DisplayPage(strings.TrimPrefix(path, pageRoot+"/")))
+		return
+	}
+
+	http.NotFound(rw, r)
+}
+
+func (w *Wiki) serveResult(rw http.ResponseWriter, r *http.Request, res interface{}) {
+	switch res := res.(type) {
+
+	case wiki.DisplayPage:
+		title := res.Title
+		if title == "" {
+			title = w.Opt.Name
+		}
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		rw.Write([]byte("<!DOCTYPE html>\n<html>\n<head>\n" +
+			"<meta charset=\"utf-8\" />\n" +
+			"<title>" + html.EscapeString(title) + "</title>\n" +
+			"<style>\n" + res.CSS + "</style>\n" +
+			"</head>\n<body>\n" + string(res.Content) + "</body>\n</html>\n"))
+
+	case wiki.DisplayImage:
+		http.ServeFile(rw, r, res.Path)
+
+	case wiki.DisplayRedirect:
+		http.Redirect(rw, r, res.Redirect, http.StatusMovedPermanently)
+
+	case wiki.DisplayError:
+		status := res.Status
+		if status == 0 {
+			status = http.StatusNotFound
+		}
+		http.Error(rw, res.Error, status)
+
+	default:
+		http.NotFound(rw, r)
+	}
+}
@@ -0,0 +1,119 @@
+// Package quiki provides a small, stable API for embedding quiki in other
+// Go applications.
+//
+// It wraps the wiki and wikifier packages so that a program can render and
+// serve a wiki without configuring the quiki webserver:
+//
+//	w, err := quiki.Open("/path/to/mywiki")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	http.Handle("/", w.Handler())
+//
+// For finer control, use the wiki and wikifier packages directly.
+package quiki
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cooper/quiki/wiki"
+	"github.com/cooper/quiki/wikifier"
+)
+
+// Wiki is a wiki opened for embedding.
+type Wiki struct {
+	*wiki.Wiki
+}
+
+// Page is the result of rendering a page.
+type Page struct {
+	Name        string             // page name without extension
+	Title       string             // page title, without formatting
+	Description string             // page description
+	Author      string             // page author
+	Categories  []string           // categories the page belongs to
+	Modified    *time.Time         // last modification time, if known
+	HTML        string             // generated HTML content
+	CSS         string             // CSS generated from style{} blocks
+	Warnings    []wikifier.Warning // parser warnings
+}
+
+// Open opens the wiki located at the given directory.
+// The directory must contain a wiki.conf file.
+func Open(wikiDir string) (*Wiki, error) {
+	w, err := wiki.NewWiki(wikiDir)
+	if err != nil {
+		return nil, err
+	}
+	return &Wiki{w}, nil
+}
+
+// RenderPage renders the page by the given name, using the page cache when
+// it is enabled and up-to-date.
+//
+// If the page is a redirect, the error is a *RedirectError.
+func (w *Wiki) RenderPage(name string) (*Page, error) {
+	switch res := w.DisplayPage(name).(type) {
+
+	case wiki.DisplayPage:
+		return &Page{
+			Name:        res.Name,
+			Title:       res.Title,
+			Description: res.Description,
+			Author:      res.Author,
+			Categories:  res.Categories,
+			Modified:    res.Modified,
+			HTML:        string(res.Content),
+			CSS:         res.CSS,
+			Warnings:    res.Warnings,
+		}, nil
+
+	case wiki.DisplayRedirect:
+		return nil, &RedirectError{res.Redirect}
+
+	case wiki.DisplayError:
+		return nil, errors.New(res.Error)
+	}
+
+	return nil, errors.New("unknown result")
+}
+
+// RenderString renders quiki source code in the context of this wiki,
+// so that links, images, and models refer to the wiki's content.
+func (w *Wiki) RenderString(source string) (*Page, error) {
+	page := wikifier.NewPageSource(source)
+	page.Wiki = w.Wiki
+	page.Opt = &w.Opt
+	return renderPage(page)
+}
+
+// RenderString renders quiki source code with default options and
+// without any associated wiki.
+func RenderString(source string) (*Page, error) {
+	return renderPage(wikifier.NewPageSource(source))
+}
+
+func renderPage(page *wikifier.Page) (*Page, error) {
+	if err := page.Parse(); err != nil {
+		return nil, err
+	}
+	return &Page{
+		Title:       page.Title(),
+		Description: page.Description(),
+		Author:      page.Author(),
+		Categories:  page.Categories(),
+		HTML:        string(page.HTML()),
+		CSS:         page.CSS(),
+		Warnings:    page.Warnings,
+	}, nil
+}
+
+// RedirectError is returned by RenderPage when the page redirects elsewhere.
+type RedirectError struct {
+	Redirect string // relative or absolute URL
+}
+
+func (e *RedirectError) Error() string {
+	return "page redirects to " + e.Redirect
+}
@@ -2,11 +2,12 @@
 
 var imageList = new FileList({
     root: 'images',
-    columns: ['Filename', 'Author', 'Dimensions', 'Created', 'Modified'],
+    columns: ['Filename', 'Author', 'Dimensions', 'Pages', 'Created', 'Modified'],
     columnData: {
         Filename:   { sort: 't', isTitle: true },
         Author:     { sort: 'a' },
         Dimensions: { sort: 'd' },
+        Pages:      { },
         Created:    { sort: 'c', fixer: dateToHRTimeAgo, tooltipFixer: dateToPreciseHR, dataType: 'date' },
         Modified:   { sort: 'm', fixer: dateToHRTimeAgo, tooltipFixer: dateToPreciseHR, dataType: 'date' }
     }
@@ -17,13 +18,21 @@ a.json.results.each(function (imageData) {
     var dim = null;
     if (imageData.width && imageData.height)
         dim = imageData.width + 'x' + imageData.height;
+    var usage = imageData.usage || [];
     var entry = new FileListEntry({
+        data:       imageData,
         Filename:   imageData.file,
         Author:     imageData.author,
         Dimensions: dim,
+        Pages:      usage.length ? usage.length.toString() : null,
         Created:    imageData.created,
         Modified:   imageData.modified
     });
+    if (usage.length)
+        imageData.desc = 'Used by ' + usage.map(function (use) {
+            return use.title || use.page;
+        }).join(', ');
+    entry.setInfoState('Unused', !usage.length);
     entry.link = adminifier.wikiRoot + '/func/image/' + imageData.file;
     imageList.addEntry(entry);
 });
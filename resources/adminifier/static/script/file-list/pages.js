@@ -34,16 +34,7 @@ a.json.results.each(function (pageData) {
 pageList.draw($('content'));
 
 exports.createPage = function () {
-    var createWindow = new ModalWindow({
-        icon:           'plus-circle',
-        title:          'Create page',
-        html:           'Coming soon',
-        padded:         true,
-        id:             'create-page-window',
-        autoDestroy:    true,
-        onDone:         null
-    });
-    createWindow.show();
-}
+    window.location = adminifier.wikiRoot + '/create-page';
+};
 
 })(adminifier, window);
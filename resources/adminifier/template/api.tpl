@@ -0,0 +1,19 @@
+<!doctype html>
+<html>
+<head>
+    <meta charset="utf-8" />
+    <title>quiki API</title>
+    <link rel="icon" type="image/png" href="/static/favicon.png" />
+    <link rel="stylesheet" type="text/css" href="{{.SwaggerUI}}/swagger-ui.css" />
+</head>
+<body>
+    <div id="swagger-ui"></div>
+    <script src="{{.SwaggerUI}}/swagger-ui-bundle.js"></script>
+    <script>
+        SwaggerUIBundle({
+            url: {{.Spec}},
+            dom_id: '#swagger-ui'
+        });
+    </script>
+</body>
+</html>
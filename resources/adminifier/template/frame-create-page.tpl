@@ -0,0 +1,34 @@
+<meta
+    data-nav="pages"
+    data-title="Create page"
+    data-icon="plus-circle"
+/>
+
+{{if .Chosen}}
+<h2>{{.Page}}{{with .Template}} from {{.File}}{{end}}</h2>
+<form action="{{.Root}}/func/create-page" method="post">
+    <input type="hidden" name="page" value="{{.Page}}" />
+{{with .Template}}
+    <input type="hidden" name="template" value="{{.File}}" />
+{{range .Placeholders}}
+    <p><label>{{.}}<br /><input type="text" name="ph.{{.}}" size="60" /></label></p>
+{{end}}
+{{if .Placeholders}}
+    <p>Placeholders left blank can be filled in later. Until then, the page is
+    saved as a draft.</p>
+{{end}}
+{{end}}
+    <input type="submit" name="submit" value="Create" />
+</form>
+{{else}}
+<form action="{{.Root}}/create-page" method="get">
+    <p><label>Page name<br /><input type="text" name="page" value="{{.Page}}" /></label></p>
+    <p><label>Template<br /><select name="template">
+        <option value="">None</option>
+{{range .Templates}}
+        <option value="{{.File}}">{{.File}}</option>
+{{end}}
+    </select></label></p>
+    <input type="submit" name="submit" value="Next" />
+</form>
+{{end}}
@@ -4,7 +4,8 @@
     data-icon="home"
     data-styles="dashboard"
     data-flags="buttons"
-    data-buttons="date-selection"
+    data-buttons="{{if .Editor}}backup {{end}}date-selection"
+    data-button-backup="{'title': 'Download backup', 'icon': 'download', 'href': '{{.Root}}/func/backup'}"
     data-button-date-selection="{'title': 'Last 30 days', 'icon': 'calendar', 'func': 'displayDateSelector'}"
 />
 
@@ -36,6 +37,29 @@
 </pre>
 {{end}}
 
+<h2>Scheduled Jobs</h2>
+<table class="jobs">
+<tr>
+    <th>Job</th>
+    <th>Schedule</th>
+    <th>Last run</th>
+    <th>Result</th>
+    <th>Next run</th>
+</tr>
+{{- range .Jobs}}
+<tr>
+    <td>{{.Description}}</td>
+    <td>{{if .Schedule}}<code>{{.Schedule}}</code>{{else}}Not scheduled{{end}}</td>
+    <td>
+        {{- if .Running}}Running
+        {{- else if .LastRun.IsZero}}Never
+        {{- else}}{{.LastRun.Format "January 2, 2006 15:04"}} ({{.Duration.Round 1000000}}){{end -}}
+    </td>
+    <td{{if .Error}} class="error"{{end}}>{{if .Error}}{{.Error}}{{else}}{{.Result}}{{end}}</td>
+    <td>{{if not .NextRun.IsZero}}{{.NextRun.Format "January 2, 2006 15:04"}}{{end}}</td>
+</tr>
+{{- end}}
+</table>
 
 <h2>Logs</h2>
 <pre class="info">
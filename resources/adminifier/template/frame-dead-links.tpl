@@ -0,0 +1,42 @@
+<meta
+    data-nav="dead-links"
+    data-title="Dead links"
+    data-icon="unlink"
+    data-styles="dead-links"
+/>
+
+{{if not .Links}}
+No dead links to other sites have been found.
+{{if not .Scheduled}}
+Links are checked by the <code>check_external_links</code> job, which is not scheduled.
+{{end}}
+{{else}}
+<p>
+These links to other sites did not work when they were last checked. Where
+the Wayback Machine has a copy of the page from around the time the link last
+worked, it is suggested as a replacement.
+</p>
+
+<table class="dead-links">
+<tr>
+    <th>Link</th>
+    <th>Status</th>
+    <th>Last working</th>
+    <th>Pages</th>
+    <th>Archived copy</th>
+</tr>
+{{- range .Links}}
+<tr>
+    <td class="dead-link-url"><a href="{{.URL}}" target="_blank" rel="noopener noreferrer">{{.URL}}</a></td>
+    <td class="error">{{if .Error}}{{.Error}}{{else}}{{.Status}}{{end}}</td>
+    <td>{{with .LastWorking}}{{.Format "January 2, 2006"}}{{else}}Never{{end}}</td>
+    <td>
+        {{- range $file, $lines := .Pages}}
+        <a href="edit-page?page={{$file}}">{{$file}}</a>{{range $lines}}:{{.}}{{end}}<br />
+        {{- end}}
+    </td>
+    <td>{{with .Archive}}<a href="{{.}}" target="_blank" rel="noopener noreferrer">Wayback Machine</a>{{end}}</td>
+</tr>
+{{- end}}
+</table>
+{{end}}
@@ -4,6 +4,9 @@
 {{if .Model}}
       data-nav="models"
       data-icon="cube"
+{{else if and .Config (eq .File "vars.conf")}}
+      data-nav="variables"
+      data-icon="at"
 {{else if .Config}}
       data-nav="settings"
       data-icon="cog"
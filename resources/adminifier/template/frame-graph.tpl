@@ -0,0 +1,64 @@
+<meta
+    data-nav="graph"
+    data-title="Link graph"
+    data-icon="project-diagram"
+    data-styles="dashboard graph"
+/>
+
+{{if not .Graph.Nodes}}
+No published pages have been generated yet.
+{{else}}
+<svg class="link-graph" viewBox="{{.Graph.ViewBox}}" role="img" aria-label="Links between pages">
+<g class="link-graph-edges">
+{{- range .Graph.Edges}}
+<line x1="{{printf "%.1f" .X1}}" y1="{{printf "%.1f" .Y1}}" x2="{{printf "%.1f" .X2}}" y2="{{printf "%.1f" .Y2}}" />
+{{- end}}
+</g>
+{{- range .Graph.Nodes}}
+<a href="edit-page?page={{.File}}">
+<circle cx="{{printf "%.1f" .X}}" cy="{{printf "%.1f" .Y}}" r="{{printf "%.1f" .R}}" fill="{{.Color}}">
+<title>{{.Title}}: {{.Backlinks}} backlink{{if ne .Backlinks 1}}s{{end}}, {{.Links}} link{{if ne .Links 1}}s{{end}}</title>
+</circle>
+</a>
+{{- end}}
+{{- range .Graph.Clusters}}
+{{- if .Name}}
+<text class="link-graph-cluster" x="{{printf "%.1f" .X}}" y="{{printf "%.1f" .Y}}" fill="{{.Color}}">{{.Name}}</text>
+{{- end}}
+{{- end}}
+</svg>
+
+<p>
+Pages are sized by the number of pages linking to them and grouped by their
+largest category. Links are recorded when pages are generated.
+</p>
+
+{{if .Graph.Hubs}}
+<h2>Hubs</h2>
+<table class="link-graph-pages">
+<tr>
+    <th>Page</th>
+    <th>Backlinks</th>
+    <th>Links</th>
+</tr>
+{{- range .Graph.Hubs}}
+<tr>
+    <td><a href="edit-page?page={{.File}}">{{.Title}}</a></td>
+    <td>{{.Backlinks}}</td>
+    <td>{{.Links}}</td>
+</tr>
+{{- end}}
+</table>
+{{end}}
+
+{{if .Graph.Orphans}}
+<h2>Orphans</h2>
+{{len .Graph.Orphans}} page{{if gt (len .Graph.Orphans) 1}}s are{{else}} is{{end}} not linked from any other page.
+
+<pre class="info">
+{{- range .Graph.Orphans -}}
+<a href="edit-page?page={{.File}}">{{.File}}</a>{{if .Cluster}} ({{.Cluster}}){{end}}
+{{end -}}
+</pre>
+{{end}}
+{{end}}
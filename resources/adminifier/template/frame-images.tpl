@@ -7,7 +7,7 @@
     data-search="fileSearch"
     data-sort="{{.Order}}"
 
-    data-buttons="upload image-mode filter"
+    data-buttons="upload image-mode unused filter"
     data-button-upload="{'title': 'Upload', 'icon': 'upload', 'href': '{{.Root}}/upload-images'}"
     data-button-filter="{'title': 'Filter', 'icon': 'filter', 'func': 'displayFilter'}"
 
@@ -16,12 +16,18 @@
     data-button-rename="{'title': 'Rename', 'icon': 'file-signature', 'func': 'renameSelected', 'hide': true}"
     data-button-delete="{'title': 'Delete', 'icon': 'trash', 'func': 'deleteSelected', 'hide': true}"
 
+{{if .Unused}}
+    data-button-unused="{'title': 'All images', 'icon': 'images', 'href': '{{.Root}}/images{{if .List}}?mode=list{{end}}'}"
+{{else}}
+    data-button-unused="{'title': 'Unused', 'icon': 'unlink', 'href': '{{.Root}}/images?unused{{if .List}}&mode=list{{end}}'}"
+{{end}}
+
 {{if .List}}
-    data-button-image-mode="{'title': 'Grid view', 'icon': 'th', 'href': '{{.Root}}/images'}"
+    data-button-image-mode="{'title': 'Grid view', 'icon': 'th', 'href': '{{.Root}}/images{{if .Unused}}?unused{{end}}'}"
     data-scripts="file-list file-list/images pikaday"
     data-styles="file-list pikaday"
 {{else}}
-    data-button-image-mode="{'title': 'List view', 'icon': 'list', 'href': '{{.Root}}/images?mode=list'}"
+    data-button-image-mode="{'title': 'List view', 'icon': 'list', 'href': '{{.Root}}/images?mode=list{{if .Unused}}&unused{{end}}'}"
     data-scripts="image-grid pikaday"
     data-styles="image-grid pikaday"
 {{end}}
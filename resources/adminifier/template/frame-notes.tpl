@@ -0,0 +1,21 @@
+<meta
+    data-nav="notes"
+    data-title="Notes"
+    data-icon="sticky-note"
+/>
+
+{{if not .Pages}}
+No pages have [todo:...] or [review:...] notes.
+{{end}}
+
+{{range .Pages}}
+<h2>{{if .Title}}{{.Title}}{{else}}{{.File}}{{end}}</h2>
+
+<pre class="info">
+{{- $file := .File -}}
+{{- range .Notes -}}
+<a href="edit-page?page={{$file}}">{{$file}}</a>:
+{{- .Pos.Line}}:{{.Pos.Column}}: {{.Kind}}: {{.Text}}
+{{end -}}
+</pre>
+{{end}}
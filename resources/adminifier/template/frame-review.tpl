@@ -0,0 +1,29 @@
+<meta
+    data-nav="review"
+    data-title="Review"
+    data-icon="clipboard-check"
+/>
+
+{{if not .Queue}}
+No changes are awaiting review.
+{{end}}
+
+{{range .Queue}}
+<h2>{{.File}}</h2>
+Proposed by {{.Name}} on {{.Created.Format "January 2, 2006 15:04"}}{{if .Comment}}: {{.Comment}}{{end}}
+{{if .Flag}}<p><b>Flagged by content filters:</b> {{.Flag}}</p>{{end}}
+
+<pre class="info">{{.Diff}}</pre>
+
+{{if $.Editor}}
+<form action="{{$.Root}}/func/approve-review" method="post">
+    <input type="hidden" name="id" value="{{.ID}}" />
+    <input type="submit" name="submit" value="Approve" />
+</form>
+<form action="{{$.Root}}/func/reject-review" method="post">
+    <input type="hidden" name="id" value="{{.ID}}" />
+    <input type="text" name="reason" placeholder="Reason" />
+    <input type="submit" name="submit" value="Reject" />
+</form>
+{{end}}
+{{end}}
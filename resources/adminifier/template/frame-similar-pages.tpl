@@ -0,0 +1,38 @@
+<meta
+    data-nav="similar-pages"
+    data-title="Similar pages"
+    data-icon="clone"
+    data-styles="similar-pages"
+/>
+
+<p class="similar-pages-min">
+Showing pages at least
+{{- range $i, $t := .Thresholds}}{{if $i}} /{{end}}
+{{if eq $t $.Min}}<b>{{$t}}%</b>{{else}}<a href="similar-pages?min={{$t}}">{{$t}}%</a>{{end}}
+{{- end}}
+similar.
+</p>
+
+{{if not .Pairs}}
+No pages are at least {{.Min}}% similar.
+{{else}}
+<p>
+These pairs of pages share much of their text, so they may be redundant and
+could be merged.
+</p>
+
+<table class="similar-pages">
+<tr>
+    <th>Page</th>
+    <th>Similar page</th>
+    <th>Similarity</th>
+</tr>
+{{- range .Pairs}}
+<tr>
+    <td><a href="edit-page?page={{index .Pages 0}}">{{index .Pages 0}}</a></td>
+    <td><a href="edit-page?page={{index .Pages 1}}">{{index .Pages 1}}</a></td>
+    <td>{{.Percent}}%</td>
+</tr>
+{{- end}}
+</table>
+{{end}}
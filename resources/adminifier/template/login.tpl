@@ -34,6 +34,11 @@
                     <td class="left">Password</td>
                     <td><input type="password" name="password" /></td>
                 </tr>
+{{with .Captcha}}
+                <tr>
+                    <td colspan="2">{{.}}</td>
+                </tr>
+{{end}}
                 <tr>
                     <td><input type="submit" name="submit" value="Login" /></td>
                 </tr>
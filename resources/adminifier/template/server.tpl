@@ -6,4 +6,4 @@ Available sites:
     <li><a href="{{$shortcode}}/dashboard">{{$wi.Title}}</a></li>
 {{end}}
 </ul>
-<a href="logout">Logout</a>
\ No newline at end of file
+<a href="logout">Logout</a> | <a href="api">API documentation</a>
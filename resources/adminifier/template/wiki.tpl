@@ -49,7 +49,18 @@ var adminifier = {
         <li data-nav="categories"><a class="frame-click" href="{{.Root}}/categories"><i class="fa fa-list"></i> <span>Categories</span></a></li>
         <li data-nav="images"><a class="frame-click" href="{{.Root}}/images"><i class="fa fa-images"></i> <span>Images</span></a></li>
         <li data-nav="models"><a class="frame-click" href="{{.Root}}/models"><i class="fa fa-cube"></i> <span>Models</span></a></li>
+        {{if .Review}}
+            <li data-nav="review"><a class="frame-click" href="{{.Root}}/review"><i class="fa fa-clipboard-check"></i> <span>Review</span></a></li>
+        {{end}}
+        <li data-nav="notes"><a class="frame-click" href="{{.Root}}/notes"><i class="fa fa-sticky-note"></i> <span>Notes</span></a></li>
+        <li data-nav="graph"><a class="frame-click" href="{{.Root}}/graph"><i class="fa fa-project-diagram"></i> <span>Link graph</span></a></li>
+        <li data-nav="dead-links"><a class="frame-click" href="{{.Root}}/dead-links"><i class="fa fa-unlink"></i> <span>Dead links</span></a></li>
+        <li data-nav="similar-pages"><a class="frame-click" href="{{.Root}}/similar-pages"><i class="fa fa-clone"></i> <span>Similar pages</span></a></li>
+        <li data-nav="variables"><a class="frame-click" href="{{.Root}}/variables"><i class="fa fa-at"></i> <span>Variables</span></a></li>
         <li data-nav="settings"><a class="frame-click" href="{{.Root}}/settings"><i class="fa fa-cog"></i> <span>Settings</a></li>
+        {{range .ExtensionFrames}}
+            <li data-nav="{{.Name}}"><a class="frame-click" href="{{$.Root}}/{{.Name}}"><i class="fa {{.Icon}}"></i> <span>{{.Title}}</span></a></li>
+        {{end}}
         <li data-nav="help"><a class="frame-click" href="{{.Root}}/help"><i class="fa fa-question-circle"></i> <span>Help</a></li>
         {{if .ServerPanelAccess}}
             <li><a href="{{.AdminRoot}}/"><i class="fa fa-globe-americas"></i> <span>Sites</span></a></li>
@@ -13,15 +13,220 @@ document.addEvent('domready', function () {
             loadJS("/static/ext/nanogallery2/jquery.nanogallery2.min.js");
         });
     }
+
+    // render equations if the template includes KaTeX
+    if (window.katex) $$(".q-math").each(function (el) {
+        if (el.hasClass("q-math-rendered"))
+            return;
+        var tex = el.get("text").trim();
+        katex.render(tex.substring(2, tex.length - 2), el, {
+            displayMode: el.get("tag") == "div",
+            throwOnError: false
+        });
+    });
+
+    // render graphviz diagrams if the template includes Viz.js. mermaid.js
+    // renders mermaid diagrams by itself
+    if (window.Viz && $$(".q-diagram-graphviz pre").length) Viz.instance().then(function (viz) {
+        $$(".q-diagram-graphviz pre").each(function (el) {
+            try {
+                var svg = viz.renderSVGElement(el.get("text"));
+                el.getParent().addClass("q-diagram-rendered");
+                el.getParent().replaceChild(svg, el);
+            } catch (e) { }
+        });
+    });
+
+    // sort csv{} tables by the clicked column
+    $$(".q-csv-sortable").each(function (table) {
+        table.getElements("thead th").each(function (th, col) {
+            th.addEvent("click", function () {
+                sortTable(table, th, col);
+            });
+        });
+    });
+
+    // copy buttons for code{} with the copy option and terminal{}
+    $$("pre[data-copy]").each(addCopyButton);
+
+    // Ctrl-K or Cmd-K opens the quick switcher if the template enables it
+    var qsURL = document.body.get("data-quickswitch");
+    if (qsURL) document.addEvent("keydown", function (e) {
+        if (e.key == "k" && (e.control || e.meta)) {
+            e.preventDefault();
+            quickSwitch(qsURL);
+        }
+    });
 });
 
 window.addEvent('hashchange', hashLoad);
 
+// sorts a table by a column, toggling the order if it is already sorted by it
+function sortTable (table, th, col) {
+    var type = th.get("data-type");
+    var desc = th.hasClass("q-sort-asc");
+    table.getElements("thead th").removeClass("q-sort-asc").removeClass("q-sort-desc");
+    th.addClass(desc ? "q-sort-desc" : "q-sort-asc");
+
+    var tbody = table.getElement("tbody");
+    var rows = tbody.getElements("tr");
+    var value = function (row) {
+        var cell = row.getElements("td")[col];
+        if (!cell)
+            return "";
+        return cell.get("data-sort") || cell.get("text");
+    };
+    rows.sort(function (a, b) {
+        var x = value(a), y = value(b), cmp;
+        if (type == "number")
+            cmp = (parseFloat(x) || 0) - (parseFloat(y) || 0);
+        else
+            cmp = x.localeCompare(y);
+        return desc ? -cmp : cmp;
+    });
+    rows.each(function (row) {
+        tbody.appendChild(row);
+    });
+}
+
+// adds a button which copies the code in a pre, without line numbers, or
+// for terminal{}, only the commands
+function addCopyButton (pre) {
+    var wrap = new Element("div", { "class": "q-copy-wrap" }).wraps(pre);
+    var button = new Element("button", {
+        type: "button",
+        "class": "q-copy-button",
+        text: "Copy"
+    });
+    button.addEvent("click", function () {
+        var text;
+        if (pre.get("data-copy") == "commands") {
+            text = pre.get("data-commands");
+        } else {
+            var copy = pre.clone();
+            copy.getElements(".ln").destroy();
+            text = copy.get("text");
+        }
+        copyText(text, function (ok) {
+            button.set("text", ok ? "Copied" : "Copy failed");
+            setTimeout(function () { button.set("text", "Copy"); }, 1500);
+        });
+    });
+    wrap.appendChild(button);
+}
+
+// copies text to the clipboard, calling done with whether it succeeded
+function copyText (text, done) {
+    if (navigator.clipboard && window.isSecureContext) {
+        navigator.clipboard.writeText(text).then(function () {
+            done(true);
+        }, function () {
+            done(false);
+        });
+        return;
+    }
+
+    // older browsers and plain HTTP
+    var area = new Element("textarea", { value: text, readonly: true });
+    area.setStyles({ position: "fixed", top: 0, left: 0, opacity: 0 });
+    document.body.appendChild(area);
+    area.select();
+    var ok = false;
+    try {
+        ok = document.execCommand("copy");
+    } catch (e) { }
+    area.destroy();
+    done(ok);
+}
+
+// opens the quick switcher, which finds pages by title as you type
+function quickSwitch (url) {
+    if ($("q-quickswitch")) {
+        $("q-quickswitch").getElement("input").focus();
+        return;
+    }
+    var overlay = new Element("div", { id: "q-quickswitch" });
+    var box = new Element("div", { "class": "q-quickswitch-box" });
+    var input = new Element("input", {
+        type: "text",
+        placeholder: "Jump to page",
+        autocomplete: "off"
+    });
+    var list = new Element("ul");
+    box.adopt(input, list);
+    overlay.adopt(box);
+    document.body.appendChild(overlay);
+    input.focus();
+
+    var results = [], selected = 0, req;
+    var close = function () {
+        if (req) req.abort();
+        overlay.destroy();
+    };
+    var select = function (i) {
+        var items = list.getElements("li");
+        if (!items.length)
+            return;
+        selected = (i + items.length) % items.length;
+        items.removeClass("q-quickswitch-selected");
+        items[selected].addClass("q-quickswitch-selected");
+    };
+    var show = function (res) {
+        results = res || [];
+        list.empty();
+        results.each(function (page, i) {
+            var li = new Element("li").adopt(
+                new Element("a", { href: page.link, text: page.title })
+            );
+            li.addEvent("mouseenter", function () { select(i); });
+            list.appendChild(li);
+        });
+        select(0);
+    };
+
+    overlay.addEvent("click", function (e) {
+        if (e.target == overlay)
+            close();
+    });
+    input.addEvent("keydown", function (e) {
+        if (e.key == "esc")
+            close();
+        else if (e.key == "down")
+            select(selected + 1);
+        else if (e.key == "up")
+            select(selected - 1);
+        else if (e.key == "enter" && results[selected])
+            window.location = results[selected].link;
+        else
+            return;
+        e.preventDefault();
+    });
+    input.addEvent("input", function () {
+        if (req) req.abort();
+        var q = input.get("value").trim();
+        if (!q) {
+            show([]);
+            return;
+        }
+        req = new XMLHttpRequest();
+        req.open("GET", url + "?q=" + encodeURIComponent(q));
+        req.responseType = "json";
+        req.addEventListener("load", function () {
+            if (this.status == 200)
+                show(this.response);
+        });
+        req.send();
+    });
+}
+
 // redirect #some-section to #qa-some-section
 function hashLoad() {
     var hash = window.location.hash;
     if (hash.lastIndexOf('#', 0) === 0)
         hash = hash.substring(1);
+    try {
+        hash = decodeURIComponent(hash);
+    } catch (e) { }
     var anchor = 'qa-' + hash;
     var el = $(anchor);
     if (el) {
@@ -12,11 +12,16 @@
     <meta name="author" content="{{.}}" />
 {{end}}
     <title>{{.VisibleTitle}}</title>
+{{with .Canonical}}
+    <link rel="canonical" href="{{.}}" />
+{{end}}
     <link rel="stylesheet" type="text/css" href="{{.StaticRoot}}/style.css" />
     <link rel="stylesheet" type="text/css" href="/static/quiki.css" />
-{{with .PageCSS}}
-    <style>
-{{.}}
+{{if .PageCSSURL}}
+    <link rel="stylesheet" type="text/css" href="{{.PageCSSURL}}" />
+{{else if .PageCSS}}
+    <style nonce="{{.CSPNonce}}">
+{{.PageCSS}}
     </style>
 {{end}}
 {{range .Scripts}}
@@ -24,7 +29,7 @@
 {{end}}
 </head>
 
-<body>
+<body{{with .QuickSwitch}} data-quickswitch="{{.}}"{{end}}>
 <div id="container">
 
     <div id="header">
@@ -33,6 +38,13 @@
                 <li><a href="{{.Link}}">{{.Display}}</a></li>
             {{end}}
         </ul>
+        {{if .Versions}}
+        <ul id="versions">
+            {{range .Versions}}
+                <li{{if .Current}} class="current"{{end}}><a href="{{.Link}}">{{.Name}}</a></li>
+            {{end}}
+        </ul>
+        {{end}}
         <a href="{{.Root.Wiki}}/">
             {{if .WikiLogo}}
                 <img src="{{.WikiLogo}}" alt="{{.WikiTitle}}" data-rjs="3" />
@@ -1,3 +1,6 @@
 {{ template "header.tpl" . }}
 {{.HTMLContent}}
+{{with .Sections}}
+<script type="application/json" id="q-sections">{{.}}</script>
+{{end}}
 {{ template "footer.tpl" . }}
@@ -9,6 +9,6 @@
     </div>
 {{end}}
 {{range $n := .PageNumbers}}
-    <a class="page-number{{if eq $.PageN $n}} active{{end}}" href="{{$.Root.Category}}/{{$.Name}}/{{$n}}">{{$n}}</a>
+    <a class="page-number{{if eq $.PageN $n}} active{{end}}" href="{{$.PostsRoot}}/{{$.Name}}/{{$n}}">{{$n}}</a>
 {{end}}
 {{template "footer.tpl" .}}
\ No newline at end of file
@@ -0,0 +1,33 @@
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8" />
+    <meta name="viewport" content="width=device-width, initial-scale=1.0" />
+    <title>{{.VisibleTitle}}</title>
+    <link rel="stylesheet" type="text/css" href="{{.RevealJS}}/reveal.css" />
+    <link rel="stylesheet" type="text/css" href="{{.RevealJS}}/theme/white.css" />
+    <link rel="stylesheet" type="text/css" href="/static/quiki.css" />
+{{if .PageCSSURL}}
+    <link rel="stylesheet" type="text/css" href="{{.PageCSSURL}}" />
+{{else if .PageCSS}}
+    <style nonce="{{.CSPNonce}}">
+{{.PageCSS}}
+    </style>
+{{end}}
+</head>
+<body>
+<div class="reveal">
+<div class="slides">
+{{range .Slides}}
+<section>
+{{.}}
+</section>
+{{end}}
+</div>
+</div>
+<script src="{{.RevealJS}}/reveal.js"></script>
+<script nonce="{{.CSPNonce}}">
+Reveal.initialize({ hash: true });
+</script>
+</body>
+</html>
@@ -1,6 +1,27 @@
+// Command standalone parses a single quiki page and prints the generated HTML.
+//
+//	standalone file.page
+//
+// With -snapshots, it instead renders each .page fixture in a directory and
+// compares the output to the .html golden file of the same name, exiting
+// with a nonzero status if any differ. Add -update to write the golden files
+// from the current output, then review the changes with git diff.
+//
+//	standalone -snapshots wikifier/testdata/snapshots
+//	standalone -snapshots wikifier/testdata/snapshots -update
+//
+// With -trace, each block and variable the parser enters and leaves is
+// printed to standard error before the HTML.
+//
+//	standalone -trace file.page
+//
+// quiki's own fixtures are also compared by go test ./wikifier, which takes
+// the same -update flag. Template and extension authors can use this runner
+// on their own fixtures, or call wikifier.RunSnapshots directly.
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
@@ -8,11 +29,27 @@ import (
 	"github.com/cooper/quiki/wikifier"
 )
 
+var (
+	snapshotDir = flag.String("snapshots", "", "directory of .page fixtures to compare with golden .html files")
+	update      = flag.Bool("update", false, "with -snapshots, write golden files rather than comparing")
+	trace       = flag.Bool("trace", false, "print parser events to standard error")
+)
+
 func main() {
-	if len(os.Args) != 2 {
+	flag.Parse()
+
+	// compare snapshots
+	if *snapshotDir != "" {
+		os.Exit(runSnapshots())
+	}
+
+	if flag.NArg() != 1 {
 		log.Fatal("wrong # of args")
 	}
-	page := wikifier.NewPage(os.Args[1])
+	page := wikifier.NewPage(flag.Arg(0))
+	if *trace {
+		page.Tracer = wikifier.WriterTracer{W: os.Stderr}
+	}
 
 	// parse
 	err := page.Parse()
@@ -22,3 +59,32 @@ func main() {
 
 	fmt.Println(page.HTML())
 }
+
+func runSnapshots() int {
+	results, err := wikifier.RunSnapshots(*snapshotDir, *update)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	failed := 0
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			failed++
+			fmt.Printf("FAIL %s: %v\n", r.Name, r.Err)
+		case r.Updated:
+			fmt.Printf("updated %s\n", r.Golden)
+		case !r.OK():
+			failed++
+			fmt.Printf("FAIL %s: output differs from %s\n--- want\n%s--- got\n%s", r.Name, r.Golden, r.Want, r.Got)
+		default:
+			fmt.Printf("ok %s\n", r.Name)
+		}
+	}
+
+	if failed != 0 {
+		fmt.Printf("%d of %d snapshots failed\n", failed, len(results))
+		return 1
+	}
+	return 0
+}
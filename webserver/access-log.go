@@ -0,0 +1,231 @@
+package webserver
+
+// Copyright (c) 2020, Mitchell Cooper
+// access-log.go - HTTP access logging, separate from the application log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// access log destination, or nil if access logging is disabled
+var accessLog *accessLogger
+
+type accessLogger struct {
+	mu     sync.Mutex
+	format string // common, combined, or json
+	out    io.Writer
+}
+
+// a log file which is rotated when it reaches a maximum size. rotated files
+// are named as in access.log.1, with higher numbers being older
+type accessLogFile struct {
+	path    string
+	maxSize int64 // bytes, or 0 to never rotate
+	keep    int   // number of rotated files to keep
+	file    *os.File
+	size    int64
+}
+
+// records the status and size of a response
+type accessLogWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+// configure access logging from server.access_log.* options
+func setupAccessLog() error {
+	path, _ := Conf.GetStr("server.access_log.file")
+	if path == "" {
+		return nil
+	}
+
+	format, _ := Conf.GetStr("server.access_log.format")
+	switch format {
+	case "":
+		format = "combined"
+	case "common", "combined", "json":
+	default:
+		return errors.New("server.access_log.format: must be one of 'common', 'combined', or 'json'")
+	}
+	accessLog = &accessLogger{format: format}
+
+	// standard output
+	if path == "stdout" {
+		accessLog.out = os.Stdout
+		return nil
+	}
+
+	// file, optionally rotated
+	out := &accessLogFile{path: path, keep: 5}
+	if str, _ := Conf.GetStr("server.access_log.max_size"); str != "" {
+		megabytes, err := strconv.Atoi(str)
+		if err != nil || megabytes < 0 {
+			return errors.New("server.access_log.max_size: must be a number of megabytes")
+		}
+		out.maxSize = int64(megabytes) << 20
+	}
+	if str, _ := Conf.GetStr("server.access_log.keep"); str != "" {
+		keep, err := strconv.Atoi(str)
+		if err != nil || keep < 0 {
+			return errors.New("server.access_log.keep: must be a number of files")
+		}
+		out.keep = keep
+	}
+	if err := out.open(); err != nil {
+		return errors.Wrap(err, "server.access_log.file")
+	}
+	accessLog.out = out
+	return nil
+}
+
+// accessLogMiddleware logs each request after it is handled.
+func accessLogMiddleware(next http.Handler) http.Handler {
+	if accessLog == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		lw := &accessLogWriter{ResponseWriter: w}
+		next.ServeHTTP(lw, r)
+		if lw.status == 0 {
+			lw.status = http.StatusOK
+		}
+		accessLog.log(r, lw.status, lw.size, start)
+	})
+}
+
+func (w *accessLogWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessLogWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// writes a log entry for a request
+func (l *accessLogger) log(r *http.Request, status int, size int64, start time.Time) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	user, _, _ := r.BasicAuth()
+
+	var entry []byte
+	switch l.format {
+	case "json":
+		entry, _ = json.Marshal(struct {
+			Time      string `json:"time"`
+			Remote    string `json:"remote"`
+			User      string `json:"user,omitempty"`
+			Host      string `json:"host"`
+			Method    string `json:"method"`
+			URI       string `json:"uri"`
+			Proto     string `json:"proto"`
+			Status    int    `json:"status"`
+			Size      int64  `json:"size"`
+			Referer   string `json:"referer,omitempty"`
+			UserAgent string `json:"user_agent,omitempty"`
+			Duration  int64  `json:"duration_ms"`
+		}{
+			Time:      start.Format(time.RFC3339),
+			Remote:    host,
+			User:      user,
+			Host:      r.Host,
+			Method:    r.Method,
+			URI:       r.RequestURI,
+			Proto:     r.Proto,
+			Status:    status,
+			Size:      size,
+			Referer:   r.Referer(),
+			UserAgent: r.UserAgent(),
+			Duration:  time.Since(start).Milliseconds(),
+		})
+		entry = append(entry, '\n')
+
+	default:
+		sizeStr := "-"
+		if size != 0 {
+			sizeStr = strconv.FormatInt(size, 10)
+		}
+		line := fmt.Sprintf("%s - %s [%s] %q %d %s",
+			host, orDash(user), start.Format("02/Jan/2006:15:04:05 -0700"),
+			r.Method+" "+r.RequestURI+" "+r.Proto, status, sizeStr,
+		)
+		if l.format == "combined" {
+			line += fmt.Sprintf(" %q %q", orDash(r.Referer()), orDash(r.UserAgent()))
+		}
+		entry = []byte(line + "\n")
+	}
+
+	l.mu.Lock()
+	l.out.Write(entry)
+	l.mu.Unlock()
+}
+
+// fields which are empty are written as - in common and combined formats
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func (f *accessLogFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, fi.Size()
+	return nil
+}
+
+func (f *accessLogFile) Write(b []byte) (int, error) {
+	if f.maxSize != 0 && f.size != 0 && f.size+int64(len(b)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(b)
+	f.size += int64(n)
+	return n, err
+}
+
+// renames the current file to .1, the previous .1 to .2, and so on, deleting
+// the oldest, and then opens a new file
+func (f *accessLogFile) rotate() error {
+	f.file.Close()
+	if f.keep == 0 {
+		os.Remove(f.path)
+		return f.open()
+	}
+	os.Remove(f.path + "." + strconv.Itoa(f.keep))
+	for i := f.keep - 1; i >= 1; i-- {
+		os.Rename(f.path+"."+strconv.Itoa(i), f.path+"."+strconv.Itoa(i+1))
+	}
+	os.Rename(f.path, f.path+".1")
+	return f.open()
+}
@@ -0,0 +1,473 @@
+package webserver
+
+// Copyright (c) 2020, Mitchell Cooper
+// api.go - JSON content API and its OpenAPI description
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cooper/quiki/authenticator"
+	"github.com/cooper/quiki/wiki"
+	"github.com/cooper/quiki/wikifier"
+)
+
+// an API route. the OpenAPI document is generated from these, so every
+// route must be described here
+type apiRoute struct {
+	method   string     // HTTP method
+	path     string     // path relative to /api, with {param} placeholders
+	summary  string     // short description
+	params   []apiParam // query or form parameters, in addition to path parameters
+	body     []apiParam // properties of the JSON request body, if any
+	auth     bool       // true if HTTP basic authentication as a server user is required
+	response string     // description of a successful response
+	handler  func(req *apiRequest)
+}
+
+// a query or form parameter or JSON body property of an API route
+type apiParam struct {
+	name     string
+	typ      string // JSON schema type; defaults to string
+	desc     string
+	required bool
+}
+
+// an API request being handled
+type apiRequest struct {
+	w      http.ResponseWriter
+	r      *http.Request
+	params map[string]string   // path parameters
+	user   *authenticator.User // authenticated user, if any
+}
+
+// API routes, in the order they are documented. they are set in init
+// because the OpenAPI handler refers to them
+var apiRoutes []*apiRoute
+
+func init() {
+	apiRoutes = []*apiRoute{
+		{
+			method:   http.MethodGet,
+			path:     "/openapi.json",
+			summary:  "OpenAPI description of this API",
+			response: "OpenAPI 3 document",
+			handler:  handleAPIOpenAPI,
+		},
+		{
+			method:   http.MethodGet,
+			path:     "/wikis",
+			summary:  "List the wikis served by this server",
+			response: "Array of wikis, each with name, title, host, and root",
+			handler:  handleAPIWikis,
+		},
+		{
+			method:   http.MethodGet,
+			path:     "/wikis/{wiki}/pages",
+			summary:  "List the published pages of a wiki",
+			response: "Array of page info, sorted by title. hash is the SHA-256 of the generated HTML",
+			handler:  handleAPIPages,
+		},
+		{
+			method:  http.MethodGet,
+			path:    "/wikis/{wiki}/quickswitch",
+			summary: "Find pages by title for a quick switcher",
+			params: []apiParam{
+				{name: "q", desc: "Beginning of or characters in the title or name of a page", required: true},
+				{name: "limit", typ: "integer", desc: "Maximum number of results; defaults to 10, at most 50"},
+			},
+			response: "Array of matching pages, each with file, title, link, and score, best match first",
+			handler:  handleAPIQuickSwitch,
+		},
+		{
+			method:   http.MethodGet,
+			path:     "/wikis/{wiki}/graph",
+			summary:  "Get the graph of links between the published pages of a wiki",
+			response: "Object with nodes, each page with its categories and numbers of links and backlinks, and links, each with source and target filenames",
+			handler:  handleAPIGraph,
+		},
+		{
+			method:   http.MethodGet,
+			path:     "/wikis/{wiki}/images",
+			summary:  "List the images of a wiki and the pages which use them",
+			auth:     true,
+			response: "Array of image info, sorted by filename. hashes are the SHA-256 of each generated image",
+			handler:  handleAPIImages,
+		},
+		{
+			method:  http.MethodPost,
+			path:    "/render",
+			summary: "Render quiki or Markdown source",
+			body: []apiParam{
+				{name: "source", desc: "Source code to render", required: true},
+				{name: "markdown", typ: "boolean", desc: "True if the source is Markdown"},
+				{name: "wiki", desc: "Name of a wiki in which context to render, so that links, images, and models refer to its content"},
+			},
+			auth:     true,
+			response: "Object with html, css, warnings, error, vars, and toc",
+			handler:  handleAPIRender,
+		},
+	}
+}
+
+// apiMiddleware answers requests within /api/ for all hosts, before any
+// wiki can claim the path.
+func apiMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		relPath := strings.TrimPrefix(r.URL.Path, "/api")
+
+		// find the route
+		var route *apiRoute
+		var params map[string]string
+		for _, rt := range apiRoutes {
+			if params = apiPathMatch(rt.path, relPath); params == nil {
+				continue
+			}
+			if rt.method == r.Method || (rt.method == http.MethodGet && r.Method == http.MethodHead) {
+				route = rt
+				break
+			}
+			w.Header().Add("Allow", rt.method)
+		}
+		if route == nil {
+			if len(w.Header()["Allow"]) != 0 {
+				apiError(w, http.StatusMethodNotAllowed, "method not allowed")
+				return
+			}
+			apiError(w, http.StatusNotFound, "no such endpoint")
+			return
+		}
+
+		// authenticate
+		req := &apiRequest{w: w, r: r, params: params, user: basicAuthUser(r)}
+		if route.auth && req.user == nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="quiki"`)
+			apiError(w, http.StatusUnauthorized, "authentication required")
+			return
+		}
+
+		route.handler(req)
+	})
+}
+
+var apiParamRegex = regexp.MustCompile(`\{(\w+)\}`)
+
+// maximum size of a POST /api/render request body
+const maxRenderSize = 5 << 20
+
+// maximum number of GET /api/wikis/{wiki}/quickswitch results
+const maxQuickSwitchLimit = 50
+
+// returns path parameters if the path matches the pattern, or nil if not
+func apiPathMatch(pattern, relPath string) map[string]string {
+	patternParts := strings.Split(pattern, "/")
+	pathParts := strings.Split(relPath, "/")
+	if len(patternParts) != len(pathParts) {
+		return nil
+	}
+	params := make(map[string]string)
+	for i, part := range patternParts {
+		if m := apiParamRegex.FindStringSubmatch(part); m != nil && m[0] == part {
+			if pathParts[i] == "" {
+				return nil
+			}
+			params[m[1]] = pathParts[i]
+		} else if part != pathParts[i] {
+			return nil
+		}
+	}
+	return params
+}
+
+// returns the server user identified by HTTP basic authentication, if any
+func basicAuthUser(r *http.Request) *authenticator.User {
+	username, password, ok := r.BasicAuth()
+	if !ok || Auth == nil {
+		return nil
+	}
+	user, err := Auth.Login(username, password)
+	if err != nil {
+		return nil
+	}
+	return &user
+}
+
+// returns true if the request is by an editor of the wiki, who is either
+// logged in to adminifier or identified by HTTP basic authentication
+func requestEditor(wi *WikiInfo, r *http.Request) bool {
+	var user *authenticator.User
+	if SessMgr != nil && SessMgr.GetBool(r.Context(), "loggedIn") {
+		user, _ = SessMgr.Get(r.Context(), "user").(*authenticator.User)
+	}
+	if user == nil {
+		user = basicAuthUser(r)
+	}
+	return user != nil && wi.IsEditor(user.Username)
+}
+
+// finds the wiki in the {wiki} path parameter, or responds with an error
+func (req *apiRequest) wiki() *WikiInfo {
+	wi := Wikis[req.params["wiki"]]
+	if wi == nil {
+		apiError(req.w, http.StatusNotFound, "no such wiki")
+	}
+	return wi
+}
+
+func apiJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func apiError(w http.ResponseWriter, code int, message string) {
+	apiJSON(w, code, struct {
+		Error string `json:"error"`
+	}{message})
+}
+
+// GET /api/openapi.json
+func handleAPIOpenAPI(req *apiRequest) {
+	apiJSON(req.w, http.StatusOK, openAPIDocument())
+}
+
+// GET /api/wikis
+func handleAPIWikis(req *apiRequest) {
+	type wikiInfo struct {
+		Name  string `json:"name"`
+		Title string `json:"title"`
+		Host  string `json:"host,omitempty"`
+		Root  string `json:"root"`
+	}
+	names := make([]string, 0, len(Wikis))
+	for name := range Wikis {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	wikis := make([]wikiInfo, len(names))
+	for i, name := range names {
+		wi := Wikis[name]
+		wikis[i] = wikiInfo{wi.Name, wi.Title, wi.Host, wi.Opt.Root.Wiki + "/"}
+	}
+	apiJSON(req.w, http.StatusOK, wikis)
+}
+
+// GET /api/wikis/{wiki}/pages
+func handleAPIPages(req *apiRequest) {
+	wi := req.wiki()
+	if wi == nil {
+		return
+	}
+	pages := make([]wikifier.PageInfo, 0)
+	for _, info := range wi.PagesSorted(false, wiki.SortTitle) {
+		if !info.Draft {
+			pages = append(pages, info)
+		}
+	}
+	apiJSON(req.w, http.StatusOK, pages)
+}
+
+// GET /api/wikis/{wiki}/quickswitch
+func handleAPIQuickSwitch(req *apiRequest) {
+	wi := req.wiki()
+	if wi == nil {
+		return
+	}
+	limit := 10
+	if l := req.r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 {
+			apiError(req.w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		if n > maxQuickSwitchLimit {
+			n = maxQuickSwitchLimit
+		}
+		limit = n
+	}
+	apiJSON(req.w, http.StatusOK, wi.QuickSwitch(req.r.URL.Query().Get("q"), limit))
+}
+
+// GET /api/wikis/{wiki}/graph
+func handleAPIGraph(req *apiRequest) {
+	wi := req.wiki()
+	if wi == nil {
+		return
+	}
+	apiJSON(req.w, http.StatusOK, wi.LinkGraph())
+}
+
+// GET /api/wikis/{wiki}/images
+func handleAPIImages(req *apiRequest) {
+	wi := req.wiki()
+	if wi == nil {
+		return
+	}
+	apiJSON(req.w, http.StatusOK, wi.ImagesSorted(false, wiki.SortTitle))
+}
+
+// POST /api/render
+func handleAPIRender(req *apiRequest) {
+	var body struct {
+		Source   string `json:"source"`
+		Markdown bool   `json:"markdown"`
+		Wiki     string `json:"wiki"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(req.w, req.r.Body, maxRenderSize)).Decode(&body); err != nil {
+		apiError(req.w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	page := wikifier.NewPageSource(body.Source)
+	page.Markdown = body.Markdown
+	if body.Wiki != "" {
+		wi := Wikis[body.Wiki]
+		if wi == nil {
+			apiError(req.w, http.StatusNotFound, "no such wiki")
+			return
+		}
+		page.Wiki = wi.Wiki
+		page.Opt = &wi.Opt
+	}
+
+	res := struct {
+		HTML     wikifier.HTML          `json:"html"`
+		CSS      string                 `json:"css,omitempty"`
+		Warnings []wikifier.Warning     `json:"warnings,omitempty"`
+		Error    *wikifier.Warning      `json:"error,omitempty"`
+		Vars     map[string]interface{} `json:"vars,omitempty"`
+		TOC      []wikifier.TOCEntry    `json:"toc,omitempty"`
+	}{}
+	if err := page.Parse(); err == nil {
+		res.HTML = page.HTML()
+		res.CSS = page.CSS()
+		res.Vars = page.Vars()
+		res.TOC = page.TOC()
+	}
+	res.Warnings, res.Error = page.Warnings, page.Error
+	apiJSON(req.w, http.StatusOK, res)
+}
+
+// OpenAPI 3 document describing apiRoutes
+func openAPIDocument() map[string]interface{} {
+	paths := make(map[string]map[string]interface{})
+	for _, route := range apiRoutes {
+		var params []map[string]interface{}
+		for _, m := range apiParamRegex.FindAllStringSubmatch(route.path, -1) {
+			params = append(params, map[string]interface{}{
+				"name":     m[1],
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]string{"type": "string"},
+			})
+		}
+		for _, p := range route.params {
+			params = append(params, map[string]interface{}{
+				"name":        p.name,
+				"in":          "query",
+				"description": p.desc,
+				"required":    p.required,
+				"schema":      map[string]string{"type": p.schemaType()},
+			})
+		}
+
+		op := map[string]interface{}{
+			"summary":     route.summary,
+			"operationId": apiOperationID(route),
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": route.response,
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{},
+					},
+				},
+			},
+		}
+		if len(params) != 0 {
+			op["parameters"] = params
+		}
+		if route.body != nil {
+			properties := make(map[string]interface{}, len(route.body))
+			var required []string
+			for _, p := range route.body {
+				properties[p.name] = map[string]string{
+					"type":        p.schemaType(),
+					"description": p.desc,
+				}
+				if p.required {
+					required = append(required, p.name)
+				}
+			}
+			schema := map[string]interface{}{
+				"type":       "object",
+				"properties": properties,
+			}
+			if len(required) != 0 {
+				schema["required"] = required
+			}
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schema},
+				},
+			}
+		}
+		if route.auth {
+			op["security"] = []map[string][]string{{"basicAuth": {}}}
+			op["responses"].(map[string]interface{})["401"] = map[string]string{
+				"description": "Authentication required",
+			}
+		}
+
+		path := "/api" + route.path
+		if paths[path] == nil {
+			paths[path] = make(map[string]interface{})
+		}
+		paths[path][strings.ToLower(route.method)] = op
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]string{
+			"title":       "quiki API",
+			"description": "Content API of a quiki server.",
+			"version":     "1",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"securitySchemes": map[string]interface{}{
+				"basicAuth": map[string]string{
+					"type":        "http",
+					"scheme":      "basic",
+					"description": "Username and password of a server user",
+				},
+			},
+		},
+	}
+}
+
+func (p apiParam) schemaType() string {
+	if p.typ == "" {
+		return "string"
+	}
+	return p.typ
+}
+
+// operation ID such as getWikisWikiPages
+func apiOperationID(route *apiRoute) string {
+	id := strings.ToLower(route.method)
+	for _, part := range strings.FieldsFunc(route.path, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	}) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
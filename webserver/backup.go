@@ -0,0 +1,114 @@
+package webserver
+
+// Copyright (c) 2020, Mitchell Cooper
+// backup.go - scheduled wiki backups
+
+import (
+	"bytes"
+	"html"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cooper/quiki/wiki"
+	"github.com/pkg/errors"
+)
+
+var backupClient = &http.Client{Timeout: 5 * time.Minute}
+
+// S3-compatible storage for backups
+type s3Conf struct {
+	bucket    string
+	region    string
+	endpoint  string
+	accessKey string
+	secretKey string
+	prefix    string
+}
+
+// backs up a wiki to the directory and/or S3 bucket in the server config
+func backupWiki(wi *WikiInfo) (string, error) {
+	dir := backupOpt("server.backup.dir")
+	s3 := s3Conf{
+		bucket:    backupOpt("server.backup.s3.bucket"),
+		region:    backupOpt("server.backup.s3.region"),
+		endpoint:  backupOpt("server.backup.s3.endpoint"),
+		accessKey: backupOpt("server.backup.s3.access_key"),
+		secretKey: backupOpt("server.backup.s3.secret_key"),
+		prefix:    backupOpt("server.backup.s3.prefix"),
+	}
+	if dir == "" && s3.bucket == "" {
+		return "", errors.New("neither server.backup.dir nor server.backup.s3 is configured")
+	}
+
+	var buf bytes.Buffer
+	if err := wi.Backup(&buf); err != nil {
+		return "", err
+	}
+	fileName := wi.Name + "-" + time.Now().UTC().Format("20060102-150405") + ".tar.gz"
+
+	var saved []string
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", err
+		}
+		filePath := filepath.Join(dir, fileName)
+		if err := ioutil.WriteFile(filePath, buf.Bytes(), 0600); err != nil {
+			return "", err
+		}
+		saved = append(saved, filePath)
+	}
+	if s3.bucket != "" {
+		key := strings.TrimPrefix(s3.prefix+fileName, "/")
+		if err := s3.put(key, buf.Bytes()); err != nil {
+			return "", errors.Wrap(err, "s3")
+		}
+		saved = append(saved, "s3://"+s3.bucket+"/"+key)
+	}
+	return "saved to " + strings.Join(saved, ", "), nil
+}
+
+// option values are HTML-encoded by the parser
+func backupOpt(key string) string {
+	str, _ := Conf.GetStr(key)
+	return html.UnescapeString(str)
+}
+
+// uploads an object using AWS Signature Version 4
+func (s s3Conf) put(key string, body []byte) error {
+	region := s.region
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := strings.TrimSuffix(s.endpoint, "/")
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint + "/" + s.bucket + "/" + key)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPut, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/gzip")
+	wiki.SignAWSRequest(req, body, s.accessKey, s.secretKey, region, "s3", time.Now().UTC())
+
+	res, err := backupClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return errors.New(res.Status + ": " + strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
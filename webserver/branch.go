@@ -0,0 +1,107 @@
+package webserver
+
+// branch.go - read-only previews of wiki branches
+
+import (
+	"log"
+	"net/http"
+	"strings"
+)
+
+// serve previews of each branch at <root.wiki>/_branch/<name>/
+func setupBranchPreviews(wi *WikiInfo) {
+	branchRoot := wi.Opt.Root.Wiki + "/_branch/"
+	Mux.HandleFunc(wi.Host+branchRoot, func(w http.ResponseWriter, r *http.Request) {
+		handleBranchPreview(wi, strings.TrimPrefix(r.URL.Path, branchRoot), w, r)
+	})
+	log.Printf("[%s] registered branch preview root: %s", wi.Name, wi.Host+branchRoot)
+}
+
+// branch preview request.
+// relPath is the branch name followed by the path relative to the wiki root
+func handleBranchPreview(wi *WikiInfo, relPath string, w http.ResponseWriter, r *http.Request) {
+
+	// previews are read-only
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// only available to users of the server or wiki
+	if !branchPreviewAllowed(wi, r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="quiki"`)
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	// find the branch. names may contain slashes, so prefer the longest match
+	names, err := wi.BranchNames()
+	if err != nil {
+		handleError(wi, err, w, r)
+		return
+	}
+	branchName := ""
+	for _, name := range names {
+		if name == "master" || len(name) <= len(branchName) {
+			continue
+		}
+		if relPath == name || strings.HasPrefix(relPath, name+"/") {
+			branchName = name
+		}
+	}
+	if branchName == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	// check out the branch and serve it under this root. pages are not
+	// cached, since the branch is also edited with the wiki's usual roots
+	bw, err := wi.Branch(branchName)
+	if err != nil {
+		handleError(wi, err, w, r)
+		return
+	}
+	bw.Opt.Page.EnableCache = false
+	rebaseRoots(bw, wi.Opt.Root.Wiki+"/_branch/"+branchName)
+	bi := wi.Copy(bw)
+	bi.Title = wi.Title + " (" + branchName + ")"
+
+	// previews should not be cached or indexed
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+
+	// dispatch by root
+	root := bw.Opt.Root
+	for _, item := range []struct {
+		root    string
+		handler func(*WikiInfo, string, http.ResponseWriter, *http.Request)
+	}{
+		{root.Wiki + "/_css", handlePageCSS},
+		{root.Image, handleImage},
+		{root.Category, handleCategoryPosts},
+		{root.Author, handleAuthorPosts},
+		{root.Page, handlePage},
+	} {
+		if item.root != "" && strings.HasPrefix(r.URL.Path, item.root+"/") {
+			item.handler(bi, strings.TrimPrefix(r.URL.Path, item.root+"/"), w, r)
+			return
+		}
+	}
+
+	// the main page and, if the page root is blank, pages
+	handleWikiRoot(bi, w, r)
+}
+
+// returns true if the request is authenticated as a server user or a user of
+// the wiki
+func branchPreviewAllowed(wi *WikiInfo, r *http.Request) bool {
+	if basicAuthUser(r) != nil {
+		return true
+	}
+	username, password, ok := r.BasicAuth()
+	if !ok || wi.Auth == nil {
+		return false
+	}
+	_, err := wi.Auth.Login(username, password)
+	return err == nil
+}
@@ -0,0 +1,243 @@
+package webserver
+
+// Copyright (c) 2020, Mitchell Cooper
+// captcha.go - challenges which protect public forms from spam
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cooper/quiki/wikifier"
+	"github.com/pkg/errors"
+)
+
+// Captcha is a challenge which protects a public form from spam, such as
+// anonymous edit proposals and the adminifier login.
+type Captcha interface {
+
+	// HTML returns the fields to include in the form.
+	HTML() template.HTML
+
+	// CSP returns the sources which the fields need, mapped by
+	// Content-Security-Policy directive.
+	CSP() map[string]string
+
+	// Verify checks the fields of a submitted form.
+	Verify(r *http.Request) bool
+}
+
+// NewCaptcha returns a captcha of the configured provider. A simple math
+// question is used if none is configured.
+func NewCaptcha(opt wikifier.PageOptCaptcha) (Captcha, error) {
+	var c hostedCaptcha
+	switch opt.Provider {
+	case "", "math":
+		return mathCaptcha{}, nil
+	case "hcaptcha":
+		c = hostedCaptcha{
+			script:    "https://js.hcaptcha.com/1/api.js",
+			class:     "h-captcha",
+			field:     "h-captcha-response",
+			verifyURL: "https://api.hcaptcha.com/siteverify",
+			origins:   "https://hcaptcha.com https://*.hcaptcha.com",
+		}
+	case "turnstile":
+		c = hostedCaptcha{
+			script:    "https://challenges.cloudflare.com/turnstile/v0/api.js",
+			class:     "cf-turnstile",
+			field:     "cf-turnstile-response",
+			verifyURL: "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+			origins:   "https://challenges.cloudflare.com",
+		}
+	default:
+		return nil, errors.New("unknown captcha provider: " + opt.Provider)
+	}
+	if opt.SiteKey == "" || opt.SecretKey == "" {
+		return nil, errors.New(opt.Provider + " captcha requires site_key and secret_key")
+	}
+	c.siteKey, c.secretKey = opt.SiteKey, opt.SecretKey
+	return c, nil
+}
+
+// how long a challenge may be answered after it is issued
+const captchaLifetime = 30 * time.Minute
+
+var (
+	// secret which signs challenges. it is generated at startup, so
+	// challenges issued before a restart are no longer accepted
+	captchaSecret = randomBytes(32)
+
+	// tokens which have been answered, mapped to their expiry
+	captchaUsed     = make(map[string]time.Time)
+	captchaUsedLock sync.Mutex
+)
+
+// a simple arithmetic question, answered in the form
+type mathCaptcha struct{}
+
+// a widget of a captcha service, which is verified with the service
+type hostedCaptcha struct {
+	siteKey, secretKey string
+	script             string // widget script
+	class              string // class of the widget element
+	field              string // form field containing the response
+	verifyURL          string // where the response is verified
+	origins            string // sources the widget loads from
+}
+
+// client for verifying hosted captchas
+var captchaClient = &http.Client{Timeout: 10 * time.Second}
+
+// template for math captcha fields
+var mathCaptchaTmpl = template.Must(template.New("captcha").Parse(
+	`<p class="q-p"><label>{{.Question}}<br /><input type="text" name="captcha" size="5" autocomplete="off" /></label></p>
+<input type="hidden" name="captcha_token" value="{{.Token}}" />`))
+
+// a captcha challenge. the answer is not stored; instead, the token
+// contains a signature of it, so no state is kept until it is answered
+type captchaChallenge struct {
+	Question string // question displayed to the user
+	Token    string // submitted along with the answer
+}
+
+func (mathCaptcha) HTML() template.HTML {
+	var b strings.Builder
+	mathCaptchaTmpl.Execute(&b, newCaptcha())
+	return template.HTML(b.String())
+}
+
+func (mathCaptcha) CSP() map[string]string {
+	return nil
+}
+
+func (mathCaptcha) Verify(r *http.Request) bool {
+	return checkCaptcha(r.PostFormValue("captcha_token"), r.PostFormValue("captcha"))
+}
+
+func (c hostedCaptcha) HTML() template.HTML {
+	return template.HTML(`<script src="` + c.script + `" async defer></script>
+<div class="` + c.class + `" data-sitekey="` + template.HTMLEscapeString(c.siteKey) + `"></div>`)
+}
+
+func (c hostedCaptcha) CSP() map[string]string {
+	return map[string]string{
+		"script-src":  c.origins,
+		"frame-src":   "'self' " + c.origins,
+		"style-src":   c.origins,
+		"connect-src": "'self' " + c.origins,
+	}
+}
+
+func (c hostedCaptcha) Verify(r *http.Request) bool {
+	response := r.PostFormValue(c.field)
+	if response == "" {
+		return false
+	}
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+	res, err := captchaClient.PostForm(c.verifyURL, url.Values{
+		"secret":   {c.secretKey},
+		"response": {response},
+		"remoteip": {remote},
+	})
+	if err != nil {
+		log.Println("verify captcha:", err)
+		return false
+	}
+	defer res.Body.Close()
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		log.Println("verify captcha:", err)
+		return false
+	}
+	return result.Success
+}
+
+// creates a new challenge
+func newCaptcha() captchaChallenge {
+	a, b := randomInt(1, 10), randomInt(1, 10)
+	expires := time.Now().Add(captchaLifetime).Unix()
+	payload := strconv.FormatInt(expires, 10) + ":" + hex.EncodeToString(randomBytes(8))
+	return captchaChallenge{
+		Question: fmt.Sprintf("What is %d plus %d?", a, b),
+		Token:    payload + ":" + captchaSignature(payload, a+b),
+	}
+}
+
+// checks the answer to a challenge. each token can only be used once
+func checkCaptcha(token, answer string) bool {
+	split := strings.Split(token, ":")
+	if len(split) != 3 {
+		return false
+	}
+	payload, sig := split[0]+":"+split[1], split[2]
+
+	// expired, or not issued by us
+	expires, err := strconv.ParseInt(split[0], 10, 64)
+	now := time.Now()
+	if err != nil || now.Unix() > expires || expires > now.Add(captchaLifetime).Unix() {
+		return false
+	}
+
+	captchaUsedLock.Lock()
+	defer captchaUsedLock.Unlock()
+
+	// forget tokens which have expired anyway
+	for used, exp := range captchaUsed {
+		if now.After(exp) {
+			delete(captchaUsed, used)
+		}
+	}
+
+	// already used. each token allows only one attempt, so it is used up
+	// even if the answer is wrong
+	if _, used := captchaUsed[payload]; used {
+		return false
+	}
+	captchaUsed[payload] = time.Unix(expires, 0)
+
+	// wrong answer
+	n, err := strconv.Atoi(strings.TrimSpace(answer))
+	return err == nil && hmac.Equal([]byte(sig), []byte(captchaSignature(payload, n)))
+}
+
+func captchaSignature(payload string, answer int) string {
+	mac := hmac.New(sha256.New, captchaSecret)
+	mac.Write([]byte(payload + ":" + strconv.Itoa(answer)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// random integer in [min, max)
+func randomInt(min, max int) int {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max-min)))
+	if err != nil {
+		panic(err)
+	}
+	return min + int(n.Int64())
+}
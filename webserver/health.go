@@ -0,0 +1,102 @@
+package webserver
+
+// Copyright (c) 2020, Mitchell Cooper
+// health.go - health check endpoints for load balancers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cooper/quiki/wiki"
+)
+
+// health check response, sent as JSON to authenticated users
+type healthStatus struct {
+	Status  string                        `json:"status"` // ok or unavailable
+	Started time.Time                     `json:"started"`
+	Wikis   map[string][]wiki.HealthCheck `json:"wikis"`
+}
+
+// time the server was configured
+var startTime = time.Now()
+
+// how long the result of the wiki checks is reused. the checks parse each
+// wiki's configuration and write to its cache, so they are not run for
+// every probe
+const healthCheckInterval = 5 * time.Second
+
+// the last result of the wiki checks
+var healthCache struct {
+	checked time.Time
+	status  string
+	wikis   map[string][]wiki.HealthCheck
+	mu      sync.Mutex
+}
+
+// healthMiddleware answers /healthz and /readyz for all hosts, before any
+// wiki can claim the path.
+//
+// /healthz succeeds as long as the server is responding. /readyz fails with
+// 503 Service Unavailable if any wiki fails its health checks. Both report
+// the result of the checks as JSON to clients which authenticate with HTTP
+// basic authentication as a server user.
+func healthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" && r.URL.Path != "/readyz" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// liveness does not need to check unless details are requested
+		detailed := basicAuthUser(r) != nil
+		status := healthStatus{Status: "ok", Started: startTime}
+		if r.URL.Path == "/readyz" || detailed {
+			status.Status, status.Wikis = checkWikisHealth()
+		}
+
+		code := http.StatusOK
+		if r.URL.Path == "/readyz" && status.Status != "ok" {
+			code = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		if !detailed {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(code)
+			w.Write([]byte(status.Status + "\n"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(status)
+	})
+}
+
+// runs the health checks of all wikis, or returns the previous result if it
+// is recent. concurrent requests wait for the same run
+func checkWikisHealth() (string, map[string][]wiki.HealthCheck) {
+	healthCache.mu.Lock()
+	defer healthCache.mu.Unlock()
+	if time.Since(healthCache.checked) < healthCheckInterval {
+		return healthCache.status, healthCache.wikis
+	}
+
+	status := "ok"
+	wikis := make(map[string][]wiki.HealthCheck, len(Wikis))
+	for name, wi := range Wikis {
+		checks := wi.CheckHealth()
+		for _, check := range checks {
+			if !check.OK {
+				status = "unavailable"
+			}
+		}
+		wikis[name] = checks
+	}
+
+	healthCache.checked = time.Now()
+	healthCache.status = status
+	healthCache.wikis = wikis
+	return status, wikis
+}
@@ -4,15 +4,24 @@ package webserver
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"html/template"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
+	"path"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cooper/quiki/wiki"
 )
 
+var resizeSizeRegex = regexp.MustCompile(`^(\d+)x(\d+)$`)
+
 // master handler
 func handleRoot(w http.ResponseWriter, r *http.Request) {
 	var delayedWiki *WikiInfo
@@ -45,47 +54,68 @@ func handleRoot(w http.ResponseWriter, r *http.Request) {
 
 	// a wiki matches this
 	if delayedWiki != nil {
+		handleWikiRoot(delayedWiki, w, r)
+		return
+	}
 
-		// show the main page for the delayed wiki
-		wikiRoot := delayedWiki.Opt.Root.Wiki
-		mainPage := delayedWiki.Opt.MainPage
-		if mainPage != "" && (r.URL.Path == wikiRoot || r.URL.Path == wikiRoot+"/") {
-
-			// main page redirect is enabled
-			if delayedWiki.Opt.MainRedirect {
-				http.Redirect(
-					w, r,
-					delayedWiki.Opt.Root.Page+
-						"/"+mainPage,
-					http.StatusMovedPermanently,
-				)
-				return
-			}
+	// anything else is a generic 404
+	http.NotFound(w, r)
+}
 
-			// display main page
-			handlePage(delayedWiki, mainPage, w, r)
+// request within a wiki root but not handled by a more specific root
+func handleWikiRoot(wi *WikiInfo, w http.ResponseWriter, r *http.Request) {
+
+	// show the main page
+	wikiRoot := wi.Opt.Root.Wiki
+	mainPage := wi.Opt.MainPage
+	if mainPage != "" && (r.URL.Path == wikiRoot || r.URL.Path == wikiRoot+"/") {
+
+		// main page redirect is enabled
+		if wi.Opt.MainRedirect {
+			http.Redirect(
+				w, r,
+				wi.Opt.Root.Page+
+					"/"+mainPage,
+				http.StatusMovedPermanently,
+			)
 			return
 		}
 
-		// if the page root is blank, this may be a page
-		if delayedWiki.Opt.Root.Page == "" {
-			relPath := strings.TrimLeft(strings.TrimPrefix(r.URL.Path, wikiRoot), "/")
-			handlePage(delayedWiki, relPath, w, r)
-			return
-		}
+		// display main page
+		handlePage(wi, mainPage, w, r)
+		return
+	}
 
-		// show the 404 page for the delayed wiki
-		handleError(delayedWiki, "Page not found.", w, r)
+	// if the page root is blank, this may be a page
+	if wi.Opt.Root.Page == "" {
+		relPath := strings.TrimLeft(strings.TrimPrefix(r.URL.Path, wikiRoot), "/")
+		handlePage(wi, relPath, w, r)
 		return
 	}
 
-	// anything else is a generic 404
-	http.NotFound(w, r)
+	// show the 404 page for the wiki
+	handleError(wi, "Page not found.", w, r)
 }
 
 // page request
 func handlePage(wi *WikiInfo, relPath string, w http.ResponseWriter, r *http.Request) {
-	handleResponse(wi, wi.DisplayPage(relPath), w, r)
+
+	// source of a plain text page
+	if _, ok := r.URL.Query()["download"]; ok {
+		handleResponse(wi, wi.DisplayPageSource(relPath), w, r)
+		return
+	}
+
+	// static mode
+	if wi.static != nil && wi.serveStatic(relPath, w, r) {
+		return
+	}
+
+	res := wi.DisplayPage(relPath)
+	if page, ok := res.(wiki.DisplayPage); ok && wi.static != nil {
+		wi.rememberStatic(page.File)
+	}
+	handleResponse(wi, res, w, r)
 }
 
 // image request
@@ -93,21 +123,92 @@ func handleImage(wi *WikiInfo, relPath string, w http.ResponseWriter, r *http.Re
 	handleResponse(wi, wi.DisplayImage(relPath), w, r)
 }
 
+// resized image request, such as 300x200/myimage.png
+func handleResizedImage(wi *WikiInfo, relPath string, w http.ResponseWriter, r *http.Request) {
+	split := strings.SplitN(relPath, "/", 2)
+	if len(split) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	matches := resizeSizeRegex.FindStringSubmatch(split[0])
+	if matches == nil {
+		http.NotFound(w, r)
+		return
+	}
+	width, _ := strconv.Atoi(matches[1])
+	height, _ := strconv.Atoi(matches[2])
+	res := wi.DisplayResizedImage(width, height, split[1], r.URL.Query().Get("sig"))
+	handleResponse(wi, res, w, r)
+}
+
+// page metadata request
+func handlePageMeta(wi *WikiInfo, relPath string, w http.ResponseWriter, r *http.Request) {
+	if relPath == "" {
+		apiError(w, http.StatusNotFound, "no page specified")
+		return
+	}
+	switch res := wi.DisplayPageMeta(relPath).(type) {
+	case wiki.DisplayPageMeta:
+		apiJSON(w, http.StatusOK, res)
+	case wiki.DisplayError:
+		status := res.Status
+		if status == 0 {
+			status = http.StatusNotFound
+		}
+		apiError(w, status, res.Error)
+	default:
+		apiError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// generated CSS of a page, as linked by templates. the query string is a hash
+// of the CSS, so when it matches, the CSS can be cached indefinitely
+func handlePageCSS(wi *WikiInfo, relPath string, w http.ResponseWriter, r *http.Request) {
+	res, ok := wi.DisplayPage(relPath).(wiki.DisplayPage)
+	if !ok || res.CSS == "" {
+		http.NotFound(w, r)
+		return
+	}
+	hash := pageCSSHash(res.CSS)
+	if r.URL.RawQuery == hash {
+		w.Header().Set("Cache-Control", "max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	w.Header().Set("ETag", `"`+hash+`"`)
+	http.ServeContent(w, r, "", time.Time{}, strings.NewReader(res.CSS))
+}
+
+// short hash of generated page CSS, for its URL
+func pageCSSHash(css string) string {
+	sum := sha256.Sum256([]byte(css))
+	return hex.EncodeToString(sum[:8])
+}
+
 // topic request
 func handleCategoryPosts(wi *WikiInfo, relPath string, w http.ResponseWriter, r *http.Request) {
+	catName, pageN := postsPageN(relPath)
+	handleResponse(wi, wi.DisplayCategoryPosts(catName, pageN), w, r)
+}
+
+// author request
+func handleAuthorPosts(wi *WikiInfo, relPath string, w http.ResponseWriter, r *http.Request) {
+	authorName, pageN := postsPageN(relPath)
+	handleResponse(wi, wi.DisplayAuthorPosts(authorName, pageN), w, r)
+}
 
-	// extract page number from relPath
-	pageN := 0
-	catName := relPath
+// extract page number from relPath, like name/2
+func postsPageN(relPath string) (name string, pageN int) {
+	name = relPath
 	split := strings.SplitN(relPath, "/", 2)
 	if len(split) == 2 {
 		if i, err := strconv.Atoi(split[1]); err == nil {
 			pageN = i - 1
 		}
-		catName = split[0]
+		name = split[0]
 	}
-
-	handleResponse(wi, wi.DisplayCategoryPosts(catName, pageN), w, r)
+	return
 }
 
 func handleResponse(wi *WikiInfo, res interface{}, w http.ResponseWriter, r *http.Request) {
@@ -115,12 +216,26 @@ func handleResponse(wi *WikiInfo, res interface{}, w http.ResponseWriter, r *htt
 
 	// page content
 	case wiki.DisplayPage:
-		renderTemplate(wi, w, "page", wikiPageFromRes(wi, res))
+		page := wikiPageFromRes(wi, res)
+
+		// notes are shown only to editors
+		if res.EditorContent != "" && requestEditor(wi, r) {
+			page.HTMLContent = template.HTML(res.EditorContent)
+			w.Header().Set("Cache-Control", "private, no-store")
+		}
+
+		renderTemplate(wi, w, "page", page)
 
 	// image content
 	case wiki.DisplayImage:
 		http.ServeFile(w, r, res.Path)
 
+	// file download
+	case wiki.DisplayFile:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(res.File)}))
+		http.ServeContent(w, r, res.File, *res.Modified, strings.NewReader(res.Content))
+
 	// posts
 	case wiki.DisplayCategoryPosts:
 
@@ -132,6 +247,10 @@ func handleResponse(wi *WikiInfo, res interface{}, w http.ResponseWriter, r *htt
 		page.Title = res.Title
 		page.PageN = res.PageN + 1
 		page.NumPages = res.NumPages
+		page.PostsRoot = wi.Opt.Root.Category
+		if res.Type == wiki.CategoryTypeAuthor {
+			page.PostsRoot = wi.Opt.Root.Author
+		}
 
 		// add each page result as a wikiPage
 		for _, dispPage := range res.Pages {
@@ -200,8 +319,9 @@ func handleError(wi *WikiInfo, errMaybe interface{}, w http.ResponseWriter, r *h
 
 	if errTmpl := wi.template.template.Lookup("error.tpl"); errTmpl != nil {
 		var buf bytes.Buffer
-		w.WriteHeader(status)
 		page := wikiPageWith(wi)
+		page.CSPNonce = setCSP(wi, w)
+		w.WriteHeader(status)
 		page.Name = "Error"
 		page.Title = "Error"
 		page.Message = msg
@@ -216,7 +336,13 @@ func handleError(wi *WikiInfo, errMaybe interface{}, w http.ResponseWriter, r *h
 }
 
 func renderTemplate(wi *WikiInfo, w http.ResponseWriter, templateName string, dot wikiPage) {
+	renderTemplateWith(wi, w, templateName, dot, nil)
+}
+
+// like renderTemplate, but allows extra Content-Security-Policy sources
+func renderTemplateWith(wi *WikiInfo, w http.ResponseWriter, templateName string, dot wikiPage, csp map[string]string) {
 	var buf bytes.Buffer
+	dot.CSPNonce = setCSPWith(wi, w, csp)
 	err := wi.template.template.ExecuteTemplate(&buf, templateName+".tpl", dot)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -230,16 +356,31 @@ func wikiPageFromRes(wi *WikiInfo, res wiki.DisplayPage) wikiPage {
 	page := wikiPageWith(wi)
 	page.HTMLContent = template.HTML(res.Content)
 	page.PageCSS = template.CSS(res.CSS)
+	if res.CSS != "" {
+		page.PageCSSURL = wi.Opt.Root.Wiki + "/_css/" + (&url.URL{Path: res.File}).EscapedPath() + "?" + pageCSSHash(res.CSS)
+	}
 	page.File = res.File
 	page.Name = res.Name
 	page.Title = res.Title
 	page.Description = res.Description
 	page.Keywords = res.Keywords
 	page.Author = res.Author
+	page.Info = wi.PageInfo(res.File)
+	page.Sections = res.Sections
+	for _, catName := range res.Categories {
+		page.Categories = append(page.Categories, wi.CategoryInfo(catName))
+	}
+	page.Version = wi.Opt.Version.Name
+	page.Versions, page.Canonical = wi.pageVersions(res.Name)
 	return page
 }
 
 func wikiPageWith(wi *WikiInfo) wikiPage {
+	// versions are not served by the API
+	var quickSwitch string
+	if wi.latest == nil {
+		quickSwitch = "/api/wikis/" + url.PathEscape(wi.Name) + "/quickswitch"
+	}
 	return wikiPage{
 		WikiTitle:  wi.Title,
 		WikiLogo:   wi.Logo,
@@ -247,6 +388,9 @@ func wikiPageWith(wi *WikiInfo) wikiPage {
 		Root:       wi.Opt.Root,
 		StaticRoot: wi.template.staticRoot,
 		Navigation: wi.Opt.Navigation,
+		Opt:        newWikiOpt(&wi.Opt),
 		retina:     wi.Opt.Image.Retina,
+
+		QuickSwitch: quickSwitch,
 	}
 }
@@ -0,0 +1,341 @@
+package webserver
+
+// Copyright (c) 2020, Mitchell Cooper
+// jobs.go - scheduled maintenance jobs
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cooper/quiki/wikifier"
+	"github.com/pkg/errors"
+)
+
+// a maintenance job which can be scheduled in the server configuration
+type job struct {
+	name string // name in server.jobs.[name]
+	desc string // description for the dashboard
+	run  func(wi *WikiInfo) (string, error)
+}
+
+// available jobs, in the order they are displayed
+var jobs = []job{
+	{"prune_cache", "Prune cache", func(wi *WikiInfo) (string, error) {
+		n, err := wi.PruneCache()
+		return fmt.Sprintf("%d files deleted", n), err
+	}},
+	{"gc", "Repository garbage collection", func(wi *WikiInfo) (string, error) {
+		return "", wi.GarbageCollect()
+	}},
+	{"check_links", "Check links", func(wi *WikiInfo) (string, error) {
+		broken := wi.BrokenLinks()
+		for _, link := range broken {
+			wi.Logf("broken link on %s: %s", link.Page, link.Target)
+		}
+		return fmt.Sprintf("%d broken links", len(broken)), nil
+	}},
+	{"check_external_links", "Check external links", func(wi *WikiInfo) (string, error) {
+		checked, dead, err := wi.CheckExternalLinks()
+		return fmt.Sprintf("%d links checked, %d dead", checked, dead), err
+	}},
+	{"feed", "Regenerate feed", func(wi *WikiInfo) (string, error) {
+		return "", wi.GenerateFeed()
+	}},
+	{"calendar", "Regenerate calendar", func(wi *WikiInfo) (string, error) {
+		return "", wi.GenerateCalendar()
+	}},
+	{"sitemap", "Refresh sitemap", func(wi *WikiInfo) (string, error) {
+		return "", wi.GenerateSitemap()
+	}},
+	{"backup", "Backup", backupWiki},
+}
+
+// schedules from the server configuration, by job name
+var jobSchedules map[string]string
+
+// JobStatus describes a scheduled job and the result of its last run.
+type JobStatus struct {
+	Name        string        // job name, as in the configuration
+	Description string        // description of the job
+	Schedule    string        // schedule from the configuration, or empty if not scheduled
+	Running     bool          // true if the job is running now
+	LastRun     time.Time     // start time of the last run, or zero if it has not run
+	Duration    time.Duration // duration of the last run
+	NextRun     time.Time     // time of the next run, or zero if not scheduled
+	Result      string        // summary of the last run, if any
+	Error       string        // error from the last run, if any
+}
+
+// wiki job state
+type wikiJobs struct {
+	status []*JobStatus
+	mu     sync.Mutex // protects status
+	run    sync.Mutex // held while a job is running, so jobs run one at a time
+}
+
+// Jobs returns the status of maintenance jobs for the wiki.
+func (wi *WikiInfo) Jobs() []JobStatus {
+	if wi.jobs == nil {
+		return nil
+	}
+	wi.jobs.mu.Lock()
+	defer wi.jobs.mu.Unlock()
+	status := make([]JobStatus, len(wi.jobs.status))
+	for i, s := range wi.jobs.status {
+		status[i] = *s
+	}
+	return status
+}
+
+// read job schedules from the server configuration
+func setupJobs() error {
+	jobSchedules = make(map[string]string)
+	found, _ := Conf.Get("server.jobs")
+	if found == nil {
+		return nil
+	}
+	jobMap, ok := found.(*wikifier.Map)
+	if !ok {
+		return errors.New("server.jobs is not a map")
+	}
+	for _, name := range jobMap.Keys() {
+		if findJob(name) == nil {
+			return errors.New("server.jobs." + name + ": no such job")
+		}
+		str, err := Conf.GetStr("server.jobs." + name)
+		if err != nil {
+			return err
+		}
+		str = strings.TrimSpace(str)
+		if str == "" || str == "off" {
+			continue
+		}
+		if _, err := parseSchedule(str); err != nil {
+			return errors.Wrap(err, "server.jobs."+name)
+		}
+		jobSchedules[name] = str
+	}
+	return nil
+}
+
+func findJob(name string) *job {
+	for i := range jobs {
+		if jobs[i].name == name {
+			return &jobs[i]
+		}
+	}
+	return nil
+}
+
+// start scheduled jobs for a wiki
+func startJobs(wi *WikiInfo) {
+	for i := range jobs {
+		j := &jobs[i]
+		status := &JobStatus{Name: j.name, Description: j.desc}
+		wi.jobs.status = append(wi.jobs.status, status)
+
+		schedStr := jobSchedules[j.name]
+		if schedStr == "" {
+			continue
+		}
+		sched, _ := parseSchedule(schedStr) // already validated
+		status.Schedule = schedStr
+		status.NextRun = sched.next(time.Now())
+		go runJobLoop(wi, j, status, sched)
+		log.Printf("[%s] scheduled job %s: %s", wi.Name, j.name, schedStr)
+	}
+}
+
+func runJobLoop(wi *WikiInfo, j *job, status *JobStatus, sched schedule) {
+	for {
+		wi.jobs.mu.Lock()
+		next := status.NextRun
+		wi.jobs.mu.Unlock()
+		time.Sleep(time.Until(next))
+
+		// run
+		wi.jobs.run.Lock()
+		start := time.Now()
+		wi.jobs.mu.Lock()
+		status.Running = true
+		wi.jobs.mu.Unlock()
+
+		result, err := j.run(wi)
+
+		wi.jobs.mu.Lock()
+		status.Running = false
+		status.LastRun = start
+		status.Duration = time.Since(start)
+		status.Result = result
+		status.Error = ""
+		if err != nil {
+			status.Error = err.Error()
+			wi.Logf("job %s failed: %v", j.name, err)
+		}
+		status.NextRun = sched.next(time.Now())
+		wi.jobs.mu.Unlock()
+		wi.jobs.run.Unlock()
+	}
+}
+
+// a schedule determines when a job runs next
+type schedule interface {
+	next(after time.Time) time.Time
+}
+
+// runs at a fixed interval
+type intervalSchedule time.Duration
+
+func (s intervalSchedule) next(after time.Time) time.Time {
+	return after.Add(time.Duration(s))
+}
+
+// runs at times matching a cron expression
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64 // bit n is set if n matches
+	domAny, dowAny                bool   // day of month or week is *
+}
+
+// cron shorthands
+var cronDescriptors = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+	"@yearly":  "0 0 1 1 *",
+}
+
+// parses a schedule, which is either a duration such as "6h" or a cron
+// expression such as "30 4 * * *" or "@daily"
+func parseSchedule(str string) (schedule, error) {
+	if d, err := time.ParseDuration(str); err == nil {
+		if d < time.Minute {
+			return nil, errors.New("interval must be at least one minute")
+		}
+		return intervalSchedule(d), nil
+	}
+	if expr, ok := cronDescriptors[str]; ok {
+		str = expr
+	}
+
+	fields := strings.Fields(str)
+	if len(fields) != 5 {
+		return nil, errors.New("schedule must be a duration or a cron expression with 5 fields")
+	}
+	var s cronSchedule
+	var err error
+	for i, f := range []struct {
+		bits     *uint64
+		min, max int
+	}{
+		{&s.minute, 0, 59},
+		{&s.hour, 0, 23},
+		{&s.dom, 1, 31},
+		{&s.month, 1, 12},
+		{&s.dow, 0, 7},
+	} {
+		if *f.bits, err = parseCronField(fields[i], f.min, f.max); err != nil {
+			return nil, errors.Wrapf(err, "field %d", i+1)
+		}
+	}
+
+	// 7 is also sunday
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domAny = fields[2] == "*"
+	s.dowAny = fields[4] == "*"
+	if s.next(time.Now()).IsZero() {
+		return nil, errors.New("schedule never matches")
+	}
+	return s, nil
+}
+
+// parses a cron field consisting of comma-separated values, ranges, and
+// steps, such as "*/15" or "1-5,10"
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.IndexByte(part, '/'); i != -1 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return 0, errors.New("invalid step: " + part)
+			}
+			step, part = n, part[:i]
+		}
+
+		start, end := min, max
+		if part != "*" {
+			split := strings.SplitN(part, "-", 2)
+			var err error
+			if start, err = strconv.Atoi(split[0]); err != nil {
+				return 0, errors.New("invalid value: " + part)
+			}
+			end = start
+			if len(split) == 2 {
+				if end, err = strconv.Atoi(split[1]); err != nil {
+					return 0, errors.New("invalid value: " + part)
+				}
+			} else if step != 1 {
+				end = max
+			}
+		}
+		if start < min || end > max || start > end {
+			return 0, errors.New("out of range: " + part)
+		}
+
+		for n := start; n <= end; n += step {
+			bits |= 1 << uint(n)
+		}
+	}
+	return bits, nil
+}
+
+func (s cronSchedule) next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+
+	// give up after five years, which is only possible for dates like
+	// February 30th. parseSchedule rejects these
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// like cron, if both the day of month and day of week are restricted, a day
+// matching either one matches
+func (s cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	}
+	return dom || dow
+}
@@ -0,0 +1,158 @@
+package webserver
+
+// Copyright (c) 2020, Mitchell Cooper
+// propose.go - anonymous edit proposals
+
+import (
+	"bytes"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/cooper/quiki/wiki"
+)
+
+// maximum size of a proposal request body
+const maxProposeSize = 1 << 20
+
+// maximum length of a contributor name
+const maxProposeName = 64
+
+// the form is rendered into the page template as content, so that
+// proposals work with any template
+var proposeTmpl = template.Must(template.New("propose").Parse(`<div class="q-main">
+<div class="q-sec">
+<h1 class="q-sec-page-title">Edit {{.Page}}</h1>
+{{if .Done}}
+<p class="q-p">Thank you! Your changes will appear once approved by an editor.</p>
+<p class="q-p"><a href="{{.PageURL}}">Return to {{.Page}}</a></p>
+{{else}}
+<p class="q-p">Changes you propose will be reviewed by an editor before they appear on the wiki.</p>
+{{with .Message}}<p class="q-p q-propose-message"><strong>{{.}}</strong></p>{{end}}
+<form class="q-propose" method="post" action="{{.Action}}">
+<p class="q-p"><textarea name="content" rows="25" cols="80">{{.Content}}</textarea></p>
+<p class="q-p"><label>Summary of changes<br /><input type="text" name="message" size="60" value="{{.Comment}}" /></label></p>
+<p class="q-p"><label>Your name (optional)<br /><input type="text" name="name" size="30" maxlength="64" value="{{.Name}}" /></label></p>
+{{.Captcha}}
+<p class="q-p"><input type="submit" value="Propose changes" /></p>
+</form>
+{{end}}
+</div>
+</div>`))
+
+type proposeForm struct {
+	Page    string // page name, without extension
+	PageURL string // link to the page
+	Action  string // form target
+	Content string // page source
+	Comment string // summary of changes
+	Name    string // contributor name
+	Message string // error message, if any
+	Done    bool   // true if the change was proposed
+	Captcha template.HTML
+}
+
+// anonymous edit proposal request.
+// relPath is the page name, relative to the propose root
+func handlePropose(wi *WikiInfo, relPath string, w http.ResponseWriter, r *http.Request) {
+
+	// only available when moderation and anonymous proposals are enabled
+	if !wi.Opt.Review.Enable || !wi.Opt.Review.Anonymous {
+		http.NotFound(w, r)
+		return
+	}
+
+	// clean the name so it cannot leave the page directory
+	name := strings.TrimPrefix(path.Clean("/"+relPath), "/")
+	if name == "" {
+		http.NotFound(w, r)
+		return
+	}
+	page := wi.FindPage(name)
+	file := path.Join("pages", page.Name())
+	captcha, err := NewCaptcha(wi.Opt.Captcha)
+	if err != nil {
+		log.Printf("[%s] %v", wi.Name, err)
+		http.Error(w, "Captcha is misconfigured", http.StatusInternalServerError)
+		return
+	}
+
+	form := proposeForm{
+		Page:    page.NameNE(),
+		PageURL: wi.Opt.Root.Page + "/" + page.NameNE(),
+		Action:  r.URL.Path,
+	}
+
+	switch r.Method {
+
+	// show the current source
+	case http.MethodGet, http.MethodHead:
+		if content, err := ioutil.ReadFile(page.Path()); err == nil {
+			form.Content = string(content)
+		}
+
+	// propose the change
+	case http.MethodPost:
+		r.Body = http.MaxBytesReader(w, r.Body, maxProposeSize)
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		form.Content = r.PostForm.Get("content")
+		form.Comment = strings.TrimSpace(r.PostForm.Get("message"))
+		form.Name = strings.TrimSpace(r.PostForm.Get("name"))
+		if len(form.Name) > maxProposeName {
+			form.Name = form.Name[:maxProposeName]
+		}
+		form.Message, form.Done = proposeAnonymous(wi, file, form, captcha, r)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// render the form into the page template
+	var buf bytes.Buffer
+	form.Captcha = captcha.HTML()
+	if err := proposeTmpl.Execute(&buf, form); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	dot := wikiPageWith(wi)
+	dot.Name = page.NameNE()
+	dot.Title = "Edit " + page.NameNE()
+	dot.HTMLContent = template.HTML(buf.String())
+	w.Header().Set("Cache-Control", "no-store")
+	renderTemplateWith(wi, w, "page", dot, captcha.CSP())
+}
+
+// checks the captcha and proposes the change. returns a message to display
+// if it could not be proposed, or true if successful
+func proposeAnonymous(wi *WikiInfo, file string, form proposeForm, captcha Captcha, r *http.Request) (string, bool) {
+	if !captcha.Verify(r) {
+		return "The captcha was not solved. Please try again.", false
+	}
+
+	// anonymous users are subject to access rules like any other user
+	// without group membership
+	commit := wiki.CommitOpts{
+		Comment: form.Comment,
+		Name:    form.Name,
+	}
+	if commit.Comment == "" {
+		commit.Comment = "Anonymous edit"
+	}
+	if commit.Name == "" {
+		commit.Name = "Anonymous"
+	}
+
+	rev, err := wi.ProposeFile(file, []byte(form.Content), commit)
+	if err != nil {
+		return "Your changes could not be proposed: " + err.Error(), false
+	}
+	log.Printf("[%s] anonymous edit to %s proposed from %s: review %s", wi.Name, file, r.RemoteAddr, rev.ID)
+	return "", true
+}
@@ -0,0 +1,181 @@
+package webserver
+
+// Copyright (c) 2020, Mitchell Cooper
+// security.go - security-related HTTP response headers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/cooper/quiki/wikifier"
+)
+
+// default Content-Security-Policy directives for pages rendered by templates.
+//
+// scripts and styles are restricted to this origin, plus the per-response
+// nonce which permits <style> blocks in templates, such as for the combined
+// CSS of category posts. generated page CSS is otherwise linked from
+// [root.wiki]/_css/. inline style attributes are still allowed because the
+// wikifier emits them for text formatting and, unless
+// page.enable.scoped_styles is set, for image sizing.
+var defaultCSP = map[string]string{
+	"default-src":     "'self'",
+	"script-src":      "'self'",
+	"style-src":       "'self'",
+	"style-src-attr":  "'unsafe-inline'",
+	"img-src":         "'self' data:",
+	"font-src":        "'self' data:",
+	"object-src":      "'none'",
+	"base-uri":        "'self'",
+	"frame-ancestors": "'self'",
+}
+
+// security headers which are sent with every response
+var securityHeaders = map[string]string{
+	"X-Content-Type-Options": "nosniff",
+	"X-Frame-Options":        "SAMEORIGIN",
+	"Referrer-Policy":        "strict-origin-when-cross-origin",
+}
+
+// whether to send Content-Security-Policy with template responses
+var enableCSP = true
+
+// configure security headers from server.http.* options
+func setupSecurity() {
+
+	// -@server.http.enable.csp disables Content-Security-Policy
+	if val, _ := Conf.Get("server.http.enable.csp"); val == false {
+		enableCSP = false
+	}
+
+	// server.http.csp.[directive] overrides a directive. underscores in the
+	// directive name are replaced with hyphens, e.g. img_src -> img-src
+	if obj, _ := Conf.GetObj("server.http.csp"); obj != nil {
+		if cspMap, ok := obj.(*wikifier.Map); ok {
+			for _, key := range cspMap.Keys() {
+				value, err := cspMap.GetStr(key)
+				if err != nil {
+					continue
+				}
+				directive := strings.Replace(key, "_", "-", -1)
+				if value == "" {
+					delete(defaultCSP, directive)
+					continue
+				}
+				defaultCSP[directive] = value
+			}
+		}
+	}
+
+	// other headers. an empty value disables the header
+	for key, header := range map[string]string{
+		"server.http.frame_options":   "X-Frame-Options",
+		"server.http.referrer_policy": "Referrer-Policy",
+	} {
+		val, _ := Conf.Get(key)
+		if val == nil {
+			continue
+		}
+		value, _ := Conf.GetStr(key)
+		if value == "" {
+			delete(securityHeaders, header)
+			continue
+		}
+		securityHeaders[header] = value
+	}
+
+	// -@server.http.enable.nosniff disables X-Content-Type-Options
+	if val, _ := Conf.Get("server.http.enable.nosniff"); val == false {
+		delete(securityHeaders, "X-Content-Type-Options")
+	}
+}
+
+// securityMiddleware adds the static security headers to every response
+func securityMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for header, value := range securityHeaders {
+			w.Header().Set(header, value)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setCSP generates a nonce and sets the Content-Security-Policy header for a
+// template response, including any sources requested by the template manifest.
+// it returns the nonce, or an empty string if CSP is disabled
+func setCSP(wi *WikiInfo, w http.ResponseWriter) string {
+	return setCSPWith(wi, w, nil)
+}
+
+// setCSPWith is like setCSP but also allows extra sources needed by a
+// particular response, mapped by directive name
+func setCSPWith(wi *WikiInfo, w http.ResponseWriter, extra map[string]string) string {
+	if !enableCSP {
+		return ""
+	}
+
+	// generate nonce
+	nonceBytes := make([]byte, 16)
+	if _, err := rand.Read(nonceBytes); err != nil {
+		return ""
+	}
+	nonce := base64.StdEncoding.EncodeToString(nonceBytes)
+
+	// copy defaults
+	directives := make(map[string]string, len(defaultCSP))
+	for directive, sources := range defaultCSP {
+		directives[directive] = sources
+	}
+
+	// add sources from the template
+	if wi != nil {
+		for directive, sources := range wi.template.manifest.CSP {
+			if existing := directives[directive]; existing != "" {
+				sources = existing + " " + sources
+			}
+			directives[directive] = sources
+		}
+	}
+
+	// allow iframes from the hosts permitted in raw html
+	if wi != nil && wi.Opt.HTML.Sanitize && len(wi.Opt.HTML.IframeHosts) != 0 {
+		sources := directives["frame-src"]
+		if sources == "" {
+			sources = "'self'"
+		}
+		for _, host := range wi.Opt.HTML.IframeHosts {
+			sources += " https://" + host
+		}
+		directives["frame-src"] = sources
+	}
+
+	// add sources for this response
+	for directive, sources := range extra {
+		if existing := directives[directive]; existing != "" {
+			sources = existing + " " + sources
+		}
+		directives[directive] = sources
+	}
+
+	// allow the nonce for scripts and styles
+	for _, directive := range []string{"script-src", "style-src"} {
+		directives[directive] = strings.TrimSpace(directives[directive] + " 'nonce-" + nonce + "'")
+	}
+
+	// build the policy in a consistent order
+	names := make([]string, 0, len(directives))
+	for directive := range directives {
+		names = append(names, directive)
+	}
+	sort.Strings(names)
+	policy := make([]string, len(names))
+	for i, directive := range names {
+		policy[i] = directive + " " + directives[directive]
+	}
+
+	w.Header().Set("Content-Security-Policy", strings.Join(policy, "; "))
+	return nonce
+}
@@ -0,0 +1,69 @@
+package webserver
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/cooper/quiki/wiki"
+)
+
+// reveal.js distribution used to present slides, without trailing slash.
+// the version is pinned so that the files do not change beneath us. set
+// server.slides.reveal_js to serve it from elsewhere, such as locally
+var revealJS = "https://cdn.jsdelivr.net/npm/reveal.js@5.1.0/dist"
+
+// page presented as slides
+func handleSlides(wi *WikiInfo, relPath string, w http.ResponseWriter, r *http.Request) {
+	if relPath == "" {
+		http.NotFound(w, r)
+		return
+	}
+	res := wi.DisplaySlides(relPath)
+	slides, ok := res.(wiki.DisplaySlides)
+	if !ok {
+		handleResponse(wi, res, w, r)
+		return
+	}
+
+	page := wikiPageFromRes(wi, slides.DisplayPage)
+	page.RevealJS = revealJS
+	for _, slide := range slides.Slides {
+		page.Slides = append(page.Slides, template.HTML(slide))
+	}
+
+	// use the default slides template if the wiki template has none
+	tmpl := wi.template
+	if tmpl.template.Lookup("slides.tpl") == nil {
+		def, err := findTemplate("default")
+		if err != nil || def.template.Lookup("slides.tpl") == nil {
+			http.Error(w, "template has no slides.tpl", http.StatusInternalServerError)
+			return
+		}
+		tmpl = def
+	}
+
+	// allow reveal.js if it is served from elsewhere. only its directory is
+	// allowed, not the whole host, since CDNs serve many other packages
+	var extra map[string]string
+	if u, err := url.Parse(revealJS); err == nil && u.Host != "" {
+		source := u.Scheme + "://" + u.Host + strings.TrimSuffix(u.EscapedPath(), "/") + "/"
+		extra = map[string]string{
+			"script-src": source,
+			"style-src":  source,
+			"font-src":   source,
+		}
+	}
+
+	var buf bytes.Buffer
+	page.CSPNonce = setCSPWith(wi, w, extra)
+	if err := tmpl.template.ExecuteTemplate(&buf, "slides.tpl", page); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Length", strconv.FormatInt(int64(buf.Len()), 10))
+	w.Write(buf.Bytes())
+}
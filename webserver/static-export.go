@@ -0,0 +1,179 @@
+package webserver
+
+// Copyright (c) 2020, Mitchell Cooper
+// static-export.go - serve pages from exported static files
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/cooper/quiki/wiki"
+	"github.com/cooper/quiki/wikifier"
+)
+
+// stands in for the CSP nonce in static files. the nonce attributes are
+// replaced with a new nonce each time a file is served
+const staticNonce = "quiki-static-nonce"
+
+var staticNonceAttr = []byte(`nonce="` + staticNonce + `"`)
+
+// static export of a wiki's pages, which are served from
+// cache/static/[page].html and re-exported in the background when they are
+// regenerated
+type wikiStatic struct {
+	mu      sync.Mutex
+	files   map[string]string // page filename for each FileNE
+	pending map[string]bool   // pages to export
+	wake    chan struct{}
+}
+
+// enables static mode for a wiki, exporting each page as it is generated
+func setupStaticExport(wi *WikiInfo) {
+	wi.static = &wikiStatic{
+		files:   make(map[string]string),
+		pending: make(map[string]bool),
+		wake:    make(chan struct{}, 1),
+	}
+	wi.AddPageHook(wiki.HookAfterWrite, func(w *wiki.Wiki, page *wikifier.Page, r *wiki.DisplayPage) {
+		wi.static.queue(r.File)
+	})
+	go wi.exportStaticLoop()
+
+	// export the published pages now, and serve them at their usual paths
+	for _, info := range wi.Pages() {
+		if info.Draft || info.Redirect != "" || info.Error != nil {
+			continue
+		}
+		wi.static.files[info.FileNE] = info.File
+		wi.static.queue(info.File)
+	}
+	log.Printf("[%s] serving pages from static files in %s", wi.Name, wi.staticDir())
+}
+
+// schedules a page to be exported
+func (s *wikiStatic) queue(file string) {
+	s.mu.Lock()
+	s.pending[file] = true
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// exports pages as they are queued. pages are displayed again when exported,
+// so the most recent version is always written
+func (wi *WikiInfo) exportStaticLoop() {
+	for range wi.static.wake {
+		wi.static.mu.Lock()
+		pending := wi.static.pending
+		wi.static.pending = make(map[string]bool)
+		wi.static.mu.Unlock()
+		for file := range pending {
+			if err := wi.exportStatic(file); err != nil {
+				log.Printf("[%s] export %s: %v", wi.Name, file, err)
+			}
+		}
+	}
+}
+
+// writes the static file for a page, or removes it if the page is not
+// displayed as a page, such as a draft or redirect
+func (wi *WikiInfo) exportStatic(file string) error {
+	staticPath := filepath.Join(wi.staticDir(), filepath.FromSlash(file)+".html")
+	res, ok := wi.DisplayPage(file).(wiki.DisplayPage)
+	if !ok {
+		if err := os.Remove(staticPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	var buf bytes.Buffer
+	page := wikiPageFromRes(wi, res)
+	page.CSPNonce = staticNonce
+	if err := wi.template.template.ExecuteTemplate(&buf, "page.tpl", page); err != nil {
+		return err
+	}
+
+	// write atomically, so a partial file is never served
+	wikifier.MakeDir(wi.staticDir(), filepath.FromSlash(file))
+	tmp, err := ioutil.TempFile(filepath.Dir(staticPath), ".export-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	os.Chmod(tmp.Name(), 0644)
+	return os.Rename(tmp.Name(), staticPath)
+}
+
+// serves a page from its static file, if it has been exported since the page
+// was last generated and the cached page is still current. returns false if
+// the page must be displayed instead
+func (wi *WikiInfo) serveStatic(relPath string, w http.ResponseWriter, r *http.Request) bool {
+	wi.static.mu.Lock()
+	file := wi.static.files[wi.Opt.PageNameNE(relPath)]
+	wi.static.mu.Unlock()
+	if file == "" {
+		return false
+	}
+
+	// editors may see notes which are not in the static file
+	if requestEditor(wi, r) {
+		return false
+	}
+
+	staticPath := filepath.Join(wi.staticDir(), filepath.FromSlash(file)+".html")
+	staticFi, err := os.Stat(staticPath)
+	if err != nil {
+		return false
+	}
+
+	// the page, something it depends on, or the pages it lists have changed,
+	// so it will be generated and exported again
+	cacheModify := wi.CachedPageModified(file)
+	if cacheModify == nil || cacheModify.After(staticFi.ModTime()) {
+		return false
+	}
+	content, err := ioutil.ReadFile(staticPath)
+	if err != nil {
+		return false
+	}
+
+	nonce := []byte(`nonce="` + setCSP(wi, w) + `"`)
+	content = bytes.ReplaceAll(content, staticNonceAttr, nonce)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.Write(content)
+	return true
+}
+
+// remembers a displayed page, so that later requests for it can be served
+// from its static file
+func (wi *WikiInfo) rememberStatic(file string) {
+	fileNE := wi.Opt.PageNameNE(file)
+	wi.static.mu.Lock()
+	known := wi.static.files[fileNE] == file
+	wi.static.files[fileNE] = file
+	wi.static.mu.Unlock()
+	if !known {
+		wi.static.queue(file)
+	}
+}
+
+func (wi *WikiInfo) staticDir() string {
+	return filepath.Join(wi.Opt.Dir.Cache, "static")
+}
@@ -14,6 +14,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/cooper/quiki/wiki"
 	"github.com/cooper/quiki/wikifier"
 )
 
@@ -55,6 +56,10 @@ type wikiTemplate struct {
 			Height int
 			Width  int
 		}
+
+		// additional Content-Security-Policy sources needed by the template,
+		// mapped by directive name, e.g. "img-src": "https://cdn.example.com"
+		CSP map[string]string
 	}
 }
 
@@ -174,6 +179,10 @@ type wikiPage struct {
 	Description string                       // page description
 	Keywords    []string                     // page keywords
 	Author      string                       // page author
+	Info        wikifier.PageInfo            // all page info, such as created and modified times
+	Categories  []wiki.CategoryInfo          // categories the page belongs to
+	Opt         *wikiOpt                     // wiki options for display
+	Sections    []wikifier.SectionInfo       // every section, with word counts
 	WikiTitle   string                       // wiki titled
 	WikiLogo    string                       // path to wiki logo image (deprecated, use Logo)
 	WikiRoot    string                       // wiki HTTP root (deprecated, use Root.Wiki)
@@ -182,13 +191,45 @@ type wikiPage struct {
 	Pages       []wikiPage                   // more pages for category posts
 	Message     string                       // message for error page
 	Navigation  []wikifier.PageOptNavigation // slice of nav items
+	Version     string                       // name of the wiki version
+	Versions    []wikiVersion                // versions for a version switcher, if any
+	Canonical   string                       // canonical URL of the page, if another version
 	PageN       int                          // for category posts, the page number (first page = 1)
 	NumPages    int                          // for category posts, the number of pages
+	PostsRoot   string                       // for category posts, the root for page numbers
 	PageCSS     template.CSS                 // css
+	PageCSSURL  string                       // url of the css, with a hash of its content
+	CSPNonce    string                       // nonce for inline <style> and <script>
 	HTMLContent template.HTML                // html
+	QuickSwitch string                       // quick switcher API endpoint, if available
+	Slides      []template.HTML              // for slides, html of each slide
+	RevealJS    string                       // for slides, reveal.js distribution root
 	retina      []int                        // retina scales for logo
 }
 
+// wikiOpt is the part of the wiki options available to page templates.
+// templates can be edited by users, so options like CDN keys and webhook
+// URLs are not included
+type wikiOpt struct {
+	Name       string                       // wiki name
+	Logo       string                       // logo filename, relative to image dir
+	MainPage   string                       // name of main page
+	Host       wikifier.PageOptHost         // HTTP hosts
+	Root       wikifier.PageOptRoot         // all roots
+	Navigation []wikifier.PageOptNavigation // slice of nav items
+}
+
+func newWikiOpt(opt *wikifier.PageOpt) *wikiOpt {
+	return &wikiOpt{
+		Name:       opt.Name,
+		Logo:       opt.Logo,
+		MainPage:   opt.MainPage,
+		Host:       opt.Host,
+		Root:       opt.Root,
+		Navigation: opt.Navigation,
+	}
+}
+
 func (p wikiPage) VisibleTitle() string {
 	if p.WholeTitle != "" {
 		return p.WholeTitle
@@ -199,6 +240,15 @@ func (p wikiPage) VisibleTitle() string {
 	return p.Title + " - " + p.WikiTitle
 }
 
+// AuthorLink returns the link to the pages by the page author, or an empty
+// string if the page has no author.
+func (p wikiPage) AuthorLink() string {
+	if p.Author == "" || p.Opt == nil {
+		return ""
+	}
+	return p.Opt.Root.Author + "/" + wikifier.CategoryNameNE(p.Author)
+}
+
 func (p wikiPage) Scripts() []string {
 	return []string{
 		"/static/ext/mootools.min.js",
@@ -0,0 +1,105 @@
+package webserver
+
+// version.go - serve versions of a wiki, such as documentation for older
+// releases, under the wiki root
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/cooper/quiki/monitor"
+	"github.com/cooper/quiki/wiki"
+)
+
+// wikiVersion represents a version of the wiki in a template version switcher.
+type wikiVersion struct {
+	Name    string // version name
+	Link    string // the page in this version, or the version's wiki root
+	Current bool   // true if this is the version being viewed
+}
+
+// set up each version listed in version.list at <root.wiki>/<version>/
+func setupVersions(wi *WikiInfo) error {
+	wikis, err := wi.VersionWikis()
+	if err != nil {
+		return err
+	}
+
+	for i, vw := range wikis {
+		name := wi.Opt.Version.List[i].Name
+		rebaseRoots(vw, wi.Opt.Root.Wiki+"/"+name)
+
+		// versions are named by this wiki and have no versions of their own
+		vw.Opt.Version.Name = name
+		vw.Opt.Version.List = nil
+
+		vi := wi.Copy(vw)
+		vi.latest = wi
+		vw.Pregenerate()
+		go monitor.WatchWiki(vw)
+		if err := setupWiki(vi); err != nil {
+			return err
+		}
+
+		// the main page and, if the page root is blank, pages
+		Mux.HandleFunc(vi.Host+vw.Opt.Root.Wiki+"/", func(w http.ResponseWriter, r *http.Request) {
+			handleWikiRoot(vi, w, r)
+		})
+		log.Printf("[%s] registered version %s: %s/", wi.Name, name, vi.Host+vw.Opt.Root.Wiki)
+
+		wi.versions = append(wi.versions, vi)
+	}
+	return nil
+}
+
+// moves all HTTP roots of a wiki under a new wiki root. blank roots stay
+// blank, since they mean pages at the wiki root or no file index
+func rebaseRoots(w *wiki.Wiki, wikiRoot string) {
+	root := &w.Opt.Root
+	for _, ptr := range []*string{&root.Image, &root.Category, &root.Author, &root.Page, &root.File} {
+		if *ptr != "" {
+			*ptr = wikiRoot + strings.TrimPrefix(*ptr, root.Wiki)
+		}
+	}
+	root.Wiki = wikiRoot
+}
+
+// versions of the wiki for the version switcher, linking to the named page
+// where it exists. if viewing a version other than the latest, canonical is
+// the URL of the page in the latest version
+func (wi *WikiInfo) pageVersions(name string) (versions []wikiVersion, canonical string) {
+	latest := wi
+	if wi.latest != nil {
+		latest = wi.latest
+	}
+	if len(latest.versions) == 0 {
+		return
+	}
+
+	for _, v := range append([]*WikiInfo{latest}, latest.versions...) {
+		version := wikiVersion{
+			Name:    v.Opt.Version.Name,
+			Link:    v.Opt.Root.Wiki + "/",
+			Current: v == wi,
+		}
+		if name != "" && v.FindPage(name).Exists() {
+			version.Link = v.pageURL(name)
+		}
+		versions = append(versions, version)
+	}
+
+	if wi != latest && name != "" && latest.FindPage(name).Exists() {
+		canonical = latest.Opt.Root.Ext + latest.pageURL(name)
+	}
+	return
+}
+
+// HTTP path to a page
+func (wi *WikiInfo) pageURL(name string) string {
+	pageRoot := wi.Opt.Root.Page
+	if !strings.HasPrefix(pageRoot, wi.Opt.Root.Wiki) {
+		pageRoot = wi.Opt.Root.Wiki + pageRoot
+	}
+	return pageRoot + "/" + name
+}
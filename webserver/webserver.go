@@ -11,9 +11,11 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/cooper/quiki/authenticator"
+	"github.com/cooper/quiki/extension"
 	"github.com/cooper/quiki/wikifier"
 	"github.com/pkg/errors"
 )
@@ -79,11 +81,42 @@ func Configure(confFile string) {
 		*ptr = str
 	}
 
+	// security headers
+	setupSecurity()
+
+	// access log
+	if err = setupAccessLog(); err != nil {
+		log.Fatal(errors.Wrap(err, "setup access log"))
+	}
+
+	// reveal.js for slides
+	if str, _ := Conf.GetStr("server.slides.reveal_js"); str != "" {
+		revealJS = strings.TrimSuffix(str, "/")
+	}
+
 	// normalize paths
 	templateDirs = filepath.FromSlash(templateDirs)
 	dirResource = filepath.FromSlash(dirResource)
 	dirStatic := filepath.Join(dirResource, "webserver", "static")
 
+	// load extensions
+	if found, _ := Conf.Get("server.extensions"); found != nil {
+		extPaths, err := Conf.GetStrList("server.extensions")
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "server.extensions"))
+		}
+		for _, path := range extPaths {
+			if err = extension.Load(filepath.FromSlash(path)); err != nil {
+				log.Fatal(errors.Wrap(err, "load extension "+path))
+			}
+		}
+	}
+
+	// read job schedules
+	if err = setupJobs(); err != nil {
+		log.Fatal(errors.Wrap(err, "setup jobs"))
+	}
+
 	// set up wikis
 	if err = initWikis(); err != nil {
 		log.Fatal(errors.Wrap(err, "init wikis"))
@@ -94,12 +127,20 @@ func Configure(confFile string) {
 		log.Fatal(errors.Wrap(err, "setup static"))
 	}
 
+	// setup routes provided by extensions
+	for _, ext := range extension.Extensions() {
+		for pattern, handler := range ext.Routes {
+			Mux.Handle(pattern, handler)
+			log.Printf("[%s] registered extension route: %s", ext.Name, pattern)
+		}
+	}
+
 	// create session manager
 	SessMgr = scs.New()
 
 	// create server with main handler
 	Mux.HandleFunc("/", handleRoot)
-	Server = &http.Server{Handler: SessMgr.LoadAndSave(Mux)}
+	Server = &http.Server{Handler: accessLogMiddleware(securityMiddleware(healthMiddleware(apiMiddleware(SessMgr.LoadAndSave(Mux)))))}
 
 	// create authenticator
 	Auth, err = authenticator.Open(filepath.Join(filepath.Dir(confFile), "quiki-auth.json"))
@@ -22,6 +22,10 @@ type WikiInfo struct {
 	Logo     string
 	Host     string
 	template wikiTemplate
+	jobs     *wikiJobs
+	static   *wikiStatic // static export, if enabled
+	versions []*WikiInfo // versions served alongside this wiki
+	latest   *WikiInfo   // for a version, the wiki it is a version of
 	*wiki.Wiki
 }
 
@@ -105,7 +109,7 @@ func initWikis() error {
 		}
 
 		// create wiki info for webserver
-		wi := &WikiInfo{Wiki: w, Host: wikiHost, Name: wikiName}
+		wi := &WikiInfo{Wiki: w, Host: wikiHost, Name: wikiName, jobs: new(wikiJobs)}
 
 		// initialize git repsitory
 		log.Println(w.BranchNames())
@@ -121,6 +125,22 @@ func initWikis() error {
 			return err
 		}
 
+		// serve pages from static files
+		if static, _ := Conf.GetBool(configPfx + ".static"); static {
+			setupStaticExport(wi)
+		}
+
+		// serve other versions of the wiki
+		if err := setupVersions(wi); err != nil {
+			return err
+		}
+
+		// serve previews of branches
+		setupBranchPreviews(wi)
+
+		// schedule maintenance jobs
+		startJobs(wi)
+
 		Wikis[wikiName] = wi
 	}
 
@@ -198,6 +218,11 @@ func setupWiki(wi *WikiInfo) error {
 			root:     wi.Opt.Root.Category,
 			handler:  handleCategoryPosts,
 		},
+		{
+			rootType: "author",
+			root:     wi.Opt.Root.Author,
+			handler:  handleAuthorPosts,
+		},
 	}
 
 	// setup handlers
@@ -249,6 +274,55 @@ func setupWiki(wi *WikiInfo) error {
 		log.Printf("[%s] registered file root: %s (%s)", wi.Name, wi.Host+rootFile, dirWiki)
 	}
 
+	// anonymous edit proposals
+	if wi.Opt.Review.Enable && wi.Opt.Review.Anonymous {
+		proposeRoot := wikiRoot + "/propose/"
+		Mux.HandleFunc(wi.Host+proposeRoot, func(w http.ResponseWriter, r *http.Request) {
+			handlePropose(wi, strings.TrimPrefix(r.URL.Path, proposeRoot), w, r)
+		})
+		log.Printf("[%s] registered propose root: %s", wi.Name, wi.Host+proposeRoot)
+	}
+
+	// images resized on demand
+	if wi.ResizeEnabled() {
+		resizeRoot := wikiRoot + "/imgsz/"
+		Mux.HandleFunc(wi.Host+resizeRoot, func(w http.ResponseWriter, r *http.Request) {
+			handleResizedImage(wi, strings.TrimPrefix(r.URL.Path, resizeRoot), w, r)
+		})
+		log.Printf("[%s] registered resize root: %s", wi.Name, wi.Host+resizeRoot)
+	}
+
+	// page metadata for client scripts and other programs
+	metaRoot := wikiRoot + "/_meta/"
+	Mux.HandleFunc(wi.Host+metaRoot, func(w http.ResponseWriter, r *http.Request) {
+		handlePageMeta(wi, strings.TrimPrefix(r.URL.Path, metaRoot), w, r)
+	})
+
+	// generated page CSS
+	cssRoot := wikiRoot + "/_css/"
+	Mux.HandleFunc(wi.Host+cssRoot, func(w http.ResponseWriter, r *http.Request) {
+		handlePageCSS(wi, strings.TrimPrefix(r.URL.Path, cssRoot), w, r)
+	})
+
+	// pages presented as slides
+	slidesRoot := wikiRoot + "/_slides/"
+	Mux.HandleFunc(wi.Host+slidesRoot, func(w http.ResponseWriter, r *http.Request) {
+		handleSlides(wi, strings.TrimPrefix(r.URL.Path, slidesRoot), w, r)
+	})
+
+	// feed, calendar, and sitemap, which are generated by scheduled jobs
+	for file, mime := range map[string]string{
+		"feed.atom":   "application/atom+xml",
+		"events.ics":  "text/calendar; charset=utf-8",
+		"sitemap.xml": "application/xml",
+	} {
+		file, mime := file, mime
+		Mux.HandleFunc(wi.Host+wikiRoot+"/"+file, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", mime)
+			http.ServeFile(w, r, wi.Dir("cache", file))
+		})
+	}
+
 	// store the wiki info
 	wi.Title = wi.Opt.Name
 	return nil
@@ -263,6 +337,7 @@ func (wi *WikiInfo) Copy(w *wiki.Wiki) *WikiInfo {
 		Logo:     wi.Logo,
 		Host:     wi.Host,
 		template: wi.template,
+		jobs:     wi.jobs,
 		Wiki:     w,
 	}
 }
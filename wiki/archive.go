@@ -0,0 +1,546 @@
+package wiki
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cooper/go-git/v4"
+	"github.com/cooper/go-git/v4/plumbing/object"
+	"github.com/cooper/quiki/authenticator"
+	"github.com/pkg/errors"
+)
+
+// ArchiveFormat identifies quiki archive manifests.
+const ArchiveFormat = "quiki-archive"
+
+// ArchiveVersion is the version of the archive format written by Export.
+const ArchiveVersion = 1
+
+// directories included in archives in addition to backupDirs
+var archiveDirs = []string{"review"}
+
+// An ArchiveManifest describes the contents of an archive created by Export.
+// It is stored as manifest.json, the first file in the archive.
+type ArchiveManifest struct {
+	Format   string        `json:"format"`         // always ArchiveFormat
+	Version  int           `json:"version"`        // ArchiveVersion at the time of export
+	Name     string        `json:"name"`           // wiki name
+	Exported time.Time     `json:"exported"`       // time of export
+	Head     string        `json:"head,omitempty"` // current commit hash, if history is included
+	History  bool          `json:"history"`        // true if revision history is included
+	Accounts bool          `json:"accounts"`       // true if user accounts are included
+	Users    []ArchiveUser `json:"users,omitempty"`
+	Files    []ArchiveFile `json:"files"`
+}
+
+// An ArchiveUser is a user known to a wiki, either because they have an
+// account or because they have authored revisions.
+type ArchiveUser struct {
+	Username string `json:"username,omitempty"` // username, if the user has an account
+	Name     string `json:"name,omitempty"`     // display name
+	Email    string `json:"email,omitempty"`    // email address
+	Commits  int    `json:"commits,omitempty"`  // number of revisions authored
+}
+
+// An ArchiveFile is a file in an archive.
+type ArchiveFile struct {
+	Path     string    `json:"path"` // path relative to the wiki directory
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	SHA256   string    `json:"sha256"`
+}
+
+// ExportOpts describes the options for Export.
+type ExportOpts struct {
+
+	// History includes the revision history. When it is imported, the
+	// wiki keeps its commit hashes.
+	History bool
+
+	// Accounts includes the wiki's user accounts, with password hashes.
+	Accounts bool
+}
+
+// ImportOpts describes the options for Import.
+type ImportOpts struct {
+
+	// Users maps users in the archive to users on this server. Keys are
+	// usernames, email addresses, or names from the archive manifest, which
+	// are tried in that order.
+	//
+	// Revisions by mapped users are attributed to the new user with a git
+	// mailmap, which is applied when the history is read, so the history is
+	// not rewritten. Pending reviews and
+	// imported accounts are updated to match.
+	Users map[string]ArchiveUser
+}
+
+// Export writes a portable archive of the wiki to out. Unlike Backup, the
+// archive includes a manifest with checksums of every file and the users
+// who have contributed to the wiki, and optionally the revision history
+// and user accounts.
+//
+// The archive can be extracted with Import.
+func (w *Wiki) Export(out io.Writer, opts ExportOpts) error {
+	manifest := ArchiveManifest{
+		Format:   ArchiveFormat,
+		Version:  ArchiveVersion,
+		Name:     w.Opt.Name,
+		Exported: time.Now().UTC(),
+		History:  opts.History,
+		Accounts: opts.Accounts,
+	}
+
+	// find the files, in order
+	paths := make(map[string]string)
+	add := func(filePath, name string) error {
+		paths[name] = filePath
+		return nil
+	}
+	add(w.ConfigFile, "wiki.conf")
+	if opts.Accounts {
+		add(w.Auth.Path(), "auth.json")
+	}
+	for _, dir := range append(append([]string(nil), backupDirs...), archiveDirs...) {
+		if err := w.walkFiles(dir, add); err != nil {
+			return errors.Wrap(err, dir)
+		}
+	}
+	if opts.History {
+		repo, err := w.repo()
+		if err != nil {
+			return err
+		}
+		head, err := repo.Head()
+		if err != nil {
+			return errors.Wrap(err, "git:repo:Head")
+		}
+		manifest.Head = head.Hash().String()
+
+		// the repository is stored in history/
+		gitDir := w.Dir(".git")
+		if fi, err := os.Stat(gitDir); err != nil || !fi.IsDir() {
+			return errors.New("history is not available for linked branches")
+		}
+		err = w.walkFiles(".git", func(filePath, name string) error {
+			name = strings.TrimPrefix(name, ".git/")
+			if !archiveHistoryFileOK(name) {
+				return nil
+			}
+			return add(filePath, "history/"+name)
+		})
+		if err != nil {
+			return errors.Wrap(err, "history")
+		}
+	}
+	names := make([]string, 0, len(paths))
+	for name := range paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// checksums
+	for _, name := range names {
+		file, err := archiveFileInfo(paths[name], name)
+		if err != nil {
+			return errors.Wrap(err, name)
+		}
+		manifest.Files = append(manifest.Files, file)
+	}
+
+	// authors
+	users, err := w.archiveUsers()
+	if err != nil {
+		return errors.Wrap(err, "users")
+	}
+	manifest.Users = users
+
+	// write the manifest first so Import can verify the files as it goes
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	jsonData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = tw.WriteHeader(&tar.Header{
+		Name:     "manifest.json",
+		Mode:     0644,
+		Size:     int64(len(jsonData)),
+		ModTime:  manifest.Exported,
+		Typeflag: tar.TypeReg,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := tw.Write(jsonData); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err := addBackupFile(tw, paths[name], name); err != nil {
+			return errors.Wrap(err, name)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func archiveFileInfo(filePath, name string) (ArchiveFile, error) {
+	file := ArchiveFile{Path: name}
+	f, err := os.Open(filePath)
+	if err != nil {
+		return file, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return file, err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return file, err
+	}
+	file.Size = fi.Size()
+	file.Modified = fi.ModTime().UTC()
+	file.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return file, nil
+}
+
+// users with accounts on the wiki and authors of revisions. authors are
+// matched to accounts by email address
+func (w *Wiki) archiveUsers() ([]ArchiveUser, error) {
+	var users []*ArchiveUser
+	byEmail := make(map[string]*ArchiveUser)
+	byName := make(map[string]*ArchiveUser)
+	for _, u := range w.Auth.Users {
+		user := &ArchiveUser{Username: u.Username, Name: u.DisplayName, Email: u.Email}
+		users = append(users, user)
+		if u.Email != "" {
+			byEmail[strings.ToLower(u.Email)] = user
+		}
+	}
+
+	repo, err := w.repo()
+	if err != nil {
+		return nil, err
+	}
+	commits, err := repo.Log(&git.LogOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "git:repo:Log")
+	}
+	mailmap := w.mailmap()
+	err = commits.ForEach(func(c *object.Commit) error {
+		name, email := commitAuthor(c, mailmap)
+		user := byEmail[strings.ToLower(email)]
+		if email == "" {
+			user = byName[name]
+		}
+		if user == nil {
+			user = &ArchiveUser{Name: name, Email: email}
+			users = append(users, user)
+			if email != "" {
+				byEmail[strings.ToLower(email)] = user
+			} else {
+				byName[name] = user
+			}
+		}
+		user.Commits++
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "git:repo:Log")
+	}
+
+	sort.SliceStable(users, func(i, j int) bool {
+		if users[i].Commits != users[j].Commits {
+			return users[i].Commits > users[j].Commits
+		}
+		return users[i].Username+users[i].Name < users[j].Username+users[j].Name
+	})
+	result := make([]ArchiveUser, len(users))
+	for i, user := range users {
+		result[i] = *user
+	}
+	return result, nil
+}
+
+// Import extracts an archive created by Export into the wiki directory dir,
+// which is created if it does not exist. Every file is verified against the
+// checksums in the manifest, which is returned.
+//
+// If the archive includes history, dir must not already be a git
+// repository. Otherwise, the imported files are committed to the wiki
+// repository the next time the wiki is loaded.
+func Import(in io.Reader, dir string, opts ImportOpts) (*ArchiveManifest, error) {
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	// the manifest comes first
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, errors.Wrap(err, "manifest")
+	}
+	if hdr.Name != "manifest.json" {
+		return nil, errors.New("not a quiki archive: manifest.json missing")
+	}
+	var manifest ArchiveManifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return nil, errors.Wrap(err, "manifest")
+	}
+	if manifest.Format != ArchiveFormat {
+		return nil, errors.New("not a quiki archive: format is " + manifest.Format)
+	}
+	if manifest.Version > ArchiveVersion {
+		return nil, errors.Errorf("archive version %d is not supported", manifest.Version)
+	}
+	if manifest.History {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return nil, errors.New("cannot import history into an existing repository")
+		}
+	}
+
+	expected := make(map[string]ArchiveFile, len(manifest.Files))
+	for _, file := range manifest.Files {
+		expected[file.Path] = file
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		// only accept files which Export creates
+		name := path.Clean(hdr.Name)
+		file, ok := expected[name]
+		if !ok || !archiveFileOK(name, manifest.History) {
+			return nil, errors.New("unexpected file in archive: " + hdr.Name)
+		}
+		delete(expected, name)
+
+		// write and verify the file
+		filePath := filepath.Join(dir, filepath.FromSlash(name))
+		if strings.HasPrefix(name, "history/") {
+			filePath = filepath.Join(dir, ".git", filepath.FromSlash(strings.TrimPrefix(name, "history/")))
+		}
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			return nil, err
+		}
+		f, err := os.Create(filePath)
+		if err != nil {
+			return nil, err
+		}
+		h := sha256.New()
+		_, err = io.Copy(io.MultiWriter(f, h), tr)
+		f.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, name)
+		}
+		if hex.EncodeToString(h.Sum(nil)) != file.SHA256 {
+			return nil, errors.New("checksum mismatch: " + name)
+		}
+		os.Chtimes(filePath, file.Modified, file.Modified)
+	}
+
+	// make sure nothing is missing
+	if len(expected) != 0 {
+		var missing []string
+		for name := range expected {
+			missing = append(missing, name)
+		}
+		sort.Strings(missing)
+		return nil, errors.New("files missing from archive: " + strings.Join(missing, ", "))
+	}
+
+	// the index is not archived, so it is rebuilt from the imported head
+	if manifest.History {
+		if err := resetArchiveIndex(dir); err != nil {
+			return nil, errors.Wrap(err, "history")
+		}
+	}
+
+	if len(opts.Users) != 0 {
+		if err := mapArchiveUsers(&manifest, dir, opts.Users); err != nil {
+			return nil, errors.Wrap(err, "map users")
+		}
+	}
+	return &manifest, nil
+}
+
+// true if a cleaned file name from an archive is within the wiki. files of
+// the repository are only accepted if the archive includes history
+func archiveFileOK(name string, history bool) bool {
+	if strings.HasPrefix(name, "history/") {
+		return history && archiveHistoryFileOK(strings.TrimPrefix(name, "history/"))
+	}
+	if backupFileOK(name) || name == "auth.json" {
+		return true
+	}
+	for _, dir := range archiveDirs {
+		if strings.HasPrefix(name, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// rebuilds the index of an imported repository to match its head, leaving
+// the imported files as they are
+func resetArchiveIndex(dir string) error {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return errors.Wrap(err, "git:PlainOpen")
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return errors.Wrap(err, "git:repo:Head")
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return errors.Wrap(err, "git:repo:Worktree")
+	}
+	return wt.Reset(&git.ResetOptions{Commit: head.Hash(), Mode: git.MixedReset})
+}
+
+// true if a file of the repository, relative to .git, is stored in history/.
+// only objects and refs are, so that an archive cannot change the
+// configuration or install hooks
+func archiveHistoryFileOK(name string) bool {
+	return name == "HEAD" || name == "packed-refs" ||
+		strings.HasPrefix(name, "objects/") || strings.HasPrefix(name, "refs/")
+}
+
+// applies the user mapping to an imported wiki
+func mapArchiveUsers(manifest *ArchiveManifest, dir string, userMap map[string]ArchiveUser) error {
+	var mailmap []string
+	type mapping struct{ from, to ArchiveUser }
+	var mappings []mapping
+	for _, user := range manifest.Users {
+		var to ArchiveUser
+		ok := false
+		for _, key := range []string{user.Username, user.Email, user.Name} {
+			if key == "" {
+				continue
+			}
+			if to, ok = userMap[key]; ok {
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		// unspecified fields are unchanged
+		if to.Username == "" {
+			to.Username = user.Username
+		}
+		if to.Name == "" {
+			to.Name = user.Name
+		}
+		if to.Email == "" {
+			to.Email = user.Email
+		}
+
+		mappings = append(mappings, mapping{user, to})
+		if manifest.History && user.Commits != 0 {
+			mailmap = append(mailmap, mailmapIdentity(to.Name, to.Email)+" "+mailmapIdentity(user.Name, user.Email))
+		}
+	}
+
+	// attribute revisions to the new users
+	if len(mailmap) != 0 {
+		f, err := os.OpenFile(filepath.Join(dir, mailmapFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = f.WriteString(strings.Join(mailmap, "\n") + "\n")
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	// pending reviews
+	files, _ := filepath.Glob(filepath.Join(dir, "review", "*.json"))
+	for _, file := range files {
+		jsonData, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		var r Review
+		if err := json.Unmarshal(jsonData, &r); err != nil {
+			return errors.Wrap(err, filepath.Base(file))
+		}
+		changed := false
+		for _, m := range mappings {
+			if r.Name == m.from.Name && strings.EqualFold(r.Email, m.from.Email) {
+				r.Name, r.Email, changed = m.to.Name, m.to.Email, true
+				break
+			}
+		}
+		if !changed {
+			continue
+		}
+		if jsonData, err = json.Marshal(r); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(file, jsonData, 0644); err != nil {
+			return err
+		}
+	}
+
+	// accounts
+	if !manifest.Accounts {
+		return nil
+	}
+	auth, err := authenticator.Open(filepath.Join(dir, "auth.json"))
+	if err != nil {
+		return err
+	}
+	for _, m := range mappings {
+		if m.from.Username == "" {
+			continue
+		}
+		err := auth.UpdateUser(m.from.Username, authenticator.User{
+			Username:    m.to.Username,
+			DisplayName: m.to.Name,
+			Email:       m.to.Email,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formats a mailmap name and email
+func mailmapIdentity(name, email string) string {
+	if name == "" {
+		return "<" + email + ">"
+	}
+	return name + " <" + email + ">"
+}
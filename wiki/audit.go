@@ -0,0 +1,58 @@
+package wiki
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// An AuditEntry is a record of an action taken on behalf of a user, such as
+// an edit rejected by the content filters.
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	Event  string    `json:"event"`            // what happened, such as filter.reject
+	File   string    `json:"file,omitempty"`   // filename relative to the wiki directory
+	User   string    `json:"user,omitempty"`   // username, if logged in
+	Name   string    `json:"name,omitempty"`   // name of the contributor
+	Email  string    `json:"email,omitempty"`  // email of the contributor
+	Detail string    `json:"detail,omitempty"` // description, such as the filter matched
+}
+
+// appends an entry to the audit log, cache/audit.log, which has one JSON
+// object per line. entries are also written to the wiki log
+func (w *Wiki) audit(event, file string, commit CommitOpts, detail string) {
+	w.Logf("audit: %s %s by %s: %s", event, file, commitUser(commit), detail)
+	jsonData, err := json.Marshal(AuditEntry{
+		Time:   time.Now(),
+		Event:  event,
+		File:   file,
+		User:   commit.User,
+		Name:   commit.Name,
+		Email:  commit.Email,
+		Detail: detail,
+	})
+	if err != nil {
+		return
+	}
+	os.MkdirAll(w.Opt.Dir.Cache, 0755)
+	f, err := os.OpenFile(w.Dir("cache", "audit.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		w.Logf("audit: %v", err)
+		return
+	}
+	defer f.Close()
+	f.Write(append(jsonData, '\n'))
+}
+
+// describes the user committing changes for the log
+func commitUser(commit CommitOpts) string {
+	switch {
+	case commit.User != "":
+		return commit.User
+	case commit.Email != "":
+		return commit.Name + " <" + commit.Email + ">"
+	case commit.Name != "":
+		return commit.Name
+	}
+	return "anonymous"
+}
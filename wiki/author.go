@@ -0,0 +1,28 @@
+package wiki
+
+import "github.com/cooper/quiki/wikifier"
+
+// PagesByAuthor returns info about the pages in the wiki grouped by the
+// name of the author, as in @page.author. Each author's pages are sorted
+// with the newest first. Pages without an author are omitted.
+func (w *Wiki) PagesByAuthor() map[string][]wikifier.PageInfo {
+	authors := make(map[string][]wikifier.PageInfo)
+	for _, info := range w.PagesSorted(true, SortCreated) {
+		if info.Author != "" {
+			authors[info.Author] = append(authors[info.Author], info)
+		}
+	}
+	return authors
+}
+
+// DisplayAuthorPosts returns the display result for the pages by an author.
+// It is like DisplayCategoryPosts, and the result's Title is the author's
+// name as in @page.author.
+func (w *Wiki) DisplayAuthorPosts(authorName string, pageN int) interface{} {
+	cat := w.GetSpecialCategory(authorName, CategoryTypeAuthor)
+	cat.update(w)
+	if !cat.Exists() {
+		return DisplayError{Error: "Author does not exist."}
+	}
+	return w.displayCategoryPosts(cat, pageN)
+}
@@ -0,0 +1,74 @@
+package wiki
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/cooper/quiki/wikifier"
+)
+
+// PagesLinkingTo returns info about the published pages which link to the
+// named page, sorted by title.
+//
+// Pages are found from the links recorded by updatePageCategories.
+func (w *Wiki) PagesLinkingTo(name string) []wikifier.PageInfo {
+	self := w.Opt.PageNameNE(name)
+	var pages []wikifier.PageInfo
+	for _, file := range w.Dependents(Dependency{CategoryTypePage, name}) {
+		if w.Opt.PageNameNE(file) == self {
+			continue
+		}
+		info := w.PageInfo(file)
+		if info.File == "" || info.Draft || info.Redirect != "" {
+			continue
+		}
+		pages = append(pages, info)
+	}
+	sort.SliceStable(pages, func(i, j int) bool {
+		return strings.ToLower(backlinkTitle(pages[i])) < strings.ToLower(backlinkTitle(pages[j]))
+	})
+	return pages
+}
+
+func backlinkTitle(info wikifier.PageInfo) string {
+	if info.Title != "" {
+		return info.Title
+	}
+	return info.FileNE
+}
+
+// filenames of pages listed by backlinks{} or pages-where{}, for detecting
+// when they change
+func pageFileNames(pages []wikifier.PageInfo) []string {
+	names := make([]string, len(pages))
+	for i, info := range pages {
+		names[i] = info.File
+	}
+	return names
+}
+
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// returns true if the pages linking to a page are no longer those listed by
+// the backlinks{} of its cached copy
+func (w *Wiki) backlinksChanged(page *wikifier.Page, cached []string) bool {
+	return !stringsEqual(pageFileNames(w.PagesLinkingTo(page.Name())), cached)
+}
+
+func pageBacklinks(page *wikifier.Page) []wikifier.PageInfo {
+	w, ok := page.Wiki.(*Wiki)
+	if !ok || page.External() {
+		return nil
+	}
+	return w.PagesLinkingTo(page.Name())
+}
@@ -0,0 +1,173 @@
+package wiki
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// directories included in backups, relative to the wiki directory
+var backupDirs = []string{"pages", "models", "images", SiteVarsDir}
+
+// Backup writes a gzipped tar archive of the wiki's pages, models, images,
+// variables files, and configuration to out. The cache and revision history
+// are not included.
+//
+// The archive can be extracted with Restore.
+func (w *Wiki) Backup(out io.Writer) error {
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	// configuration
+	if err := addBackupFile(tw, w.ConfigFile, "wiki.conf"); err != nil {
+		return errors.Wrap(err, "wiki.conf")
+	}
+	if _, err := os.Stat(w.Dir(SiteVarsFile)); err == nil {
+		if err := addBackupFile(tw, w.Dir(SiteVarsFile), SiteVarsFile); err != nil {
+			return errors.Wrap(err, SiteVarsFile)
+		}
+	}
+
+	// content
+	for _, dir := range backupDirs {
+		err := w.walkFiles(dir, func(filePath, name string) error {
+			return addBackupFile(tw, filePath, name)
+		})
+		if err != nil {
+			return errors.Wrap(err, dir)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// calls fn for each file within a directory relative to the wiki directory,
+// with the name of the file relative to the wiki directory. broken symlinks
+// are skipped
+func (w *Wiki) walkFiles(dir string, fn func(filePath, name string) error) error {
+	base := w.Dir(dir)
+	return filepath.Walk(base, func(filePath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && filePath == base {
+				return nil
+			}
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+
+		// skip broken symlinks
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if _, err := os.Stat(filePath); err != nil {
+				w.Log("skipping broken symlink:", filePath)
+				return nil
+			}
+		}
+
+		rel, err := filepath.Rel(w.Dir(), filePath)
+		if err != nil {
+			return err
+		}
+		return fn(filePath, filepath.ToSlash(rel))
+	})
+}
+
+// adds a file to a backup. symlinks are followed
+func addBackupFile(tw *tar.Writer, filePath, name string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil
+	}
+	hdr, err := tar.FileInfoHeader(fi, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// Restore extracts a backup created by Backup into the wiki directory dir,
+// which is created if it does not exist. Existing files are overwritten, but
+// files absent from the backup are not deleted.
+//
+// Restored files are committed to the wiki repository the next time the wiki
+// is loaded.
+func Restore(in io.Reader, dir string) error {
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		// only accept files which Backup creates
+		name := path.Clean(hdr.Name)
+		if !backupFileOK(name) {
+			return errors.New("unexpected file in backup: " + hdr.Name)
+		}
+
+		// write the file
+		filePath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			return err
+		}
+		f, err := os.Create(filePath)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, tr)
+		f.Close()
+		if err != nil {
+			return errors.Wrap(err, name)
+		}
+		os.Chtimes(filePath, hdr.ModTime, hdr.ModTime)
+	}
+}
+
+// true if a cleaned file name from a backup is within the wiki. since the
+// name is cleaned, it cannot escape one of the content directories
+func backupFileOK(name string) bool {
+	if name == "wiki.conf" || name == SiteVarsFile {
+		return true
+	}
+	for _, dir := range backupDirs {
+		if strings.HasPrefix(name, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
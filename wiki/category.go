@@ -26,6 +26,12 @@ const (
 
 	// CategoryTypePage is a metacategory that tracks which pages reference another page.
 	CategoryTypePage = "page"
+
+	// CategoryTypeData is a metacategory that tracks which pages use a data file.
+	CategoryTypeData = "data"
+
+	// CategoryTypeAuthor is a metacategory that tracks which pages are by an author.
+	CategoryTypeAuthor = "author"
 )
 
 // A Category is a collection of pages pertaining to a topic.
@@ -79,6 +85,14 @@ type Category struct {
 	// for CategoryTypePage, this is the info for the tracked page
 	PageInfo *wikifier.PageInfo `json:"page_info,omitempty"`
 
+	// for CategoryTypePage, the resources the tracked page depended on
+	// when it was last generated
+	Dependencies []Dependency `json:"dependencies,omitempty"`
+
+	// for CategoryTypePage, the web addresses the tracked page linked to
+	// when it was last generated, mapped to line numbers
+	ExternalLinks map[string][]int `json:"external_links,omitempty"`
+
 	// for CategoryTypeModel, this is the info for the tracked model
 	ModelInfo *wikifier.ModelInfo `json:"model_info,omitempty"`
 
@@ -87,6 +101,10 @@ type Category struct {
 		Width  int `json:"width,omitempty"`
 		Height int `json:"height,omitempty"`
 	} `json:"image_info,omitempty"`
+
+	// for CategoryTypeImage, SHA-256 hashes of images generated in other
+	// dimensions. keys are filenames in the image cache
+	ImageHashes map[string]string `json:"image_hashes,omitempty"`
 }
 
 // A CategoryEntry describes a page that belongs to a category.
@@ -110,6 +128,10 @@ type CategoryEntry struct {
 	// for CategoryTypePage, an array of line numbers on which the tracked page is
 	// referenced on the page described by this entry
 	Lines []int `json:"lines,omitempty"`
+
+	// for CategoryTypeImage, the number of times the image appears within
+	// a gallery on this page
+	Galleries int `json:"galleries,omitempty"`
 }
 
 // DisplayCategoryPosts represents a category result to display.
@@ -163,7 +185,7 @@ func (w *Wiki) GetSpecialCategory(name string, typ CategoryType) *Category {
 		}
 
 		// with any error, we need to create the category new
-		now := time.Now()
+		now := w.timestamp(time.Time{})
 		cat.Created = &now
 		cat.Modified = &now
 		cat.CreatedHTTP = httpdate.Time2Str(now)
@@ -255,7 +277,7 @@ func (w *Wiki) readCategoryMeta(metaPath string, cat *Category) {
 	}
 
 	// update the category
-	now := time.Now()
+	now := w.timestamp(p.Modified())
 	cat.Asof = &now
 	cat.write(w)
 }
@@ -265,10 +287,12 @@ func (w *Wiki) readCategoryMeta(metaPath string, cat *Category) {
 // If the page already belongs and any information has changed, the category is updated.
 // If force is true,
 func (cat *Category) AddPage(w *Wiki, page *wikifier.Page) {
-	cat.addPageExtras(w, page, nil, nil)
+	cat.addPageExtras(w, page, CategoryEntry{})
 }
 
-func (cat *Category) addPageExtras(w *Wiki, pageMaybe *wikifier.Page, dimensions [][]int, lines []int) {
+// like AddPage, except that extras specifies the type-specific fields
+// of the page entry
+func (cat *Category) addPageExtras(w *Wiki, pageMaybe *wikifier.Page, extras CategoryEntry) {
 
 	// update existing info
 	cat.update(w)
@@ -294,19 +318,20 @@ func (cat *Category) addPageExtras(w *Wiki, pageMaybe *wikifier.Page, dimensions
 	}
 
 	// ok, at this point we're gonna add or update the page if there is one
-	now := time.Now()
+	var mod time.Time
+	if pageMaybe != nil {
+		mod = pageMaybe.Modified()
+	}
+	now := w.timestamp(mod)
 	cat.Modified = &now
 	cat.ModifiedHTTP = httpdate.Time2Str(now)
 	if pageMaybe != nil {
 		if cat.Pages == nil {
 			cat.Pages = make(map[string]CategoryEntry)
 		}
-		cat.Pages[pageMaybe.Name()] = CategoryEntry{
-			Asof:       &now,
-			PageInfo:   pageMaybe.Info(),
-			Dimensions: dimensions,
-			Lines:      lines,
-		}
+		extras.Asof = &now
+		extras.PageInfo = pageMaybe.Info()
+		cat.Pages[pageMaybe.Name()] = extras
 	}
 
 	// write it
@@ -330,7 +355,7 @@ func (cat *Category) write(w *Wiki) {
 	}
 
 	// write
-	ioutil.WriteFile(cat.Path, jsonData, 0666)
+	writeFileAtomic(cat.Path, jsonData, 0644)
 }
 
 func (cat *Category) update(w *Wiki) {
@@ -342,7 +367,7 @@ func (cat *Category) update(w *Wiki) {
 	}
 
 	// check each page
-	now := time.Now()
+	now := w.timestamp(time.Time{})
 	changed := false
 	newPages := make(map[string]CategoryEntry, len(cat.Pages))
 	for pageName, entry := range cat.Pages {
@@ -388,6 +413,14 @@ func (cat *Category) update(w *Wiki) {
 			case CategoryTypeModel:
 				_, stillMember = page.Models[wikifier.CategoryNameNE(cat.Name)]
 
+			// for data files, check if the page still loads the file
+			case CategoryTypeData:
+				_, stillMember = page.DataFiles[wikifier.CategoryNameNE(cat.Name)]
+
+			// for authors, check if the page is still by the author
+			case CategoryTypeAuthor:
+				stillMember = wikifier.CategoryNameNE(page.Author()) == cat.Name
+
 			// for normal categories, check @category
 			default:
 				for _, catName := range page.Categories() {
@@ -404,8 +437,12 @@ func (cat *Category) update(w *Wiki) {
 			}
 
 			// update page info
+			asof := w.timestamp(pageFi.ModTime())
 			entry.PageInfo = page.Info()
-			entry.Asof = &now
+			entry.Asof = &asof
+			if w.Opt.Deterministic && asof.After(now) {
+				now = asof
+			}
 		}
 
 		newPages[pageName] = entry
@@ -467,6 +504,11 @@ func (cat *Category) shouldPurge(w *Wiki) bool {
 		_, err := os.Lstat(w.pathForModel(nameNE))
 		preserve = err != nil
 
+	// for data files and authors, there is nothing to keep once no pages
+	// use them
+	case CategoryTypeData, CategoryTypeAuthor:
+		preserve = false
+
 	// for normal categories, check if it's being manually preserved
 	default:
 		preserve = cat.Preserve
@@ -488,7 +530,7 @@ func (cat *Category) addImage(w *Wiki, imageName string, pageMaybe *wikifier.Pag
 	// find the image dimensions if not present
 	if cat.ImageInfo == nil {
 		path := w.pathForImage(imageName)
-		width, height := getImageDimensions(path)
+		width, height := getImageDimensions(path, w.Opt.Image.AutoOrient)
 		if width != 0 && height != 0 {
 			cat.ImageInfo = &struct {
 				Width  int `json:"width,omitempty"`
@@ -497,22 +539,53 @@ func (cat *Category) addImage(w *Wiki, imageName string, pageMaybe *wikifier.Pag
 		}
 	}
 
-	cat.addPageExtras(w, pageMaybe, dimensionsMaybe, nil)
+	extras := CategoryEntry{Dimensions: dimensionsMaybe}
+	if pageMaybe != nil {
+		extras.Galleries = pageMaybe.Galleries[imageName]
+	}
+	cat.addPageExtras(w, pageMaybe, extras)
 }
 
 // cat_check_page
-func (w *Wiki) updatePageCategories(page *wikifier.Page) {
+//
+// This records the links and other references of a page each time it is
+// generated. Pages which have not been generated yet have nothing recorded, so
+// their links are missing from backlinks, the link graph, and link checks.
+//
+// hash is the SHA-256 of the generated content, if it was generated. If it
+// differs from the hash recorded last time, the page is invalidated.
+func (w *Wiki) updatePageCategories(page *wikifier.Page, hash string) {
 
 	// page metadata category
 	info := page.Info()
+	info.Hash = hash
 	pageCat := w.GetSpecialCategory(page.NameNE(), CategoryTypePage)
+
+	// content changed since it was last generated; purge edge caches
+	if hash != "" && pageCat.PageInfo != nil && pageCat.PageInfo.Hash != "" && pageCat.PageInfo.Hash != hash {
+		w.InvalidatePage(page.NameNE())
+	}
+
+	// pages-where{} may list other pages or titles
+	metaChanged := pageCat.PageInfo == nil || pageQueryInfoChanged(*pageCat.PageInfo, info)
+
 	pageCat.PageInfo = &info
+	w.updateQuickSwitchEntry(info, false)
+	pageCat.Dependencies = pageDependencies(page)
+	pageCat.ExternalLinks = page.ExternalLinks
 	pageCat.Preserve = true // keep until page no longer exists
-	pageCat.addPageExtras(w, nil, nil, nil)
+	pageCat.addPageExtras(w, nil, CategoryEntry{})
 
 	// actual categories
 	for _, name := range page.Categories() {
-		w.GetCategory(name).AddPage(w, page)
+		cat := w.GetCategory(name)
+		if _, ok := cat.Pages[page.Name()]; !ok {
+			metaChanged = true
+		}
+		cat.AddPage(w, page)
+	}
+	if metaChanged {
+		w.pageMetadataChanged()
 	}
 
 	// image tracking categories
@@ -527,7 +600,7 @@ func (w *Wiki) updatePageCategories(page *wikifier.Page) {
 		// however, we track references to not-yet-existent pages as well
 		pageCat := w.GetSpecialCategory(pageName, CategoryTypePage)
 		pageCat.Preserve = true // keep until there are no more references
-		pageCat.addPageExtras(w, page, nil, lines)
+		pageCat.addPageExtras(w, page, CategoryEntry{Lines: lines})
 	}
 
 	// model tracking categories
@@ -537,12 +610,29 @@ func (w *Wiki) updatePageCategories(page *wikifier.Page) {
 		modelCat.ModelInfo = &modelInfo
 		modelCat.AddPage(w, page)
 	}
+
+	// data file tracking categories
+	for dataName := range page.DataFiles {
+		dataCat := w.GetSpecialCategory(dataName, CategoryTypeData)
+		dataCat.Preserve = true // keep until there are no more references
+		dataCat.AddPage(w, page)
+	}
+
+	// author tracking category
+	if author := page.Author(); author != "" {
+		authorCat := w.GetSpecialCategory(author, CategoryTypeAuthor)
+		authorCat.Title = author
+		authorCat.Preserve = true // keep until there are no more pages
+		authorCat.AddPage(w, page)
+	}
 }
 
 // DisplayCategoryPosts returns the display result for a category.
 func (w *Wiki) DisplayCategoryPosts(catName string, pageN int) interface{} {
-	cat := w.GetCategory(catName)
-	catName = cat.Name
+	return w.displayCategoryPosts(w.GetCategory(catName), pageN)
+}
+
+func (w *Wiki) displayCategoryPosts(cat *Category, pageN int) interface{} {
 
 	// update info
 	// note: this needs to be before existence check because it may purge
@@ -562,9 +652,14 @@ func (w *Wiki) DisplayCategoryPosts(catName string, pageN int) interface{} {
 		return DisplayError{Error: "Category is empty."}
 	}
 
-	// load each page
-	var pages pagesToSort
+	// load each page, in a consistent order
+	pageNames := make([]string, 0, len(cat.Pages))
 	for pageName := range cat.Pages {
+		pageNames = append(pageNames, pageName)
+	}
+	sort.Strings(pageNames)
+	var pages pagesToSort
+	for _, pageName := range pageNames {
 
 		// fetch page display result
 		res := w.DisplayPage(pageName)
@@ -581,7 +676,7 @@ func (w *Wiki) DisplayCategoryPosts(catName string, pageN int) interface{} {
 	}
 
 	// order with newest first
-	sort.Sort(pages)
+	sort.Stable(pages)
 
 	// decide on the limit of cats per page
 	limit := cat.PerPage // try local option
@@ -0,0 +1,133 @@
+package wiki
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	cdnClient  = &http.Client{Timeout: 30 * time.Second}
+	errRootExt = errors.New("@root.ext is not set")
+)
+
+// CloudFront is an Invalidator which creates invalidations for an Amazon
+// CloudFront distribution.
+type CloudFront struct {
+	Distribution string // distribution ID
+	AccessKey    string // AWS access key ID
+	SecretKey    string // AWS secret access key
+}
+
+type cloudFrontInvalidationBatch struct {
+	XMLName         xml.Name `xml:"http://cloudfront.amazonaws.com/doc/2020-05-31/ InvalidationBatch"`
+	Quantity        int      `xml:"Paths>Quantity"`
+	Items           []string `xml:"Paths>Items>Path"`
+	CallerReference string   `xml:"CallerReference"`
+}
+
+// Invalidate creates an invalidation for the paths.
+func (cf CloudFront) Invalidate(w *Wiki, paths []string) error {
+	body, err := xml.Marshal(cloudFrontInvalidationBatch{
+		Quantity:        len(paths),
+		Items:           paths,
+		CallerReference: "quiki" + strconv.FormatInt(time.Now().UnixNano(), 36),
+	})
+	if err != nil {
+		return err
+	}
+	url := "https://cloudfront.amazonaws.com/2020-05-31/distribution/" + cf.Distribution + "/invalidation"
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/xml")
+	// CloudFront is a global service, so requests are signed for us-east-1
+	SignAWSRequest(req, body, cf.AccessKey, cf.SecretKey, "us-east-1", "cloudfront", time.Now().UTC())
+	return cdnDo("cloudfront", req)
+}
+
+// Fastly is an Invalidator which purges URLs from Fastly. root.ext must be
+// set to the URL of the wiki as served by Fastly.
+type Fastly struct {
+	Key string // API token with purge access
+}
+
+// Invalidate purges the URL of each path.
+func (f Fastly) Invalidate(w *Wiki, paths []string) error {
+	urls, err := absoluteURLs(w, paths)
+	if err != nil {
+		return err
+	}
+	for _, u := range urls {
+		u = strings.TrimPrefix(strings.TrimPrefix(u, "https://"), "http://")
+		req, err := http.NewRequest(http.MethodPost, "https://api.fastly.com/purge/"+u, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Fastly-Key", f.Key)
+		if err := cdnDo("fastly", req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Cloudflare is an Invalidator which purges URLs from a Cloudflare zone.
+// root.ext must be set to the URL of the wiki as served by Cloudflare.
+type Cloudflare struct {
+	Zone  string // zone ID
+	Token string // API token with cache purge permission
+}
+
+// Cloudflare purges at most this many URLs per request
+const cloudflareMaxFiles = 30
+
+// Invalidate purges the URL of each path.
+func (cf Cloudflare) Invalidate(w *Wiki, paths []string) error {
+	urls, err := absoluteURLs(w, paths)
+	if err != nil {
+		return err
+	}
+	for len(urls) != 0 {
+		n := len(urls)
+		if n > cloudflareMaxFiles {
+			n = cloudflareMaxFiles
+		}
+		body, err := json.Marshal(map[string][]string{"files": urls[:n]})
+		if err != nil {
+			return err
+		}
+		urls = urls[n:]
+		url := "https://api.cloudflare.com/client/v4/zones/" + cf.Zone + "/purge_cache"
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+cf.Token)
+		if err := cdnDo("cloudflare", req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sends a purge request, returning an error for unsuccessful responses
+func cdnDo(service string, req *http.Request) error {
+	res, err := cdnClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, service)
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return errors.New(service + ": " + res.Status)
+	}
+	return nil
+}
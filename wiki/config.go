@@ -15,6 +15,19 @@ var defaultWikiOpt = wikifier.PageOpt{
 		Code: wikifier.PageOptCode{
 			Style: "monokailight",
 		},
+		Extensions: []wikifier.PageOptExtension{
+			{Ext: ".page", Translator: "quiki"},
+			{Ext: ".md", Translator: "markdown"},
+			{Ext: ".txt", Translator: "plaintext"},
+			{Ext: ".log", Translator: "plaintext"},
+			{Ext: ".csv", Translator: "csv"},
+		},
+		Plaintext: wikifier.PageOptPlaintext{
+			Download: true,
+		},
+		Contributors: pageContributors,
+		Backlinks:    pageBacklinks,
+		Query:        pageQuery,
 	},
 	Dir: wikifier.PageOptDir{
 		Wiki:  "",
@@ -28,6 +41,7 @@ var defaultWikiOpt = wikifier.PageOpt{
 		Page:     "", // aka /
 		Image:    "/images",
 		Category: "/topic",
+		Author:   "/author",
 		File:     "", // (i.e., disabled)
 	},
 	Image: wikifier.PageOptImage{
@@ -35,6 +49,9 @@ var defaultWikiOpt = wikifier.PageOpt{
 		SizeMethod: "server",
 		Calc:       defaultImageCalc,
 		Sizer:      defaultImageSizer,
+		Cropper:    defaultImageCropper,
+		Converter:  defaultImageConverter,
+		AutoOrient: true,
 	},
 	Category: wikifier.PageOptCategory{
 		PerPage: 5,
@@ -85,7 +102,7 @@ func defaultImageCalc(name string, width, height int, page *wikifier.Page) (int,
 	}
 
 	path := filepath.Join(page.Opt.Dir.Image, filepath.FromSlash(name))
-	bigW, bigH := getImageDimensions(path)
+	bigW, bigH := getImageDimensions(path, page.Opt.Image.AutoOrient)
 
 	// original has no dimensions??
 	if bigW == 0 || bigH == 0 {
@@ -101,16 +118,27 @@ func defaultImageCalc(name string, width, height int, page *wikifier.Page) (int,
 	width, height = calculateImageDimensions(bigW, bigH, width, height)
 
 	// also pregenerate the image maybe
+	sized := SizedImageFromName(name)
+	sized.Width = width
+	sized.Height = height
+	pregenerateImage(page, sized)
+
+	return width, height, false
+}
+
+// pregenerates an image if the wiki is pregenerating,
+// as well as in each format it is converted to
+func pregenerateImage(page *wikifier.Page, sized SizedImage) {
 	w, ok := page.Wiki.(*Wiki)
-	if ok && w.pregenerating {
-		sized := SizedImageFromName(name)
-		sized.Width = width
-		sized.Height = height
-		w.Debug("pregen image:", sized.ScaleName())
+	if !ok || !w.pregenerating {
+		return
+	}
+	w.Debug("pregen image:", sized.ScaleName())
+	w.DisplaySizedImageGenerate(sized, true)
+	for _, format := range page.Opt.Image.Formats() {
+		sized.Format = format
 		w.DisplaySizedImageGenerate(sized, true)
 	}
-
-	return width, height, false
 }
 
 func defaultImageSizer(name string, width, height int, page *wikifier.Page) string {
@@ -120,6 +148,17 @@ func defaultImageSizer(name string, width, height int, page *wikifier.Page) stri
 	return page.Opt.Root.Image + "/" + si.TrueName()
 }
 
+func defaultImageCropper(name string, width, height, focusX, focusY int, page *wikifier.Page) string {
+	si := SizedImageFromName(name)
+	si.Width = width
+	si.Height = height
+	si.Crop = true
+	si.FocusX = focusX
+	si.FocusY = focusY
+	pregenerateImage(page, si)
+	return page.Opt.Root.Image + "/" + si.TrueName()
+}
+
 func linkPageExists(page *wikifier.Page, o *wikifier.PageOptLinkOpts) {
 	w, good := page.Wiki.(*Wiki)
 	if !good {
@@ -0,0 +1,126 @@
+package wiki
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cooper/go-git/v4"
+	"github.com/cooper/go-git/v4/plumbing"
+	"github.com/cooper/go-git/v4/plumbing/object"
+	"github.com/cooper/quiki/wikifier"
+	"github.com/pkg/errors"
+)
+
+// contributors of pages, which are found again when the repository HEAD
+// changes
+type contributorsCache struct {
+	head  plumbing.Hash
+	names map[string][]string // by page name
+	mu    sync.Mutex
+}
+
+// Contributors returns the names of the authors of revisions to a page, with
+// those who made the most revisions first. Authors are matched by email
+// address, and the name from their most recent revision is used. Authors
+// are first mapped by the wiki's .mailmap, if any.
+//
+// If the wiki is not yet a git repository, the result is empty.
+func (w *Wiki) Contributors(pageName string) ([]string, error) {
+
+	// don't create the repository just to find nobody
+	if w._repo == nil {
+		if _, err := os.Stat(w.Dir(".git")); err != nil {
+			return nil, nil
+		}
+	}
+	repo, err := w.repo()
+	if err != nil {
+		return nil, err
+	}
+
+	// the history has not changed since they were last found
+	ref, err := repo.Head()
+	if err != nil {
+		// no commits yet
+		return nil, nil
+	}
+	c := &w.contributors
+	c.mu.Lock()
+	if c.head != ref.Hash() {
+		c.head, c.names = ref.Hash(), make(map[string][]string)
+	}
+	names, ok := c.names[pageName]
+	c.mu.Unlock()
+	if ok {
+		return names, nil
+	}
+
+	// path relative to the repository
+	rel, err := filepath.Rel(w.Dir(), w.pathForPage(pageName))
+	if err != nil {
+		return nil, err
+	}
+	rel = filepath.ToSlash(rel)
+
+	commits, err := repo.Log(&git.LogOptions{From: ref.Hash(), FileName: &rel})
+	if err != nil {
+		return nil, errors.Wrap(err, "git:repo:Log")
+	}
+
+	// count revisions by each author, newest first
+	type contributor struct {
+		name    string
+		commits int
+	}
+	var contributors []*contributor
+	byKey := make(map[string]*contributor)
+	mailmap := w.mailmap()
+	err = commits.ForEach(func(c *object.Commit) error {
+		name, email := commitAuthor(c, mailmap)
+		key := strings.ToLower(email)
+		if key == "" {
+			key = name
+		}
+		con := byKey[key]
+		if con == nil {
+			con = &contributor{name: name}
+			byKey[key] = con
+			contributors = append(contributors, con)
+		}
+		con.commits++
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "git:repo:Log")
+	}
+
+	sort.SliceStable(contributors, func(i, j int) bool {
+		return contributors[i].commits > contributors[j].commits
+	})
+	names = make([]string, len(contributors))
+	for i, con := range contributors {
+		names[i] = con.name
+	}
+
+	c.mu.Lock()
+	if c.head == ref.Hash() {
+		c.names[pageName] = names
+	}
+	c.mu.Unlock()
+	return names, nil
+}
+
+func pageContributors(page *wikifier.Page) []string {
+	w, ok := page.Wiki.(*Wiki)
+	if !ok || page.External() {
+		return nil
+	}
+	names, err := w.Contributors(page.Name())
+	if err != nil {
+		w.Debug("contributors:", page.Name(), err)
+	}
+	return names
+}
@@ -0,0 +1,175 @@
+package wiki
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cooper/quiki/wikifier"
+)
+
+// A Dependency is a resource which a page depends on during generation.
+type Dependency struct {
+
+	// type of resource: CategoryTypeModel, CategoryTypeImage,
+	// CategoryTypePage, or CategoryTypeData
+	Type CategoryType `json:"type"`
+
+	// name of the model, image, linked page, or data file
+	Name string `json:"name"`
+}
+
+// find the dependencies of a page which has just been parsed
+func pageDependencies(page *wikifier.Page) []Dependency {
+	var deps []Dependency
+	for name := range page.Models {
+		deps = append(deps, Dependency{CategoryTypeModel, name})
+	}
+	for name := range page.Images {
+		deps = append(deps, Dependency{CategoryTypeImage, name})
+	}
+	for name := range page.PageLinks {
+		deps = append(deps, Dependency{CategoryTypePage, name})
+	}
+	for name := range page.DataFiles {
+		deps = append(deps, Dependency{CategoryTypeData, name})
+	}
+	sort.Slice(deps, func(i, j int) bool {
+		if deps[i].Type != deps[j].Type {
+			return deps[i].Type < deps[j].Type
+		}
+		return deps[i].Name < deps[j].Name
+	})
+	return deps
+}
+
+// Dependencies returns the models, images, pages, and data files which the named page
+// depended on when it was last generated.
+//
+// Dependencies are recorded whenever a page is generated, so the result is
+// empty for pages which have not been generated yet.
+func (w *Wiki) Dependencies(pageName string) []Dependency {
+	pageCat := w.GetSpecialCategory(w.Opt.PageNameNE(pageName), CategoryTypePage)
+	if !pageCat.Exists() {
+		return nil
+	}
+	return pageCat.Dependencies
+}
+
+// Dependents returns the names of pages which depend on the given resource,
+// in sorted order.
+func (w *Wiki) Dependents(dep Dependency) []string {
+	name := dep.Name
+	switch dep.Type {
+	case CategoryTypeModel:
+		name = wikifier.PageNameExt(name, ".model")
+	case CategoryTypePage:
+		name = w.Opt.PageNameNE(name)
+	}
+
+	cat := w.GetSpecialCategory(name, dep.Type)
+	cat.update(w)
+	if !cat.Exists() {
+		return nil
+	}
+	names := make([]string, 0, len(cat.Pages))
+	for name := range cat.Pages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// returns true if any dependency which affects the generated HTML of a page
+// has been modified since the given time.
+//
+// models and data are expanded into the page, and links to pages which have
+// since been created would no longer be marked as missing. images are referenced
+// by URL, so changes to them do not require the page to be regenerated.
+func (w *Wiki) dependenciesModifiedAfter(pageName string, t time.Time) bool {
+	for _, dep := range w.Dependencies(pageName) {
+		var path string
+		switch dep.Type {
+		case CategoryTypeModel:
+			path = w.pathForModel(dep.Name)
+		case CategoryTypePage:
+			path = w.pathForPage(dep.Name)
+		case CategoryTypeData:
+			path = w.pathForData(dep.Name)
+		default:
+			continue
+		}
+		if fi, err := os.Stat(path); err == nil && fi.ModTime().After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// RegenerateDependents regenerates the pages which depend on the given
+// resource, such as after a model has been modified. Only pages whose cached
+// copies are outdated are rebuilt. It returns the number of pages rebuilt.
+func (w *Wiki) RegenerateDependents(dep Dependency) int {
+	rebuilt := 0
+	for _, pageName := range w.Dependents(dep) {
+		w.Debug("regenerate dependent page:", pageName)
+		res := w.DisplayPageDraft(pageName, true)
+		if r, ok := res.(DisplayPage); ok && r.FromCache {
+			continue
+		}
+		rebuilt++
+	}
+	return rebuilt
+}
+
+// RegenerateLinkingPages regenerates the pages which link to a page that
+// has been created or deleted, so that their links to it are no longer or
+// are now marked as missing. Cached copies written before the given time are
+// discarded first, since a deleted page does not otherwise make them
+// outdated. It returns the number of pages rebuilt.
+func (w *Wiki) RegenerateLinkingPages(pageName string, since time.Time) int {
+	rebuilt := 0
+	for _, name := range w.linkingPages(pageName) {
+		if page := w.FindPage(name); page.CacheModified().Before(since) {
+			os.Remove(page.CachePath())
+		}
+		w.Debug("regenerate linking page:", name)
+		res := w.DisplayPageDraft(name, true)
+		if r, ok := res.(DisplayPage); ok && r.FromCache {
+			continue
+		}
+		rebuilt++
+	}
+	return rebuilt
+}
+
+// returns the names of pages which link to a page, in sorted order. links
+// are recorded with the case they were written in, which may differ from
+// that of the page file
+func (w *Wiki) linkingPages(pageName string) []string {
+	pageName = w.Opt.PageNameNE(pageName)
+	path := w.pathForCategory(pageName, CategoryTypePage, false)
+	files, _ := ioutil.ReadDir(filepath.Dir(path))
+	seen := make(map[string]bool)
+	var names []string
+	for _, fi := range files {
+		if fi.IsDir() || !strings.EqualFold(fi.Name(), filepath.Base(path)) {
+			continue
+		}
+		linked := strings.TrimSuffix(fi.Name(), ".cat")
+		if dir := filepath.Dir(filepath.FromSlash(pageName)); dir != "." {
+			linked = filepath.ToSlash(filepath.Join(dir, linked))
+		}
+		for _, name := range w.Dependents(Dependency{CategoryTypePage, linked}) {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,179 @@
+package wiki
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	shingleSize  = 5  // number of words per shingle
+	minhashBands = 20 // number of LSH bands
+	minhashRows  = 5  // number of signature rows per band
+)
+
+// SimilarPages describes two pages with similar content.
+type SimilarPages struct {
+	Pages      [2]string `json:"pages"`      // page filenames
+	Similarity float64   `json:"similarity"` // fraction of shared content, from 0 to 1
+}
+
+// DuplicatePages finds pairs of pages whose text content is at least
+// threshold similar, where 1 means identical. The results are ordered by
+// descending similarity and are useful for finding redundant articles which
+// might be merged.
+//
+// Similarity is the Jaccard index of the sets of overlapping five-word
+// sequences ("shingles") in each page's text. To scale to large wikis,
+// candidate pairs are found using MinHash signatures with locality-sensitive
+// hashing rather than comparing every pair of pages, so pairs only slightly
+// above a low threshold may occasionally be missed.
+func (w *Wiki) DuplicatePages(threshold float64) []SimilarPages {
+	var names []string
+	var sets []map[uint64]bool
+
+	// shingle each page
+	for _, name := range w.allPageFiles() {
+		set := textShingles(w.pageText(name))
+		if len(set) == 0 {
+			continue
+		}
+		names = append(names, name)
+		sets = append(sets, set)
+	}
+
+	// group pages by each band of their signatures. pages which share
+	// any band are candidates
+	candidates := make(map[[2]int]bool)
+	buckets := make(map[uint64][]int)
+	for i, set := range sets {
+		sig := minhashSignature(set)
+		for band := 0; band < minhashBands; band++ {
+			h := fnv.New64a()
+			binary.Write(h, binary.LittleEndian, uint64(band))
+			binary.Write(h, binary.LittleEndian, sig[band*minhashRows:(band+1)*minhashRows])
+			key := h.Sum64()
+			for _, j := range buckets[key] {
+				candidates[[2]int{j, i}] = true
+			}
+			buckets[key] = append(buckets[key], i)
+		}
+	}
+
+	// check actual similarity of candidates
+	var similar []SimilarPages
+	for pair := range candidates {
+		sim := jaccard(sets[pair[0]], sets[pair[1]])
+		if sim < threshold {
+			continue
+		}
+		pages := [2]string{names[pair[0]], names[pair[1]]}
+		if pages[1] < pages[0] {
+			pages[0], pages[1] = pages[1], pages[0]
+		}
+		similar = append(similar, SimilarPages{pages, sim})
+	}
+
+	// most similar first
+	sort.Slice(similar, func(i, j int) bool {
+		if similar[i].Similarity != similar[j].Similarity {
+			return similar[i].Similarity > similar[j].Similarity
+		}
+		if similar[i].Pages[0] != similar[j].Pages[0] {
+			return similar[i].Pages[0] < similar[j].Pages[0]
+		}
+		return similar[i].Pages[1] < similar[j].Pages[1]
+	})
+
+	return similar
+}
+
+// returns the text content of a page, preferably from the search text file
+func (w *Wiki) pageText(name string) string {
+	page := w.FindPage(name)
+
+	// use the search text if it is up-to-date
+	if fi, err := os.Stat(page.SearchPath()); err == nil && !page.Modified().After(fi.ModTime()) {
+		if text, err := ioutil.ReadFile(page.SearchPath()); err == nil {
+			return string(text)
+		}
+	}
+
+	// otherwise generate it
+	if err := page.Parse(); err != nil {
+		return ""
+	}
+	return page.Text()
+}
+
+// returns the set of hashed shingles for some text
+func textShingles(text string) map[uint64]bool {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) == 0 {
+		return nil
+	}
+
+	// short text is a single shingle
+	n := len(words) - shingleSize + 1
+	if n < 1 {
+		n = 1
+	}
+
+	set := make(map[uint64]bool, n)
+	for i := 0; i < n; i++ {
+		end := i + shingleSize
+		if end > len(words) {
+			end = len(words)
+		}
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:end], " ")))
+		set[h.Sum64()] = true
+	}
+	return set
+}
+
+// returns the MinHash signature of a set of shingles
+func minhashSignature(set map[uint64]bool) []uint64 {
+	sig := make([]uint64, minhashBands*minhashRows)
+	for i := range sig {
+		sig[i] = ^uint64(0)
+	}
+	for shingle := range set {
+		for i := range sig {
+			if h := mix64(shingle ^ uint64(i+1)*0x9e3779b97f4a7c15); h < sig[i] {
+				sig[i] = h
+			}
+		}
+	}
+	return sig
+}
+
+// splitmix64 finalizer, used to derive independent hash functions
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// returns the Jaccard index of two sets
+func jaccard(a, b map[uint64]bool) float64 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	shared := 0
+	for x := range a {
+		if b[x] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
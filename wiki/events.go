@@ -0,0 +1,118 @@
+package wiki
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"html"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// GenerateCalendar writes an iCalendar feed of upcoming events from the
+// events{} blocks of published pages to cache/events.ics, for subscription
+// in calendar apps. The root.ext option must be set.
+//
+// Events are found in generated pages, so pages are generated as needed.
+func (w *Wiki) GenerateCalendar() error {
+	if w.Opt.Root.Ext == "" {
+		return errors.New("@root.ext is not set")
+	}
+	base := strings.TrimSuffix(html.UnescapeString(w.Opt.Root.Ext), "/")
+	host := base
+	if u, err := url.Parse(base); err == nil && u.Host != "" {
+		host = u.Host
+	}
+
+	var cal icsWriter
+	cal.line("BEGIN", "VCALENDAR")
+	cal.line("VERSION", "2.0")
+	cal.line("PRODID", "-//quiki//quiki//EN")
+	cal.line("CALSCALE", "GREGORIAN")
+	cal.line("X-WR-CALNAME", icsText(w.Opt.Name))
+
+	// events which have not ended
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	usedUIDs := make(map[string]bool)
+	for _, info := range w.publishedPages() {
+		res, ok := w.DisplayPage(info.File).(DisplayPage)
+		if !ok {
+			continue
+		}
+		for _, ev := range res.Events {
+			if ev.AllDay && ev.End.Before(today) || !ev.AllDay && ev.End.Before(now) {
+				continue
+			}
+
+			// stable identifier, unique within the calendar
+			sum := sha1.Sum([]byte(info.File + "\x00" + ev.Start.Format(time.RFC3339) + "\x00" + ev.Title))
+			uid := hex.EncodeToString(sum[:])
+			for n := 2; usedUIDs[uid]; n++ {
+				uid = hex.EncodeToString(sum[:]) + "-" + strconv.Itoa(n)
+			}
+			usedUIDs[uid] = true
+
+			cal.line("BEGIN", "VEVENT")
+			cal.line("UID", uid+"@"+host)
+			stamp := now
+			if info.Modified != nil {
+				stamp = *info.Modified
+			}
+			cal.line("DTSTAMP", stamp.UTC().Format("20060102T150405Z"))
+			if ev.AllDay {
+				// the end date is exclusive
+				cal.line("DTSTART;VALUE=DATE", ev.Start.Format("20060102"))
+				cal.line("DTEND;VALUE=DATE", ev.End.AddDate(0, 0, 1).Format("20060102"))
+			} else {
+				// floating times, as written on the page
+				cal.line("DTSTART", ev.Start.Format("20060102T150405"))
+				if ev.End.After(ev.Start) {
+					cal.line("DTEND", ev.End.Format("20060102T150405"))
+				}
+			}
+			cal.line("SUMMARY", icsText(ev.Title))
+			cal.line("URL", w.pageURL(info))
+			cal.line("END", "VEVENT")
+		}
+	}
+
+	cal.line("END", "VCALENDAR")
+	return writeFileAtomic(w.Dir("cache", "events.ics"), []byte(cal.String()), 0644)
+}
+
+// writes iCalendar content lines, folded at 75 octets
+type icsWriter struct {
+	strings.Builder
+}
+
+func (cal *icsWriter) line(name, value string) {
+	s, limit := name+":"+value, 75
+	for len(s) > limit {
+		// do not split a UTF-8 sequence
+		i := limit
+		for i > 0 && s[i]&0xC0 == 0x80 {
+			i--
+		}
+		cal.WriteString(s[:i] + "\r\n ")
+		s = s[i:]
+
+		// continuation lines begin with a space
+		limit = 74
+	}
+	cal.WriteString(s + "\r\n")
+}
+
+// escapes text for an iCalendar value
+func icsText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
@@ -0,0 +1,384 @@
+package wiki
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// working links are checked again after this long
+	externalLinkRecheck = 7 * 24 * time.Hour
+
+	// dead links are checked again sooner, since sites are often down only
+	// temporarily
+	deadLinkRecheck = 24 * time.Hour
+
+	// most links checked by each run of CheckExternalLinks. the links of
+	// large wikis are checked over several runs, least recently checked first
+	externalLinkMaxChecks = 500
+
+	// number of hosts checked at once. links to the same host are checked
+	// one at a time, waiting externalLinkDelay between requests
+	externalLinkWorkers = 4
+	externalLinkDelay   = 2 * time.Second
+)
+
+var (
+	// requests are made directly rather than through a proxy, so that the
+	// address of each connection can be checked
+	externalLinkClient = &http.Client{
+		Timeout: 15 * time.Second,
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout: 10 * time.Second,
+				Control: publicAddressOnly,
+			}).DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+
+	// Wayback Machine availability API, which suggests archived copies of
+	// dead links
+	waybackAPI = "https://archive.org/wayback/available"
+)
+
+// An ExternalLink is a web address linked to by the published pages of a
+// wiki, along with the result of the last time it was checked.
+type ExternalLink struct {
+	URL         string           `json:"url"`
+	Status      int              `json:"status,omitempty"`       // HTTP status of the last check, or 0 if the request failed
+	Error       string           `json:"error,omitempty"`        // error from the last check, if the request failed
+	Checked     *time.Time       `json:"checked,omitempty"`      // time of the last check
+	LastWorking *time.Time       `json:"last_working,omitempty"` // time the link last worked, if ever
+	Archive     string           `json:"archive,omitempty"`      // archived copy from the Wayback Machine, if dead
+	Pages       map[string][]int `json:"pages,omitempty"`        // filenames of the linking pages to line numbers of the links
+}
+
+// Dead returns true if the link did not work when it was last checked.
+//
+// Links are dead if the request failed or the server responded 404 Not Found,
+// 410 Gone, or with a server error. Other errors, such as 403 Forbidden from
+// sites which block automated requests, do not mean that a link is dead.
+func (l ExternalLink) Dead() bool {
+	if l.Checked == nil {
+		return false
+	}
+	return l.Error != "" || l.Status == http.StatusNotFound || l.Status == http.StatusGone || l.Status >= 500
+}
+
+// CheckExternalLinks checks the web addresses linked to by published pages,
+// recording the HTTP status of each in cache/external-links.json. It returns
+// the number of links checked and the number of dead links found.
+//
+// Links which were checked recently are not checked again, and requests to
+// the same host are spaced apart. For each link which is newly dead, the
+// Wayback Machine is asked for a copy archived around the time it last
+// worked.
+//
+// Only the links recorded by updatePageCategories are checked. If a check is
+// already running, such as one started by the scheduler, CheckExternalLinks
+// waits for it to finish, so the results of neither are lost.
+func (w *Wiki) CheckExternalLinks() (checked, dead int, err error) {
+	w.externalLinksLock.Lock()
+	defer w.externalLinksLock.Unlock()
+
+	linked := w.externalLinkPages()
+	links := w.readExternalLinks()
+	now := time.Now()
+
+	// links due to be checked, least recently checked first
+	var due []*ExternalLink
+	for u := range linked {
+		link := links[u]
+		if link == nil {
+			link = &ExternalLink{URL: u}
+			links[u] = link
+		}
+		recheck := externalLinkRecheck
+		if link.Dead() {
+			recheck = deadLinkRecheck
+		}
+		if link.Checked == nil || now.Sub(*link.Checked) >= recheck {
+			due = append(due, link)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].Checked == nil || due[j].Checked == nil {
+			return due[i].Checked == nil && due[j].Checked != nil
+		}
+		return due[i].Checked.Before(*due[j].Checked)
+	})
+	if len(due) > externalLinkMaxChecks {
+		due = due[:externalLinkMaxChecks]
+	}
+
+	// group by host, so each host is checked by one worker
+	byHost := make(map[string][]*ExternalLink)
+	var hosts []string
+	for _, link := range due {
+		host := ""
+		if u, err := url.Parse(link.URL); err == nil {
+			host = strings.ToLower(u.Host)
+		}
+		if byHost[host] == nil {
+			hosts = append(hosts, host)
+		}
+		byHost[host] = append(byHost[host], link)
+	}
+
+	// check each host
+	queue := make(chan []*ExternalLink)
+	var wg sync.WaitGroup
+	for i := 0; i < externalLinkWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for hostLinks := range queue {
+				for i, link := range hostLinks {
+					if i != 0 {
+						time.Sleep(externalLinkDelay)
+					}
+					checkExternalLink(link)
+				}
+			}
+		}()
+	}
+	for _, host := range hosts {
+		queue <- byHost[host]
+	}
+	close(queue)
+	wg.Wait()
+
+	// suggest archived copies of newly dead links. these are all requests
+	// to the same host, so they are spaced apart too
+	first := true
+	for _, link := range due {
+		if !link.Dead() || link.Archive != "" {
+			continue
+		}
+		if !first {
+			time.Sleep(externalLinkDelay)
+		}
+		first = false
+		link.Archive = waybackSnapshot(link.URL, link.LastWorking)
+	}
+
+	// forget links which are no longer linked to
+	for u := range links {
+		if _, ok := linked[u]; !ok {
+			delete(links, u)
+		}
+	}
+	for _, link := range links {
+		if link.Dead() {
+			dead++
+		}
+	}
+	return len(due), dead, w.writeExternalLinks(links)
+}
+
+// DeadExternalLinks returns the web addresses linked to by published pages
+// which did not work when CheckExternalLinks last checked them, each with the
+// pages linking to it, most linked first.
+func (w *Wiki) DeadExternalLinks() []ExternalLink {
+	linked := w.externalLinkPages()
+	var dead []ExternalLink
+	for u, link := range w.readExternalLinks() {
+		pages, ok := linked[u]
+		if !ok || !link.Dead() {
+			continue
+		}
+		link.Pages = pages
+		dead = append(dead, *link)
+	}
+	sort.Slice(dead, func(i, j int) bool {
+		if len(dead[i].Pages) != len(dead[j].Pages) {
+			return len(dead[i].Pages) > len(dead[j].Pages)
+		}
+		return dead[i].URL < dead[j].URL
+	})
+	return dead
+}
+
+// finds the web addresses linked to by published pages, each with the
+// filenames of the linking pages mapped to line numbers
+func (w *Wiki) externalLinkPages() map[string]map[string][]int {
+	linked := make(map[string]map[string][]int)
+	for _, info := range w.publishedPages() {
+		pageCat := w.GetSpecialCategory(w.Opt.PageNameNE(info.File), CategoryTypePage)
+		if !pageCat.Exists() {
+			continue
+		}
+		for u, lines := range pageCat.ExternalLinks {
+			if linked[u] == nil {
+				linked[u] = make(map[string][]int)
+			}
+			linked[u][info.File] = lines
+		}
+	}
+	return linked
+}
+
+// reads the results of previous checks, by URL
+func (w *Wiki) readExternalLinks() map[string]*ExternalLink {
+	links := make(map[string]*ExternalLink)
+	data, err := ioutil.ReadFile(w.Dir("cache", "external-links.json"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			w.Logf("read external links: %v", err)
+		}
+		return links
+	}
+	var list []*ExternalLink
+	if err := json.Unmarshal(data, &list); err != nil {
+		w.Logf("read external links: %v", err)
+		return links
+	}
+	for _, link := range list {
+		links[link.URL] = link
+	}
+	return links
+}
+
+// writes the results of checks, sorted by URL
+func (w *Wiki) writeExternalLinks(links map[string]*ExternalLink) error {
+	list := make([]*ExternalLink, 0, len(links))
+	for _, link := range links {
+		link.Pages = nil
+		list = append(list, link)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].URL < list[j].URL
+	})
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(w.Dir("cache", "external-links.json"), append(data, '\n'), 0644)
+}
+
+// checks a link and records the result
+func checkExternalLink(link *ExternalLink) {
+	status, err := externalLinkStatus(http.MethodHead, link.URL)
+
+	// some servers do not support HEAD, so errors are confirmed with GET
+	if err == nil && status >= 400 {
+		status, err = externalLinkStatus(http.MethodGet, link.URL)
+	}
+
+	now := time.Now()
+	link.Checked = &now
+	link.Status = status
+	link.Error = ""
+	if err != nil {
+		link.Status = 0
+		link.Error = err.Error()
+	}
+	if link.Error == "" && link.Status < 400 {
+		link.LastWorking = &now
+		link.Archive = ""
+	}
+}
+
+// requests a URL and returns the HTTP status. redirects are followed
+func externalLinkStatus(method, link string) (int, error) {
+	req, err := http.NewRequest(method, link, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "quiki link checker")
+	res, err := externalLinkClient.Do(req)
+	if err != nil {
+
+		// the error without the method and URL
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return 0, err
+	}
+	res.Body.Close()
+	return res.StatusCode, nil
+}
+
+// refuses connections to addresses which are not on the public internet, so
+// that links cannot be used to probe the server or its local network. this is
+// checked for each connection, including those made to follow redirects
+func publicAddressOnly(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsGlobalUnicast() {
+		return errors.New("refusing to connect to non-public address " + host)
+	}
+	for _, block := range privateNetworks {
+		if block.Contains(ip) {
+			return errors.New("refusing to connect to non-public address " + host)
+		}
+	}
+	return nil
+}
+
+// private and shared address space, which IsGlobalUnicast allows
+var privateNetworks = func() []*net.IPNet {
+	var blocks []*net.IPNet
+	for _, cidr := range []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7"} {
+		_, block, _ := net.ParseCIDR(cidr)
+		blocks = append(blocks, block)
+	}
+	return blocks
+}()
+
+// response of the Wayback Machine availability API
+type waybackAvailability struct {
+	ArchivedSnapshots struct {
+		Closest struct {
+			Available bool   `json:"available"`
+			URL       string `json:"url"`
+			Status    string `json:"status"`
+		} `json:"closest"`
+	} `json:"archived_snapshots"`
+}
+
+// returns the URL of the archived copy of a link closest to the time it
+// last worked, or an empty string if there is none
+func waybackSnapshot(link string, lastWorking *time.Time) string {
+	query := url.Values{"url": {link}}
+	if lastWorking != nil {
+		query.Set("timestamp", lastWorking.UTC().Format("20060102"))
+	}
+	req, err := http.NewRequest(http.MethodGet, waybackAPI+"?"+query.Encode(), nil)
+	if err != nil {
+		return ""
+	}
+	req.Header.Set("User-Agent", "quiki link checker")
+	res, err := externalLinkClient.Do(req)
+	if err != nil {
+		return ""
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return ""
+	}
+	var avail waybackAvailability
+	if err := json.NewDecoder(res.Body).Decode(&avail); err != nil {
+		return ""
+	}
+	closest := avail.ArchivedSnapshots.Closest
+	if !closest.Available || closest.Status != "200" {
+		return ""
+	}
+	return closest.URL
+}
@@ -148,7 +148,11 @@ func makeRelPath(absPath, base string) string {
 }
 
 func (w *Wiki) allPageFiles() []string {
-	files, _ := wikifier.UniqueFilesInDir(w.Opt.Dir.Page, []string{"page", "md"}, false)
+	var exts []string
+	for _, ext := range w.Opt.PageExtensions() {
+		exts = append(exts, strings.TrimPrefix(ext, "."))
+	}
+	files, _ := wikifier.UniqueFilesInDir(w.Opt.Dir.Page, exts, false)
 	return files
 }
 
@@ -175,12 +179,12 @@ func (w *Wiki) allImageFiles() []string {
 func (w *Wiki) pathForPage(pageName string) string {
 
 	// try lowercased version first (quiki style)
-	lcPageName := filepath.FromSlash(wikifier.PageName(pageName))
+	lcPageName := filepath.FromSlash(w.Opt.PageName(pageName))
 	path, _ := filepath.Abs(filepath.Join(w.Opt.Dir.Page, lcPageName))
 
 	// it doesn't exist; try non-lowercased version (markdown/etc)
 	if _, err := os.Stat(path); err != nil {
-		normalPageName := filepath.FromSlash(wikifier.PageName(pageName))
+		normalPageName := filepath.FromSlash(w.Opt.PageName(pageName))
 		normalPath, _ := filepath.Abs(filepath.Join(w.Opt.Dir.Page, normalPageName))
 		if _, err := os.Stat(normalPath); err == nil {
 			return normalPath
@@ -225,6 +229,12 @@ func (w *Wiki) pathForModel(modelName string) string {
 	return path
 }
 
+// pathForData returns the absolute path for a data file.
+func (w *Wiki) pathForData(dataName string) string {
+	path, _ := filepath.Abs(filepath.Join(w.Opt.Dir.Wiki, "data", filepath.FromSlash(dataName)))
+	return path
+}
+
 // Dir returns the absolute path to the resolved wiki directory.
 // If the wiki directory is a symlink, it is followed.
 //
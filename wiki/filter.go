@@ -0,0 +1,190 @@
+package wiki
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cooper/go-git/v4"
+	"github.com/cooper/go-git/v4/plumbing/object"
+	"github.com/cooper/go-git/v4/plumbing/storer"
+)
+
+// A FilterError is returned by WritePage and ProposeFile when a change fails
+// the content filters configured by the filter.* wiki options.
+type FilterError struct {
+	File   string  // filename relative to the wiki directory
+	Reason string  // description of the filter which failed
+	Review *Review // if not nil, the change was held for review instead
+}
+
+func (e *FilterError) Error() string {
+	if e.Review != nil {
+		return "change held for review: " + e.Reason
+	}
+	return "change rejected: " + e.Reason
+}
+
+var externalLinkRegex = regexp.MustCompile(`(?i)\bhttps?://[^\s\[\]{}|;<>"']+`)
+
+// checks a change to a file, which is relative to the wiki directory, and
+// returns a FilterError if it fails a filter. if filter.action is review, a
+// direct write is proposed for review instead
+func (w *Wiki) filterWrite(name string, content []byte, commit CommitOpts) error {
+	reason := w.checkFilters(name, content, commit)
+	if reason == "" {
+		return nil
+	}
+	if w.Opt.Filter.Action == "review" && w.Opt.Review.Enable {
+		r, err := w.proposeFile(name, content, commit, reason)
+		if err != nil {
+			return err
+		}
+		w.audit("filter.review", name, commit, reason)
+		return &FilterError{File: name, Reason: reason, Review: r}
+	}
+	w.audit("filter.reject", name, commit, reason)
+	return &FilterError{File: name, Reason: reason}
+}
+
+// returns a description of the first filter which a change fails, or an
+// empty string if it passes them all. editors are not subject to filters
+func (w *Wiki) checkFilters(name string, content []byte, commit CommitOpts) string {
+	opt := w.Opt.Filter
+	if w.Opt.Review.Enable && commit.User != "" && w.IsEditor(commit.User) {
+		return ""
+	}
+	if opt.Blocklist == "" && opt.MaxLinks == 0 && opt.NewUserEdits == 0 {
+		return ""
+	}
+
+	// only content added by the change is checked
+	original, _ := ioutil.ReadFile(w.UnresolvedAbsFilePath(name))
+
+	// blocked patterns
+	if opt.Blocklist != "" {
+		for _, re := range w.filterBlocklist() {
+			if match := addedMatch(re, string(original), string(content)); match != "" {
+				return "adds " + strconv.Quote(match) + ", which matches a blocked pattern"
+			}
+		}
+	}
+
+	// external links
+	links := addedLinks(string(original), string(content))
+	if links == 0 {
+		return ""
+	}
+	if opt.NewUserEdits != 0 && links > opt.NewUserMaxLinks && w.isNewUser(commit) {
+		if opt.NewUserMaxLinks == 0 {
+			return "new users cannot add external links"
+		}
+		return "adds " + strconv.Itoa(links) + " external links; new users can add at most " +
+			strconv.Itoa(opt.NewUserMaxLinks)
+	}
+	if opt.MaxLinks != 0 && links > opt.MaxLinks {
+		return "adds " + strconv.Itoa(links) + " external links; at most " +
+			strconv.Itoa(opt.MaxLinks) + " are allowed"
+	}
+	return ""
+}
+
+// reads the patterns in the filter.blocklist file, one per line. they are
+// matched case-insensitively. blank lines and those starting with # are
+// ignored
+func (w *Wiki) filterBlocklist() []*regexp.Regexp {
+	f, err := os.Open(w.Dir(filepath.FromSlash(w.Opt.Filter.Blocklist)))
+	if err != nil {
+		w.Logf("filter.blocklist: %v", err)
+		return nil
+	}
+	defer f.Close()
+
+	var patterns []*regexp.Regexp
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		pattern := strings.TrimSpace(scanner.Text())
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			w.Logf("filter.blocklist: line %d: %v", line, err)
+			continue
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns
+}
+
+// returns the first match of a pattern in new content which is not also in
+// the original content, or an empty string if there is none
+func addedMatch(re *regexp.Regexp, original, content string) string {
+	existing := make(map[string]int)
+	for _, match := range re.FindAllString(original, -1) {
+		existing[match]++
+	}
+	for _, match := range re.FindAllString(content, -1) {
+		if existing[match] == 0 {
+			return match
+		}
+		existing[match]--
+	}
+	return ""
+}
+
+// returns the number of external links in new content which are not also in
+// the original content
+func addedLinks(original, content string) int {
+	existing := make(map[string]int)
+	for _, link := range externalLinkRegex.FindAllString(original, -1) {
+		existing[strings.ToLower(link)]++
+	}
+	added := 0
+	for _, link := range externalLinkRegex.FindAllString(content, -1) {
+		if existing[strings.ToLower(link)] == 0 {
+			added++
+			continue
+		}
+		existing[strings.ToLower(link)]--
+	}
+	return added
+}
+
+// returns true if the user committing changes has made fewer revisions than
+// filter.new_user.edits. revisions are matched by email address, so users
+// without one are always new
+func (w *Wiki) isNewUser(commit CommitOpts) bool {
+	if commit.Email == "" {
+		return true
+	}
+	if w._repo == nil {
+		if _, err := os.Stat(w.Dir(".git")); err != nil {
+			return true
+		}
+	}
+	repo, err := w.repo()
+	if err != nil {
+		return true
+	}
+	commits, err := repo.Log(&git.LogOptions{})
+	if err != nil {
+		return true
+	}
+	edits := 0
+	mailmap := w.mailmap()
+	commits.ForEach(func(c *object.Commit) error {
+		if _, email := commitAuthor(c, mailmap); strings.EqualFold(email, commit.Email) {
+			edits++
+		}
+		if edits >= w.Opt.Filter.NewUserEdits {
+			return storer.ErrStop
+		}
+		return nil
+	})
+	return edits < w.Opt.Filter.NewUserEdits
+}
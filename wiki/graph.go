@@ -0,0 +1,96 @@
+package wiki
+
+import (
+	"sort"
+	"strings"
+)
+
+// A LinkGraph describes the links between the published pages of a wiki.
+type LinkGraph struct {
+	Nodes []GraphNode `json:"nodes"` // pages, sorted by title
+	Links []GraphLink `json:"links"` // links between them
+}
+
+// A GraphNode is a page in a LinkGraph.
+type GraphNode struct {
+	File       string   `json:"file"`                 // page filename
+	Title      string   `json:"title"`                // page title, or name without extension
+	Categories []string `json:"categories,omitempty"` // categories the page belongs to
+	Cluster    string   `json:"cluster,omitempty"`    // largest category, for grouping related pages
+	Backlinks  int      `json:"backlinks"`            // number of pages linking to this one
+	Links      int      `json:"links"`                // number of pages this one links to
+}
+
+// A GraphLink is a link from one page to another in a LinkGraph.
+type GraphLink struct {
+	Source string `json:"source"` // filename of the linking page
+	Target string `json:"target"` // filename of the linked page
+}
+
+// LinkGraph returns the graph of links between the published pages of the
+// wiki.
+//
+// Links come from those recorded by updatePageCategories.
+func (w *Wiki) LinkGraph() LinkGraph {
+	graph := LinkGraph{Nodes: []GraphNode{}, Links: []GraphLink{}}
+
+	// published pages, by lowercase name without extension
+	index := make(map[string]int)
+	for _, info := range w.PagesSorted(false, SortTitle) {
+		if info.File == "" || info.Draft || info.Redirect != "" || info.Error != nil {
+			continue
+		}
+		title := info.Title
+		if title == "" {
+			title = info.FileNE
+		}
+		index[strings.ToLower(info.FileNE)] = len(graph.Nodes)
+		graph.Nodes = append(graph.Nodes, GraphNode{File: info.File, Title: title})
+	}
+
+	// categories, largest first so that each page is clustered by the
+	// largest one it belongs to
+	cats := w.Categories()
+	sort.SliceStable(cats, func(i, j int) bool {
+		if len(cats[i].Pages) != len(cats[j].Pages) {
+			return len(cats[i].Pages) > len(cats[j].Pages)
+		}
+		return cats[i].Name < cats[j].Name
+	})
+	for _, cat := range cats {
+		for file := range cat.Pages {
+			i, ok := index[strings.ToLower(w.Opt.PageNameNE(file))]
+			if !ok {
+				continue
+			}
+			node := &graph.Nodes[i]
+			node.Categories = append(node.Categories, cat.Name)
+			if node.Cluster == "" {
+				node.Cluster = cat.Name
+			}
+		}
+	}
+
+	// links between published pages
+	for i := range graph.Nodes {
+		source := &graph.Nodes[i]
+		sort.Strings(source.Categories)
+		seen := make(map[int]bool)
+		for _, dep := range w.Dependencies(source.File) {
+			if dep.Type != CategoryTypePage {
+				continue
+			}
+			j, ok := index[strings.ToLower(w.Opt.PageNameNE(dep.Name))]
+			if !ok || j == i || seen[j] {
+				continue
+			}
+			seen[j] = true
+			target := &graph.Nodes[j]
+			source.Links++
+			target.Backlinks++
+			graph.Links = append(graph.Links, GraphLink{Source: source.File, Target: target.File})
+		}
+	}
+
+	return graph
+}
@@ -0,0 +1,62 @@
+package wiki
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// A HealthCheck is the result of checking one part of a wiki.
+type HealthCheck struct {
+	Name  string `json:"name"`            // config, repo, or cache
+	OK    bool   `json:"ok"`              // true if the check passed
+	Error string `json:"error,omitempty"` // reason the check failed
+}
+
+// CheckHealth verifies that the wiki configuration file can be parsed, the
+// repository can be opened, and the cache directory is writable.
+func (w *Wiki) CheckHealth() []HealthCheck {
+	return []HealthCheck{
+		healthCheck("config", w.checkConfig()),
+		healthCheck("repo", w.checkRepo()),
+		healthCheck("cache", w.checkCache()),
+	}
+}
+
+func healthCheck(name string, err error) HealthCheck {
+	if err != nil {
+		return HealthCheck{Name: name, Error: err.Error()}
+	}
+	return HealthCheck{Name: name, OK: true}
+}
+
+// parse the config file again without affecting the loaded options
+func (w *Wiki) checkConfig() error {
+	tmp := &Wiki{Opt: defaultWikiOpt}
+	tmp.Opt.Dir.Wiki = w.Opt.Dir.Wiki
+	return tmp.readConfig(w.ConfigFile)
+}
+
+func (w *Wiki) checkRepo() error {
+	repo, err := w.repo()
+	if err != nil {
+		return err
+	}
+	if _, err := repo.Head(); err != nil {
+		return errors.Wrap(err, "git:repo:Head")
+	}
+	return nil
+}
+
+func (w *Wiki) checkCache() error {
+	if err := os.MkdirAll(w.Opt.Dir.Cache, 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(w.Opt.Dir.Cache, ".health")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
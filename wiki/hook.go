@@ -0,0 +1,104 @@
+package wiki
+
+import (
+	"sync"
+
+	"github.com/cooper/quiki/wikifier"
+)
+
+// PageHookStage is a point in page generation at which hooks are called.
+type PageHookStage int
+
+const (
+	// HookBeforeParse is called before a page is parsed.
+	// Hooks can set page variables or options here.
+	HookBeforeParse PageHookStage = iota
+
+	// HookAfterParse is called after a page is parsed successfully.
+	HookAfterParse
+
+	// HookBeforeHTML is called after page metadata has been extracted but
+	// before HTML is generated.
+	HookBeforeHTML
+
+	// HookAfterHTML is called after HTML and CSS are generated but before
+	// they are written to the cache. Hooks can modify the result's Content
+	// and CSS here, for example to rewrite links or inject analytics.
+	HookAfterHTML
+
+	// HookAfterWrite is called after the cache and search files are written.
+	HookAfterWrite
+)
+
+// A PageHook is a function called during page generation.
+//
+// r is the display result being built. Before HookBeforeHTML, only the
+// file and path information is available.
+type PageHook func(w *Wiki, page *wikifier.Page, r *DisplayPage)
+
+var (
+	globalPageHooks = make(map[PageHookStage][]PageHook)
+	pageHooksLock   sync.RWMutex
+)
+
+// AddPageHook registers a hook which is called for pages on all wikis.
+func AddPageHook(stage PageHookStage, hook PageHook) {
+	pageHooksLock.Lock()
+	defer pageHooksLock.Unlock()
+	globalPageHooks[stage] = append(globalPageHooks[stage], hook)
+}
+
+// AddPageHook registers a hook which is called for pages on this wiki only.
+// Hooks for a specific wiki are called after those registered globally.
+func (w *Wiki) AddPageHook(stage PageHookStage, hook PageHook) {
+	pageHooksLock.Lock()
+	defer pageHooksLock.Unlock()
+	if w.pageHooks == nil {
+		w.pageHooks = make(map[PageHookStage][]PageHook)
+	}
+	w.pageHooks[stage] = append(w.pageHooks[stage], hook)
+}
+
+// call all hooks for a stage
+func (w *Wiki) runPageHooks(stage PageHookStage, page *wikifier.Page, r *DisplayPage) {
+	pageHooksLock.RLock()
+	hooks := append(append([]PageHook(nil), globalPageHooks[stage]...), w.pageHooks[stage]...)
+	pageHooksLock.RUnlock()
+	for _, hook := range hooks {
+		hook(w, page, r)
+	}
+}
+
+// A Change describes a file in the wiki which was written or deleted.
+type Change struct {
+	File       string // filename relative to the wiki directory
+	Deleted    bool   // true if the file was deleted
+	Commit     string // hash of the commit which made the change
+	CommitOpts        // author and comment
+}
+
+// A ChangeHook is a function called after a change to a file in the wiki is
+// committed.
+type ChangeHook func(w *Wiki, c Change)
+
+var (
+	changeHooks     []ChangeHook
+	changeHooksLock sync.RWMutex
+)
+
+// AddChangeHook registers a hook which is called for changes on all wikis.
+func AddChangeHook(hook ChangeHook) {
+	changeHooksLock.Lock()
+	defer changeHooksLock.Unlock()
+	changeHooks = append(changeHooks, hook)
+}
+
+// call all change hooks
+func (w *Wiki) runChangeHooks(c Change) {
+	changeHooksLock.RLock()
+	hooks := append([]ChangeHook(nil), changeHooks...)
+	changeHooksLock.RUnlock()
+	for _, hook := range hooks {
+		hook(w, c)
+	}
+}
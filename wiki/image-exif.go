@@ -0,0 +1,208 @@
+package wiki
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cooper/quiki/wikifier"
+	"github.com/disintegration/imaging"
+	"github.com/pkg/errors"
+)
+
+// JPEG segments which hold metadata: APP1 (EXIF and XMP), APP13 (IPTC), and
+// comments. others, such as ICC color profiles, affect how the image looks
+var jpegMetadataMarkers = map[byte]bool{0xe1: true, 0xed: true, 0xfe: true}
+
+// PNG chunks which hold metadata
+var pngMetadataChunks = map[string]bool{"tEXt": true, "zTXt": true, "iTXt": true, "eXIf": true, "tIME": true}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// returns the EXIF orientation of a JPEG image, from 1 to 8, or 0 if it has
+// none. only the start of the file is read, where the EXIF data is found
+func readOrientation(path string) int {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(file, 256<<10))
+	if err != nil {
+		return 0
+	}
+	return jpegOrientation(data)
+}
+
+// returns the EXIF orientation of JPEG data, or 0 if it has none
+func jpegOrientation(data []byte) int {
+	orientation := 0
+	jpegSegments(data, func(marker byte, segment []byte) {
+		if marker == 0xe1 && orientation == 0 {
+			orientation = exifOrientation(segment[4:])
+		}
+	})
+	return orientation
+}
+
+// orientations 5 through 8 are rotated by 90 degrees, so the width and
+// height are swapped when the image is displayed
+func orientationTransposed(orientation int) bool {
+	return orientation >= 5 && orientation <= 8
+}
+
+// finds the orientation tag in the first IFD of EXIF data
+func exifOrientation(exif []byte) int {
+	if !bytes.HasPrefix(exif, []byte("Exif\x00\x00")) {
+		return 0
+	}
+	tiff := exif[6:]
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0
+	}
+	for i := 0; i < int(order.Uint16(tiff[ifd:])); i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:]) != 0x0112 {
+			continue
+		}
+		if orientation := int(order.Uint16(tiff[entry+8:])); orientation <= 8 {
+			return orientation
+		}
+		return 0
+	}
+	return 0
+}
+
+// calls fn with each segment of a JPEG before the image data, including its
+// marker. returns the offset of the image data, or -1 if it is not a JPEG
+func jpegSegments(data []byte, fn func(marker byte, segment []byte)) int {
+	if len(data) < 2 || data[0] != 0xff || data[1] != 0xd8 {
+		return -1
+	}
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xff {
+			return -1
+		}
+		marker := data[i+1]
+		switch marker {
+		case 0xff: // fill byte
+			i++
+			continue
+		case 0xda: // start of scan
+			return i
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return -1
+		}
+		fn(marker, data[i:i+2+size])
+		i += 2 + size
+	}
+	return -1
+}
+
+// removes metadata from JPEG or PNG data without re-encoding it
+func stripMetadata(data []byte) ([]byte, error) {
+
+	// png
+	if bytes.HasPrefix(data, pngSignature) {
+		stripped := append([]byte(nil), pngSignature...)
+		for i := len(pngSignature); i < len(data); {
+			if i+12 > len(data) {
+				return nil, errors.New("malformed png")
+			}
+			end := i + 12 + int(binary.BigEndian.Uint32(data[i:]))
+			if end < i || end > len(data) {
+				return nil, errors.New("malformed png")
+			}
+			if !pngMetadataChunks[string(data[i+4:i+8])] {
+				stripped = append(stripped, data[i:end]...)
+			}
+			i = end
+		}
+		return stripped, nil
+	}
+
+	// jpeg
+	stripped := append([]byte(nil), data[:2]...)
+	start := jpegSegments(data, func(marker byte, segment []byte) {
+		if !jpegMetadataMarkers[marker] {
+			stripped = append(stripped, segment...)
+		}
+	})
+	if start == -1 {
+		return nil, errors.New("malformed jpeg")
+	}
+	return append(stripped, data[start:]...), nil
+}
+
+// returns the path of a copy of a full-size image without metadata, which is
+// created in the cache when the image is newer. if the image has an EXIF
+// orientation which must be applied, the copy is rotated instead, since the
+// orientation is lost with the metadata
+func (w *Wiki) strippedImage(name, path string, fi os.FileInfo) (string, os.FileInfo, error) {
+	ext := strings.ToLower(filepath.Ext(name))
+	if ext != ".jpg" && ext != ".jpeg" && ext != ".png" {
+		return path, fi, nil
+	}
+
+	// use the cached copy unless the original is newer
+	cachePath := w.Opt.Dir.Cache + "/image/" + name
+	if cacheFi, err := os.Stat(cachePath); err == nil && !cacheFi.ModTime().Before(fi.ModTime()) {
+		return cachePath, cacheFi, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	w.Debug("strip image:", name)
+	wikifier.MakeDir(w.Opt.Dir.Cache+"/image/", name)
+
+	if orientation := jpegOrientation(data); w.Opt.Image.AutoOrient && orientation > 1 {
+		img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+		if err == nil {
+			err = writeFileAtomicFunc(cachePath, 0644, func(w io.Writer) error {
+				return imaging.Encode(w, img, imaging.JPEG, imaging.JPEGQuality(95))
+			})
+		}
+		if err != nil {
+			return "", nil, err
+		}
+	} else {
+		stripped, err := stripMetadata(data)
+		if err == nil {
+			err = writeFileAtomic(cachePath, stripped, 0644)
+		}
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	cacheFi, err := os.Stat(cachePath)
+	if err != nil {
+		return "", nil, err
+	}
+	return cachePath, cacheFi, nil
+}
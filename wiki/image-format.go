@@ -0,0 +1,158 @@
+package wiki
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	httpdate "github.com/Songmu/go-httpdate"
+	"github.com/cooper/quiki/wikifier"
+	"github.com/pkg/errors"
+)
+
+// mime types of the formats images can be converted to
+var imageFormatMimes = map[string]string{
+	"avif": "image/avif",
+	"webp": "image/webp",
+}
+
+// how long a conversion command may run before it is killed
+const imageConvertTimeout = time.Minute
+
+// converts an image used on a page to another format, so that it is only
+// offered in that format once the conversion succeeded. the result is
+// cached, so this usually only checks that it is
+func defaultImageConverter(path, format string, page *wikifier.Page) bool {
+	w, ok := page.Wiki.(*Wiki)
+	if !ok {
+		return false
+	}
+	sized := SizedImageFromName(strings.TrimPrefix(path, page.Opt.Root.Image+"/"))
+	sized.Format = format
+	_, ok = w.DisplaySizedImageGenerate(sized, true).(DisplayImage)
+	return ok
+}
+
+// displays an image converted to another format with the command configured
+// for it. the image is first displayed in its own format, and the result is
+// converted and cached alongside it, as in 100x200-myimage.png.webp
+func (w *Wiki) displayImageFormat(img SizedImage, generateOK, retinaOK bool) interface{} {
+	var r DisplayImage
+	logName := img.ScaleName()
+
+	command := w.Opt.Image.FormatCommand(img.Format)
+	if command == "" {
+		return DisplayError{
+			Error:         "Unknown image type.",
+			DetailedError: "Image format '" + img.Format + "' is not enabled",
+		}
+	}
+	r.ImageType = img.Format
+	r.Mime = imageFormatMimes[img.Format]
+
+	// pregenerate each retina scale in this format too
+	if img.Scale <= 1 && retinaOK {
+		for _, scale := range w.Opt.Image.Retina {
+			w.Debugf("display image: %s: also generating retina @%dx", logName, scale)
+			scaledImage := img
+			scaledImage.Scale = scale
+			w.displaySizedImage(scaledImage, generateOK, false)
+		}
+	}
+
+	// the image in its own format, which is converted
+	srcImage := img
+	srcImage.Format = ""
+	src, ok := w.displaySizedImage(srcImage, generateOK, false).(DisplayImage)
+	if !ok {
+		return DisplayError{
+			Error:         "Image does not exist.",
+			DetailedError: "Image '" + srcImage.ScaleName() + "' could not be displayed",
+		}
+	}
+	srcFi, err := os.Stat(src.Path)
+	if err != nil {
+		return DisplayError{
+			Error:         "Image does not exist.",
+			DetailedError: "Image '" + src.Path + "' error: " + err.Error(),
+		}
+	}
+	r.FullsizePath = src.FullsizePath
+
+	// use the cached conversion unless the source is newer
+	trueName := img.TrueName()
+	cachePath := w.Opt.Dir.Cache + "/image/" + trueName
+	wikifier.MakeDir(w.Opt.Dir.Cache+"/image/", trueName)
+	cacheFi, err := os.Stat(cachePath)
+	if err == nil && !cacheFi.ModTime().Before(srcFi.ModTime()) {
+		w.Debugf("display image: %s: using cached version", logName)
+		r.FromCache = true
+	} else {
+		w.Debug("convert image:", trueName)
+		if err := convertImage(command, src.Path, cachePath); err != nil {
+			return DisplayError{
+				Error:         "Failed to generate image.",
+				DetailedError: "Convert image '" + src.Path + "' to " + img.Format + " error: " + err.Error(),
+			}
+		}
+		if cacheFi, err = os.Stat(cachePath); err != nil {
+			return DisplayError{
+				Error:         "Failed to generate image.",
+				DetailedError: "Convert image '" + src.Path + "' to " + img.Format + " error: " + err.Error(),
+			}
+		}
+		r.Generated = true
+		r.CacheGenerated = true
+	}
+
+	mod := cacheFi.ModTime()
+	r.Path = cachePath
+	r.File = filepath.Base(cachePath)
+	r.Modified = &mod
+	r.ModifiedHTTP = httpdate.Time2Str(mod)
+	r.Length = cacheFi.Size()
+
+	w.symlinkScaledImage(img, trueName)
+	return r
+}
+
+// runs a conversion command, replacing $in and $out in its arguments. the
+// output is written to a temporary file which then replaces the destination
+func convertImage(command, in, out string) error {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return errors.New("no command")
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(out), ".convert-*"+filepath.Ext(out))
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	for i, arg := range args {
+		args[i] = strings.NewReplacer("$in", in, "$out", tmp.Name()).Replace(arg)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), imageConvertTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return errors.New("timed out after " + imageConvertTimeout.String())
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return errors.New(msg)
+		}
+		return err
+	}
+	os.Chmod(tmp.Name(), 0644)
+	return os.Rename(tmp.Name(), out)
+}
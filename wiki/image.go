@@ -1,10 +1,14 @@
 package wiki
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"image"
 	_ "image/jpeg" // for jpegs
 	_ "image/png"  // for pngs
+	"io"
+	"io/ioutil"
 	"math"
 	"os"
 	"path/filepath"
@@ -20,18 +24,27 @@ import (
 )
 
 var (
-	imageNameRegex  = regexp.MustCompile(`^(\d+)x(\d+)-(.+)$`)
+	imageNameRegex  = regexp.MustCompile(`^(\d+)x(\d+)(?:c(\d+)_(\d+))?-(.+)$`)
 	imageScaleRegex = regexp.MustCompile(`^(.+)\@(\d+)x$`)
 )
 
 // ImageInfo represents a full-size image on the wiki.
 type ImageInfo struct {
-	File       string     `json:"file"`               // filename
-	Width      int        `json:"width,omitempty"`    // full-size width
-	Height     int        `json:"height,omitempty"`   // full-size height
-	Created    *time.Time `json:"created,omitempty"`  // creation time
-	Modified   *time.Time `json:"modified,omitempty"` // modify time
-	Dimensions [][]int    `json:"-"`                  // dimensions used throughout the wiki
+	File       string            `json:"file"`               // filename
+	Width      int               `json:"width,omitempty"`    // full-size width
+	Height     int               `json:"height,omitempty"`   // full-size height
+	Created    *time.Time        `json:"created,omitempty"`  // creation time
+	Modified   *time.Time        `json:"modified,omitempty"` // modify time
+	Dimensions [][]int           `json:"-"`                  // dimensions used throughout the wiki
+	Usage      []ImageUse        `json:"usage,omitempty"`    // pages which reference the image
+	Hashes     map[string]string `json:"hashes,omitempty"`   // SHA-256 of each generated image, by filename
+}
+
+// ImageUse describes a page which references an image.
+type ImageUse struct {
+	Page      string `json:"page"`                // page filename
+	Title     string `json:"title,omitempty"`     // page title
+	Galleries int    `json:"galleries,omitempty"` // number of times used within galleries
 }
 
 // SizedImage represents an image in specific dimensions.
@@ -43,12 +56,22 @@ type SizedImage struct {
 	RelNameNE     string // myimage (name without extension)
 	Ext           string // png (extension)
 	zeroByZero    bool   // true when created from 0x0-name
+
+	// format the image is converted to, such as webp in
+	// mydir/100x200-myimage@3x.png.webp. empty for the source format
+	Format string
+
+	// for a crop to exactly the dimensions, as in mydir/100x200c30_50-myimage.png,
+	// the focal point in percent from the top left
+	Crop           bool
+	FocusX, FocusY int
 }
 
 // SizedImageFromName returns a SizedImage given an image name.
 func SizedImageFromName(name string) SizedImage {
 	w, h := 0, 0
 	zeroByZero := false
+	crop, focusX, focusY := false, 0, 0
 
 	// before all else, separate name and prefix
 	pfx := ""
@@ -64,7 +87,21 @@ func SizedImageFromName(name string) SizedImage {
 		w, _ = strconv.Atoi(matches[1])
 		h, _ = strconv.Atoi(matches[2])
 		zeroByZero = w == 0 && h == 0
-		name = matches[3]
+		if matches[3] != "" && w != 0 && h != 0 {
+			crop = true
+			focusX, _ = strconv.Atoi(matches[3])
+			focusY, _ = strconv.Atoi(matches[4])
+		}
+		name = matches[5]
+	}
+
+	// extract format of a converted image, as in myimage.png.webp
+	format := ""
+	if lastDot := strings.LastIndexByte(name, '.'); lastDot != -1 {
+		if f := name[lastDot+1:]; imageFormatMimes[f] != "" && strings.IndexByte(name[:lastDot], '.') != -1 {
+			format = f
+			name = name[:lastDot]
+		}
 	}
 
 	// extract extension
@@ -93,6 +130,10 @@ func SizedImageFromName(name string) SizedImage {
 		RelNameNE:  nameNE,
 		Ext:        ext,
 		zeroByZero: zeroByZero,
+		Format:     format,
+		Crop:       crop,
+		FocusX:     focusX,
+		FocusY:     focusY,
 	}
 }
 
@@ -117,17 +158,34 @@ func (img SizedImage) TrueNameNE() string {
 		return img.Prefix + img.RelNameNE
 	}
 	return fmt.Sprintf(
-		"%s%dx%d-%s",
+		"%s%dx%d%s-%s",
 		img.Prefix,
 		img.TrueWidth(),
 		img.TrueHeight(),
+		img.cropSuffix(),
 		img.RelNameNE,
 	)
 }
 
+// crop and focal point following the dimensions, if any
+func (img SizedImage) cropSuffix() string {
+	if !img.Crop {
+		return ""
+	}
+	return fmt.Sprintf("c%d_%d", img.FocusX, img.FocusY)
+}
+
 // TrueName returns the image name with true dimensions.
 func (img SizedImage) TrueName() string {
-	return img.TrueNameNE() + "." + img.Ext
+	return img.TrueNameNE() + "." + img.Ext + img.formatExt()
+}
+
+// extension of the format the image is converted to, if any
+func (img SizedImage) formatExt() string {
+	if img.Format == "" {
+		return ""
+	}
+	return "." + img.Format
 }
 
 // ScaleName returns the image name with dimensions and scale.
@@ -135,13 +193,15 @@ func (img SizedImage) ScaleName() string {
 	if img.Scale <= 1 {
 		return img.TrueName()
 	}
-	return fmt.Sprintf("%s%dx%d-%s@%dx.%s",
+	return fmt.Sprintf("%s%dx%d%s-%s@%dx.%s%s",
 		img.Prefix,
 		img.Width,
 		img.Height,
+		img.cropSuffix(),
 		img.RelNameNE,
 		img.Scale,
 		img.Ext,
+		img.formatExt(),
 	)
 }
 
@@ -160,10 +220,10 @@ type DisplayImage struct {
 	FullsizePath string `json:"fullsize_path,omitempty"`
 
 	// image type
-	// 'png' or 'jpeg'
+	// 'png', 'jpeg', or a converted format such as 'webp'
 	ImageType string `json:"image_type,omitempty"`
 
-	// mime 'image/png' or 'image/jpeg'
+	// mime such as 'image/png' or 'image/jpeg'
 	// suitable for the Content-Type header
 	Mime string `json:"mime,omitempty"`
 
@@ -189,6 +249,10 @@ type DisplayImage struct {
 	// true if the content generated in order to fulfill this request was
 	// written to cache. this can only been true when Generated is true
 	CacheGenerated bool `json:"cache_gen,omitempty"`
+
+	// SHA-256 of the generated image, as hexadecimal.
+	// empty when the full-size image is served
+	Hash string `json:"hash,omitempty"`
 }
 
 // DisplayImage returns the display result for an image.
@@ -204,6 +268,12 @@ func (w *Wiki) DisplaySizedImage(img SizedImage) interface{} {
 // DisplaySizedImageGenerate returns the display result for an image in specific dimensions
 // and allows images to be generated in any dimension.
 func (w *Wiki) DisplaySizedImageGenerate(img SizedImage, generateOK bool) interface{} {
+	return w.displaySizedImage(img, generateOK, generateOK)
+}
+
+// like DisplaySizedImageGenerate, except retina scales of the image are only
+// pregenerated if retinaOK is true
+func (w *Wiki) displaySizedImage(img SizedImage, generateOK, retinaOK bool) interface{} {
 	var r DisplayImage
 	logName := img.ScaleName()
 	w.Debug("display image:", logName)
@@ -218,6 +288,16 @@ func (w *Wiki) DisplaySizedImageGenerate(img SizedImage, generateOK bool) interf
 		}
 	}
 
+	// serve a copy of the image without metadata
+	if w.Opt.Image.StripMetadata {
+		if bigPath, fi, err = w.strippedImage(img.FullSizeName(), bigPath, fi); err != nil {
+			return DisplayError{
+				Error:         "Failed to generate image.",
+				DetailedError: "Strip image '" + img.FullSizeName() + "' error: " + err.Error(),
+			}
+		}
+	}
+
 	// one dimension is missing
 	var bigW, bigH int
 	oldName := img.TrueName()
@@ -225,7 +305,7 @@ func (w *Wiki) DisplaySizedImageGenerate(img SizedImage, generateOK bool) interf
 		w.Debugf("display image: %s: missing a dimension; have to open", logName)
 
 		// get full size dimensions
-		bigW, bigH = getImageDimensions(bigPath)
+		bigW, bigH = getImageDimensions(bigPath, w.Opt.Image.AutoOrient)
 
 		// find missing dimension
 		// note: we haven't checked if both are 0 yet, but this will return 0, 0 in that case
@@ -240,6 +320,11 @@ func (w *Wiki) DisplaySizedImageGenerate(img SizedImage, generateOK bool) interf
 		return DisplayRedirect{filepath.Base(trueName)}
 	}
 
+	// the image converted to another format
+	if img.Format != "" {
+		return w.displayImageFormat(img, generateOK, retinaOK)
+	}
+
 	// image name and full path
 	r.Path = bigPath
 	r.FullsizePath = bigPath
@@ -261,7 +346,8 @@ func (w *Wiki) DisplaySizedImageGenerate(img SizedImage, generateOK bool) interf
 
 	// create or update image category
 	// consider: do we need to do this here, and does it write every time?
-	w.GetSpecialCategory(r.File, CategoryTypeImage).addImage(w, r.File, nil, nil)
+	imageCat := w.GetSpecialCategory(r.File, CategoryTypeImage)
+	imageCat.addImage(w, r.File, nil, nil)
 
 	// if both dimensions are missing, display the full-size version of the image
 	if img.Width == 0 && img.Height == 0 {
@@ -283,7 +369,7 @@ func (w *Wiki) DisplaySizedImageGenerate(img SizedImage, generateOK bool) interf
 	// this is not a retina request, but retina is enabled, and
 	// this is a pregeneration request of the normal-scale image.
 	// so, commit a pregeneration request for each scaled version.
-	if img.Scale <= 1 && generateOK {
+	if img.Scale <= 1 && retinaOK {
 		for _, scale := range w.Opt.Image.Retina {
 			w.Debugf("display image: %s: also generating retina @%dx", logName, scale)
 			scaledImage := img        // copy
@@ -320,6 +406,7 @@ func (w *Wiki) DisplaySizedImageGenerate(img SizedImage, generateOK bool) interf
 			r.Modified = &mod
 			r.ModifiedHTTP = httpdate.Time2Str(mod)
 			r.Length = cacheFi.Size()
+			r.Hash = imageCat.ImageHashes[trueName]
 
 			w.symlinkScaledImage(img, trueName)
 			return r
@@ -349,6 +436,17 @@ func (w *Wiki) DisplaySizedImageGenerate(img SizedImage, generateOK bool) interf
 		return dispErr
 	}
 
+	// record the hash of the generated image. the category is loaded again
+	// because retina scales may have been recorded since
+	if r.Hash != "" {
+		imageCat = w.GetSpecialCategory(imageCat.Name, CategoryTypeImage)
+		if imageCat.ImageHashes == nil {
+			imageCat.ImageHashes = make(map[string]string)
+		}
+		imageCat.ImageHashes[trueName] = r.Hash
+		imageCat.write(w)
+	}
+
 	w.symlinkScaledImage(img, trueName)
 	return r
 }
@@ -419,6 +517,34 @@ func (w *Wiki) ImageMap() map[string]ImageInfo {
 	return images
 }
 
+// ImageUsage returns a map of image filename to the pages which reference
+// the image, for all images in the wiki. Images which are not referenced by
+// any page have no entries.
+func (w *Wiki) ImageUsage() map[string][]ImageUse {
+	imageNames := w.allImageFiles()
+	usage := make(map[string][]ImageUse, len(imageNames))
+	for _, name := range imageNames {
+		usage[name] = w.ImageInfo(name).Usage
+	}
+	return usage
+}
+
+// UnusedImages returns the filenames of images which are not referenced by
+// any page, in sorted order. These are candidates for cleanup.
+//
+// Usage is recorded as pages are generated, so the result is only accurate
+// once all pages have been generated, as occurs when the webserver starts.
+func (w *Wiki) UnusedImages() []string {
+	var unused []string
+	for name, uses := range w.ImageUsage() {
+		if len(uses) == 0 {
+			unused = append(unused, name)
+		}
+	}
+	sort.Strings(unused)
+	return unused
+}
+
 // ImageInfo returns info for an image given its full-size name.
 func (w *Wiki) ImageInfo(name string) (info ImageInfo) {
 
@@ -448,15 +574,24 @@ func (w *Wiki) ImageInfo(name string) (info ImageInfo) {
 			info.Height = imageCat.ImageInfo.Height
 		}
 		info.Created = imageCat.Created // category creation time, not image
-		for _, entry := range imageCat.Pages {
+		info.Hashes = imageCat.ImageHashes
+		for pageName, entry := range imageCat.Pages {
 			info.Dimensions = append(info.Dimensions, entry.Dimensions...)
+			info.Usage = append(info.Usage, ImageUse{
+				Page:      pageName,
+				Title:     entry.Title,
+				Galleries: entry.Galleries,
+			})
 		}
+		sort.Slice(info.Usage, func(i, j int) bool {
+			return info.Usage[i].Page < info.Usage[j].Page
+		})
 		return
 	}
 
 	// image category still doesn't exist???
 	// let's read the dimensions manually
-	info.Width, info.Height = getImageDimensions(path)
+	info.Width, info.Height = getImageDimensions(path, w.Opt.Image.AutoOrient)
 
 	return
 }
@@ -465,7 +600,7 @@ func (w *Wiki) generateImage(img SizedImage, bigPath string, bigW, bigH int, r *
 	width, height := img.TrueWidth(), img.TrueHeight()
 
 	// open the full-size image
-	bigImage, err := imaging.Open(bigPath)
+	bigImage, err := imaging.Open(bigPath, imaging.AutoOrientation(w.Opt.Image.AutoOrient))
 	if err != nil {
 		return DisplayError{
 			Error:         "Image does not exist.",
@@ -482,7 +617,7 @@ func (w *Wiki) generateImage(img SizedImage, bigPath string, bigW, bigH int, r *
 	}
 
 	// the request is to generate an image the same or larger than the original
-	if width >= bigW || height >= bigH {
+	if !img.Crop && (width >= bigW || height >= bigH) {
 
 		// symlink this to the full-size image
 		w.symlinkScaledImage(img, img.FullSizeName())
@@ -495,11 +630,21 @@ func (w *Wiki) generateImage(img SizedImage, bigPath string, bigW, bigH int, r *
 	w.Debug("generate image:", img.TrueName())
 
 	// create resized image
-	newImage := imaging.Resize(bigImage, width, height, imaging.Lanczos)
+	var newImage image.Image
+	if img.Crop {
+		newImage = cropImage(bigImage, width, height, img.FocusX, img.FocusY)
+	} else {
+		newImage = imaging.Resize(bigImage, width, height, imaging.Lanczos)
+	}
 
 	// generate the image in the source format and write
 	newImagePath := filepath.FromSlash(w.Opt.Dir.Cache + "/image/" + img.TrueName())
-	err = imaging.Save(newImage, newImagePath)
+	format, err := imaging.FormatFromFilename(newImagePath)
+	if err == nil {
+		err = writeFileAtomicFunc(newImagePath, 0644, func(w io.Writer) error {
+			return imaging.Encode(w, newImage, format)
+		})
+	}
 	if err != nil {
 		return DisplayError{
 			Error:         "Failed to generate image.",
@@ -508,6 +653,8 @@ func (w *Wiki) generateImage(img SizedImage, bigPath string, bigW, bigH int, r *
 	}
 
 	newImageFi, _ := os.Lstat(newImagePath)
+	newImageData, _ := ioutil.ReadFile(newImagePath)
+	sum := sha256.Sum256(newImageData)
 
 	// inject info from the newly generated image
 	mod := newImageFi.ModTime()
@@ -518,10 +665,39 @@ func (w *Wiki) generateImage(img SizedImage, bigPath string, bigW, bigH int, r *
 	r.ModifiedHTTP = httpdate.Time2Str(mod)
 	r.Length = newImageFi.Size()
 	r.CacheGenerated = true
+	r.Hash = hex.EncodeToString(sum[:])
 
 	return nil // success
 }
 
+// crops an image to the aspect ratio of the dimensions, keeping the focal
+// point (in percent from the top left) as close to the center as possible,
+// and scales it to the dimensions. it is not enlarged, so the result may be
+// smaller than the dimensions
+func cropImage(img image.Image, width, height, focusX, focusY int) image.Image {
+	b := img.Bounds()
+	bigW, bigH := b.Dx(), b.Dy()
+
+	// the largest region of the aspect ratio
+	scale := math.Max(float64(width)/float64(bigW), float64(height)/float64(bigH))
+	regionW := int(math.Min(float64(bigW), math.Floor(float64(width)/scale+0.5)))
+	regionH := int(math.Min(float64(bigH), math.Floor(float64(height)/scale+0.5)))
+
+	// centered on the focal point, but within the image
+	clamp := func(v, max int) int {
+		return int(math.Max(0, math.Min(float64(v), float64(max))))
+	}
+	x := clamp(bigW*clamp(focusX, 100)/100-regionW/2, bigW-regionW)
+	y := clamp(bigH*clamp(focusY, 100)/100-regionH/2, bigH-regionH)
+	cropped := imaging.Crop(img, image.Rect(x, y, x+regionW, y+regionH).Add(b.Min))
+
+	// do not enlarge
+	if scale >= 1 {
+		return cropped
+	}
+	return imaging.Resize(cropped, width, height, imaging.Lanczos)
+}
+
 // symlinks scaled cache file e.g. 100x200-asdf@2x.jpg -> 200x400-asdf.jpg
 func (w *Wiki) symlinkScaledImage(img SizedImage, name string) {
 
@@ -547,7 +723,9 @@ func (w *Wiki) symlinkScaledImage(img SizedImage, name string) {
 	os.Symlink(filepath.Base(name), scalePath)
 }
 
-func getImageDimensions(path string) (w, h int) {
+// returns the dimensions of an image. if autoOrient is true, they are
+// swapped when its EXIF orientation rotates it by 90 degrees
+func getImageDimensions(path string, autoOrient bool) (w, h int) {
 	file, err := os.Open(path)
 	defer file.Close()
 	if err != nil {
@@ -556,6 +734,9 @@ func getImageDimensions(path string) (w, h int) {
 	c, _, _ := image.DecodeConfig(file)
 	w = c.Width
 	h = c.Height
+	if autoOrient && orientationTransposed(readOrientation(path)) {
+		w, h = h, w
+	}
 	return
 }
 
@@ -0,0 +1,123 @@
+package wiki
+
+import (
+	"html"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// An Invalidator purges cached copies of wiki content, such as from a content
+// delivery network, when the content changes.
+//
+// paths are URL paths relative to the HTTP root, such as /page/some_page,
+// with each segment already escaped. Use w.Opt.Root.Ext to make absolute
+// URLs.
+type Invalidator interface {
+	Invalidate(w *Wiki, paths []string) error
+}
+
+// deleted pages are invalidated by a change hook
+func init() {
+	AddChangeHook(invalidateChange)
+}
+
+var (
+	invalidators     []Invalidator
+	invalidatorsLock sync.RWMutex
+)
+
+// AddInvalidator registers an invalidator which is used for all wikis, in
+// addition to those configured with the cdn.* options.
+func AddInvalidator(inv Invalidator) {
+	invalidatorsLock.Lock()
+	defer invalidatorsLock.Unlock()
+	invalidators = append(invalidators, inv)
+}
+
+// InvalidatePage purges cached copies of a page from all invalidators, in
+// the background. This is called when a page is regenerated with different
+// content, including when it is regenerated because a dependency changed,
+// and when a page is deleted.
+func (w *Wiki) InvalidatePage(name string) {
+	nameNE := w.Opt.PageNameNE(name)
+	paths := []string{w.Opt.Root.Page + "/" + nameNE}
+	if nameNE == w.Opt.PageNameNE(w.Opt.MainPage) {
+		paths = append(paths, w.Opt.Root.Wiki+"/")
+	}
+	w.invalidate(paths)
+}
+
+// page deletions
+func invalidateChange(w *Wiki, c Change) {
+	if c.Deleted && strings.HasPrefix(c.File, "pages/") {
+		w.InvalidatePage(strings.TrimPrefix(c.File, "pages/"))
+	}
+}
+
+// purges paths from all invalidators, in the background
+func (w *Wiki) invalidate(paths []string) {
+	for i, path := range paths {
+		paths[i] = escapePath(path)
+	}
+	invalidatorsLock.RLock()
+	invs := append(w.cdnInvalidators(), invalidators...)
+	invalidatorsLock.RUnlock()
+	for _, inv := range invs {
+		go func(inv Invalidator) {
+			if err := inv.Invalidate(w, paths); err != nil {
+				w.Logf("invalidate %v: %v", paths, err)
+			}
+		}(inv)
+	}
+}
+
+// invalidators configured with cdn.* options
+func (w *Wiki) cdnInvalidators() []Invalidator {
+	var invs []Invalidator
+	cdn := w.Opt.CDN
+	if cdn.CloudFrontDistribution != "" {
+		invs = append(invs, CloudFront{
+			Distribution: cdnOpt(cdn.CloudFrontDistribution),
+			AccessKey:    cdnOpt(cdn.CloudFrontAccessKey),
+			SecretKey:    cdnOpt(cdn.CloudFrontSecretKey),
+		})
+	}
+	if cdn.FastlyKey != "" {
+		invs = append(invs, Fastly{Key: cdnOpt(cdn.FastlyKey)})
+	}
+	if cdn.CloudflareZone != "" {
+		invs = append(invs, Cloudflare{
+			Zone:  cdnOpt(cdn.CloudflareZone),
+			Token: cdnOpt(cdn.CloudflareToken),
+		})
+	}
+	return invs
+}
+
+// escapes each segment of a path for use in a URL
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+// option values are HTML-encoded by the parser
+func cdnOpt(value string) string {
+	return html.UnescapeString(value)
+}
+
+// absolute URLs for paths, for CDNs which purge by URL
+func absoluteURLs(w *Wiki, paths []string) ([]string, error) {
+	base := strings.TrimSuffix(cdnOpt(w.Opt.Root.Ext), "/")
+	if base == "" {
+		return nil, errRootExt
+	}
+	urls := make([]string, len(paths))
+	for i, path := range paths {
+		urls[i] = base + path
+	}
+	return urls, nil
+}
@@ -0,0 +1,99 @@
+package wiki
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"github.com/cooper/go-git/v4/plumbing/object"
+)
+
+// the name of the file in the wiki directory which maps the authors of
+// revisions to other names and email addresses, in the format of git's
+// .mailmap. Import writes it when users are mapped, so the history does not
+// have to be rewritten
+const mailmapFile = ".mailmap"
+
+// an entry of the mailmap. empty fields of the proper identity are not
+// changed, and an empty commit name matches any name
+type mailmapEntry struct {
+	properName, properEmail string
+	commitName, commitEmail string
+}
+
+// reads the mailmap of the wiki, if any. lines are like
+//
+//	Proper Name <proper@example.com> Commit Name <commit@example.com>
+//	Proper Name <proper@example.com> <commit@example.com>
+//	<proper@example.com> <commit@example.com>
+//	Proper Name <commit@example.com>
+func (w *Wiki) mailmap() []mailmapEntry {
+	f, err := os.Open(w.Dir(mailmapFile))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var entries []mailmapEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i != -1 {
+			line = line[:i]
+		}
+
+		// up to two identities, each a name followed by an email
+		var names, emails []string
+		for len(emails) < 2 {
+			open := strings.IndexByte(line, '<')
+			end := strings.IndexByte(line, '>')
+			if open == -1 || end < open {
+				break
+			}
+			names = append(names, strings.TrimSpace(line[:open]))
+			emails = append(emails, strings.TrimSpace(line[open+1:end]))
+			line = line[end+1:]
+		}
+
+		var e mailmapEntry
+		switch len(emails) {
+		case 1:
+			// Proper Name <commit@example.com>
+			e = mailmapEntry{properName: names[0], commitEmail: emails[0]}
+		case 2:
+			e = mailmapEntry{names[0], emails[0], names[1], emails[1]}
+		default:
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// returns the name and email of the author of a commit, as mapped by the
+// mailmap. entries with a commit name take precedence over those without
+func commitAuthor(c *object.Commit, mailmap []mailmapEntry) (name, email string) {
+	name, email = c.Author.Name, c.Author.Email
+	var match *mailmapEntry
+	for i, e := range mailmap {
+		if !strings.EqualFold(e.commitEmail, email) {
+			continue
+		}
+		if e.commitName != "" && e.commitName != name {
+			continue
+		}
+		if match == nil || e.commitName != "" {
+			match = &mailmap[i]
+		}
+	}
+	if match == nil {
+		return
+	}
+	if match.properName != "" {
+		name = match.properName
+	}
+	if match.properEmail != "" {
+		email = match.properEmail
+	}
+	return
+}
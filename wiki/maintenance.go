@@ -0,0 +1,296 @@
+package wiki
+
+import (
+	"encoding/xml"
+	"html"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cooper/go-git/v4"
+	"github.com/cooper/quiki/wikifier"
+	"github.com/pkg/errors"
+)
+
+// number of pages included in the feed
+const feedLength = 20
+
+// unreferenced git objects are kept for this long when git is not
+// installed, like git gc
+const gcGracePeriod = 14 * 24 * time.Hour
+
+// A BrokenLink is a link to a page which does not exist.
+type BrokenLink struct {
+	Page   string `json:"page"`   // name of the page containing the link
+	Target string `json:"target"` // name of the missing page
+}
+
+// A MissingLink is a page which does not exist but is linked to.
+type MissingLink struct {
+	Target string           `json:"target"` // name of the missing page
+	Pages  map[string][]int `json:"pages"`  // filenames of the linking pages to line numbers of the links
+}
+
+// PruneCache deletes cached pages and generated images whose source files
+// no longer exist. It returns the number of files deleted.
+func (w *Wiki) PruneCache() (int, error) {
+	pruned := 0
+
+	// page caches and search text
+	pageCache := w.Dir("cache", "page")
+	err := filepath.Walk(pageCache, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return nil
+		}
+		ext := filepath.Ext(path)
+		if ext != ".cache" && ext != ".txt" {
+			return nil
+		}
+		name := filepath.ToSlash(strings.TrimSuffix(makeRelPath(path, pageCache), ext))
+		if _, err := os.Stat(w.pathForPage(name)); !os.IsNotExist(err) {
+			return nil
+		}
+		w.Debug("prune page cache:", name)
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		pruned++
+		return nil
+	})
+	if err != nil {
+		return pruned, errors.Wrap(err, "prune page cache")
+	}
+
+	// scaled images
+	imageCache := w.Dir("cache", "image")
+	err = filepath.Walk(imageCache, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return nil
+		}
+		name := filepath.ToSlash(makeRelPath(path, imageCache))
+		img := SizedImageFromName(name)
+		if _, err := os.Stat(w.pathForImage(img.FullSizeName())); !os.IsNotExist(err) {
+			return nil
+		}
+		w.Debug("prune image cache:", name)
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		pruned++
+		return nil
+	})
+	if err != nil {
+		return pruned, errors.Wrap(err, "prune image cache")
+	}
+
+	return pruned, nil
+}
+
+// GarbageCollect deletes unreferenced objects from the wiki repository and
+// packs the rest. If git is installed, it runs git gc; otherwise, go-git is
+// used, which fails for repositories containing symlinks.
+func (w *Wiki) GarbageCollect() error {
+	repo, err := w.repo()
+	if err != nil {
+		return err
+	}
+
+	// prefer git itself
+	if gitPath, err := exec.LookPath("git"); err == nil {
+		cmd := exec.Command(gitPath, "gc", "--quiet")
+		cmd.Dir = w.Dir()
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return errors.Wrap(err, "git gc: "+strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+
+	// delete unreferenced loose objects
+	err = repo.Prune(git.PruneOptions{
+		OnlyObjectsOlderThan: time.Now().Add(-gcGracePeriod),
+		Handler:              repo.DeleteObject,
+	})
+	if err != nil && err != git.ErrLooseObjectsNotSupported {
+		return errors.Wrap(err, "prune")
+	}
+
+	// pack everything else
+	if err = repo.RepackObjects(&git.RepackConfig{OnlyDeletePacksOlderThan: time.Now()}); err != nil {
+		return errors.Wrap(err, "repack")
+	}
+	return nil
+}
+
+// BrokenLinks returns links to pages which do not exist.
+//
+// It uses the links recorded by updatePageCategories.
+func (w *Wiki) BrokenLinks() []BrokenLink {
+	var broken []BrokenLink
+	for _, pageName := range w.allPageFiles() {
+		for _, dep := range w.Dependencies(pageName) {
+			if dep.Type != CategoryTypePage || w.FindPage(dep.Name).Exists() {
+				continue
+			}
+			broken = append(broken, BrokenLink{
+				Page:   w.Opt.PageNameNE(pageName),
+				Target: dep.Name,
+			})
+		}
+	}
+	return broken
+}
+
+// MissingLinks returns the pages which do not exist but are linked to, each
+// with the pages linking to it, most linked first.
+//
+// Like BrokenLinks, it uses the links recorded by updatePageCategories.
+func (w *Wiki) MissingLinks() []MissingLink {
+	var missing []MissingLink
+	for _, name := range w.allCategoryFiles(CategoryTypePage) {
+		name = wikifier.CategoryNameNE(name)
+		if w.FindPage(name).Exists() {
+			continue
+		}
+
+		// skip pages which no longer link to it
+		cat := w.GetSpecialCategory(name, CategoryTypePage)
+		cat.update(w)
+		if len(cat.Pages) == 0 {
+			continue
+		}
+
+		link := MissingLink{Target: name, Pages: make(map[string][]int, len(cat.Pages))}
+		for file, entry := range cat.Pages {
+			link.Pages[file] = entry.Lines
+		}
+		missing = append(missing, link)
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		if len(missing[i].Pages) != len(missing[j].Pages) {
+			return len(missing[i].Pages) > len(missing[j].Pages)
+		}
+		return missing[i].Target < missing[j].Target
+	})
+	return missing
+}
+
+// pages which are published, in order of most recently modified
+func (w *Wiki) publishedPages() []wikifier.PageInfo {
+	var pages []wikifier.PageInfo
+	for _, info := range w.PagesSorted(true, SortModified, SortTitle) {
+		if info.Draft || info.Redirect != "" || info.Error != nil {
+			continue
+		}
+		pages = append(pages, info)
+	}
+	return pages
+}
+
+// absolute URL for a page
+func (w *Wiki) pageURL(info wikifier.PageInfo) string {
+	base := strings.TrimSuffix(html.UnescapeString(w.Opt.Root.Ext), "/")
+	return base + w.Opt.Root.Page + "/" + w.Opt.PageNameNE(info.File)
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// GenerateSitemap writes an XML sitemap of all published pages to
+// cache/sitemap.xml. The root.ext option must be set.
+func (w *Wiki) GenerateSitemap() error {
+	if w.Opt.Root.Ext == "" {
+		return errors.New("@root.ext is not set")
+	}
+	var set sitemapURLSet
+	for _, info := range w.publishedPages() {
+		set.URLs = append(set.URLs, sitemapURL{
+			Loc:     w.pageURL(info),
+			LastMod: info.Modified.UTC().Format(time.RFC3339),
+		})
+	}
+	return writeXML(w.Dir("cache", "sitemap.xml"), set)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Link    atomLink    `xml:"link"`
+	Updated string      `xml:"updated"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Link    atomLink    `xml:"link"`
+	Updated string      `xml:"updated"`
+	Author  *atomAuthor `xml:"author,omitempty"`
+	Summary string      `xml:"summary,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+// GenerateFeed writes an Atom feed of the most recently modified published
+// pages to cache/feed.atom. The root.ext option must be set.
+func (w *Wiki) GenerateFeed() error {
+	if w.Opt.Root.Ext == "" {
+		return errors.New("@root.ext is not set")
+	}
+	base := strings.TrimSuffix(html.UnescapeString(w.Opt.Root.Ext), "/")
+	feed := atomFeed{
+		Title:   w.Opt.Name,
+		ID:      base + w.Opt.Root.Wiki + "/",
+		Link:    atomLink{base + w.Opt.Root.Wiki + "/"},
+		Updated: time.Now().UTC().Format(time.RFC3339),
+	}
+	pages := w.publishedPages()
+	if len(pages) > feedLength {
+		pages = pages[:feedLength]
+	}
+	for i, info := range pages {
+		if i == 0 {
+			feed.Updated = info.Modified.UTC().Format(time.RFC3339)
+		}
+		entry := atomEntry{
+			Title:   info.Title,
+			ID:      w.pageURL(info),
+			Link:    atomLink{w.pageURL(info)},
+			Updated: info.Modified.UTC().Format(time.RFC3339),
+			Summary: info.Description,
+		}
+		if entry.Summary == "" {
+			entry.Summary = info.Preview
+		}
+		if info.Author != "" {
+			entry.Author = &atomAuthor{info.Author}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return writeXML(w.Dir("cache", "feed.atom"), feed)
+}
+
+func writeXML(path string, v interface{}) error {
+	data, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append([]byte(xml.Header), data...)
+	return writeFileAtomic(path, append(data, '\n'), 0644)
+}
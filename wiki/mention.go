@@ -0,0 +1,125 @@
+package wiki
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/cooper/go-git/v4/plumbing"
+	"github.com/cooper/quiki/wikifier"
+)
+
+// A Mention describes users who were mentioned as @username in a change,
+// either in a page or in the commit message.
+type Mention struct {
+	Change
+
+	// usernames of the users mentioned, with groups replaced by their
+	// members. the author of the change is not included
+	Users []string
+}
+
+// A MentionHook is called when users are mentioned in a change.
+type MentionHook func(w *Wiki, m Mention)
+
+var (
+	mentionHooks     []MentionHook
+	mentionHooksLock sync.RWMutex
+)
+
+// mentions are found after changes are committed
+func init() {
+	AddChangeHook(findMentions)
+}
+
+// AddMentionHook registers a hook which is called for mentions on all wikis.
+// Mentions are only found if page.enable.mentions is enabled.
+func AddMentionHook(hook MentionHook) {
+	mentionHooksLock.Lock()
+	defer mentionHooksLock.Unlock()
+	mentionHooks = append(mentionHooks, hook)
+}
+
+// call all mention hooks
+func (w *Wiki) runMentionHooks(m Mention) {
+	mentionHooksLock.RLock()
+	hooks := append([]MentionHook(nil), mentionHooks...)
+	mentionHooksLock.RUnlock()
+	for _, hook := range hooks {
+		hook(w, m)
+	}
+}
+
+// finds the users mentioned by a change. in pages, only mentions which were
+// not in the previous revision are considered
+func findMentions(w *Wiki, c Change) {
+	if !w.Opt.Page.EnableMentions || c.Deleted {
+		return
+	}
+	names := wikifier.FindMentions(c.Comment)
+	if strings.HasPrefix(c.File, "pages/") {
+		before, after := w.changeContent(c)
+		previous := make(map[string]bool)
+		for _, name := range w.sourceMentions(c.File, before) {
+			previous[strings.ToLower(name)] = true
+		}
+		for _, name := range w.sourceMentions(c.File, after) {
+			if !previous[strings.ToLower(name)] {
+				names = append(names, name)
+			}
+		}
+	}
+
+	// replace groups with their members, and remove duplicates
+	var users []string
+	seen := map[string]bool{strings.ToLower(c.User): true}
+	for _, name := range names {
+		members, isGroup := w.Opt.Groups[name]
+		if !isGroup {
+			members = []string{name}
+		}
+		for _, user := range members {
+			if key := strings.ToLower(user); !seen[key] {
+				seen[key] = true
+				users = append(users, user)
+			}
+		}
+	}
+	if len(users) != 0 {
+		w.runMentionHooks(Mention{Change: c, Users: users})
+	}
+}
+
+// returns the content of a file before and after a change. either is empty
+// if the file did not exist
+func (w *Wiki) changeContent(c Change) (before, after string) {
+	repo, err := w.repo()
+	if err != nil {
+		return
+	}
+	commit, err := repo.CommitObject(plumbing.NewHash(c.Commit))
+	if err != nil {
+		return
+	}
+	if f, err := commit.File(c.File); err == nil {
+		after, _ = f.Contents()
+	}
+	if parent, err := commit.Parent(0); err == nil {
+		if f, err := parent.File(c.File); err == nil {
+			before, _ = f.Contents()
+		}
+	}
+	return
+}
+
+// returns the names mentioned in the body text of page source, without
+// generating HTML
+func (w *Wiki) sourceMentions(file, source string) []string {
+	if source == "" {
+		return nil
+	}
+	page := w.parseSource(file, source)
+	if page == nil {
+		return nil
+	}
+	return page.SourceMentions()
+}
@@ -0,0 +1,33 @@
+package wiki
+
+import "github.com/cooper/quiki/wikifier"
+
+// PageNotes is the [todo:...] and [review:...] markers of a page.
+type PageNotes struct {
+	File  string                `json:"file"`  // page filename
+	Title string                `json:"title"` // page title
+	Notes []wikifier.EditorNote `json:"notes"`
+}
+
+// EditorNotes returns the outstanding [todo:...] and [review:...] markers of
+// every page which has any, including drafts, sorted by title.
+//
+// Notes are found in generated pages, so pages are generated as needed.
+func (w *Wiki) EditorNotes() []PageNotes {
+	var notes []PageNotes
+	for _, info := range w.PagesSorted(false, SortTitle) {
+		if info.Redirect != "" || info.Error != nil {
+			continue
+		}
+		res, ok := w.DisplayPageDraft(info.File, true).(DisplayPage)
+		if !ok || len(res.Notes) == 0 {
+			continue
+		}
+		notes = append(notes, PageNotes{
+			File:  info.File,
+			Title: res.Title,
+			Notes: res.Notes,
+		})
+	}
+	return notes
+}
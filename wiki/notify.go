@@ -0,0 +1,249 @@
+package wiki
+
+import (
+	"bytes"
+	"encoding/json"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// chat notifications are sent by change and review hooks
+func init() {
+	AddChangeHook(notifyChange)
+	AddReviewHook(notifyReview)
+	AddMentionHook(notifyMention)
+}
+
+var notifyClient = &http.Client{Timeout: 10 * time.Second}
+
+// a chat notification: a sentence mentioning a page, optionally followed by
+// a summary and a link to the diff
+type notification struct {
+	before, after string // text before and after the page title
+	title         string // page title
+	titleURL      string // link to the page, if known
+	summary       string // commit message or reason, if any
+	diffURL       string // link to the diff, if known
+}
+
+// page changes
+func notifyChange(w *Wiki, c Change) {
+	if !w.notifyEnabled() || !strings.HasPrefix(c.File, "pages/") {
+		return
+	}
+	name := strings.TrimPrefix(c.File, "pages/")
+	n := w.pageNotification(name)
+	n.summary = c.Comment
+	if c.Deleted {
+		n.after = " was deleted"
+	} else {
+		n.after = " was edited"
+	}
+	if c.Name != "" {
+		n.after += " by " + c.Name
+	}
+	if diffURL := w.notifyOpt(w.Opt.Notify.DiffURL); diffURL != "" {
+		n.diffURL = strings.NewReplacer("$commit", c.Commit, "$file", c.File).Replace(diffURL)
+	}
+	w.notify(n)
+}
+
+// review events. approved changes are reported as page changes
+func notifyReview(w *Wiki, r *Review) {
+	if !w.notifyEnabled() || !strings.HasPrefix(r.File, "pages/") {
+		return
+	}
+	name := strings.TrimPrefix(r.File, "pages/")
+	n := w.pageNotification(name)
+	switch r.Status {
+	case ReviewPending:
+		n.before = contributorName(r.Name) + " proposed changes to "
+		n.after = ", awaiting review"
+		n.summary = r.Comment
+	case ReviewRejected:
+		n.before = r.Reviewer + " rejected changes to "
+		n.after = " by " + contributorName(r.Name)
+		n.summary = r.Reason
+	default:
+		return
+	}
+	w.notify(n)
+}
+
+// users mentioned in pages
+func notifyMention(w *Wiki, m Mention) {
+	if !w.notifyEnabled() || !strings.HasPrefix(m.File, "pages/") {
+		return
+	}
+	n := w.pageNotification(strings.TrimPrefix(m.File, "pages/"))
+	n.before = contributorName(m.Name) + " mentioned @" + strings.Join(m.Users, ", @") + " on "
+	w.notify(n)
+}
+
+func contributorName(name string) string {
+	if name == "" {
+		return "Someone"
+	}
+	return name
+}
+
+// creates a notification for a page
+func (w *Wiki) pageNotification(name string) notification {
+	nameNE := w.Opt.PageNameNE(name)
+	n := notification{title: nameNE}
+	if info := w.PageInfo(name); info.Title != "" {
+		n.title = info.Title
+	}
+	base := w.Opt.Notify.URL
+	if base == "" {
+		base = w.Opt.Root.Ext
+	}
+	if base = w.notifyOpt(base); base != "" {
+		n.titleURL = strings.TrimSuffix(base, "/") + w.Opt.Root.Page + "/" + nameNE
+	}
+	return n
+}
+
+func (w *Wiki) notifyEnabled() bool {
+	n := w.Opt.Notify
+	return n.Slack != "" || n.Discord != "" || n.Matrix != ""
+}
+
+// option values are HTML-encoded by the parser
+func (w *Wiki) notifyOpt(value string) string {
+	return html.UnescapeString(value)
+}
+
+// sends a notification to all configured services, in the background
+func (w *Wiki) notify(n notification) {
+	if url := w.notifyOpt(w.Opt.Notify.Slack); url != "" {
+		go w.notifyPost("slack", http.MethodPost, url, map[string]string{
+			"text": n.slack(),
+		})
+	}
+	if url := w.notifyOpt(w.Opt.Notify.Discord); url != "" {
+		go w.notifyPost("discord", http.MethodPost, url, map[string]interface{}{
+			"content": n.discord(),
+
+			// names and summaries must not ping anyone
+			"allowed_mentions": map[string][]string{"parse": {}},
+		})
+	}
+	if url := w.notifyOpt(w.Opt.Notify.Matrix); url != "" {
+		go w.notifyPost("matrix", http.MethodPut, matrixTxnURL(url), map[string]string{
+			"msgtype":        "m.text",
+			"body":           n.plain(),
+			"format":         "org.matrix.custom.html",
+			"formatted_body": n.html(),
+		})
+	}
+}
+
+func (w *Wiki) notifyPost(service, method, url string, payload interface{}) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		w.Logf("notify %s: %v", service, err)
+		return
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		w.Logf("notify %s: %v", service, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := notifyClient.Do(req)
+	if err != nil {
+		w.Logf("notify %s: %v", service, err)
+		return
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		w.Logf("notify %s: %s", service, res.Status)
+	}
+}
+
+// matrix messages are sent with a unique transaction ID, which is appended
+// to the room message URL before the query string
+func matrixTxnURL(url string) string {
+	txnID := "quiki" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	if i := strings.IndexByte(url, '?'); i != -1 {
+		return strings.TrimSuffix(url[:i], "/") + "/" + txnID + url[i:]
+	}
+	return strings.TrimSuffix(url, "/") + "/" + txnID
+}
+
+// Slack mrkdwn
+func (n notification) slack() string {
+	esc := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace
+	title := "*" + esc(n.title) + "*"
+	if n.titleURL != "" {
+		title = "<" + n.titleURL + "|" + esc(n.title) + ">"
+	}
+	text := esc(n.before) + title + esc(n.after)
+	if n.diffURL != "" {
+		text += " (<" + n.diffURL + "|diff>)"
+	}
+	if n.summary != "" {
+		text += "\n>" + esc(n.summary)
+	}
+	return text
+}
+
+// escapes Discord markdown, so that titles and summaries are shown as written
+var discordEscaper = strings.NewReplacer(
+	"\\", "\\\\", "*", "\\*", "_", "\\_", "~", "\\~", "`", "\\`", "|", "\\|",
+	">", "\\>", "#", "\\#", "[", "\\[", "]", "\\]", "(", "\\(", ")", "\\)",
+	"<", "\\<", "\n", " ",
+)
+
+// Discord markdown
+func (n notification) discord() string {
+	esc := discordEscaper.Replace
+	title := "**" + esc(n.title) + "**"
+	if n.titleURL != "" {
+		title = "[" + esc(n.title) + "](<" + n.titleURL + ">)"
+	}
+	text := esc(n.before) + title + esc(n.after)
+	if n.diffURL != "" {
+		text += " ([diff](<" + n.diffURL + ">))"
+	}
+	if n.summary != "" {
+		text += "\n> " + esc(n.summary)
+	}
+	return text
+}
+
+// plain text, used for Matrix clients without HTML support
+func (n notification) plain() string {
+	text := n.before + n.title + n.after
+	if n.summary != "" {
+		text += ": " + n.summary
+	}
+	if n.titleURL != "" {
+		text += " " + n.titleURL
+	}
+	if n.diffURL != "" {
+		text += " (diff: " + n.diffURL + ")"
+	}
+	return text
+}
+
+// HTML, used for Matrix
+func (n notification) html() string {
+	esc := html.EscapeString
+	title := "<strong>" + esc(n.title) + "</strong>"
+	if n.titleURL != "" {
+		title = `<a href="` + esc(n.titleURL) + `">` + esc(n.title) + "</a>"
+	}
+	text := esc(n.before) + title + esc(n.after)
+	if n.diffURL != "" {
+		text += ` (<a href="` + esc(n.diffURL) + `">diff</a>)`
+	}
+	if n.summary != "" {
+		text += "<blockquote>" + esc(n.summary) + "</blockquote>"
+	}
+	return text
+}
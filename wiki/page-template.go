@@ -0,0 +1,202 @@
+package wiki
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/cooper/quiki/wikifier"
+	"github.com/pkg/errors"
+)
+
+// PageTemplate is a page in the wiki's templates directory, from which new
+// pages can be created, such as templates/incident.page. The template may
+// contain {{placeholder}} tokens, which are replaced with values given when
+// the page is created.
+type PageTemplate struct {
+	File         string   `json:"file"`                   // template filename
+	Placeholders []string `json:"placeholders,omitempty"` // placeholder names, in order of appearance
+	Content      string   `json:"-"`                      // template source
+}
+
+var (
+	placeholderRegex = regexp.MustCompile(`\{\{\s*(\w[\w .-]*?)\s*\}\}`)
+
+	// the opening of a block, such as code [name] {
+	blockOpenRegex = regexp.MustCompile(`([\w-]+)(?:\.[\w-]+)*\s*(?:\[[^\]]*\])?\s*\{`)
+
+	// inline code, such as [c]{{name}}[/c]
+	inlineCodeRegex = regexp.MustCompile(`(?s)\[c\].*?\[/c\]`)
+
+	// marks pages created from a template whose placeholders are not yet
+	// filled in. only these pages are checked for placeholders when saved
+	templateMarkRegex = regexp.MustCompile(`/\*\s*created from template (\S+)\s*\*/`)
+)
+
+// blocks whose content is code, in which double braces are not placeholders
+var codeBlockTypes = map[string]bool{"code": true, "codecompare": true, "math": true, "html": true}
+
+// Placeholders returns the names of the {{placeholder}} tokens in page
+// source, in order of first appearance. Tokens escaped as \{{name}} are not
+// placeholders, nor are double braces in code, such as code{} blocks and
+// [c] formatting, or in blocks which are brace-escaped, as in math {{ x }}.
+func Placeholders(content string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, m := range placeholderMatches(content) {
+		name := content[m[2]:m[3]]
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// returns the submatch indices of unescaped placeholders
+func placeholderMatches(content string) [][]int {
+	skip := placeholderSkipRanges(content)
+	var matches [][]int
+	for _, m := range placeholderRegex.FindAllStringSubmatchIndex(content, -1) {
+		if m[0] != 0 && content[m[0]-1] == '\\' {
+			continue
+		}
+		skipped := false
+		for _, r := range skip {
+			if m[0] >= r[0] && m[0] < r[1] {
+				skipped = true
+				break
+			}
+		}
+		if !skipped {
+			matches = append(matches, m)
+		}
+	}
+	return matches
+}
+
+// returns the ranges of page source in which double braces are not
+// placeholders: inline code, code blocks, and brace-escaped blocks
+func placeholderSkipRanges(content string) [][]int {
+	skip := inlineCodeRegex.FindAllStringIndex(content, -1)
+	for _, m := range blockOpenRegex.FindAllStringSubmatchIndex(content, -1) {
+		blockType := content[m[2]:m[3]]
+		open := m[1] - 1
+		escaped := open+1 < len(content) && content[open+1] == '{'
+		if !wikifier.IsBlockType(blockType) || !escaped && !codeBlockTypes[blockType] {
+			continue
+		}
+
+		// find the matching closing brace
+		depth, end := 0, len(content)
+		for i := open; i < len(content); i++ {
+			if content[i] == '{' {
+				depth++
+			} else if content[i] == '}' {
+				depth--
+				if depth == 0 {
+					end = i + 1
+					break
+				}
+			}
+		}
+		skip = append(skip, []int{open, end})
+	}
+	return skip
+}
+
+// FillPlaceholders replaces {{placeholder}} tokens in page source with the
+// given values. Placeholders without a value are left as they are.
+func FillPlaceholders(content string, values map[string]string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range placeholderMatches(content) {
+		value, ok := values[content[m[2]:m[3]]]
+		if !ok {
+			continue
+		}
+		b.WriteString(content[last:m[0]])
+		b.WriteString(value)
+		last = m[1]
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
+
+// PageTemplates returns the templates from which pages can be created.
+func (w *Wiki) PageTemplates() []PageTemplate {
+	var exts []string
+	for _, ext := range w.Opt.PageExtensions() {
+		exts = append(exts, strings.TrimPrefix(ext, "."))
+	}
+	files, _ := wikifier.UniqueFilesInDir(w.Dir("templates"), exts, false)
+	templates := make([]PageTemplate, 0, len(files))
+	for _, name := range files {
+		if t, err := w.PageTemplate(name); err == nil {
+			templates = append(templates, t)
+		}
+	}
+	return templates
+}
+
+// PageTemplate returns the template of the given filename.
+func (w *Wiki) PageTemplate(name string) (PageTemplate, error) {
+	name = filepath.ToSlash(filepath.Clean(w.Opt.PageName(name)))
+	if name == "." || strings.HasPrefix(name, "../") || filepath.IsAbs(name) {
+		return PageTemplate{}, errors.New("invalid template name: " + name)
+	}
+	content, err := ioutil.ReadFile(w.Dir("templates", filepath.FromSlash(name)))
+	if err != nil {
+		return PageTemplate{}, errors.Wrap(err, "template "+name)
+	}
+	return PageTemplate{
+		File:         name,
+		Placeholders: Placeholders(string(content)),
+		Content:      string(content),
+	}, nil
+}
+
+// NewPageFromTemplate returns the source of a new page created from a
+// template, with its placeholders replaced by the given values. If any are
+// not given, the page is marked as a draft, since it cannot be published
+// until they are filled in, and a comment recording the template is added.
+func (w *Wiki) NewPageFromTemplate(name string, values map[string]string) (string, error) {
+	t, err := w.PageTemplate(name)
+	if err != nil {
+		return "", err
+	}
+	content := FillPlaceholders(t.Content, values)
+	if len(Placeholders(content)) != 0 {
+		if !sourceIsDraft(content) {
+			content = "@page.draft;\n" + content
+		}
+		content = "/* created from template " + t.File + " */\n" + content
+	}
+	return content, nil
+}
+
+// returns an error if page source created from a template has unresolved
+// placeholders but is not a draft, which prevents publishing a page before
+// its template is filled in. other pages may contain double braces freely
+func checkPlaceholders(content []byte) error {
+	if !templateMarkRegex.Match(content) {
+		return nil
+	}
+	names := Placeholders(string(content))
+	if len(names) == 0 || sourceIsDraft(string(content)) {
+		return nil
+	}
+	return errors.New("page cannot be published with unresolved placeholders: " + strings.Join(names, ", ") +
+		"; fill them in or mark the page as a draft")
+}
+
+// returns true if page source sets @page.draft
+func sourceIsDraft(content string) bool {
+	page := wikifier.NewPageSource(content)
+	page.VarsOnly = true
+	if err := page.Parse(); err != nil {
+		return false
+	}
+	return page.Draft()
+}
@@ -1,6 +1,7 @@
 package wiki
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"errors"
@@ -76,6 +77,26 @@ type DisplayPage struct {
 	// list of categories the page belongs to, without the '.cat' extension
 	Categories []string `json:"categories,omitempty"`
 
+	// table of contents
+	TOC []wikifier.TOCEntry `json:"toc,omitempty"`
+
+	// every section, with word counts
+	Sections []wikifier.SectionInfo `json:"sections,omitempty"`
+
+	// entries of events{} blocks
+	Events []wikifier.Event `json:"events,omitempty"`
+
+	// [todo:...] and [review:...] markers, which are only for editors
+	Notes []wikifier.EditorNote `json:"-"`
+
+	// the content including editor notes, which are removed from Content.
+	// empty if the page has no notes
+	EditorContent wikifier.HTML `json:"-"`
+
+	// SHA-256 of the generated HTML content, as hexadecimal. this does not
+	// include the comment prepended to cached content
+	Hash string `json:"hash,omitempty"`
+
 	// page title as extracted from the special @page.title variable, including
 	// any possible HTML-encoded formatting
 	FmtTitle wikifier.HTML `json:"fmt_title,omitempty"`
@@ -95,11 +116,41 @@ type DisplayPage struct {
 }
 
 type pageJSONManifest struct {
-	CSS        string   `json:"css,omitempty"`
-	Categories []string `json:"categories,omitempty"`
+	CSS        string                 `json:"css,omitempty"`
+	Categories []string               `json:"categories,omitempty"`
+	TOC        []wikifier.TOCEntry    `json:"toc,omitempty"`
+	Sections   []wikifier.SectionInfo `json:"sections,omitempty"`
+	Events     []wikifier.Event       `json:"events,omitempty"`
+	Notes      []wikifier.EditorNote  `json:"notes,omitempty"`
+
+	// pages listed by backlinks{}, if any
+	ListsBacklinks bool     `json:"lists_backlinks,omitempty"`
+	Backlinks      []string `json:"backlinks,omitempty"`
+
+	// pages listed by pages-where{}, if any
+	Queries []pageQueryManifest `json:"queries,omitempty"`
+
 	wikifier.PageInfo
 }
 
+// DisplayPageMeta represents information about a page for client scripts
+// and other programs which introspect pages.
+type DisplayPageMeta struct {
+	wikifier.PageInfo
+
+	// list of categories the page belongs to, without the '.cat' extension
+	Categories []string `json:"categories,omitempty"`
+
+	// table of contents
+	TOC []wikifier.TOCEntry `json:"toc,omitempty"`
+
+	// names of pages which link to this one
+	Backlinks []string `json:"backlinks,omitempty"`
+
+	// models, images, and pages which this page depends on
+	Dependencies []Dependency `json:"dependencies,omitempty"`
+}
+
 // FindPage attempts to find a page on this wiki given its name,
 // regardless of the file format or filename case.
 //
@@ -112,13 +163,13 @@ func (w *Wiki) FindPage(name string) (p *wikifier.Page) {
 	// separate into prefix and base
 	pfx, base := filepath.Dir(name), filepath.Base(name)
 
-	// try in this order
-	tryFiles := []string{
-		wikifier.PageNameLink(base),                            // exact match with no lowercasing
-		wikifier.PageNameLink(base) + ".page",                  // .page with no lowercasing
-		strings.ToLower(wikifier.PageNameLink(base)) + ".page", // .page with lowercasing
-		wikifier.PageNameLink(base) + ".md",                    // .md with no lowercasing
-		strings.ToLower(wikifier.PageNameLink(base)) + ".md",   // .md with lowercasing
+	// try an exact match, then each extension with and without lowercasing
+	tryFiles := []string{wikifier.PageNameLink(base)}
+	for _, ext := range w.Opt.PageExtensions() {
+		tryFiles = append(tryFiles,
+			wikifier.PageNameLink(base)+ext,
+			strings.ToLower(wikifier.PageNameLink(base))+ext,
+		)
 	}
 	path := ""
 	for _, try := range tryFiles {
@@ -135,21 +186,51 @@ func (w *Wiki) FindPage(name string) (p *wikifier.Page) {
 	} else {
 
 		// didn't find anything, so create one
-		p = wikifier.NewPagePath(w.pathForPage(wikifier.PageName(name)), name)
+		p = wikifier.NewPagePath(w.pathForPage(w.Opt.PageName(name)), name)
 	}
 
 	// these are available to all pages
 	p.Wiki = w
 	p.Opt = &w.Opt
-
-	// create page lock
-	if _, exist := w.pageLocks[p.Name()]; !exist {
-		w.pageLocks[p.Name()] = new(sync.Mutex)
+	if site := w.siteVars(); site != nil {
+		p.Set("site", site)
 	}
 
 	return
 }
 
+// parses the source for a file which may not have been written yet, with
+// the translator for its extension. returns nil if the file is not a page or
+// the source cannot be parsed
+func (w *Wiki) parseSource(file, source string) *wikifier.Page {
+	source, ok, err := w.Opt.TranslateSource(file, source)
+	if !ok || err != nil {
+		return nil
+	}
+	page := wikifier.NewPageSource(source)
+	page.Wiki = w
+	page.Opt = &w.Opt
+	if site := w.siteVars(); site != nil {
+		page.Set("site", site)
+	}
+	if err := page.Parse(); err != nil {
+		return nil
+	}
+	return page
+}
+
+// returns the lock for generating a page, creating it if needed
+func (w *Wiki) pageLock(name string) *sync.Mutex {
+	w.pageLocksLock.Lock()
+	defer w.pageLocksLock.Unlock()
+	lock, exist := w.pageLocks[name]
+	if !exist {
+		lock = new(sync.Mutex)
+		w.pageLocks[name] = lock
+	}
+	return lock
+}
+
 // DisplayPage returns the display result for a page.
 func (w *Wiki) DisplayPage(name string) interface{} {
 	return w.DisplayPageDraft(name, false)
@@ -185,12 +266,20 @@ func (w *Wiki) DisplayPageDraft(name string, draftOK bool) interface{} {
 		return DisplayRedirect{Redirect: page.Redirect()}
 	}
 
+	// only generate once at a time. if pregeneration and a request or two
+	// requests want the same page, the one which waits is served the cached
+	// copy written by the other
+	lock := w.pageLock(r.File)
+	lock.Lock()
+	defer lock.Unlock()
+
 	// caching is enabled, so serve the cached copy if available
 	if w.Opt.Page.EnableCache && page.CacheExists() {
 		if errOrRedir := w.displayCachedPage(page, &r, draftOK); errOrRedir != nil {
 			return errOrRedir
 		}
 		if r.FromCache {
+			r.hideEditorNotes()
 			return r
 		}
 	}
@@ -202,6 +291,7 @@ func (w *Wiki) DisplayPageDraft(name string, draftOK bool) interface{} {
 	// if an error occurs, parse it again in variable-only mode.
 	// then hopefully we can at least get the metadata and categories
 	//
+	w.runPageHooks(HookBeforeParse, page, &r)
 	err := page.Parse()
 	if err != nil {
 
@@ -218,7 +308,7 @@ func (w *Wiki) DisplayPageDraft(name string, draftOK bool) interface{} {
 
 		// add page to categories-
 		// should be possible if VarsOnly mode was successful
-		w.updatePageCategories(page)
+		w.updatePageCategories(page, "")
 
 		// extract the ParserError
 		var pErr *wikifier.ParserError
@@ -227,6 +317,7 @@ func (w *Wiki) DisplayPageDraft(name string, draftOK bool) interface{} {
 		return DisplayError{Error: err.Error(), Pos: pErr.Pos}
 	}
 
+	w.runPageHooks(HookAfterParse, page, &r)
 	// if this is a draft and we're not serving drafts, pretend
 	// that the page does not exist
 	if !draftOK && page.Draft() {
@@ -237,14 +328,13 @@ func (w *Wiki) DisplayPageDraft(name string, draftOK bool) interface{} {
 	// this is for pages we just parsed with @page.redirect
 	if redir := page.Redirect(); redir != "" {
 		w.writeVarsCache(page)
-		w.updatePageCategories(page)
+		w.updatePageCategories(page, "")
 		// consider: set r.Categories? can redirects belong to categories?
 		return DisplayRedirect{Redirect: redir}
 	}
 
-	// only generate once at a time
-	w.pageLocks[r.File].Lock()
-	defer w.pageLocks[r.File].Unlock()
+	// warn about content required by schemas
+	w.checkPageSchemas(page)
 
 	// generate HTML and metadata
 	create := page.Created()
@@ -262,12 +352,19 @@ func (w *Wiki) DisplayPageDraft(name string, draftOK bool) interface{} {
 	r.Draft = page.Draft()
 	r.Modified = &mod
 	r.ModifiedHTTP = httpdate.Time2Str(mod)
+	w.runPageHooks(HookBeforeHTML, page, &r)
 	r.Content = page.HTML()
 	r.CSS = page.CSS()
+	r.TOC = page.TOC()
+	r.Sections = page.Sections()
+	r.Events = page.Events()
+	r.Notes = page.EditorNotes()
+	w.runPageHooks(HookAfterHTML, page, &r)
 	r.Warnings = page.Warnings
+	r.Hash = contentHash(r.Content)
 
 	// update categories
-	w.updatePageCategories(page)
+	w.updatePageCategories(page, r.Hash)
 	r.Categories = page.Categories()
 
 	// only do these things if content was generated
@@ -284,9 +381,21 @@ func (w *Wiki) DisplayPageDraft(name string, draftOK bool) interface{} {
 		}
 	}
 
+	w.runPageHooks(HookAfterWrite, page, &r)
+
+	r.hideEditorNotes()
 	return r
 }
 
+// removes editor notes from the content, keeping them in EditorContent
+func (r *DisplayPage) hideEditorNotes() {
+	if len(r.Notes) == 0 {
+		return
+	}
+	r.EditorContent = r.Content
+	r.Content = wikifier.StripEditorNotes(r.Content)
+}
+
 // Pages returns info about all the pages in the wiki.
 func (w *Wiki) Pages() []wikifier.PageInfo {
 	pageNames := w.allPageFiles()
@@ -364,7 +473,7 @@ func (w *Wiki) PageInfo(name string) (info wikifier.PageInfo) {
 	}
 
 	// find page category
-	nameNE := wikifier.PageNameNE(name)
+	nameNE := w.Opt.PageNameNE(name)
 	pageCat := w.GetSpecialCategory(nameNE, CategoryTypePage)
 
 	// if page category exists use that info
@@ -391,6 +500,55 @@ func (w *Wiki) PageInfo(name string) (info wikifier.PageInfo) {
 	return
 }
 
+// DisplayPageMeta returns information about a page, including its table of
+// contents, the pages which link to it, and the resources it depends on.
+//
+// The page is generated if there is no cached copy. Like DisplayPage, the
+// result is a DisplayError for drafts. For redirects, the result is a
+// DisplayPageMeta with only the Redirect and other basic info.
+func (w *Wiki) DisplayPageMeta(name string) interface{} {
+	var page DisplayPage
+	switch res := w.DisplayPage(name).(type) {
+	case DisplayPage:
+		page = res
+	case DisplayRedirect:
+		info := w.PageInfo(w.FindPage(name).Name())
+		info.Redirect = res.Redirect
+		return DisplayPageMeta{PageInfo: info}
+	default:
+		return res
+	}
+
+	return DisplayPageMeta{
+		PageInfo:     w.PageInfo(page.File),
+		Categories:   page.Categories,
+		TOC:          page.TOC,
+		Backlinks:    w.Dependents(Dependency{CategoryTypePage, page.File}),
+		Dependencies: w.Dependencies(page.File),
+	}
+}
+
+// DisplayPageSource returns the source file of a page from a plain text file,
+// such as a changelog, as a DisplayFile for downloading.
+//
+// Like DisplayPage, the result is a DisplayError for drafts. The source of
+// pages in other formats is not available, since it may contain content
+// which is not displayed.
+func (w *Wiki) DisplayPageSource(name string) interface{} {
+	res := w.DisplayPage(name)
+	page, ok := res.(DisplayPage)
+	if !ok {
+		return res
+	}
+	if w.Opt.PageTranslatorName(page.Path) != "plaintext" {
+		return DisplayError{
+			Error:         "Page source is not available.",
+			DetailedError: "Page '" + page.Path + "' is not a plain text page.",
+		}
+	}
+	return w.DisplayFile(page.Path)
+}
+
 // like writePageCache except it only includes PageInfo.
 // used for redirects and parser errors where vars could still be extracted.
 func (w *Wiki) writeVarsCache(page *wikifier.Page) {
@@ -400,21 +558,13 @@ func (w *Wiki) writeVarsCache(page *wikifier.Page) {
 		return
 	}
 
-	// open the cache file for writing
-	cacheFile, err := os.Create(page.CachePath())
-	defer cacheFile.Close()
-	if err != nil {
-		return
-	}
-
 	// create manifest with just page info (includes redirect/error)
 	j, err := json.Marshal(pageJSONManifest{PageInfo: page.Info()})
 	if err != nil {
 		return
 	}
 
-	cacheFile.Write(j)
-	cacheFile.Write([]byte{'\n'})
+	writeFileAtomic(page.CachePath(), append(j, '\n'), 0644)
 }
 
 func (w *Wiki) writePageCache(page *wikifier.Page, r *DisplayPage) interface{} {
@@ -424,22 +574,24 @@ func (w *Wiki) writePageCache(page *wikifier.Page, r *DisplayPage) interface{} {
 		return nil
 	}
 
-	// open the cache file for writing
-	cacheFile, err := os.Create(page.CachePath())
-	defer cacheFile.Close()
-	if err != nil {
-		return DisplayError{
-			Error:         "Could not write page cache file.",
-			DetailedError: "Open '" + page.CachePath() + "' for write error: " + err.Error(),
-		}
-	}
-
 	// generate page info
 	info := pageJSONManifest{
 		CSS:        r.CSS,
 		Categories: r.Categories,
+		TOC:        r.TOC,
+		Sections:   r.Sections,
+		Events:     r.Events,
+		Notes:      r.Notes,
 		PageInfo:   page.Info(),
 	}
+	info.Hash = r.Hash
+	if links := page.Backlinks(); links != nil {
+		info.ListsBacklinks = true
+		info.Backlinks = pageFileNames(links)
+	}
+	for _, res := range page.Queries() {
+		info.Queries = append(info.Queries, newPageQueryManifest(res))
+	}
 
 	// encode as json
 	j, err := json.Marshal(info)
@@ -450,15 +602,23 @@ func (w *Wiki) writePageCache(page *wikifier.Page, r *DisplayPage) interface{} {
 		}
 	}
 
-	// save prefixing data
-	cacheFile.Write(j)
-	cacheFile.Write([]byte{'\n'})
-
-	// save content
+	// prefixing data, then content
+	var buf bytes.Buffer
+	buf.Write(j)
+	buf.WriteByte('\n')
 	content := string(r.Content)
-	cacheFile.WriteString(content)
+	buf.WriteString(content)
 	if len(content) != 0 && content[len(content)-1] != '\n' {
-		cacheFile.Write([]byte{'\n'})
+		buf.WriteByte('\n')
+	}
+
+	// write to a temporary file and rename it, so that a request never reads
+	// a partially written cache file
+	if err := writeFileAtomic(page.CachePath(), buf.Bytes(), 0644); err != nil {
+		return DisplayError{
+			Error:         "Could not write page cache file.",
+			DetailedError: "Write '" + page.CachePath() + "' error: " + err.Error(),
+		}
 	}
 
 	// update result with real cache modified times
@@ -477,19 +637,14 @@ func (w *Wiki) writePageText(page *wikifier.Page, r *DisplayPage) interface{} {
 		return nil
 	}
 
-	// open the text file for writing
-	textFile, err := os.Create(page.SearchPath())
-	defer textFile.Close()
-	if err != nil {
+	// save the content with HTML tags stripped
+	if err := writeFileAtomic(page.SearchPath(), []byte(page.Text()), 0644); err != nil {
 		return DisplayError{
 			Error:         "Could not write page text file.",
-			DetailedError: "Open '" + page.SearchPath() + "' for write error: " + err.Error(),
+			DetailedError: "Write '" + page.SearchPath() + "' error: " + err.Error(),
 		}
 	}
 
-	// save the content with HTML tags stripped
-	textFile.WriteString(page.Text())
-
 	r.TextGenerated = true
 	return nil // success
 }
@@ -498,9 +653,9 @@ func (w *Wiki) displayCachedPage(page *wikifier.Page, r *DisplayPage, draftOK bo
 	cacheModify := page.CacheModified()
 	timeStr := httpdate.Time2Str(cacheModify)
 
-	// the page's file is more recent than the cache file.
-	// discard the outdated cached copy
-	if page.Modified().After(cacheModify) {
+	// the page or something it depends on has changed since the cache file
+	// was written. discard the outdated cached copy
+	if w.pageModifiedAfter(page, cacheModify) {
 		os.Remove(page.CachePath())
 		return nil // OK
 	}
@@ -524,8 +679,13 @@ func (w *Wiki) displayCachedPage(page *wikifier.Page, r *DisplayPage, draftOK bo
 		cacheContent = cacheContent[firstNL:]
 	}
 
-	// the rest is the html content
-	content += string(cacheContent)
+	// the rest is the html content. with the deterministic option,
+	// serve it exactly as generated, without the cache date comment
+	if w.Opt.Deterministic {
+		content = strings.TrimPrefix(string(cacheContent), "\n")
+	} else {
+		content += string(cacheContent)
+	}
 
 	// decode the manifest
 	var info pageJSONManifest
@@ -536,6 +696,12 @@ func (w *Wiki) displayCachedPage(page *wikifier.Page, r *DisplayPage, draftOK bo
 		}
 	}
 
+	// the pages listed by backlinks{} or pages-where{} have changed
+	if w.listedPagesChanged(page, info, cacheModify) {
+		os.Remove(page.CachePath())
+		return nil // OK
+	}
+
 	// if this is a draft and we're not serving drafts, pretend
 	// that the page does not exist
 	if !draftOK && info.Draft {
@@ -572,6 +738,12 @@ func (w *Wiki) displayCachedPage(page *wikifier.Page, r *DisplayPage, draftOK bo
 	r.Warnings = info.Warnings
 	r.FromCache = true
 	r.CSS = info.CSS
+	r.Categories = info.Categories
+	r.TOC = info.TOC
+	r.Sections = info.Sections
+	r.Events = info.Events
+	r.Notes = info.Notes
+	r.Hash = info.Hash
 	r.Content = wikifier.HTML(content)
 	r.Modified = &cacheModify
 	r.ModifiedHTTP = httpdate.Time2Str(cacheModify)
@@ -579,6 +751,58 @@ func (w *Wiki) displayCachedPage(page *wikifier.Page, r *DisplayPage, draftOK bo
 	return nil // success
 }
 
+// CachedPageModified returns the time the cached copy of a page was written,
+// or nil if there is no cached copy or it is out of date, in which case the
+// page is generated again the next time it is displayed.
+func (w *Wiki) CachedPageModified(name string) *time.Time {
+	page := w.FindPage(name)
+	if !w.Opt.Page.EnableCache || !page.Exists() || !page.CacheExists() {
+		return nil
+	}
+	cacheModify := page.CacheModified()
+	if w.pageModifiedAfter(page, cacheModify) {
+		return nil
+	}
+
+	// the manifest is the first line of the cache file
+	file, err := os.Open(page.CachePath())
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	jsonData, err := bufio.NewReader(file).ReadBytes('\n')
+	if err != nil {
+		return nil
+	}
+	var info pageJSONManifest
+	if err := json.Unmarshal(jsonData, &info); err != nil || w.listedPagesChanged(page, info, cacheModify) {
+		return nil
+	}
+	return &cacheModify
+}
+
+// true if the page, a model or linked page, or the site variables have
+// changed since the given time
+func (w *Wiki) pageModifiedAfter(page *wikifier.Page, t time.Time) bool {
+	return page.Modified().After(t) ||
+		w.dependenciesModifiedAfter(page.Name(), t) ||
+		w.siteVarsModifiedAfter(t)
+}
+
+// true if the pages listed by backlinks{} or pages-where{} differ from those
+// recorded in the manifest of the page, which was cached at the given time
+func (w *Wiki) listedPagesChanged(page *wikifier.Page, info pageJSONManifest, cacheModify time.Time) bool {
+	if info.ListsBacklinks && w.backlinksChanged(page, info.Backlinks) {
+		return true
+	}
+	return w.queriesChanged(page, info.Queries, cacheModify)
+}
+
+// SHA-256 of generated content, as hexadecimal
+func contentHash(content wikifier.HTML) string {
+	return sha256Hex([]byte(content))
+}
+
 // like page.warn
 func pageWarn(p *wikifier.Page, warning string, pos wikifier.Position) {
 	w := wikifier.Warning{Message: warning, Pos: pos}
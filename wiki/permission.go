@@ -0,0 +1,134 @@
+package wiki
+
+import (
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// InGroup returns true if the user by the given username is a member of the
+// named group, as declared by the groups.[name] wiki option.
+func (w *Wiki) InGroup(username, group string) bool {
+	for _, member := range w.Opt.Groups[group] {
+		if strings.EqualFold(member, username) {
+			return true
+		}
+	}
+	return false
+}
+
+// PageGroups returns the names of the groups whose members can edit the
+// named page, in sorted order. If the page is not restricted by any of the
+// permissions.[group] wiki options, the result is empty.
+func (w *Wiki) PageGroups(pageName string) []string {
+	pageName = strings.ToLower(w.Opt.PageNameNE(path.Clean("/" + pageName)[1:]))
+	var groups []string
+	for group, patterns := range w.Opt.Permissions {
+		for _, pattern := range patterns {
+			if pagePatternMatch(strings.ToLower(pattern), pageName) {
+				groups = append(groups, group)
+				break
+			}
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// CanEdit returns true if the user by the given username can edit the named
+// page.
+//
+// Pages matching a pattern in the permissions.[group] wiki options can only
+// be edited by members of the groups which claim them. All other pages can
+// be edited by anyone.
+func (w *Wiki) CanEdit(username, pageName string) bool {
+	groups := w.PageGroups(pageName)
+	if len(groups) == 0 {
+		return true
+	}
+	for _, group := range groups {
+		if w.InGroup(username, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// WritePage writes a page file on behalf of the user identified by
+// commit.User, who must be permitted to edit it by CanEdit.
+//
+// The name is relative to the page directory. It is cleaned before
+// permissions are checked, and names which leave the page directory with
+// ".." are rejected.
+// If the page does not exist and createOK is false, an error is returned.
+// Pages with unresolved {{placeholder}} tokens from a template can only be
+// written as drafts. Pages which lack content required by a schema.[name]
+// option whose action is reject are rejected with a *SchemaError. If the
+// change fails the content filters, a *FilterError is returned, and the
+// change may have been proposed for review instead.
+func (w *Wiki) WritePage(name string, content []byte, createOK bool, commit CommitOpts) error {
+	name, err := cleanFileName(name)
+	if err != nil {
+		return err
+	}
+	if !w.CanEdit(commit.User, name) {
+		return permissionError(w, name)
+	}
+	if err := checkPlaceholders(content); err != nil {
+		return err
+	}
+	if err := w.checkSchemaWrite(path.Join("pages", name), content); err != nil {
+		return err
+	}
+	if err := w.filterWrite(path.Join("pages", name), content, commit); err != nil {
+		return err
+	}
+	return w.WriteFile(path.Join("pages", name), content, createOK, commit)
+}
+
+// returns true if a user can write to the file, which is relative to the
+// wiki directory. only pages are subject to access rules
+func (w *Wiki) canEditFile(username, name string) bool {
+	name = strings.TrimPrefix(path.Clean(name), "./")
+	if !strings.HasPrefix(name, "pages/") {
+		return true
+	}
+	return w.CanEdit(username, strings.TrimPrefix(name, "pages/"))
+}
+
+// cleans a filename relative to some directory, such as a page name. names
+// containing ".." are rejected, so that the result is always within the
+// directory and is matched by access rules as written
+func cleanFileName(name string) (string, error) {
+	name = filepath.ToSlash(name)
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", errors.New("invalid filename " + name)
+		}
+	}
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return "", errors.New("filename is empty")
+	}
+	return name, nil
+}
+
+func permissionError(w *Wiki, pageName string) error {
+	return errors.New("editing " + w.Opt.PageNameNE(pageName) +
+		" is restricted to members of " + strings.Join(w.PageGroups(pageName), ", "))
+}
+
+// reports whether a lowercase page name without extension matches a
+// pattern. a trailing slash matches all pages within a directory, including
+// subdirectories; otherwise patterns are as in path.Match
+func pagePatternMatch(pattern, pageName string) bool {
+	pattern = strings.TrimSuffix(pattern, ".page")
+	if strings.HasSuffix(pattern, "/") || strings.HasSuffix(pattern, "/*") {
+		return strings.HasPrefix(pageName, strings.TrimSuffix(pattern, "*"))
+	}
+	matched, _ := path.Match(pattern, pageName)
+	return matched
+}
@@ -0,0 +1,196 @@
+package wiki
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cooper/quiki/wikifier"
+)
+
+// the pages listed by a pages-where{} on a cached page, with the titles
+// shown for them
+type pageQueryManifest struct {
+	Query  wikifier.PageQuery `json:"query"`
+	Pages  []string           `json:"pages,omitempty"`
+	Titles []string           `json:"titles,omitempty"`
+}
+
+// when the page metadata used by pages-where{} last changed, so the queries
+// of cached pages are only run again when they might list other pages
+type pageQueryState struct {
+	changed  time.Time            // when metadata last changed
+	verified map[string]time.Time // when cached pages were found up to date
+	mu       sync.Mutex
+}
+
+// creating, changing, or deleting a page may change the pages listed
+func init() {
+	AddChangeHook(func(w *Wiki, c Change) {
+		if strings.HasPrefix(c.File, "pages/") {
+			w.pageMetadataChanged()
+		}
+	})
+}
+
+func newPageQueryManifest(res wikifier.PageQueryResult) pageQueryManifest {
+	return pageQueryManifest{
+		Query:  res.Query,
+		Pages:  pageFileNames(res.Pages),
+		Titles: pageQueryTitles(res.Pages),
+	}
+}
+
+// the titles of the pages listed by pages-where{}, as displayed
+func pageQueryTitles(pages []wikifier.PageInfo) []string {
+	titles := make([]string, len(pages))
+	for i, info := range pages {
+		titles[i] = string(info.FmtTitle)
+		if titles[i] == "" {
+			titles[i] = info.FileNE
+		}
+	}
+	return titles
+}
+
+// PagesWhere returns info about the published pages matching a query, as
+// listed by pages-where{}.
+//
+// Page metadata and categories are recorded whenever a page is generated, so
+// pages which have not been generated yet may not be included.
+func (w *Wiki) PagesWhere(q wikifier.PageQuery) []wikifier.PageInfo {
+
+	// pages in the category
+	var inCategory map[string]CategoryEntry
+	if q.Category != "" {
+		cat := w.GetCategory(q.Category)
+		cat.update(w)
+		inCategory = cat.Pages
+	}
+
+	// newest first when sorting by date
+	var pages []wikifier.PageInfo
+	switch q.Sort {
+	case "created":
+		pages = w.PagesSorted(true, SortCreated)
+	case "modified":
+		pages = w.PagesSorted(true, SortModified)
+	case "author":
+		pages = w.PagesSorted(false, SortAuthor, SortTitle)
+	default:
+		pages = w.PagesSorted(false, SortTitle)
+	}
+
+	prefix := strings.ToLower(strings.TrimPrefix(q.Prefix, "/"))
+	var matches []wikifier.PageInfo
+	for _, info := range pages {
+		if info.File == "" || info.Draft || info.Redirect != "" || info.Error != nil {
+			continue
+		}
+		if inCategory != nil {
+			if _, ok := inCategory[info.File]; !ok {
+				continue
+			}
+		} else if q.Category != "" {
+			continue
+		}
+		if prefix != "" && !strings.HasPrefix(strings.ToLower(info.FileNE), prefix) {
+			continue
+		}
+		if q.Author != "" && !strings.EqualFold(info.Author, q.Author) {
+			continue
+		}
+		matches = append(matches, info)
+		if q.Limit != 0 && len(matches) == q.Limit {
+			break
+		}
+	}
+	return matches
+}
+
+func pageQuery(page *wikifier.Page, q wikifier.PageQuery) []wikifier.PageInfo {
+	w, ok := page.Wiki.(*Wiki)
+	if !ok || page.External() {
+		return nil
+	}
+
+	// the page does not list itself, so the limit is applied after it is
+	// left out
+	self := w.Opt.PageNameNE(page.Name())
+	limit := q.Limit
+	q.Limit = 0
+	var pages []wikifier.PageInfo
+	for _, info := range w.PagesWhere(q) {
+		if info.FileNE == self {
+			continue
+		}
+		pages = append(pages, info)
+		if limit != 0 && len(pages) == limit {
+			break
+		}
+	}
+	return pages
+}
+
+// records that page metadata which pages-where{} uses has changed
+func (w *Wiki) pageMetadataChanged() {
+	st := &w.queries
+	st.mu.Lock()
+	st.changed = time.Now()
+	st.mu.Unlock()
+}
+
+// true if the metadata of a page which pages-where{} uses differs
+func pageQueryInfoChanged(a, b wikifier.PageInfo) bool {
+	timeChanged := func(a, b *time.Time) bool {
+		return (a == nil) != (b == nil) || a != nil && !a.Equal(*b)
+	}
+	return a.Title != b.Title || a.FmtTitle != b.FmtTitle ||
+		a.Author != b.Author || a.Draft != b.Draft ||
+		a.Redirect != b.Redirect || (a.Error == nil) != (b.Error == nil) ||
+		timeChanged(a.Created, b.Created) || timeChanged(a.Modified, b.Modified)
+}
+
+// returns true if the pages matching the queries of the pages-where{} blocks
+// of a page cached at the given time are no longer those listed, or their
+// titles have changed.
+//
+// the queries are only run if page metadata has changed since the page was
+// cached or last found up to date. metadata changes are not known before the
+// wiki was opened, so each cached page is checked once after that
+func (w *Wiki) queriesChanged(page *wikifier.Page, cached []pageQueryManifest, cacheModify time.Time) bool {
+	if len(cached) == 0 {
+		return false
+	}
+	st := &w.queries
+	st.mu.Lock()
+	if st.changed.IsZero() {
+		st.changed = time.Now()
+	}
+	since := cacheModify
+	if t := st.verified[page.Name()]; t.After(since) {
+		since = t
+	}
+	changed := st.changed.After(since)
+	st.mu.Unlock()
+	if !changed {
+		return false
+	}
+
+	checked := time.Now()
+	for _, query := range cached {
+		res := pageQuery(page, query.Query)
+		if !stringsEqual(pageFileNames(res), query.Pages) ||
+			!stringsEqual(pageQueryTitles(res), query.Titles) {
+			return true
+		}
+	}
+
+	st.mu.Lock()
+	if st.verified == nil {
+		st.verified = make(map[string]time.Time)
+	}
+	st.verified[page.Name()] = checked
+	st.mu.Unlock()
+	return false
+}
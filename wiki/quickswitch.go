@@ -0,0 +1,208 @@
+package wiki
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cooper/quiki/wikifier"
+)
+
+// QuickSwitchResult is a page matching a quick switcher query.
+type QuickSwitchResult struct {
+	File  string `json:"file"`  // page name with extension
+	Title string `json:"title"` // page title without tags
+	Link  string `json:"link"`  // link to the page
+	Score int    `json:"score"` // higher is a better match
+}
+
+// one page in the title index
+type quickSwitchEntry struct {
+	file, title string
+	lowerTitle  string // lowercase title
+	lowerName   string // lowercase page name without extension
+}
+
+// in-memory title index of a wiki, built on first use. entries are updated
+// when a page is generated or a page file changes. the slice is replaced
+// rather than modified, so searches can use it without holding the lock
+type quickSwitchIndex struct {
+	entries []quickSwitchEntry
+	mu      sync.Mutex
+}
+
+// page changes update the index
+func init() {
+	AddChangeHook(func(w *Wiki, c Change) {
+		if name := strings.TrimPrefix(c.File, "pages/"); name != c.File {
+			w.updateQuickSwitchPage(name)
+		}
+	})
+}
+
+// QuickSwitch returns up to limit published pages whose title or name
+// matches query, best matches first. Exact matches are preferred over
+// prefixes, prefixes over word prefixes and substrings, and those over
+// fuzzy matches in which the characters of the query appear in order.
+//
+// Page titles are indexed in memory, so this is fast even on large wikis.
+func (w *Wiki) QuickSwitch(query string, limit int) []QuickSwitchResult {
+	query = strings.ToLower(strings.TrimSpace(query))
+	results := make([]QuickSwitchResult, 0)
+	if query == "" || limit <= 0 {
+		return results
+	}
+
+	// keep only the best limit matches, in order
+	type match struct {
+		e     *quickSwitchEntry
+		score int
+	}
+	better := func(a, b match) bool {
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if len(a.e.title) != len(b.e.title) {
+			return len(a.e.title) < len(b.e.title)
+		}
+		return a.e.title < b.e.title
+	}
+	best := make([]match, 0, limit+1)
+	entries := w.quickSwitchEntries()
+	for i := range entries {
+		e := &entries[i]
+		score := quickSwitchScore(e.lowerTitle, query)
+		if nameScore := quickSwitchScore(e.lowerName, query); nameScore > score {
+			score = nameScore
+		}
+		if score == 0 {
+			continue
+		}
+		m := match{e, score}
+		if len(best) == limit && !better(m, best[limit-1]) {
+			continue
+		}
+		pos := sort.Search(len(best), func(j int) bool { return better(m, best[j]) })
+		best = append(best, match{})
+		copy(best[pos+1:], best[pos:])
+		best[pos] = m
+		if len(best) > limit {
+			best = best[:limit]
+		}
+	}
+
+	for _, m := range best {
+		results = append(results, QuickSwitchResult{
+			File:  m.e.file,
+			Title: m.e.title,
+			Link:  w.Opt.Root.Page + "/" + w.Opt.PageNameNE(m.e.file),
+			Score: m.score,
+		})
+	}
+	return results
+}
+
+// returns the title index, building it if necessary
+func (w *Wiki) quickSwitchEntries() []quickSwitchEntry {
+	idx := &w.quickSwitch
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.entries != nil {
+		return idx.entries
+	}
+
+	entries := make([]quickSwitchEntry, 0)
+	for _, info := range w.Pages() {
+		if info.Draft || info.File == "" {
+			continue
+		}
+		entries = append(entries, w.quickSwitchEntry(info))
+	}
+	idx.entries = entries
+	return entries
+}
+
+func (w *Wiki) quickSwitchEntry(info wikifier.PageInfo) quickSwitchEntry {
+	nameNE := w.Opt.PageNameNE(info.File)
+	title := info.Title
+	if title == "" {
+		title = nameNE
+	}
+	return quickSwitchEntry{
+		file:       info.File,
+		title:      title,
+		lowerTitle: strings.ToLower(title),
+		lowerName:  strings.ToLower(strings.Replace(nameNE, "_", " ", -1)),
+	}
+}
+
+// updates the entry of a page file which was written or deleted
+func (w *Wiki) updateQuickSwitchPage(name string) {
+	info := w.PageInfo(name)
+	info.File = filepath.ToSlash(name)
+	w.updateQuickSwitchEntry(info, info.Path == "")
+}
+
+// replaces the entry of a page after it is generated or changed, or removes
+// it if the page was deleted or is a draft. if the index has not been built
+// yet, there is nothing to update
+func (w *Wiki) updateQuickSwitchEntry(info wikifier.PageInfo, deleted bool) {
+	idx := &w.quickSwitch
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.entries == nil || info.File == "" || info.External {
+		return
+	}
+
+	entries := make([]quickSwitchEntry, 0, len(idx.entries)+1)
+	for _, e := range idx.entries {
+		if e.file != info.File {
+			entries = append(entries, e)
+		}
+	}
+	if !deleted && !info.Draft {
+		entries = append(entries, w.quickSwitchEntry(info))
+	}
+	idx.entries = entries
+}
+
+// scores how well s matches query, both lowercase. 0 means no match
+func quickSwitchScore(s, query string) int {
+	switch {
+	case s == query:
+		return 100
+	case strings.HasPrefix(s, query):
+		return 80
+	}
+	if i := strings.Index(s, query); i != -1 {
+		// i > 0 since s does not begin with query
+		if strings.Contains(s[i-1:], " "+query) {
+			return 60
+		}
+		return 40
+	}
+
+	// fuzzy: every character of the query in order. fewer gaps is better
+	gaps, qi := 0, 0
+	q := []rune(query)
+	matched := false
+	for _, r := range s {
+		if qi == len(q) {
+			break
+		}
+		if r == q[qi] {
+			qi++
+			matched = true
+		} else if matched {
+			gaps++
+		}
+	}
+	if qi != len(q) {
+		return 0
+	}
+	if gaps >= 19 {
+		return 1
+	}
+	return 20 - gaps
+}
@@ -0,0 +1,82 @@
+package wiki
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cooper/quiki/wikifier"
+)
+
+// a wiki whose title index holds n pages, without any files
+func quickSwitchWiki(n int) *Wiki {
+	w := new(Wiki)
+	entries := make([]quickSwitchEntry, n)
+	for i := range entries {
+		entries[i] = w.quickSwitchEntry(wikifier.PageInfo{
+			File:  fmt.Sprintf("page_%d_about_topic_%d.page", i, i%97),
+			Title: fmt.Sprintf("Page %d about topic %d", i, i%97),
+		})
+	}
+	w.quickSwitch.entries = entries
+	return w
+}
+
+func TestQuickSwitchOrder(t *testing.T) {
+	w := quickSwitchWiki(0)
+	for _, title := range []string{"Main Page", "Mainland", "The main road", "Mountain"} {
+		w.quickSwitch.entries = append(w.quickSwitch.entries, w.quickSwitchEntry(wikifier.PageInfo{
+			File:  title + ".page",
+			Title: title,
+		}))
+	}
+	results := w.QuickSwitch("main", 10)
+	want := []string{"Mainland", "Main Page", "The main road", "Mountain"}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for i, r := range results {
+		if r.Title != want[i] {
+			t.Errorf("result %d: got %q, want %q", i, r.Title, want[i])
+		}
+	}
+}
+
+func TestQuickSwitchUpdateEntry(t *testing.T) {
+	w := quickSwitchWiki(3)
+
+	// a generated page replaces its entry
+	w.updateQuickSwitchEntry(wikifier.PageInfo{File: "page_1_about_topic_1.page", Title: "Renamed"}, false)
+	if r := w.QuickSwitch("renamed", 10); len(r) != 1 || r[0].File != "page_1_about_topic_1.page" {
+		t.Fatalf("updated entry not found: %+v", r)
+	}
+	if n := len(w.quickSwitch.entries); n != 3 {
+		t.Fatalf("got %d entries after update, want 3", n)
+	}
+
+	// drafts and deleted pages are removed
+	w.updateQuickSwitchEntry(wikifier.PageInfo{File: "page_1_about_topic_1.page", Draft: true}, false)
+	w.updateQuickSwitchEntry(wikifier.PageInfo{File: "page_2_about_topic_2.page"}, true)
+	if n := len(w.quickSwitch.entries); n != 1 {
+		t.Fatalf("got %d entries after removal, want 1", n)
+	}
+}
+
+// searching 10,000 pages should take well under 10ms
+func BenchmarkQuickSwitch(b *testing.B) {
+	w := quickSwitchWiki(10000)
+	for _, query := range []string{"page 5000", "topic 42", "pg5t4"} {
+		b.Run(query, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				w.QuickSwitch(query, 10)
+			}
+		})
+	}
+}
+
+func BenchmarkQuickSwitchUpdateEntry(b *testing.B) {
+	w := quickSwitchWiki(10000)
+	info := wikifier.PageInfo{File: "page_5000_about_topic_53.page", Title: "Updated"}
+	for i := 0; i < b.N; i++ {
+		w.updateQuickSwitchEntry(info, false)
+	}
+}
@@ -0,0 +1,84 @@
+package wiki
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ResizeEnabled returns true if images can be resized on demand, which
+// requires either image.resize.secret or image.resize.sizes.
+func (w *Wiki) ResizeEnabled() bool {
+	return w.Opt.Image.ResizeSecret != "" || len(w.Opt.Image.ResizeSizes) != 0
+}
+
+// ResizeSignature returns the signature which permits an image to be resized
+// on demand to the given dimensions. Either dimension may be zero to preserve
+// the aspect ratio. It returns an empty string if image.resize.secret is not
+// configured.
+func (w *Wiki) ResizeSignature(width, height int, name string) string {
+	if w.Opt.Image.ResizeSecret == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(w.Opt.Image.ResizeSecret))
+	mac.Write([]byte(resizeSize(width, height) + "/" + name))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// ResizeURL returns the URL of an image resized on demand, relative to the
+// HTTP root. The URL is signed if image.resize.secret is configured.
+func (w *Wiki) ResizeURL(width, height int, name string) string {
+	u := w.Opt.Root.Wiki + "/imgsz/" + resizeSize(width, height) + "/" + name
+	if sig := w.ResizeSignature(width, height, name); sig != "" {
+		u += "?" + url.Values{"sig": {sig}}.Encode()
+	}
+	return u
+}
+
+// DisplayResizedImage returns the display result for an image resized on
+// demand. The dimensions must be listed in image.resize.sizes, or the
+// signature must be valid for the dimensions and image name.
+//
+// Unlike DisplayImage, the image is served in the requested dimensions
+// without redirecting, and it is generated if it is not already cached.
+func (w *Wiki) DisplayResizedImage(width, height int, name, sig string) interface{} {
+	if !w.resizeAllowed(width, height, name, sig) {
+		return DisplayError{
+			Error:         "Image size not allowed.",
+			DetailedError: "Resize '" + name + "' to " + resizeSize(width, height) + " is not allowed without a valid signature",
+			Status:        http.StatusForbidden,
+		}
+	}
+
+	// fill in a missing dimension now so there is no redirect
+	img := SizedImageFromName(name)
+	img.Width, img.Height, img.Scale = width, height, 1
+	if (width == 0) != (height == 0) {
+		bigW, bigH := getImageDimensions(w.pathForImage(img.FullSizeName()), w.Opt.Image.AutoOrient)
+		img.Width, img.Height = calculateImageDimensions(bigW, bigH, width, height)
+	}
+
+	// only this size is needed, so retina scales are not pregenerated
+	return w.displaySizedImage(img, true, false)
+}
+
+func (w *Wiki) resizeAllowed(width, height int, name, sig string) bool {
+	if width == 0 && height == 0 {
+		return false
+	}
+	size := resizeSize(width, height)
+	for _, allowed := range w.Opt.Image.ResizeSizes {
+		if allowed == size {
+			return true
+		}
+	}
+	expected := w.ResizeSignature(width, height, name)
+	return expected != "" && hmac.Equal([]byte(sig), []byte(expected))
+}
+
+func resizeSize(width, height int) string {
+	return strconv.Itoa(width) + "x" + strconv.Itoa(height)
+}
@@ -0,0 +1,324 @@
+package wiki
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cooper/go-git/v4/utils/diff"
+	"github.com/pkg/errors"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// ReviewStatus describes the state of a proposed change.
+type ReviewStatus string
+
+const (
+	// ReviewPending means the change awaits approval.
+	ReviewPending ReviewStatus = "pending"
+
+	// ReviewApproved means the change was approved and written to the wiki.
+	ReviewApproved ReviewStatus = "approved"
+
+	// ReviewRejected means the change was rejected.
+	ReviewRejected ReviewStatus = "rejected"
+)
+
+// A Review is a proposed change to a wiki file.
+//
+// When moderation is enabled, edits by users who are not editors are stored
+// as reviews rather than written to the wiki. They appear on the wiki only
+// once approved by an editor.
+type Review struct {
+	ID       string       `json:"id"`
+	File     string       `json:"file"`               // filename relative to the wiki directory
+	Content  string       `json:"content"`            // proposed file content
+	Original string       `json:"original,omitempty"` // file content when the change was proposed
+	Comment  string       `json:"comment,omitempty"`  // commit message
+	Name     string       `json:"name,omitempty"`     // name of the contributor
+	Email    string       `json:"email,omitempty"`    // email of the contributor
+	Created  time.Time    `json:"created"`            // time proposed
+	Status   ReviewStatus `json:"status"`
+	Flag     string       `json:"flag,omitempty"` // reason the change was flagged by content filters
+
+	// set when approved or rejected
+	Reviewer string     `json:"reviewer,omitempty"` // name of the editor
+	Reviewed *time.Time `json:"reviewed,omitempty"` // time reviewed
+	Reason   string     `json:"reason,omitempty"`   // reason for rejection
+}
+
+// A ReviewHook is called when a change is proposed, approved, or rejected.
+// The review's Status indicates which.
+type ReviewHook func(w *Wiki, r *Review)
+
+var (
+	reviewHooks     []ReviewHook
+	reviewHooksLock sync.RWMutex
+	reviewLock      sync.Mutex
+)
+
+// AddReviewHook registers a function which is called for all wikis when a
+// change is proposed, approved, or rejected, for example to notify editors.
+func AddReviewHook(hook ReviewHook) {
+	reviewHooksLock.Lock()
+	defer reviewHooksLock.Unlock()
+	reviewHooks = append(reviewHooks, hook)
+}
+
+func (w *Wiki) runReviewHooks(r *Review) {
+	reviewHooksLock.RLock()
+	hooks := append([]ReviewHook(nil), reviewHooks...)
+	reviewHooksLock.RUnlock()
+	for _, hook := range hooks {
+		hook(w, r)
+	}
+}
+
+// IsEditor returns true if the user by the given username can write to the
+// wiki directly and approve changes proposed by others.
+//
+// If moderation is not enabled, all users are editors.
+func (w *Wiki) IsEditor(username string) bool {
+	if !w.Opt.Review.Enable {
+		return true
+	}
+	for _, editor := range w.Opt.Review.Editors {
+		if strings.EqualFold(editor, username) {
+			return true
+		}
+
+		// @group includes the members of a group
+		if strings.HasPrefix(editor, "@") && w.InGroup(username, editor[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// ProposeFile creates a review for a change to a file in the wiki.
+//
+// The filename must be relative to the wiki directory. As in WritePage, it
+// is cleaned, and names containing ".." are rejected. The file is not
+// written until the change is approved with ApproveReview. If the file is a
+// page, the user identified by commit.User must be permitted to edit it, and
+// it must conform to the schemas which reject changes, as in WritePage.
+// Changes which fail the content filters are rejected with a *FilterError,
+// or flagged for the reviewer if filter.action is review.
+func (w *Wiki) ProposeFile(name string, content []byte, commit CommitOpts) (*Review, error) {
+	name, err := cleanFileName(name)
+	if err != nil {
+		return nil, err
+	}
+	if !w.canEditFile(commit.User, name) {
+		return nil, permissionError(w, strings.TrimPrefix(name, "pages/"))
+	}
+	if strings.HasPrefix(name, "pages/") {
+		if err := checkPlaceholders(content); err != nil {
+			return nil, err
+		}
+		if err := w.checkSchemaWrite(name, content); err != nil {
+			return nil, err
+		}
+	}
+
+	// changes which fail the content filters are flagged, or rejected
+	flag := w.checkFilters(name, content, commit)
+	if flag != "" {
+		if w.Opt.Filter.Action != "review" {
+			w.audit("filter.reject", name, commit, flag)
+			return nil, &FilterError{File: name, Reason: flag}
+		}
+		w.audit("filter.flag", name, commit, flag)
+	}
+	return w.proposeFile(name, content, commit, flag)
+}
+
+// creates a review without checking whether the change is allowed
+func (w *Wiki) proposeFile(name string, content []byte, commit CommitOpts, flag string) (*Review, error) {
+
+	// remember the original content for the diff
+	original, err := ioutil.ReadFile(w.UnresolvedAbsFilePath(name))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	now := time.Now()
+	r := &Review{
+		ID:       strconv.FormatInt(now.UnixNano(), 36),
+		File:     name,
+		Content:  string(content),
+		Original: string(original),
+		Comment:  commit.Comment,
+		Name:     commit.Name,
+		Email:    commit.Email,
+		Created:  now,
+		Status:   ReviewPending,
+		Flag:     flag,
+	}
+
+	if err := w.writeReview(r); err != nil {
+		return nil, err
+	}
+	w.runReviewHooks(r)
+	return r, nil
+}
+
+// ReviewQueue returns the changes awaiting approval, oldest first.
+func (w *Wiki) ReviewQueue() []*Review {
+	files, _ := filepath.Glob(w.Dir("review", "*.json"))
+	var queue []*Review
+	for _, path := range files {
+		r, err := w.GetReview(strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			w.Logf("ReviewQueue(): %v", err)
+			continue
+		}
+		if r.Status == ReviewPending {
+			queue = append(queue, r)
+		}
+	}
+	sort.Slice(queue, func(i, j int) bool {
+		return queue[i].Created.Before(queue[j].Created)
+	})
+	return queue
+}
+
+// GetReview returns the review by the given ID.
+func (w *Wiki) GetReview(id string) (*Review, error) {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return nil, errors.New("invalid review ID")
+	}
+	jsonData, err := ioutil.ReadFile(w.Dir("review", id+".json"))
+	if err != nil {
+		return nil, err
+	}
+	var r Review
+	if err := json.Unmarshal(jsonData, &r); err != nil {
+		return nil, errors.Wrap(err, "review "+id)
+	}
+	return &r, nil
+}
+
+// ApproveReview writes a proposed change to the wiki.
+//
+// The change is committed with the contributor as its author. The commit
+// options identify the editor approving it. If the file was modified after
+// the change was proposed, an error is returned, and the change must be
+// rejected and proposed again. The editor must also be permitted to edit the
+// file by the wiki's access rules.
+func (w *Wiki) ApproveReview(id string, editor CommitOpts) error {
+	reviewLock.Lock()
+	defer reviewLock.Unlock()
+
+	r, err := w.pendingReview(id)
+	if err != nil {
+		return err
+	}
+	if !w.canEditFile(editor.User, r.File) {
+		return permissionError(w, strings.TrimPrefix(r.File, "pages/"))
+	}
+
+	// check for conflicts
+	current, err := ioutil.ReadFile(w.UnresolvedAbsFilePath(r.File))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if string(current) != r.Original {
+		return errors.New(r.File + " has changed since the edit was proposed")
+	}
+
+	// write it
+	comment := r.Comment
+	if editor.Name != "" {
+		comment += " (approved by " + editor.Name + ")"
+	}
+	err = w.WriteFile(r.File, []byte(r.Content), true, CommitOpts{
+		Comment: strings.TrimSpace(comment),
+		Name:    r.Name,
+		Email:   r.Email,
+	})
+	if err != nil {
+		return err
+	}
+
+	return w.finishReview(r, ReviewApproved, editor.Name, "")
+}
+
+// RejectReview rejects a proposed change, optionally with a reason.
+func (w *Wiki) RejectReview(id string, editorName, reason string) error {
+	reviewLock.Lock()
+	defer reviewLock.Unlock()
+
+	r, err := w.pendingReview(id)
+	if err != nil {
+		return err
+	}
+	return w.finishReview(r, ReviewRejected, editorName, reason)
+}
+
+// Diff returns a line-oriented diff of the original file content and the
+// proposed content. Removed lines are prefixed with "-", added lines
+// with "+", and unchanged lines with a space.
+func (r *Review) Diff() string {
+	var b strings.Builder
+	for _, d := range diff.Do(r.Original, r.Content) {
+		prefix := " "
+		switch d.Type {
+		case diffmatchpatch.DiffDelete:
+			prefix = "-"
+		case diffmatchpatch.DiffInsert:
+			prefix = "+"
+		}
+		for _, line := range strings.SplitAfter(d.Text, "\n") {
+			if line == "" {
+				continue
+			}
+			b.WriteString(prefix + line)
+			if !strings.HasSuffix(line, "\n") {
+				b.WriteByte('\n')
+			}
+		}
+	}
+	return b.String()
+}
+
+func (w *Wiki) pendingReview(id string) (*Review, error) {
+	r, err := w.GetReview(id)
+	if err != nil {
+		return nil, err
+	}
+	if r.Status != ReviewPending {
+		return nil, errors.New("review " + id + " is already " + string(r.Status))
+	}
+	return r, nil
+}
+
+func (w *Wiki) finishReview(r *Review, status ReviewStatus, editorName, reason string) error {
+	now := time.Now()
+	r.Status = status
+	r.Reviewer = editorName
+	r.Reviewed = &now
+	r.Reason = reason
+	if err := w.writeReview(r); err != nil {
+		return err
+	}
+	w.runReviewHooks(r)
+	return nil
+}
+
+func (w *Wiki) writeReview(r *Review) error {
+	jsonData, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(w.Dir("review"), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(w.Dir("review", r.ID+".json"), jsonData, 0644)
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/cooper/quiki/wikifier"
@@ -30,6 +31,10 @@ type CommitOpts struct {
 	// Email is the email address of the user committing changes.
 	Email string
 
+	// User is the username of the user committing changes, which is checked
+	// against the wiki's access rules by WritePage and ProposeFile.
+	User string
+
 	// Time is the timestamp to associate with the revision.
 	// If unspecified, current time is used.
 	Time time.Time
@@ -190,16 +195,28 @@ func (w *Wiki) checkoutBranch(name string) (string, error) {
 		return "", err
 	}
 
+	// checking out the linked repository also moves HEAD of this one,
+	// so remember where it was
+	head, err := repo.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return "", err
+	}
+
 	// create the linked repository
 	if _, err = repo.PlainAddWorktree(name, targetDir, &git.AddWorktreeOptions{}); err != nil {
 		return "", err
 	}
 
+	// restore HEAD
+	if err = repo.Storer.SetReference(head); err != nil {
+		return "", err
+	}
+
 	return targetDir, nil
 }
 
 // the "and commit" portion of the *andCommit functions
-func (w *Wiki) andCommit(wt *git.Worktree, comment string, commit CommitOpts) error {
+func (w *Wiki) andCommit(wt *git.Worktree, comment string, commit CommitOpts) (plumbing.Hash, error) {
 	if commit.Comment != "" {
 		comment += ": " + commit.Comment
 	}
@@ -210,7 +227,7 @@ func (w *Wiki) andCommit(wt *git.Worktree, comment string, commit CommitOpts) er
 	}
 
 	// commit
-	_, err := wt.Commit(comment, &git.CommitOptions{
+	hash, err := wt.Commit(comment, &git.CommitOptions{
 		Author: &object.Signature{
 			Name:  commit.Name,
 			Email: commit.Email,
@@ -218,10 +235,10 @@ func (w *Wiki) andCommit(wt *git.Worktree, comment string, commit CommitOpts) er
 		},
 	})
 	if err != nil {
-		return errors.Wrap(err, "git:worktree:Commit")
+		return hash, errors.Wrap(err, "git:worktree:Commit")
 	}
 
-	return nil
+	return hash, nil
 }
 
 // addAndCommit adds a file and then commits changes
@@ -245,7 +262,13 @@ func (w *Wiki) addAndCommit(path string, commit CommitOpts) error {
 		return err
 	}
 
-	return w.andCommit(wt, "Update "+filepath.Base(path), commit)
+	hash, err := w.andCommit(wt, "Update "+filepath.Base(path), commit)
+	if err != nil {
+		return err
+	}
+
+	w.runChangeHooks(Change{File: path, Commit: hash.String(), CommitOpts: commit})
+	return nil
 }
 
 // removeAndCommit removes a file and then commits changes
@@ -263,13 +286,24 @@ func (w *Wiki) removeAndCommit(path string, commit CommitOpts) error {
 		return errors.Wrap(err, "git:repo:Worktree")
 	}
 
+	// paths in the worktree are relative to the wiki directory
+	if rel, err := filepath.Rel(w.Dir(), path); err == nil && filepath.IsAbs(path) {
+		path = filepath.ToSlash(rel)
+	}
+
 	// remove the file
 	_, err = wt.Remove(path)
 	if err != nil {
 		return err
 	}
 
-	return w.andCommit(wt, "Delete "+filepath.Base(path), commit)
+	hash, err := w.andCommit(wt, "Delete "+filepath.Base(path), commit)
+	if err != nil {
+		return err
+	}
+
+	w.runChangeHooks(Change{File: path, Deleted: true, Commit: hash.String(), CommitOpts: commit})
+	return nil
 }
 
 // Branch returns a Wiki instance for this wiki at another branch.
@@ -362,8 +396,6 @@ func ValidBranchName(name string) bool {
 	return branchNameRgx.MatchString(name)
 }
 
-// WritePage writes a page file.
-
 // WriteFile writes a file in the wiki.
 //
 // The filename must be relative to the wiki directory.
@@ -402,7 +434,15 @@ func (w *Wiki) WriteFile(name string, content []byte, createOK bool, commit Comm
 	}
 
 	// commit the change
-	return w.addAndCommit(name, commit)
+	if err := w.addAndCommit(name, commit); err != nil {
+		return err
+	}
+
+	// links to a new page are no longer missing
+	if fi == nil {
+		w.pageCreatedOrDeleted(name)
+	}
+	return nil
 }
 
 // DeleteFile deletes a file in the wiki.
@@ -426,5 +466,24 @@ func (w *Wiki) DeleteFile(name string, commit CommitOpts) error {
 	}
 
 	// delete the file and commit the change
-	return w.removeAndCommit(path, commit)
+	if err := w.removeAndCommit(path, commit); err != nil {
+		return err
+	}
+
+	// links to the page are now missing
+	w.pageCreatedOrDeleted(name)
+	return nil
+}
+
+// regenerates the pages linking to a file, if it is a page which has just
+// been created or deleted
+func (w *Wiki) pageCreatedOrDeleted(name string) {
+	name = filepath.ToSlash(filepath.Clean(name))
+	if !strings.HasPrefix(name, "pages/") {
+		return
+	}
+	name = strings.TrimPrefix(name, "pages/")
+	if n := w.RegenerateLinkingPages(name, time.Now()); n != 0 {
+		w.Logf("page %s created or deleted; regenerated %d linking pages", name, n)
+	}
 }
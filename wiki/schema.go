@@ -0,0 +1,103 @@
+package wiki
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/cooper/quiki/wikifier"
+)
+
+// A SchemaError is returned by WritePage and ProposeFile when a page lacks
+// the variables or blocks required by a schema.[name] wiki option whose
+// action is reject.
+type SchemaError struct {
+	File       string   // filename relative to the wiki directory
+	Violations []string // descriptions of the missing content
+}
+
+func (e *SchemaError) Error() string {
+	return "change rejected: " + strings.Join(e.Violations, "; ")
+}
+
+// returns the names of the schemas which apply to a page, in sorted order
+func (w *Wiki) pageSchemas(pageName string, categories []string) []string {
+	pageName = strings.ToLower(w.Opt.PageNameNE(pageName))
+	inCategory := make(map[string]bool, len(categories))
+	for _, cat := range categories {
+		inCategory[wikifier.CategoryName(cat)] = true
+	}
+
+	var names []string
+	for name, schema := range w.Opt.Schema {
+		matched := false
+		for _, pattern := range schema.Pages {
+			if pagePatternMatch(strings.ToLower(pattern), pageName) {
+				matched = true
+				break
+			}
+		}
+		for _, cat := range schema.Categories {
+			if inCategory[wikifier.CategoryName(cat)] {
+				matched = true
+				break
+			}
+		}
+		if matched {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// returns descriptions of the content which a parsed page lacks, according
+// to the schemas which apply to it. if rejectOnly is true, only schemas
+// whose action is reject are considered
+func (w *Wiki) schemaViolations(page *wikifier.Page, pageName string, rejectOnly bool) []string {
+	var violations []string
+	for _, name := range w.pageSchemas(pageName, page.Categories()) {
+		schema := w.Opt.Schema[name]
+		if rejectOnly && schema.Action != "reject" {
+			continue
+		}
+		for _, varName := range schema.Vars {
+			if val, _ := page.Get(varName); val == nil || val == "" {
+				violations = append(violations, "Missing @"+varName+", required by schema '"+name+"'")
+			}
+		}
+		for _, blockType := range schema.Blocks {
+			if !page.HasBlock(blockType) {
+				violations = append(violations, "Missing "+blockType+"{}, required by schema '"+name+"'")
+			}
+		}
+	}
+	return violations
+}
+
+// adds warnings to a page for content required by schemas which it lacks
+func (w *Wiki) checkPageSchemas(page *wikifier.Page) {
+	if len(w.Opt.Schema) == 0 {
+		return
+	}
+	for _, violation := range w.schemaViolations(page, page.Name(), false) {
+		pageWarn(page, violation, wikifier.Position{Line: 1})
+	}
+}
+
+// checks a change to a page file, which is relative to the wiki directory,
+// and returns a SchemaError if it lacks content required by a schema whose
+// action is reject. drafts are not checked
+func (w *Wiki) checkSchemaWrite(name string, content []byte) error {
+	if len(w.Opt.Schema) == 0 || !strings.HasPrefix(name, "pages/") {
+		return nil
+	}
+	page := w.parseSource(name, string(content))
+	if page == nil || page.Draft() {
+		return nil
+	}
+	pageName := strings.TrimPrefix(name, "pages/")
+	if violations := w.schemaViolations(page, pageName, true); len(violations) != 0 {
+		return &SchemaError{File: name, Violations: violations}
+	}
+	return nil
+}
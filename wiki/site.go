@@ -0,0 +1,123 @@
+package wiki
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cooper/quiki/wikifier"
+)
+
+// SiteVarsFile is the name of the file in the wiki directory whose variables
+// are available to every page as @site, such as @site.product.
+const SiteVarsFile = "vars.conf"
+
+// SiteVarsDir is the name of the directory in the wiki directory whose .conf
+// files are also available as @site. They are merged after SiteVarsFile in
+// order of filename, so variables such as those of a release can be kept in
+// a file of their own.
+const SiteVarsDir = "vars"
+
+// the parsed variables files, which are reloaded when they change
+type siteVars struct {
+	files map[string]*siteVarsFile // by path
+	mu    sync.Mutex
+}
+
+// a parsed variables file
+type siteVarsFile struct {
+	page     *wikifier.Page // nil if the file could not be parsed
+	modified time.Time      // modification time of the file when parsed
+}
+
+// returns the paths of the variables files, in the order they are merged
+func (w *Wiki) siteVarsPaths() []string {
+	paths := []string{w.Dir(SiteVarsFile)}
+	more, _ := filepath.Glob(filepath.Join(w.Dir(SiteVarsDir), "*.conf"))
+	sort.Strings(more)
+	return append(paths, more...)
+}
+
+// returns a copy of the variables in the variables files for a page, or nil
+// if there are no such files
+func (w *Wiki) siteVars() *wikifier.Map {
+	sv := &w.site
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+
+	var site *wikifier.Map
+	files := make(map[string]*siteVarsFile)
+	for _, path := range w.siteVarsPaths() {
+		fi, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		// parse it again if it has changed
+		file := sv.files[path]
+		if file == nil || !fi.ModTime().Equal(file.modified) {
+			file = &siteVarsFile{page: wikifier.NewPage(path), modified: fi.ModTime()}
+			file.page.VarsOnly = true
+			file.page.Wiki = w
+			file.page.Opt = &w.Opt
+			if err := file.page.Parse(); err != nil {
+				w.Logf("failed to parse %s: %v", filepath.Base(path), err)
+				file.page = nil
+			}
+		}
+		files[path] = file
+
+		if file.page == nil {
+			continue
+		}
+		if site == nil {
+			site = file.page.CopyVars()
+		} else {
+			mergeSiteVars(site, file.page.CopyVars())
+		}
+	}
+	sv.files = files
+	return site
+}
+
+// merges variables into a map, replacing those of the same name except
+// where both are maps, which are merged in turn
+func mergeSiteVars(site, vars *wikifier.Map) {
+	for _, key := range vars.Keys() {
+		val := vars.Map()[key]
+		if dst, ok := site.Map()[key].(*wikifier.Map); ok {
+			if src, ok := val.(*wikifier.Map); ok {
+				mergeSiteVars(dst, src)
+				continue
+			}
+		}
+		site.Set(key, val)
+	}
+}
+
+// returns true if a variables file has changed since the given time, or if
+// one has been added to or removed from the variables directory.
+//
+// if the variables file or directory does not exist, it may have been
+// deleted, which changes the modification time of the wiki directory. the
+// wiki directory is rarely otherwise changed, so a change to it is taken to
+// be a possible deletion
+func (w *Wiki) siteVarsModifiedAfter(t time.Time) bool {
+	paths := append(w.siteVarsPaths(), w.Dir(SiteVarsDir))
+	missing := false
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err == nil && fi.ModTime().After(t) {
+			return true
+		}
+		missing = missing || os.IsNotExist(err)
+	}
+	if missing {
+		if fi, err := os.Stat(w.Dir()); err == nil && fi.ModTime().After(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package wiki
+
+import "github.com/cooper/quiki/wikifier"
+
+// DisplaySlides represents a page presented as slides.
+type DisplaySlides struct {
+	DisplayPage
+
+	// HTML of each slide, which is each top-level section or other block
+	Slides []wikifier.HTML `json:"slides"`
+}
+
+// DisplaySlides returns the display result for a page presented as slides,
+// with a slide for each top-level section.
+//
+// Like DisplayPage, the result is a DisplayError for drafts and a
+// DisplayRedirect for redirects. The slides are generated each time rather
+// than cached.
+func (w *Wiki) DisplaySlides(name string) interface{} {
+	res := w.DisplayPage(name)
+	r, ok := res.(DisplayPage)
+	if !ok {
+		return res
+	}
+
+	// generate the page again for its slides, holding the page lock like
+	// DisplayPage does
+	lock := w.pageLock(r.File)
+	lock.Lock()
+	defer lock.Unlock()
+	page := w.FindPage(r.File)
+	if err := page.Parse(); err != nil {
+		return DisplayError{Error: err.Error()}
+	}
+	r.Content = wikifier.StripEditorNotes(page.HTML())
+	r.EditorContent = ""
+	r.CSS = page.CSS()
+
+	// notes to editors are not presented
+	slides := page.Slides()
+	for i, slide := range slides {
+		slides[i] = wikifier.StripEditorNotes(slide)
+	}
+	return DisplaySlides{DisplayPage: r, Slides: slides}
+}
@@ -0,0 +1,152 @@
+package wiki
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cooper/go-git/v4"
+	"github.com/cooper/go-git/v4/plumbing"
+	"github.com/cooper/go-git/v4/plumbing/filemode"
+	"github.com/cooper/go-git/v4/plumbing/object"
+	"github.com/cooper/quiki/wikifier"
+	"github.com/pkg/errors"
+)
+
+// Version returns a Wiki instance for this wiki at a git branch or tag.
+//
+// Branches are checked out as with Branch. Tags never change, so the files
+// of the tagged revision are exported once to cache/version/<tag> and served
+// from there.
+func (w *Wiki) Version(ref string) (*Wiki, error) {
+
+	// prefer a branch by this name
+	exist, err := w.hasBranch(ref)
+	if err != nil {
+		return nil, err
+	}
+	if exist {
+		return w.Branch(ref)
+	}
+
+	// otherwise it must be a tag
+	dir, err := w.exportTag(ref)
+	if err != nil {
+		return nil, err
+	}
+	return NewWiki(dir)
+}
+
+// VersionWikis returns a Wiki instance for each version listed in the
+// version.list option, in order.
+func (w *Wiki) VersionWikis() ([]*Wiki, error) {
+	wikis := make([]*Wiki, len(w.Opt.Version.List))
+	for i, version := range w.Opt.Version.List {
+		vw, err := w.Version(version.Ref)
+		if err != nil {
+			return nil, errors.Wrap(err, "version "+version.Name)
+		}
+		wikis[i] = vw
+	}
+	return wikis, nil
+}
+
+// exports the files of a tagged revision. returns the directory
+func (w *Wiki) exportTag(name string) (string, error) {
+
+	// tags may contain dots but must not escape the cache directory
+	if name == "" || strings.Contains(name, "..") || filepath.IsAbs(name) {
+		return "", errors.New("invalid tag name")
+	}
+
+	// e.g. cache/version/v1.0
+	targetDir := filepath.Join(w.Opt.Dir.Cache, "version", name)
+
+	// already exported
+	if fi, err := os.Stat(targetDir); err == nil && fi.IsDir() {
+		return targetDir, nil
+	}
+
+	repo, err := w.repo()
+	if err != nil {
+		return "", err
+	}
+
+	// find the commit, which for annotated tags is behind the tag object
+	ref, err := repo.Tag(name)
+	if err != nil {
+		if err == git.ErrTagNotFound {
+			return "", errors.New("no branch or tag named " + name)
+		}
+		return "", errors.Wrap(err, "git:repo:Tag")
+	}
+	commit, err := tagCommit(repo, ref.Hash())
+	if err != nil {
+		return "", err
+	}
+	files, err := commit.Files()
+	if err != nil {
+		return "", errors.Wrap(err, "git:commit:Files")
+	}
+
+	// export to a temporary directory first so that a partial export is
+	// never served
+	wikifier.MakeDir(filepath.Join(w.Opt.Dir.Cache, "version"), "")
+	tmpDir := targetDir + ".tmp"
+	os.RemoveAll(tmpDir)
+	err = files.ForEach(func(f *object.File) error {
+		if f.Mode != filemode.Regular && f.Mode != filemode.Executable {
+			return nil
+		}
+		return exportFile(f, filepath.Join(tmpDir, filepath.FromSlash(f.Name)))
+	})
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return "", err
+	}
+	if err := os.Rename(tmpDir, targetDir); err != nil {
+		os.RemoveAll(tmpDir)
+		return "", err
+	}
+
+	return targetDir, nil
+}
+
+// finds the commit of a tag, whether lightweight or annotated
+func tagCommit(repo *git.Repository, hash plumbing.Hash) (*object.Commit, error) {
+	tag, err := repo.TagObject(hash)
+	switch err {
+	case nil:
+		return tag.Commit()
+	case plumbing.ErrObjectNotFound:
+		return repo.CommitObject(hash)
+	default:
+		return nil, errors.Wrap(err, "git:repo:TagObject")
+	}
+}
+
+// writes a file from a revision to disk
+func exportFile(f *object.File, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	mode, err := f.Mode.ToOSFileMode()
+	if err != nil {
+		return err
+	}
+	r, err := f.Reader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
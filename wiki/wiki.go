@@ -5,6 +5,7 @@ import (
 	"log"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/cooper/go-git/v4"
 	"github.com/cooper/quiki/authenticator"
@@ -17,9 +18,20 @@ type Wiki struct {
 	Opt           wikifier.PageOpt
 	Auth          *authenticator.Authenticator
 	pageLocks     map[string]*sync.Mutex
+	pageLocksLock sync.Mutex
 	pregenerating bool
+	pageHooks     map[PageHookStage][]PageHook
 	_repo         *git.Repository
 	_logger       *log.Logger
+
+	quickSwitch  quickSwitchIndex
+	queries      pageQueryState
+	site         siteVars
+	contributors contributorsCache
+
+	// held by CheckExternalLinks while it reads, checks, and rewrites
+	// the results of previous checks
+	externalLinksLock sync.Mutex
 }
 
 // NewWiki creates a Wiki given its directory path.
@@ -63,3 +75,13 @@ func NewWikiConfig(confPath string) (*Wiki, error) {
 	// no errors occurred
 	return w, nil
 }
+
+// timestamp returns the time to record for generated data such as category
+// files. Normally this is the current time, but with the deterministic
+// option, it is the provided source time so that output is reproducible.
+func (w *Wiki) timestamp(source time.Time) time.Time {
+	if w.Opt.Deterministic {
+		return source
+	}
+	return time.Now()
+}
@@ -0,0 +1,60 @@
+package wikifier
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// source of a page with n sections, each with a paragraph, a list, and a
+// table, for several thousand elements in all
+func benchSource(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		s := strconv.Itoa(i)
+		b.WriteString("sec [Section " + s + "] {\n")
+		b.WriteString("    Paragraph " + s + " with [b]bold[/b], [i]italic[/i], and a [[ link ]].\n\n")
+		b.WriteString("    list {\n        one;\n        two;\n        three;\n    }\n\n")
+		b.WriteString("    table {\n        tr {\n            th { Key }\n            th { Value }\n        }\n")
+		b.WriteString("        tr {\n            tc { " + s + " }\n            tc { [c]value[/c] }\n        }\n    }\n}\n")
+	}
+	return b.String()
+}
+
+func parseBenchPage(b *testing.B, source string) *Page {
+	page := NewPageSource(source)
+	if err := page.Parse(); err != nil {
+		b.Fatal(err)
+	}
+	return page
+}
+
+// parsing, which is dominated by the parser's catches and text handling
+func BenchmarkParse(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		source := benchSource(n)
+		b.Run("sections="+strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				parseBenchPage(b, source)
+			}
+		})
+	}
+}
+
+// generating HTML from a parsed page. with 1000 sections, this is about
+// 1.3 MB of HTML
+func BenchmarkHTML(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		source := benchSource(n)
+		b.Run("sections="+strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				page := parseBenchPage(b, source)
+				b.StartTimer()
+				page.HTML()
+			}
+		})
+	}
+}
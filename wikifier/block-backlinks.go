@@ -0,0 +1,57 @@
+package wikifier
+
+import "html"
+
+// backlinks{} lists the pages which link to the page, by title.
+//
+//	sec [What links here] {
+//	    backlinks {}
+//	}
+//
+// The pages come from the links the wiki records when generating pages, so
+// the list is empty outside of a wiki.
+type backlinksBlock struct {
+	*parserBlock
+}
+
+func newBacklinksBlock(name string, b *parserBlock) block {
+	return &backlinksBlock{parserBlock: b}
+}
+
+func (bb *backlinksBlock) html(page *Page, el element) {
+	el.setTag("ul")
+	checkTableContent(bb.parserBlock, "backlinks")
+
+	pages := page.findBacklinks()
+	if len(pages) == 0 {
+		el.setMeta("noTags", true)
+		return
+	}
+	for _, info := range pages {
+		title := info.FmtTitle
+		if title == "" {
+			title = HTML(html.EscapeString(info.FileNE))
+		}
+		a := el.createChild("li", "backlinks-page").createChild("a", "link-internal")
+		a.setAttr("href", page.Opt.Root.Page+"/"+info.FileNE)
+		a.addHTML(title)
+	}
+}
+
+// finds the pages which link to the page, for backlinks{}
+func (p *Page) findBacklinks() []PageInfo {
+	if p.backlinks == nil && p.Opt != nil && p.Opt.Page.Backlinks != nil {
+		p.backlinks = p.Opt.Page.Backlinks(p)
+		if p.backlinks == nil {
+			p.backlinks = []PageInfo{}
+		}
+	}
+	return p.backlinks
+}
+
+// Backlinks returns the pages which link to the page, as listed by
+// backlinks{}. It is nil if the page has no backlinks{} or is not in a wiki.
+// Backlinks should be called after HTML.
+func (p *Page) Backlinks() []PageInfo {
+	return p.backlinks
+}
@@ -0,0 +1,50 @@
+package wikifier
+
+// cliopt{} documents the options of a command-line program.
+//
+//	cliopt [quiki] {
+//	    -w, --wiki DIR: Path to the wiki;
+//	    -v: Print more output;
+//	}
+//
+// Each key is an option, and its value is the description. The block name,
+// if any, is the name of the program.
+type cliopt struct {
+	*Map
+}
+
+// newCLIOpt creates a cliopt{} given an underlying parser block.
+func newCLIOpt(name string, b *parserBlock) block {
+	b.typ = "cliopt"
+	return &cliopt{newMapBlock("", b).(*Map)}
+}
+
+// parse parses the cliopt contents.
+func (co *cliopt) parse(page *Page) {
+	co.Map.parse(page)
+}
+
+// html converts the options to an HTML table.
+func (co *cliopt) html(page *Page, el element) {
+	co.Map.html(page, nil)
+	el.setTag("table")
+
+	// program name
+	if co.blockName() != "" {
+		el.createChild("caption", "cliopt-program").createChild("code", "").addText(co.blockName())
+	}
+
+	tr := el.createChild("tr", "")
+	tr.createChild("th", "").addText("Option")
+	tr.createChild("th", "").addText("Description")
+
+	for _, entry := range co.mapList {
+		if entry.keyTitle == "" {
+			co.warn(entry.pos, "cliopt{} description without an option ignored")
+			continue
+		}
+		tr := el.createChild("tr", "cliopt-option")
+		tr.createChild("td", "cliopt-flag").createChild("code", "").addText(entry.keyTitle)
+		tr.createChild("td", "cliopt-desc").add(entry.value)
+	}
+}
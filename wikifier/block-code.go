@@ -1,7 +1,8 @@
 package wikifier
 
 import (
-	"fmt"
+	htmlfmt "html"
+	"strconv"
 	"strings"
 
 	"github.com/alecthomas/chroma"
@@ -15,14 +16,31 @@ type codeBlock struct {
 	*parserBlock
 }
 
-type quikiPreWrapper bool
+// code{} options, given after the language in the block name,
+// e.g. code [go, lines, start=10, highlight=12-14 16, copy]
+type codeOpts struct {
+	lang      string   // language name
+	lines     bool     // show line numbers
+	start     int      // number of the first line
+	highlight [][2]int // ranges of line numbers to highlight
+	attrs     []string // data attributes describing the options
+}
+
+// wraps the code in <pre> with data attributes describing the options
+type quikiPreWrapper struct {
+	attrs []string
+}
 
 func init() {
 	styles.Fallback = styles.Get("monokailight")
 }
 
 func (p quikiPreWrapper) Start(code bool, styleAttr string) string {
-	return fmt.Sprintf(`<pre class="q-code chroma">`)
+	attrs := ""
+	if len(p.attrs) != 0 {
+		attrs = " " + strings.Join(p.attrs, " ")
+	}
+	return `<pre class="q-code chroma"` + attrs + ">"
 }
 
 func (p quikiPreWrapper) End(code bool) string {
@@ -38,18 +56,15 @@ func (cb *codeBlock) html(page *Page, el element) {
 	el.setMeta("noTags", true)
 	el.setMeta("noIndent", true)
 
-	// get code text
-	text := ""
-	for _, piece := range cb.textContent() {
-		text += piece
-	}
+	text := cb.text()
 
-	// if block name or page.code.lang is provided, it's the language
+	// if language or page.code.lang is provided, use it
+	opts := cb.options()
 	var lexer chroma.Lexer
-	if cb.blockName() != "" {
-		lexer = lexers.Get(cb.blockName())
+	if opts.lang != "" {
+		lexer = lexers.Get(opts.lang)
 		if lexer == nil {
-			cb.warn(cb.openPosition(), "No such code{} language '"+cb.blockName()+"'")
+			cb.warn(cb.openPosition(), "No such code{} language '"+opts.lang+"'")
 		}
 	}
 	if lexer == nil && page.Opt.Page.Code.Lang != "" {
@@ -72,25 +87,34 @@ func (cb *codeBlock) html(page *Page, el element) {
 	// coalesce like adjacent tokens
 	lexer = chroma.Coalesce(lexer)
 
-	// get style from page var or config
-	style := styles.Fallback
-	pageStyle, _ := page.getPageStr("code.style")
-	if pageStyle != "" {
-		style = styles.Get(pageStyle)
-		if style == styles.Fallback {
+	// get style from page var or config. styles are looked up in the
+	// registry, since the fallback is itself a valid style (monokailight)
+	var style *chroma.Style
+	if pageStyle, _ := page.getPageStr("code.style"); pageStyle != "" {
+		style = styles.Registry[pageStyle]
+		if style == nil {
 			cb.warn(cb.openPosition(), "No such code{} style '"+pageStyle+"'")
 		}
 	}
-	if style == styles.Fallback && page.Opt.Page.Code.Style != "" {
-		style = styles.Get(page.Opt.Page.Code.Style)
-		if style == styles.Fallback {
+	if style == nil && page.Opt.Page.Code.Style != "" {
+		style = styles.Registry[page.Opt.Page.Code.Style]
+		if style == nil {
 			cb.warn(cb.openPosition(), "No such code{} style '"+page.Opt.Page.Code.Style+"' (from config)")
 		}
 	}
+	if style == nil {
+		style = styles.Fallback
+	}
 
 	// create HTML formatter with separate CSS
 	var cssBuilder, htmlBuilder strings.Builder
-	formatter := html.New(html.WithClasses(true), html.WithPreWrapper(quikiPreWrapper(true)))
+	formatter := html.New(
+		html.WithClasses(true),
+		html.WithPreWrapper(quikiPreWrapper{opts.attrs}),
+		html.WithLineNumbers(opts.lines),
+		html.BaseLineNumber(opts.start),
+		html.HighlightLines(opts.highlight),
+	)
 
 	// HTML
 	iterator, err := lexer.Tokenise(nil, text)
@@ -110,3 +134,77 @@ func (cb *codeBlock) html(page *Page, el element) {
 		page.codeStyles = true
 	}
 }
+
+// returns the code text
+func (cb *codeBlock) text() string {
+	text := ""
+	for _, piece := range cb.textContent() {
+		text += piece
+	}
+	return text
+}
+
+// parses options from the block name. the language may be given anywhere
+// among them, but it is conventionally first
+func (cb *codeBlock) options() codeOpts {
+	opts := codeOpts{start: 1}
+	if cb.blockName() == "" {
+		return opts
+	}
+	for _, opt := range strings.Split(cb.blockName(), ",") {
+		opt = strings.TrimSpace(opt)
+		name, value := opt, ""
+		if eq := strings.IndexByte(opt, '='); eq != -1 {
+			name, value = strings.TrimSpace(opt[:eq]), strings.TrimSpace(opt[eq+1:])
+		}
+		switch name {
+		case "":
+			continue
+
+		case "lines":
+			opts.lines = true
+			opts.attrs = append(opts.attrs, `data-lines="true"`)
+
+		case "start":
+			start, err := strconv.Atoi(value)
+			if err != nil || start < 0 {
+				cb.warn(cb.openPosition(), "Invalid code{} start line '"+value+"'")
+				continue
+			}
+			opts.start = start
+			opts.lines = true
+			opts.attrs = append(opts.attrs, `data-start="`+strconv.Itoa(start)+`"`)
+
+		case "highlight":
+			for _, r := range strings.Fields(value) {
+				split := strings.SplitN(r, "-", 2)
+				from, err1 := strconv.Atoi(split[0])
+				to, err2 := from, error(nil)
+				if len(split) == 2 {
+					to, err2 = strconv.Atoi(split[1])
+				}
+				if err1 != nil || err2 != nil || to < from {
+					cb.warn(cb.openPosition(), "Invalid code{} highlight range '"+r+"'")
+					continue
+				}
+				opts.highlight = append(opts.highlight, [2]int{from, to})
+			}
+			if len(opts.highlight) != 0 {
+				opts.attrs = append(opts.attrs, `data-highlight="`+htmlfmt.EscapeString(value)+`"`)
+			}
+
+		// the button is added by a script, such as quiki.js
+		case "copy":
+			opts.attrs = append(opts.attrs, `data-copy="true"`)
+
+		default:
+			if value != "" || opts.lang != "" {
+				cb.warn(cb.openPosition(), "Unknown code{} option '"+name+"'")
+				continue
+			}
+			opts.lang = name
+			opts.attrs = append(opts.attrs, `data-lang="`+htmlfmt.EscapeString(name)+`"`)
+		}
+	}
+	return opts
+}
@@ -0,0 +1,233 @@
+package wikifier
+
+import (
+	htmlfmt "html"
+	"strconv"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// codecompare{} displays the differences between two code snippets.
+//
+//	codecompare {
+//	    before: code {{
+//	        oldFunction(x)
+//	    }};
+//	    after: code {{
+//	        newFunction(x, y)
+//	    }};
+//	}
+//
+// By default the snippets are shown side by side. With the name [inline], the
+// removed and added lines are shown in a single column. Changed lines are
+// highlighted within the line.
+type codecompare struct {
+	*Map
+}
+
+// a row of a code comparison. before or after is empty if the line was
+// added or removed
+type codecompareRow struct {
+	typ             string // eq, del, ins, or chg
+	before, after   string // HTML of each side
+	beforeN, afterN int    // line numbers, or 0 if not present on that side
+}
+
+// newCodecompare creates a codecompare{} given an underlying parser block.
+func newCodecompare(name string, b *parserBlock) block {
+	b.typ = "codecompare"
+	return &codecompare{newMapBlock("", b).(*Map)}
+}
+
+// parse parses the codecompare contents.
+func (cc *codecompare) parse(page *Page) {
+	// the snippets are compared as text, not displayed as code{} blocks
+	cc.noFormatValues = true
+	cc.Map.parse(page)
+	cc.warnUnknownKeys("before", "after")
+}
+
+// html converts the differences to HTML.
+func (cc *codecompare) html(page *Page, el element) {
+	cc.Map.html(page, nil)
+	el.setMeta("noIndent", true)
+
+	before, okBefore := cc.snippet("before")
+	after, okAfter := cc.snippet("after")
+	if !okBefore || !okAfter {
+		return
+	}
+
+	inline := false
+	switch cc.blockName() {
+	case "", "side":
+	case "inline":
+		inline = true
+	default:
+		cc.warn(cc.openPosition(), "Unknown codecompare{} layout '"+cc.blockName()+"'")
+	}
+
+	rows := codecompareRows(before, after)
+	if inline {
+		el.setTag("pre")
+		el.addClass("codecompare-inline")
+		el.addHTML(codecompareInline(rows))
+	} else {
+		el.setTag("table")
+		el.addClass("codecompare-side")
+		el.addHTML(codecompareSide(rows))
+	}
+}
+
+// fetches the text of a code{} snippet
+func (cc *codecompare) snippet(key string) (string, bool) {
+	val, _ := cc.Get(key)
+	switch v := val.(type) {
+	case *codeBlock:
+		return v.text(), true
+	case nil:
+		cc.warn(cc.openPosition(), "codecompare{} has no '"+key+"' snippet")
+	default:
+		cc.warn(cc.openPosition(), "codecompare{} '"+key+"' must be a code{} block")
+	}
+	return "", false
+}
+
+// compares snippets line by line. removed lines directly followed by added
+// lines are paired as changed lines, which are compared within the line
+func codecompareRows(before, after string) []codecompareRow {
+	// ignore trailing blank lines, including the indentation before the
+	// closing bracket, so that they are not compared
+	before = strings.TrimRight(before, " \t\r\n") + "\n"
+	after = strings.TrimRight(after, " \t\r\n") + "\n"
+
+	dmp := diffmatchpatch.New()
+	a, b, lines := dmp.DiffLinesToChars(before, after)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(a, b, false), lines)
+
+	var rows []codecompareRow
+	beforeN, afterN := 1, 1
+	for i := 0; i < len(diffs); i++ {
+		d := diffs[i]
+		switch d.Type {
+
+		case diffmatchpatch.DiffEqual:
+			for _, line := range codecompareLines(d.Text) {
+				esc := htmlfmt.EscapeString(line)
+				rows = append(rows, codecompareRow{"eq", esc, esc, beforeN, afterN})
+				beforeN++
+				afterN++
+			}
+
+		case diffmatchpatch.DiffDelete:
+			removed := codecompareLines(d.Text)
+			var added []string
+			if i+1 < len(diffs) && diffs[i+1].Type == diffmatchpatch.DiffInsert {
+				added = codecompareLines(diffs[i+1].Text)
+				i++
+			}
+			for j := 0; j < len(removed) || j < len(added); j++ {
+				switch {
+				case j < len(removed) && j < len(added):
+					del, ins := codecompareIntraline(dmp, removed[j], added[j])
+					rows = append(rows, codecompareRow{"chg", del, ins, beforeN, afterN})
+					beforeN++
+					afterN++
+				case j < len(removed):
+					rows = append(rows, codecompareRow{"del", htmlfmt.EscapeString(removed[j]), "", beforeN, 0})
+					beforeN++
+				default:
+					rows = append(rows, codecompareRow{"ins", "", htmlfmt.EscapeString(added[j]), 0, afterN})
+					afterN++
+				}
+			}
+
+		case diffmatchpatch.DiffInsert:
+			for _, line := range codecompareLines(d.Text) {
+				rows = append(rows, codecompareRow{"ins", "", htmlfmt.EscapeString(line), 0, afterN})
+				afterN++
+			}
+		}
+	}
+	return rows
+}
+
+// compares a changed line, returning HTML for each side with the changed
+// parts wrapped in <del> and <ins>
+func codecompareIntraline(dmp *diffmatchpatch.DiffMatchPatch, before, after string) (string, string) {
+	diffs := dmp.DiffCleanupSemantic(dmp.DiffMain(before, after, false))
+	var del, ins strings.Builder
+	for _, d := range diffs {
+		text := htmlfmt.EscapeString(d.Text)
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			del.WriteString(text)
+			ins.WriteString(text)
+		case diffmatchpatch.DiffDelete:
+			del.WriteString("<del>" + text + "</del>")
+		case diffmatchpatch.DiffInsert:
+			ins.WriteString("<ins>" + text + "</ins>")
+		}
+	}
+	return del.String(), ins.String()
+}
+
+// side-by-side table rows
+func codecompareSide(rows []codecompareRow) HTML {
+	var b strings.Builder
+	for _, row := range rows {
+		b.WriteString(`<tr class="q-codecompare-` + row.typ + `">`)
+		b.WriteString(codecompareCell(row.beforeN, row.before))
+		b.WriteString(codecompareCell(row.afterN, row.after))
+		b.WriteString("</tr>\n")
+	}
+	return HTML(b.String())
+}
+
+func codecompareCell(n int, line string) string {
+	if n == 0 {
+		return `<td class="q-codecompare-ln"></td><td class="q-codecompare-code"></td>`
+	}
+	return `<td class="q-codecompare-ln">` + strconv.Itoa(n) + `</td><td class="q-codecompare-code">` + line + `</td>`
+}
+
+// single column, with removed lines before the added lines that replace them
+func codecompareInline(rows []codecompareRow) HTML {
+	var b strings.Builder
+	var added []string
+	flush := func() {
+		for _, line := range added {
+			b.WriteString(`<span class="q-codecompare-ins">+ ` + line + "</span>\n")
+		}
+		added = nil
+	}
+	for _, row := range rows {
+		switch row.typ {
+		case "eq":
+			flush()
+			b.WriteString(`<span class="q-codecompare-eq">  ` + row.before + "</span>\n")
+		case "del", "chg":
+			b.WriteString(`<span class="q-codecompare-del">- ` + row.before + "</span>\n")
+			if row.typ == "chg" {
+				added = append(added, row.after)
+			}
+		case "ins":
+			added = append(added, row.after)
+		}
+	}
+	flush()
+	return HTML(b.String())
+}
+
+// splits text into lines without their newlines
+func codecompareLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\n")
+	}
+	return lines
+}
@@ -0,0 +1,32 @@
+package wikifier
+
+// contributors{} lists the people who have edited the page, with those who
+// have made the most revisions first.
+//
+//	sec [Contributors] {
+//	    contributors {}
+//	}
+//
+// The names come from the wiki revision history, so the list is empty
+// outside of a wiki.
+type contributorsBlock struct {
+	*parserBlock
+}
+
+func newContributorsBlock(name string, b *parserBlock) block {
+	return &contributorsBlock{parserBlock: b}
+}
+
+func (cb *contributorsBlock) html(page *Page, el element) {
+	el.setTag("ul")
+	checkTableContent(cb.parserBlock, "contributors")
+
+	names := page.Contributors()
+	if len(names) == 0 {
+		el.setMeta("noTags", true)
+		return
+	}
+	for _, name := range names {
+		el.createChild("li", "contributors-name").addText(name)
+	}
+}
@@ -0,0 +1,222 @@
+package wikifier
+
+import (
+	"encoding/csv"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csv{} displays comma-separated values as a table which can be sorted by
+// clicking a column heading. Brackets, braces, and backslashes must be
+// escaped as in other text.
+//
+//	csv {
+//	    Name, Released, Downloads
+//	    quiki, 2020-03-01, "1,204"
+//	    wikifier, 2014-08-12, 311
+//	}
+//
+// The first row is a header if none of its values are numbers or dates and
+// they are all different, unless the header or noheader option is given.
+// Columns of numbers or dates are sorted accordingly, and numbers are
+// aligned right. Another separator may be given with delimiter, such as
+// delimiter=; or delimiter=tab.
+//
+// Pages from .csv files are translated to a single csv{}.
+type csvBlock struct {
+	*parserBlock
+}
+
+// csv{} column types
+const (
+	csvTypeNone   = ""
+	csvTypeNumber = "number"
+	csvTypeDate   = "date"
+	csvTypeText   = "text"
+)
+
+// date formats recognized in csv{}
+var csvDateFormats = []string{
+	"2006-01-02",
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+	"01/02/2006",
+	"Jan 2, 2006",
+	"January 2, 2006",
+	"2 Jan 2006",
+}
+
+var csvNumberReplacer = strings.NewReplacer(",", "", "$", "", "€", "", "£", "", "%", "")
+
+func newCSVBlock(name string, b *parserBlock) block {
+	return &csvBlock{parserBlock: b}
+}
+
+func (cb *csvBlock) html(page *Page, el element) {
+	el.setTag("table")
+	el.addClass("table")
+
+	// options
+	header, headerKnown, delim := false, false, ','
+	for _, opt := range tableOptions(cb.parserBlock, "header", "noheader", "delimiter") {
+		switch opt[0] {
+		case "header", "noheader":
+			header, headerKnown = opt[0] == "header", true
+		case "delimiter":
+			switch {
+			case opt[1] == "tab":
+				delim = '\t'
+			case len(opt[1]) == 1 && opt[1] != `"`:
+				delim = rune(opt[1][0])
+			default:
+				cb.warn(cb.openPosition(), "Invalid csv{} delimiter '"+opt[1]+"'")
+			}
+		}
+	}
+
+	// read the records
+	text := ""
+	for _, piece := range cb.textContent() {
+		text += piece
+	}
+	r := csv.NewReader(strings.NewReader(plaintextUnescaper.Replace(text)))
+	r.Comma = delim
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		cb.warn(cb.openPosition(), "csv{}: "+err.Error())
+	}
+	for _, record := range records {
+		for i, value := range record {
+			record[i] = strings.TrimSpace(value)
+		}
+	}
+	if len(records) == 0 {
+		if err == nil {
+			cb.warn(cb.openPosition(), "csv{} is empty")
+		}
+		el.setMeta("noTags", true)
+		return
+	}
+
+	// detect the header and column types
+	if !headerKnown {
+		header = csvHasHeader(records)
+	}
+	rows := records
+	if header {
+		rows = records[1:]
+	}
+	types := csvColumnTypes(rows)
+
+	if header {
+		el.addClass("csv-sortable")
+		tr := el.createChild("thead", "table-head").createChild("tr", "tr")
+		for i, value := range records[0] {
+			th := tr.createChild("th", "th")
+			if i < len(types) {
+				th.setAttr("data-type", types[i])
+				if types[i] == csvTypeNumber {
+					th.addClass("align-right")
+				}
+			}
+			th.addText(value)
+		}
+	}
+
+	tbody := el.createChild("tbody", "table-body")
+	for _, row := range rows {
+		tr := tbody.createChild("tr", "tr")
+		for i, value := range row {
+			td := tr.createChild("td", "td")
+			switch types[i] {
+			case csvTypeNumber:
+				td.addClass("align-right")
+				if n, ok := csvNumber(value); ok {
+					td.setAttr("data-sort", strconv.FormatFloat(n, 'g', -1, 64))
+				}
+			case csvTypeDate:
+				if t, ok := csvDate(value); ok {
+					td.setAttr("data-sort", t.Format(time.RFC3339))
+				}
+			}
+			td.addText(value)
+		}
+	}
+}
+
+// the first row is a header if its values are all different and none are
+// numbers or dates
+func csvHasHeader(records [][]string) bool {
+	seen := make(map[string]bool)
+	for _, value := range records[0] {
+		if value == "" || seen[value] || csvType(value) != csvTypeText {
+			return false
+		}
+		seen[value] = true
+	}
+	return len(records) > 1
+}
+
+// the type of each column, which is the type shared by all of its values.
+// empty values are ignored
+func csvColumnTypes(rows [][]string) []string {
+	var types []string
+	for _, row := range rows {
+		for i, value := range row {
+			if i == len(types) {
+				types = append(types, csvTypeNone)
+			}
+			typ := csvType(value)
+			switch {
+			case typ == csvTypeNone:
+			case types[i] == csvTypeNone:
+				types[i] = typ
+			case types[i] != typ:
+				types[i] = csvTypeText
+			}
+		}
+	}
+	for i, typ := range types {
+		if typ == csvTypeNone {
+			types[i] = csvTypeText
+		}
+	}
+	return types
+}
+
+func csvType(value string) string {
+	if value == "" {
+		return csvTypeNone
+	}
+	if _, ok := csvNumber(value); ok {
+		return csvTypeNumber
+	}
+	if _, ok := csvDate(value); ok {
+		return csvTypeDate
+	}
+	return csvTypeText
+}
+
+// parses a number, ignoring currency symbols, percent signs, and thousands
+// separators
+func csvNumber(value string) (float64, bool) {
+	if !strings.ContainsAny(value, "0123456789") {
+		return 0, false // not Inf or NaN
+	}
+	n, err := strconv.ParseFloat(csvNumberReplacer.Replace(value), 64)
+	return n, err == nil
+}
+
+func csvDate(value string) (time.Time, bool) {
+	for _, format := range csvDateFormats {
+		if t, err := time.Parse(format, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,198 @@
+package wikifier
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// data{} loads structured data from a file in the wiki data directory so
+// that it can be used in variables.
+//
+//	@releases: data [releases.json] {};
+//
+//	The latest version is [@releases.0.version].
+//
+// JSON objects become maps and arrays become lists, so their values are
+// accessed by key or by index starting from 0. A CSV file becomes a list
+// with a map for each row, keyed by the values of its first row.
+//
+// Values from data files are not formatted. YAML is not supported, because
+// quiki has no YAML parser among its dependencies and a partial one would
+// read some files differently than other tools; such files can be converted
+// to JSON, which YAML is a superset of.
+type dataBlock struct {
+	value AttributedObject // the loaded data, or nil if it failed
+	*parserBlock
+}
+
+func newDataBlock(name string, b *parserBlock) block {
+	return &dataBlock{parserBlock: b}
+}
+
+func (db *dataBlock) parse(page *Page) {
+	name := strings.TrimSpace(db.blockName())
+	if name == "" {
+		db.warn(db.openPos, "data{} requires a file name")
+		return
+	}
+
+	// find the file within the data directory
+	dir := pageAbs(filepath.Join(page.Opt.Dir.Wiki, "data"))
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if !strings.HasPrefix(path, dir+string(filepath.Separator)) {
+		db.warn(db.openPos, "Data file '"+name+"' is outside of the data directory")
+		return
+	}
+
+	// remember the page uses this, so that it is regenerated when the
+	// data file changes
+	name = filepath.ToSlash(path[len(dir)+1:])
+	page.DataFiles[name] = append(page.DataFiles[name], db.openPos.Line)
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			db.warn(db.openPos, "Data file '"+name+"' does not exist")
+		} else {
+			db.warn(db.openPos, "Data file '"+name+"' error: "+err.Error())
+		}
+		return
+	}
+	defer file.Close()
+
+	// load it
+	var value AttributedObject
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		value, err = db.loadJSON(page, file)
+	case ".csv":
+		value, err = db.loadCSV(page, file)
+	case ".yaml", ".yml":
+		db.warn(db.openPos, "Data file '"+name+"' is YAML, which is not supported; convert it to JSON")
+		return
+	default:
+		db.warn(db.openPos, "Data file '"+name+"' is not JSON or CSV")
+		return
+	}
+	if err != nil {
+		db.warn(db.openPos, "Data file '"+name+"' error: "+err.Error())
+		return
+	}
+	db.value = value
+}
+
+// data{} produces no output. when it is assigned to a variable, the
+// variable holds the loaded data instead of the block
+func (db *dataBlock) html(page *Page, el element) {
+	el.setMeta("noTags", true)
+	db.warn(db.openPos, "data{} must be assigned to a variable")
+}
+
+// reads a JSON object or array, preserving the order of object keys
+func (db *dataBlock) loadJSON(page *Page, r io.Reader) (AttributedObject, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	value, err := db.jsonValue(page, dec)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := value.(AttributedObject)
+	if !ok {
+		return nil, errors.New("not an object or array")
+	}
+	return obj, nil
+}
+
+func (db *dataBlock) jsonValue(page *Page, dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+
+	// object
+	case json.Delim:
+		if t == '{' {
+			m := db.newMap(page)
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := db.jsonValue(page, dec)
+				if err != nil {
+					return nil, err
+				}
+				if value != nil {
+					m.appendEntry(key.(string), value, db.openPos)
+				}
+			}
+			_, err = dec.Token()
+			return m, err
+		}
+
+		// array
+		l := NewList(page.mainBlock())
+		for dec.More() {
+			value, err := db.jsonValue(page, dec)
+			if err != nil {
+				return nil, err
+			}
+			if value == nil {
+				value = ""
+			}
+			l.appendEntry(value, db.openPos)
+		}
+		_, err = dec.Token()
+		return l, err
+
+	// number
+	case json.Number:
+		return t.String(), nil
+
+	// string, boolean, or null
+	default:
+		return t, nil
+	}
+}
+
+// reads a CSV file as a list of maps
+func (db *dataBlock) loadCSV(page *Page, r io.Reader) (AttributedObject, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	cr.TrimLeadingSpace = true
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	l := NewList(page.mainBlock())
+	if len(records) == 0 {
+		return l, nil
+	}
+	header := records[0]
+	for _, record := range records[1:] {
+		m := db.newMap(page)
+		for i, value := range record {
+			if i < len(header) {
+				m.appendEntry(strings.TrimSpace(header[i]), strings.TrimSpace(value), db.openPos)
+			}
+		}
+		l.appendEntry(m, db.openPos)
+	}
+	return l, nil
+}
+
+func (db *dataBlock) newMap(page *Page) *Map {
+	m := NewMap(page.mainBlock())
+	m.noFormatValues = true
+	return m
+}
@@ -0,0 +1,44 @@
+package wikifier
+
+// deflist{} displays a list of terms and their definitions.
+//
+//	deflist {
+//	    Apple: A round fruit;
+//	        : A technology company;
+//	    Banana: A long, yellow fruit;
+//	}
+//
+// A value without a term is an additional definition of the previous term.
+type deflist struct {
+	*Map
+}
+
+// newDeflist creates a deflist{} given an underlying parser block.
+func newDeflist(name string, b *parserBlock) block {
+	b.typ = "deflist"
+	return &deflist{newMapBlock("", b).(*Map)}
+}
+
+// parse parses the deflist contents.
+func (dl *deflist) parse(page *Page) {
+	dl.Map.parse(page)
+}
+
+// html converts the contents of the deflist to HTML elements.
+func (dl *deflist) html(page *Page, el element) {
+	dl.Map.html(page, nil)
+	el.setTag("dl")
+
+	for i, entry := range dl.mapList {
+
+		// term
+		if entry.keyTitle != "" {
+			el.createChild("dt", "deflist-term").addHTML(page.Fmt(entry.keyTitle, entry.pos))
+		} else if i == 0 {
+			dl.warn(entry.pos, "Definition without a term")
+		}
+
+		// definition
+		el.createChild("dd", "deflist-def").add(entry.value)
+	}
+}
@@ -0,0 +1,146 @@
+package wikifier
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// diagram{} displays a diagram written in Mermaid or Graphviz (DOT). Like
+// code{}, the contents is not formatted, so use a brace escape.
+//
+//	diagram [mermaid] {{
+//	    graph LR
+//	        wiki --> webserver
+//	}}
+//
+//	diagram [graphviz] {{
+//	    digraph { wiki -> webserver }
+//	}}
+type diagramBlock struct {
+	*parserBlock
+}
+
+// diagram languages, with alternative names
+var diagramLanguages = map[string]string{
+	"mermaid":  "mermaid",
+	"graphviz": "graphviz",
+	"dot":      "graphviz",
+}
+
+func newDiagramBlock(name string, b *parserBlock) block {
+	return &diagramBlock{parserBlock: b}
+}
+
+func (db *diagramBlock) html(page *Page, el element) {
+	el.setTag("div")
+
+	lang := diagramLanguages[strings.ToLower(strings.TrimSpace(db.blockName()))]
+	if lang == "" {
+		if db.blockName() == "" {
+			db.warn(db.openPosition(), "diagram{} requires a language, mermaid or graphviz")
+		} else {
+			db.warn(db.openPosition(), "No such diagram{} language '"+db.blockName()+"'")
+		}
+		el.setMeta("noTags", true)
+		return
+	}
+	el.addClass("diagram-" + lang)
+
+	src := ""
+	for _, piece := range db.textContent() {
+		src += piece
+	}
+	src = strings.TrimSpace(src)
+	if src == "" {
+		db.warn(db.openPosition(), "diagram{} is empty")
+		el.setMeta("noTags", true)
+		return
+	}
+
+	// pre-rendered SVG
+	if svg, ok := page.renderDiagram(lang, src, db.openPosition()); ok {
+		el.addClass("diagram-rendered")
+		el.addHTML(svg)
+		return
+	}
+
+	// otherwise the source is left for rendering in the browser. mermaid.js
+	// looks for the mermaid class by default
+	pre := el.createChild("pre", "diagram-source")
+	pre.setMeta("noIndent", true)
+	if lang == "mermaid" {
+		pre.addClass("!mermaid")
+	}
+	pre.addText(src)
+}
+
+// renders a diagram to SVG if a renderer is configured for the language.
+// ok is false if there is none or if rendering fails
+func (p *Page) renderDiagram(lang, src string, pos Position) (svg HTML, ok bool) {
+	if p.Opt == nil {
+		return "", false
+	}
+	render := p.Opt.Page.Diagram.Render
+	if render == nil {
+		command := p.Opt.Page.Diagram.Mermaid
+		if lang == "graphviz" {
+			command = p.Opt.Page.Diagram.Graphviz
+		}
+		if command == "" {
+			return "", false
+		}
+		render = diagramCommandRenderer(command)
+	}
+
+	svg, err := render(lang, src)
+	if err != nil {
+		p.warn(pos, "Diagram rendering failed: "+err.Error())
+		return "", false
+	}
+	if svg == "" {
+		return "", false
+	}
+	return svg, true
+}
+
+// how long the diagram renderer command may run for each diagram. this is
+// longer than for math, since the Mermaid CLI starts a headless browser
+const diagramCommandTimeout = 30 * time.Second
+
+// returns a diagram renderer which runs a program, such as dot -Tsvg, with
+// the source on stdin. the XML declaration and doctype which precede the
+// <svg> element in its output are removed so it can be embedded in a page
+func diagramCommandRenderer(command string) func(lang, src string) (HTML, error) {
+	return func(lang, src string) (HTML, error) {
+		args := strings.Fields(command)
+		if len(args) == 0 {
+			return "", errors.New("no command")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), diagramCommandTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Stdin = strings.NewReader(src)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		if err := cmd.Run(); err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return "", errors.New("timed out after " + diagramCommandTimeout.String())
+			}
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return "", errors.New(msg)
+			}
+			return "", err
+		}
+		out := stdout.String()
+		start := strings.Index(out, "<svg")
+		if start == -1 {
+			return "", errors.New("no <svg> in output")
+		}
+		return HTML(strings.TrimSpace(out[start:])), nil
+	}
+}
@@ -0,0 +1,306 @@
+package wikifier
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	strip "github.com/grokify/html-strip-tags-go"
+)
+
+// events{} lists dated entries as an agenda, or as a calendar of each month
+// with events{} [calendar].
+//
+//	events {
+//	    2026-11-02: Release party;
+//	    2026-11-15 18:00 to 20:00: Meetup at [[ Cafe ]];
+//	    2026-12-01 to 2026-12-03: Conference;
+//	}
+//
+// Each entry is a date, optionally a time, and optionally " to " and an end
+// date, time, or both, followed by a colon and the title. Times are as
+// written, without a time zone.
+type eventsBlock struct {
+	style   string // agenda or calendar
+	entries []*eventEntry
+	*parserBlock
+}
+
+// An Event is a dated entry of an events{} block.
+type Event struct {
+	Start    time.Time `json:"start"`             // start time, or start day if AllDay
+	End      time.Time `json:"end"`               // end time, or last day if AllDay. same as Start if not given
+	AllDay   bool      `json:"all_day,omitempty"` // true if no time is given
+	Title    string    `json:"title"`             // title without formatting
+	FmtTitle HTML      `json:"fmt_title"`         // title with formatting
+}
+
+type eventEntry struct {
+	Event
+	title string // unformatted title
+	pos   Position
+}
+
+var eventMatch = regexp.MustCompile(`(?s)^(\d{4}-\d{2}-\d{2})(?:\s+(\d{1,2}:\d{2}))?(\s+to\s+(\d{4}-\d{2}-\d{2})?\s*(\d{1,2}:\d{2})?)?\s*:\s*(.+)$`)
+
+// days displayed for a single event in a calendar, at most
+const eventsMaxDays = 366
+
+func newEventsBlock(name string, b *parserBlock) block {
+	return &eventsBlock{parserBlock: b}
+}
+
+func (eb *eventsBlock) parse(page *Page) {
+	eb.style = strings.TrimSpace(eb.blockName())
+	switch eb.style {
+	case "":
+		eb.style = "agenda"
+	case "agenda", "calendar":
+	default:
+		eb.warn(eb.openPos, "events{}: unknown style '"+eb.style+"'; expected agenda or calendar")
+		eb.style = "agenda"
+	}
+
+	// entries are text terminated by semicolons
+	var text strings.Builder
+	var startPos Position
+	escape := false
+	for _, pc := range eb.posContent() {
+		switch item := pc.content.(type) {
+		case block:
+			eb.warn(pc.pos, "events{} can only contain text; "+item.blockType()+"{} ignored")
+		case string:
+			for i, c := range item {
+				switch {
+				case c == '\\' && !escape:
+					escape = true
+				case c == ';' && !escape:
+					eb.addEntry(text.String(), startPos)
+					text.Reset()
+				default:
+					if escape && c != ';' && c != '\\' {
+						text.WriteRune('\\')
+					}
+					escape = false
+					if text.Len() == 0 {
+						if unicode.IsSpace(c) {
+							continue
+						}
+						startPos = pc.pos
+						startPos.Column = i
+					}
+					text.WriteRune(c)
+				}
+			}
+		}
+	}
+	if strings.TrimSpace(text.String()) != "" {
+		eb.warn(startPos, "events{}: entry "+strconv.Quote(strings.TrimSpace(text.String()))+" not terminated")
+	}
+
+	// in order
+	sort.SliceStable(eb.entries, func(i, j int) bool {
+		return eb.entries[i].Start.Before(eb.entries[j].Start)
+	})
+}
+
+// parses an entry, producing a warning if it is not valid
+func (eb *eventsBlock) addEntry(text string, pos Position) {
+	text = strings.TrimSpace(text)
+	m := eventMatch.FindStringSubmatch(text)
+	if m == nil {
+		eb.warn(pos, "events{}: expected a date and a title, as in 2006-01-02: Title, but got "+strconv.Quote(text))
+		return
+	}
+	startDate, startTime, to, endDate, endTime, title := m[1], m[2], m[3], m[4], m[5], m[6]
+
+	// start
+	entry := &eventEntry{title: strings.TrimSpace(title), pos: pos}
+	entry.AllDay = startTime == ""
+	start, err := parseEventTime(startDate, startTime)
+	if err != nil {
+		eb.warn(pos, "events{}: "+err.Error())
+		return
+	}
+	entry.Start, entry.End = start, start
+
+	// end
+	if to != "" {
+		switch {
+		case endDate == "" && endTime == "":
+			eb.warn(pos, "events{}: expected an end date or time after 'to'")
+			return
+		case entry.AllDay && endTime != "":
+			eb.warn(pos, "events{}: an event without a start time cannot have an end time")
+			return
+		case !entry.AllDay && endTime == "":
+			eb.warn(pos, "events{}: an event with a start time must have an end time")
+			return
+		}
+		if endDate == "" {
+			endDate = startDate
+		}
+		end, err := parseEventTime(endDate, endTime)
+		if err != nil {
+			eb.warn(pos, "events{}: "+err.Error())
+			return
+		}
+		if end.Before(start) {
+			eb.warn(pos, "events{}: event ends before it starts")
+			return
+		}
+		entry.End = end
+	}
+
+	eb.entries = append(eb.entries, entry)
+}
+
+func parseEventTime(date, clock string) (time.Time, error) {
+	if clock == "" {
+		return time.ParseInLocation("2006-01-02", date, time.Local)
+	}
+	return time.ParseInLocation("2006-01-02 15:04", date+" "+clock, time.Local)
+}
+
+func (eb *eventsBlock) html(page *Page, el element) {
+	for _, entry := range eb.entries {
+		entry.FmtTitle = page.Fmt(entry.title, entry.pos)
+		entry.Title = html.UnescapeString(strip.StripTags(string(entry.FmtTitle)))
+	}
+	el.addClass("events-" + eb.style)
+	if eb.style == "calendar" {
+		eb.calendarHTML(el)
+		return
+	}
+
+	ul := el.createChild("ul", "events-list")
+	for _, entry := range eb.entries {
+		li := ul.createChild("li", "event")
+		eventTime(li, entry.Event)
+		li.createChild("span", "event-title").addHTML(entry.FmtTitle)
+	}
+}
+
+// a table for each month with events
+func (eb *eventsBlock) calendarHTML(el element) {
+
+	// events on each day
+	days := make(map[string][]*eventEntry)
+	var months []time.Time
+	seenMonth := make(map[string]bool)
+	for _, entry := range eb.entries {
+		day := dayOf(entry.Start)
+		for n := 0; n < eventsMaxDays && !day.After(entry.End); n++ {
+			key := day.Format("2006-01-02")
+			days[key] = append(days[key], entry)
+			month := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.Local)
+			if !seenMonth[key[:7]] {
+				seenMonth[key[:7]] = true
+				months = append(months, month)
+			}
+			day = day.AddDate(0, 0, 1)
+		}
+	}
+	sort.Slice(months, func(i, j int) bool { return months[i].Before(months[j]) })
+
+	for _, month := range months {
+		table := el.createChild("table", "events-month")
+		table.createChild("caption", "events-month-title").addText(month.Format("January 2006"))
+
+		// weekdays
+		tr := table.createChild("tr", "events-week")
+		for wd := time.Sunday; wd <= time.Saturday; wd++ {
+			tr.createChild("th", "events-weekday").addText(wd.String()[:3])
+		}
+
+		// blank days before the first
+		tr = table.createChild("tr", "events-week")
+		for i := 0; i < int(month.Weekday()); i++ {
+			tr.createChild("td", "events-day").addClass("events-blank")
+		}
+
+		for day := month; day.Month() == month.Month(); day = day.AddDate(0, 0, 1) {
+			if day.Weekday() == time.Sunday && day.Day() != 1 {
+				tr = table.createChild("tr", "events-week")
+			}
+			td := tr.createChild("td", "events-day")
+			td.createChild("span", "events-date").addText(strconv.Itoa(day.Day()))
+			entries := days[day.Format("2006-01-02")]
+			if len(entries) == 0 {
+				continue
+			}
+			ul := td.createChild("ul", "events-list")
+			for _, entry := range entries {
+				li := ul.createChild("li", "event")
+				if !entry.AllDay && sameDay(day, entry.Start) {
+					li.createChild("span", "event-clock").addText(entry.Start.Format("15:04"))
+				}
+				li.createChild("span", "event-title").addHTML(entry.FmtTitle)
+			}
+		}
+	}
+}
+
+// adds a <time> describing when an event occurs
+func eventTime(el element, ev Event) {
+	t := el.createChild("time", "event-time")
+	const dateFmt = "Mon, Jan 2, 2006"
+	if ev.AllDay {
+		t.setAttr("datetime", ev.Start.Format("2006-01-02"))
+		text := ev.Start.Format(dateFmt)
+		if !sameDay(ev.Start, ev.End) {
+			text += " – " + ev.End.Format(dateFmt)
+		}
+		t.addText(text)
+		return
+	}
+	t.setAttr("datetime", ev.Start.Format("2006-01-02T15:04"))
+	text := ev.Start.Format(dateFmt + ", 15:04")
+	switch {
+	case ev.End.Equal(ev.Start):
+	case sameDay(ev.Start, ev.End):
+		text += " – " + ev.End.Format("15:04")
+	default:
+		text += " – " + ev.End.Format(dateFmt+", 15:04")
+	}
+	t.addText(text)
+}
+
+func dayOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+func sameDay(a, b time.Time) bool {
+	return dayOf(a).Equal(dayOf(b))
+}
+
+// Events returns the entries of every events{} block on the page, in order
+// of their start times. Events should be called after HTML, so that the
+// titles are formatted.
+func (p *Page) Events() []Event {
+	if p.main == nil {
+		return nil
+	}
+	var events []Event
+	var add func(blk block)
+	add = func(blk block) {
+		for _, child := range blk.blockContent() {
+			if eb, ok := child.(*eventsBlock); ok {
+				for _, entry := range eb.entries {
+					events = append(events, entry.Event)
+				}
+				continue
+			}
+			add(child)
+		}
+	}
+	add(p.main)
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Start.Before(events[j].Start)
+	})
+	return events
+}
@@ -0,0 +1,82 @@
+package wikifier
+
+import (
+	"errors"
+	"sync"
+)
+
+// A BlockFunc generates HTML for a custom block type.
+//
+// The content of the block is parsed as a map{}, and its values are
+// formatted before the function is called, so strings obtained from m are
+// HTML. name is the block name, as in pricing [Pro] { ... }, or empty if it
+// has none.
+type BlockFunc func(page *Page, name string, m *Map) HTML
+
+var (
+	customBlocks     = make(map[string]BlockFunc)
+	customBlocksLock sync.RWMutex
+)
+
+// a block of a type registered with RegisterBlockType
+type customBlock struct {
+	fn BlockFunc
+	*Map
+}
+
+// RegisterBlockType registers a handler for a custom block type.
+//
+// For example, after
+//
+//	wikifier.RegisterBlockType("pricing", handler)
+//
+// the block pricing [Pro] { price: $10; } is converted to HTML by handler.
+//
+// Names may consist of word-like characters and hyphens. Built-in block
+// types and their aliases cannot be overridden, and a name can only be
+// registered once.
+func RegisterBlockType(name string, handler BlockFunc) error {
+	if handler == nil {
+		return errors.New("RegisterBlockType: nil handler")
+	}
+	if !formatNameRegex.MatchString(name) {
+		return errors.New("RegisterBlockType: invalid block type '" + name + "'")
+	}
+	if _, exists := blockInitializers[name]; exists || blockAliases[name] != "" {
+		return errors.New("RegisterBlockType: '" + name + "' is a built-in block type")
+	}
+
+	customBlocksLock.Lock()
+	defer customBlocksLock.Unlock()
+
+	if _, exists := customBlocks[name]; exists {
+		return errors.New("RegisterBlockType: '" + name + "' is already registered")
+	}
+	customBlocks[name] = handler
+	return nil
+}
+
+// IsBlockType returns true if name is a built-in block type, an alias of
+// one, or a type registered with RegisterBlockType.
+func IsBlockType(name string) bool {
+	if _, exists := blockInitializers[name]; exists || blockAliases[name] != "" {
+		return true
+	}
+	return customBlockType(name) != nil
+}
+
+// finds the handler for a custom block type, or nil if there is none
+func customBlockType(blockType string) BlockFunc {
+	customBlocksLock.RLock()
+	defer customBlocksLock.RUnlock()
+	return customBlocks[blockType]
+}
+
+func newCustomBlock(fn BlockFunc, b *parserBlock) block {
+	return &customBlock{fn, newMapBlock("", b).(*Map)}
+}
+
+func (cb *customBlock) html(page *Page, el element) {
+	cb.Map.html(page, nil)
+	el.addHTML(cb.fn(page, cb.blockName(), cb.Map))
+}
@@ -12,9 +12,10 @@ func (b *fmtBlock) html(page *Page, el element) {
 	el.setMeta("noIndent", true)
 	el.setMeta("noTags", true)
 	for _, item := range b.posContent() {
-		// if it's a string, format it
+		// if it's a string, format it and apply the raw html policy
 		if str, ok := item.content.(string); ok {
-			el.add(page.FmtOpts(str, item.pos, FmtOpt{NoEntities: true}))
+			formatted := page.FmtOpts(str, item.pos, FmtOpt{NoEntities: true})
+			el.add(HTML(page.sanitizeHTML(string(formatted))))
 			continue
 		}
 		el.add(item.content)
@@ -0,0 +1,210 @@
+package wikifier
+
+import (
+	"strconv"
+	"strings"
+)
+
+// foreach{} repeats its content for each item of a list or map variable,
+// with @it set to the item, @key set to its index or key, and @value set to
+// the item as well.
+//
+//	foreach [@colors] {
+//	    [@key]. [@it]
+//	}
+//
+// Within a table{}, the rows of each item are rows of the table.
+type foreachBlock struct {
+	items     []*Page // one page per item
+	startLine int     // line where the content starts
+	*parserBlock
+}
+
+// an item of a foreach{} variable
+type foreachItem struct {
+	key   string
+	value interface{}
+}
+
+// iterations beyond this are not expanded. this is for the whole page,
+// including nested foreach{} and those in item content
+const foreachMaxItems = 1000
+
+func newForeachBlock(name string, b *parserBlock) block {
+	return &foreachBlock{parserBlock: b}
+}
+
+func (fb *foreachBlock) parse(page *Page) {
+	varName := strings.TrimPrefix(strings.TrimSpace(fb.blockName()), "@")
+	if varName == "" {
+		fb.warn(fb.openPos, "foreach{} requires a variable, as in foreach [@items]")
+		return
+	}
+
+	// find the items
+	val, err := page.Get(varName)
+	if err != nil {
+		fb.warn(fb.openPos, "foreach{}: @"+varName+": "+err.Error())
+		return
+	}
+	var items []foreachItem
+	switch obj := val.(type) {
+	case nil:
+		fb.warn(fb.openPos, "foreach{}: @"+varName+" does not exist")
+		return
+	case *List:
+		for i, entry := range obj.list {
+			items = append(items, foreachItem{strconv.Itoa(i), entry.value})
+		}
+	case *Map:
+		keys := obj.OrderedKeys()
+		if len(keys) == 0 {
+			keys = obj.Keys()
+		}
+		for _, key := range keys {
+			items = append(items, foreachItem{key, obj.getOwn(key)})
+		}
+	default:
+		fb.warn(fb.openPos, "foreach{}: @"+varName+" is not a list or map")
+		return
+	}
+	if page.foreachItems == nil {
+		page.foreachItems = new(int)
+	}
+	if remaining := foreachMaxItems - *page.foreachItems; len(items) > remaining {
+		if remaining < 0 {
+			remaining = 0
+		}
+		fb.warn(fb.openPos, "foreach{}: more than "+strconv.Itoa(foreachMaxItems)+" items on this page; ignoring the rest")
+		items = items[:remaining]
+	}
+	*page.foreachItems += len(items)
+
+	// the content, and where it starts so that warnings refer to this page
+	var source strings.Builder
+	fb.startLine = fb.openPos.Line
+	for i, pc := range fb.posContent() {
+		if i == 0 && !pc.pos.none() {
+			fb.startLine = pc.pos.Line
+		}
+		if text, ok := pc.content.(string); ok {
+			source.WriteString(text)
+		}
+	}
+
+	if strings.TrimSpace(source.String()) == "" {
+		return
+	}
+
+	// the page is in the chain of including pages, so that an item cannot
+	// include it
+	chain := append([]string(nil), page.includes...)
+	if page.FilePath != "" {
+		chain = append(chain, pageAbs(page.FilePath))
+	}
+
+	for _, item := range items {
+		sub := NewPageSource(source.String())
+		sub.name = page.name
+		sub.Opt = page.Opt
+		sub.Wiki = page.Wiki
+		sub.includes = chain
+
+		// share identifiers so they are unique within this page
+		sub.elementIDs = page.elementIDs
+		sub.headingIDs = page.headingIDs
+		sub.foreachItems = page.foreachItems
+
+		// no section of an item is the page title
+		sub.sectionN = 1
+
+		// variables of the page are visible, and those set within an item
+		// are not visible outside of it
+		for key, value := range page.vars {
+			sub.vars[key] = value
+		}
+		sub.Set("it", item.value)
+		sub.Set("key", item.key)
+		sub.Set("value", item.value)
+
+		err := sub.Parse()
+		fb.adoptWarnings(sub)
+		if err != nil {
+			fb.warn(fb.pagePos(sub.Error.Pos), "foreach{} error: "+sub.Error.Message)
+			return
+		}
+		fb.items = append(fb.items, sub)
+	}
+}
+
+func (fb *foreachBlock) html(page *Page, el element) {
+	if len(fb.items) == 0 {
+		el.setMeta("noTags", true)
+		return
+	}
+	for _, sub := range fb.items {
+		mainBlock := sub.mainBlock()
+		mainEl := mainBlock.el()
+		mainBlock.html(sub, mainEl)
+		mainEl.setMeta("noTags", true)
+		el.addChild(mainEl)
+	}
+	fb.adoptItems(page)
+}
+
+// rows of each item, for table{}
+func (fb *foreachBlock) rows() []tableRow {
+	var rows []tableRow
+	for _, sub := range fb.items {
+		for _, child := range sub.main.blockContent() {
+			if tr, ok := child.(*trBlock); ok {
+				rows = append(rows, tableRow{tr, sub})
+			}
+		}
+	}
+	return rows
+}
+
+// warnings within an item are reported here, at their lines on this page
+func (fb *foreachBlock) adoptWarnings(sub *Page) {
+	for _, w := range sub.Warnings {
+		fb.warn(fb.pagePos(w.Pos), w.Message)
+	}
+	sub.Warnings = nil
+}
+
+// converts a position within an item to that on this page
+func (fb *foreachBlock) pagePos(pos Position) Position {
+	if pos.Line != 0 {
+		pos.Line += fb.startLine - 1
+	}
+	return pos
+}
+
+// the pages, external links, images, models, and data files used by the items are used by
+// this page, and warnings produced while generating them are reported here.
+// this is called once the items are generated
+func (fb *foreachBlock) adoptItems(page *Page) {
+	for _, sub := range fb.items {
+		fb.adoptWarnings(sub)
+		for name := range sub.PageLinks {
+			page.PageLinks[name] = append(page.PageLinks[name], fb.openPos.Line)
+		}
+		for url := range sub.ExternalLinks {
+			page.ExternalLinks[url] = append(page.ExternalLinks[url], fb.openPos.Line)
+		}
+		for name, dims := range sub.Images {
+			page.Images[name] = append(page.Images[name], dims...)
+		}
+		for name, n := range sub.Galleries {
+			page.Galleries[name] += n
+		}
+		for name, info := range sub.Models {
+			page.Models[name] = info
+		}
+		for name := range sub.DataFiles {
+			page.DataFiles[name] = append(page.DataFiles[name], fb.openPos.Line)
+		}
+	}
+	fb.items = nil
+}
@@ -1,20 +1,27 @@
 package wikifier
 
 import (
+	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
 type galleryBlock struct {
 	thumbHeight int
+	sort        string // name, date, or manual
 	images      []*galleryEntry
 	*Map
 }
 
 type galleryEntry struct {
 	thumbPath string
+	sortKey   string // explicit sort key, for manual sorting
+	link      string // where the image links, if not to itself
 	img       *imageBlock
 }
 
@@ -52,6 +59,19 @@ func (g *galleryBlock) parse(page *Page) {
 			// good
 			g.thumbHeight = height
 
+		// image order
+		case "sort":
+			order, err := g.GetStr(imgKey)
+			if err != nil {
+				g.warn(g.getKeyPos(imgKey), errors.Wrap(err, imgKey).Error())
+				break
+			}
+			if order != "name" && order != "date" && order != "manual" {
+				g.warn(g.getKeyPos(imgKey), "sort: expected name, date, or manual")
+				break
+			}
+			g.sort = order
+
 		default:
 
 			// unknown key
@@ -81,12 +101,54 @@ func (g *galleryBlock) parse(page *Page) {
 			g.addImage(page, img)
 		}
 	}
+
+	g.sortImages(page)
+}
+
+// sorts the images by name, by date, or by their sort keys. without sort
+// keys, manual order is the order in which the images appear
+func (g *galleryBlock) sortImages(page *Page) {
+	switch g.sort {
+
+	case "name":
+		sort.SliceStable(g.images, func(i, j int) bool {
+			return strings.ToLower(g.images[i].img.lastName) < strings.ToLower(g.images[j].img.lastName)
+		})
+
+	// by modification time of the image files, oldest first
+	case "date":
+		modTimes := make(map[*galleryEntry]time.Time, len(g.images))
+		for _, entry := range g.images {
+			if fi, err := os.Stat(filepath.Join(page.Opt.Dir.Image, filepath.FromSlash(entry.img.file))); err == nil {
+				modTimes[entry] = fi.ModTime()
+			}
+		}
+		sort.SliceStable(g.images, func(i, j int) bool {
+			return modTimes[g.images[i]].Before(modTimes[g.images[j]])
+		})
+
+	// by sort key, with images which have none at the end
+	default:
+		sort.SliceStable(g.images, func(i, j int) bool {
+			a, b := g.images[i].sortKey, g.images[j].sortKey
+			if a == "" || b == "" {
+				return b == "" && a != ""
+			}
+			aNum, aErr := strconv.ParseFloat(a, 64)
+			bNum, bErr := strconv.ParseFloat(b, 64)
+			if aErr == nil && bErr == nil {
+				return aNum < bNum
+			}
+			return a < b
+		})
+	}
 }
 
 func (g *galleryBlock) addImage(page *Page, img *imageBlock) {
 
 	// get full-size path
-	entry := &galleryEntry{img.path, img}
+	sortKey, _ := img.GetStr("sort")
+	entry := &galleryEntry{img.path, sortKey, "", img}
 
 	// determine largest support retina scale
 	// this will be used as the multiplier
@@ -99,13 +161,26 @@ func (g *galleryBlock) addImage(page *Page, img *imageBlock) {
 
 	// generate the thumbnail
 	// note: pregeneration will take care of the max scale
+	width := 0
+	if img.crop {
+		// cropped thumbnails keep the aspect ratio of the crop
+		ratio := img.cropRatio
+		if ratio == 0 {
+			ratio = float64(img.width) / float64(img.height)
+		}
+		width = int(float64(g.thumbHeight)*ratio + 0.5)
+	}
 	img.height = g.thumbHeight
-	img.width = 0
+	img.width = width
 	img.parsedDimensions = true
 	img.parse(page)
 
+	// remember that the page uses this image in a gallery
+	page.Galleries[img.file]++
+
 	// fix paths
 	entry.thumbPath = img.path
+	entry.link = img.link
 	img.path = page.Opt.Root.Image + "/" + img.file
 
 	// add the image
@@ -135,12 +210,15 @@ func (g *galleryBlock) html(page *Page, el element) {
 		"thumbnailHoverEffect2": "descriptionSlideUp|image_scale_1_1.1_500",
 		"thumbnailAlignment": "center",
 		"thumbnailGutterWidth": 10,
-		"thumbnailGutterHeight": 10
+		"thumbnailGutterHeight": 10,
+		"allowHTMLinData": true
 	}`
 
 	// set options
 	el.setAttr("data-nanogallery2", options)
 	el.setAttr("id", "q-"+el.id())
+	el.setAttr("role", "group")
+	el.setAttr("aria-label", "Gallery")
 
 	// add images
 	for _, entry := range g.images {
@@ -148,7 +226,10 @@ func (g *galleryBlock) html(page *Page, el element) {
 		// determine desc
 		// consider: this could be extracted in image{} parse.
 		// I didn't do it since image{} usually didn't have a desc.
-		desc, _ := entry.img.GetStr("description")
+		desc, _ := entry.img.GetStr("caption")
+		if desc == "" {
+			desc, _ = entry.img.GetStr("description")
+		}
 		if desc == "" {
 			desc, _ = entry.img.GetStr("desc")
 		}
@@ -158,5 +239,13 @@ func (g *galleryBlock) html(page *Page, el element) {
 		a.setAttr("href", entry.img.path)
 		a.setAttr("data-ngthumb", entry.thumbPath)
 		a.setAttr("data-ngdesc", desc)
+		a.setAttr("aria-label", entry.img.alt)
+
+		// link somewhere other than the image
+		if entry.link != "" && entry.link != "none" {
+			if ok, target, _, _, _ := page.parseLink(entry.link, &FmtOpt{Pos: entry.img.getKeyPos("link")}); ok {
+				a.setAttr("data-ngdest", target)
+			}
+		}
 	}
 }
@@ -0,0 +1,108 @@
+package wikifier
+
+import (
+	"html"
+	"strings"
+
+	strip "github.com/grokify/html-strip-tags-go"
+)
+
+// httpapi{} documents an HTTP API endpoint.
+//
+//	httpapi {
+//	    method: GET;
+//	    path: /users/:id;
+//	    desc: Fetches a user.;
+//	    params: map {
+//	        id: The user ID;
+//	    };
+//	    request: code {{
+//	        GET /users/42
+//	    }};
+//	    response: code [json] {{
+//	        { "id": 42, "name": "Alice" }
+//	    }};
+//	}
+type httpapi struct {
+	*Map
+}
+
+// newHTTPAPI creates an httpapi{} given an underlying parser block.
+func newHTTPAPI(name string, b *parserBlock) block {
+	b.typ = "httpapi"
+	return &httpapi{newMapBlock("", b).(*Map)}
+}
+
+// parse parses the httpapi contents.
+func (api *httpapi) parse(page *Page) {
+	api.Map.parse(page)
+	api.warnUnknownKeys("method", "path", "desc", "description", "params", "request", "response")
+}
+
+// html converts the endpoint documentation to HTML elements.
+func (api *httpapi) html(page *Page, el element) {
+
+	// params are a map{}, which is replaced with its element below
+	var params *Map
+	if val, _ := api.Get("params"); val != nil {
+		if params, _ = val.(*Map); params == nil {
+			api.warn(api.getKeyPos("params"), "httpapi{} params must be a map{}")
+		}
+	}
+	api.Map.html(page, nil)
+
+	// method and path
+	// the value is formatted, so it is unescaped here and escaped once
+	// when the element is written
+	method, _ := api.GetStr("method")
+	method = strings.ToUpper(strings.TrimSpace(html.UnescapeString(strip.StripTags(method))))
+	path, _ := api.Get("path")
+	if method == "" || path == nil {
+		api.warn(api.openPosition(), "httpapi{} requires method and path")
+	}
+	sig := el.createChild("div", "httpapi-signature")
+	if method != "" {
+		m := sig.createChild("span", "httpapi-method")
+		if !keyNormalizer.MatchString(method) {
+			m.addClass("httpapi-" + strings.ToLower(method))
+		}
+		m.addText(method)
+	}
+	if path != nil {
+		sig.createChild("code", "httpapi-path").add(path)
+	}
+
+	// description
+	desc, _ := api.Get("desc")
+	if desc == nil {
+		desc, _ = api.Get("description")
+	}
+	if desc != nil {
+		el.createChild("div", "httpapi-desc").add(desc)
+	}
+
+	// parameters
+	if params != nil && len(params.mapList) != 0 {
+		table := el.createChild("table", "httpapi-params")
+		tr := table.createChild("tr", "")
+		tr.createChild("th", "").addText("Parameter")
+		tr.createChild("th", "").addText("Description")
+		for _, entry := range params.mapList {
+			tr := table.createChild("tr", "httpapi-param")
+			tr.createChild("td", "httpapi-param-name").createChild("code", "").addText(entry.keyTitle)
+			tr.createChild("td", "httpapi-param-desc").add(entry.value)
+		}
+	}
+
+	// examples
+	for _, example := range []string{"request", "response"} {
+		val, _ := api.Get(example)
+		if val == nil {
+			continue
+		}
+		div := el.createChild("div", "httpapi-example")
+		div.addClass("httpapi-" + example)
+		div.createChild("div", "httpapi-example-title").addText(strings.Title(example))
+		div.add(val)
+	}
+}
@@ -18,6 +18,11 @@ type imageBlock struct {
 	parsedDimensions                bool
 	fullSize                        bool
 	scales                          []int
+
+	crop           bool    // crop to the dimensions rather than scale
+	cropRatio      float64 // width/height to crop to, if given
+	focusX, focusY int     // focal point of a crop, in percent
+	cropped        bool    // true if the image was cropped by the wiki
 	*Map
 }
 
@@ -38,6 +43,12 @@ func newImagebox(name string, b *parserBlock) block {
 // image{} or imagebox{} parse
 func (image *imageBlock) parse(page *Page) {
 	image.Map.parse(page)
+	known := []string{"file", "alt", "link", "align", "float", "author", "license",
+		"width", "height", "description", "desc", "crop", "focus_x", "focus_y"}
+	if image.parentBlock().blockType() == "gallery" {
+		known = append(known, "caption", "sort")
+	}
+	image.warnUnknownKeys(known...)
 
 	// fetch string values from map
 	image.file = image.getString("file")
@@ -62,7 +73,20 @@ func (image *imageBlock) parse(page *Page) {
 
 	// determine alt text
 	if image.alt == "" {
-		image.alt = image.file
+
+		// lint: images should describe themselves for screen readers
+		if page.Opt.Page.Lint.ImageAlt && image.file != "" {
+			image.warn(image.openPos, "Image '"+image.file+"' has no alt text")
+		}
+
+		// fall back to the description, then to the filename
+		image.alt, _ = image.GetStr("description")
+		if image.alt == "" {
+			image.alt, _ = image.GetStr("desc")
+		}
+		if image.alt == "" {
+			image.alt = image.file
+		}
 	}
 
 	// no dimensions. if it's an infobox we can guess it
@@ -70,6 +94,9 @@ func (image *imageBlock) parse(page *Page) {
 		image.width = 270
 	}
 
+	// crop, which needs both dimensions
+	image.parseCrop()
+
 	// no file - this is mandatory
 	if image.file == "" {
 		image.warn(image.getKeyPos("file"), "No file specified for image")
@@ -106,6 +133,11 @@ func (image *imageBlock) parse(page *Page) {
 		// path is file relative to image root (full size image)
 		image.path = page.Opt.Root.Image + "/" + image.file
 
+		// remember that the page uses this image, but in no particular dimensions
+		if _, exist := page.Images[image.file]; !exist {
+			page.Images[image.file] = nil
+		}
+
 	} else if sizeMethod == "server" {
 		// use server-size image sizing
 		//
@@ -131,12 +163,25 @@ func (image *imageBlock) parse(page *Page) {
 		)
 
 		// path is as returned by the function that sizes the image
-		image.path = page.Opt.Image.Sizer(
-			image.file,
-			calcWidth,
-			calcHeight,
-			page,
-		)
+		image.cropped = image.crop && image.width != 0 && image.height != 0 && page.Opt.Image.Cropper != nil
+		if image.cropped {
+			calcWidth, calcHeight = image.width, image.height
+			image.path = page.Opt.Image.Cropper(
+				image.file,
+				calcWidth,
+				calcHeight,
+				image.focusX,
+				image.focusY,
+				page,
+			)
+		} else {
+			image.path = page.Opt.Image.Sizer(
+				image.file,
+				calcWidth,
+				calcHeight,
+				page,
+			)
+		}
 
 		// remember that the page uses this image in these dimensions
 		// consider: should we remember the retina scales? I guess it doesn't really DEPEND on them
@@ -173,6 +218,59 @@ func (image *imagebox) html(page *Page, el element) {
 	image.imageHTML(true, page, el)
 }
 
+// creates the img. when the wiki converts images to other formats, such as
+// webp, it is wrapped in a picture which offers those it converted to
+func (image *imageBlock) createImg(parent element, class, srcset string, page *Page) element {
+	formats := page.Opt.Image.Formats()
+	convert := page.Opt.Image.Converter
+	if u, _ := url.Parse(image.path); image.useJS || u == nil || u.IsAbs() || len(formats) == 0 || convert == nil {
+		return image.cropImg(parent.createChild("img", class))
+	}
+
+	// find the sources first, so that picture is only used if there are any
+	var sources [][2]string
+	for _, format := range formats {
+		if !convert(image.path, format, page) {
+			continue
+		}
+		set := image.path + "." + format
+		if srcset != "" {
+			for _, scale := range image.scales {
+				// a@2x.png 2x -> a@2x.png.webp 2x
+				name, scaleStr := ScaleString(image.path, []int{scale}), strconv.Itoa(scale)+"x"
+				name = strings.TrimSuffix(name, " "+scaleStr)
+				if convert(name, format, page) {
+					set += ", " + name + "." + format + " " + scaleStr
+				}
+			}
+		}
+		sources = append(sources, [2]string{format, set})
+	}
+	if sources == nil {
+		return image.cropImg(parent.createChild("img", class))
+	}
+
+	picture := parent.createChild("picture", "image-picture")
+	for _, src := range sources {
+		source := picture.createChild("source", "")
+		source.setMeta("nonContainer", true)
+		source.setAttr("type", "image/"+src[0])
+		source.setAttr("srcset", src[1])
+	}
+	return image.cropImg(picture.createChild("img", class))
+}
+
+// crops the img in the browser if the wiki did not crop the image file
+func (image *imageBlock) cropImg(img element) element {
+	if image.crop && !image.cropped {
+		img.setStyle("width", strconv.Itoa(image.width)+"px")
+		img.setStyle("height", strconv.Itoa(image.height)+"px")
+		img.setStyle("object-fit", "cover")
+		img.setStyle("object-position", strconv.Itoa(image.focusX)+"% "+strconv.Itoa(image.focusY)+"%")
+	}
+	return img
+}
+
 // image{} or imagebox{} html
 func (image *imageBlock) imageHTML(isBox bool, page *Page, el element) {
 	image.Map.html(page, el)
@@ -248,7 +346,7 @@ func (image *imageBlock) imageHTML(isBox bool, page *Page, el element) {
 		}
 
 		// create img with parent as either a or div
-		img := divOrA.createChild("img", "image-img")
+		img := image.createImg(divOrA, "image-img", srcset, page)
 		img.setMeta("nonContainer", true)
 		img.setAttr("src", image.path)
 		img.setAttr("alt", image.alt)
@@ -257,8 +355,9 @@ func (image *imageBlock) imageHTML(isBox bool, page *Page, el element) {
 		return
 	}
 
-	// create inner box with width restriction
-	inner := el.createChild("div", "imagebox-inner")
+	// create inner box with width restriction. it is the figure, so that
+	// the description can be its caption
+	inner := el.createChild("figure", "imagebox-inner")
 	inner.setStyle("width", strconv.Itoa(image.width)+"px")
 
 	// put in link if there is one
@@ -271,7 +370,7 @@ func (image *imageBlock) imageHTML(isBox bool, page *Page, el element) {
 	}
 
 	// create img with parent as either a or div
-	img := divOrA.createChild("img", "imagebox-img")
+	img := image.createImg(divOrA, "imagebox-img", srcset, page)
 	img.setMeta("nonContainer", true)
 	img.setAttr("src", image.path)
 	img.setAttr("alt", image.alt)
@@ -288,13 +387,94 @@ func (image *imageBlock) imageHTML(isBox bool, page *Page, el element) {
 	if desc == nil {
 		desc, _ = image.Get("desc")
 	}
-	if desc != nil {
-		inner.createChild(
-			"div", "imagebox-description",
+	label := page.blockLabel(image)
+	if desc != nil || label != nil {
+		descEl := inner.createChild(
+			"figcaption", "imagebox-description",
 		).createChild(
 			"div", "imagebox-description-inner",
-		).add(desc)
+		)
+
+		// number if labeled, like Figure 1
+		if label != nil {
+			number := label.String()
+			if desc != nil {
+				number += ":"
+			}
+			descEl.createChild("span", "label-number").addText(number)
+			if desc != nil {
+				descEl.addText(" ")
+			}
+		}
+		if desc != nil {
+			descEl.add(desc)
+		}
+	}
+}
+
+// crop, focus_x, and focus_y. crop is either yes, in which case both width
+// and height are required, or an aspect ratio like 4:3, in which case one is
+// enough
+func (image *imageBlock) parseCrop() {
+	image.crop, image.cropRatio = false, 0
+	image.focusX, image.focusY = 50, 50
+	crop := image.getString("crop")
+	if b, ok := modelParamBool(crop); ok {
+		image.crop = b
+	} else {
+		ratio := strings.SplitN(crop, ":", 2)
+		var w, h float64
+		var err error
+		if len(ratio) == 2 {
+			if w, err = strconv.ParseFloat(strings.TrimSpace(ratio[0]), 64); err == nil {
+				h, err = strconv.ParseFloat(strings.TrimSpace(ratio[1]), 64)
+			}
+		}
+		if len(ratio) != 2 || err != nil || w <= 0 || h <= 0 {
+			image.warn(image.getKeyPos("crop"), "crop: expected yes or an aspect ratio like 4:3")
+			return
+		}
+		image.crop = true
+		image.cropRatio = w / h
+	}
+	if !image.crop {
+		return
+	}
+
+	// fill in a missing dimension from the ratio
+	if image.cropRatio != 0 {
+		if image.width == 0 && image.height != 0 {
+			image.width = int(float64(image.height)*image.cropRatio + 0.5)
+		} else if image.height == 0 && image.width != 0 {
+			image.height = int(float64(image.width)/image.cropRatio + 0.5)
+		}
+	}
+	if image.width == 0 || image.height == 0 {
+		// in a gallery, the dimensions are determined by the gallery
+		if image.parentBlock().blockType() == "gallery" {
+			return
+		}
+		image.warn(image.getKeyPos("crop"), "crop: requires width and height, or one of them and an aspect ratio")
+		image.crop = false
+		return
+	}
+
+	image.focusX = image.getFocus("focus_x")
+	image.focusY = image.getFocus("focus_y")
+}
+
+// fetch a focal point key from 0 to 1, returning it in percent
+func (image *imageBlock) getFocus(key string) int {
+	s := image.getString(key)
+	if s == "" {
+		return 50
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 || f > 1 {
+		image.warn(image.getKeyPos(key), key+": expected a number from 0 to 1")
+		return 50
 	}
+	return int(f*100 + 0.5)
 }
 
 // fetch a string key, producing a warning at the appropriate spot if needed
@@ -0,0 +1,190 @@
+package wikifier
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// includes deeper than this are not expanded
+const includeMaxDepth = 5
+
+// include{} inserts the content of another page, such as a license footer
+// shared by many pages.
+//
+//	include [license] {}
+//
+// With a section name after #, only that section of the page is included.
+//
+//	include [installation#Requirements] {}
+type includeBlock struct {
+	pageName string
+	included *Page
+	section  *secBlock // section to include, or nil for the whole page
+	*parserBlock
+}
+
+func newIncludeBlock(name string, b *parserBlock) block {
+	return &includeBlock{parserBlock: b}
+}
+
+func (ib *includeBlock) parse(page *Page) {
+	name, secName := ib.blockName(), ""
+	if hashIdx := strings.IndexByte(name, '#'); hashIdx != -1 {
+		name, secName = name[:hashIdx], strings.TrimSpace(name[hashIdx+1:])
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		ib.warn(ib.openPos, "include{} requires a page name")
+		return
+	}
+
+	path := findIncludedPage(page.Opt, name)
+	if path == "" {
+		ib.warn(ib.openPos, "Included page '"+name+"' does not exist")
+		return
+	}
+
+	// the pages which are including this one, to detect cycles
+	chain := append([]string(nil), page.includes...)
+	if page.FilePath != "" {
+		chain = append(chain, pageAbs(page.FilePath))
+	}
+	for _, including := range chain {
+		if including == pageAbs(path) {
+			ib.warn(ib.openPos, "Included page '"+name+"' would include itself")
+			return
+		}
+	}
+	if len(chain) > includeMaxDepth {
+		ib.warn(ib.openPos, "Included page '"+name+"' is nested too deeply")
+		return
+	}
+
+	// create page
+	included := NewPagePath(path, name)
+	included.Opt = page.Opt
+	included.Wiki = page.Wiki
+	included.includes = chain
+
+	// share identifiers so they are unique within this page
+	included.elementIDs = page.elementIDs
+	included.headingIDs = page.headingIDs
+
+	// parse the page
+	if err := included.Parse(); err != nil {
+		ib.warn(ib.openPos, "Included page '"+name+"' error: "+err.Error())
+		return
+	}
+
+	// remember the page uses this, so that it is regenerated when the
+	// included page changes or is published
+	nameNE := included.NameNE()
+	page.PageLinks[nameNE] = append(page.PageLinks[nameNE], ib.openPos.Line)
+
+	// drafts and redirects are not displayed, so they are not included
+	if included.Draft() {
+		ib.warn(ib.openPos, "Included page '"+name+"' has not been published")
+		return
+	}
+	if included.Redirect() != "" {
+		ib.warn(ib.openPos, "Included page '"+name+"' is a redirect")
+		return
+	}
+
+	// find the section
+	if secName != "" {
+		ib.section = included.findSection(secName)
+		if ib.section == nil {
+			ib.warn(ib.openPos, "Included page '"+name+"' has no section '"+secName+"'")
+			return
+		}
+	}
+
+	ib.pageName = name
+	ib.included = included
+}
+
+func (ib *includeBlock) html(page *Page, el element) {
+
+	// if there's nothing here, an error occurred in parse()
+	included := ib.included
+	ib.included = nil
+	if included == nil {
+		el.setMeta("noTags", true)
+		return
+	}
+	el.addClass("include-" + PageNameLink(ib.pageName))
+
+	// warnings on the included page, including those produced while
+	// generating it, are reported here
+	defer func() {
+		for _, w := range included.Warnings {
+			ib.warn(ib.openPos, "Included page '"+ib.pageName+"': "+w.Message)
+		}
+	}()
+
+	// just one section
+	if ib.section != nil {
+		ib.section.html(included, ib.section.el())
+		el.addChild(ib.section.el())
+		return
+	}
+
+	// the page title belongs to the included page, not this one
+	for _, child := range included.main.blockContent() {
+		if sec, ok := child.(*secBlock); ok && sec.isIntro && sec.blockName() == "" {
+			sec.title = ""
+		}
+	}
+
+	// add the main block element without its tags
+	mainBlock := included.mainBlock()
+	mainEl := mainBlock.el()
+	mainBlock.html(included, mainEl)
+	mainEl.setMeta("noTags", true)
+	el.addChild(mainEl)
+}
+
+// finds a page for include{} regardless of format or filename case.
+// returns the absolute path, without following symbolic links, or empty
+// string if it does not exist
+func findIncludedPage(opt *PageOpt, name string) string {
+	dir := pageAbs(opt.Dir.Page)
+	pfx, base := filepath.Dir(name), filepath.Base(name)
+	var tryFiles []string
+	for _, ext := range opt.PageExtensions() {
+		tryFiles = append(tryFiles, PageNameLink(base)+ext, strings.ToLower(PageNameLink(base))+ext)
+	}
+	for _, try := range tryFiles {
+		path := filepath.Join(dir, pfx, try)
+		if !strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return ""
+		}
+		if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// finds a section by its title or heading ID
+func (p *Page) findSection(name string) *secBlock {
+	var find func(blk block) *secBlock
+	find = func(blk block) *secBlock {
+		for _, child := range blk.blockContent() {
+			sec, ok := child.(*secBlock)
+			if !ok {
+				continue
+			}
+			if strings.EqualFold(sec.title, name) || sec.headingID == headingAnchor(name) {
+				return sec
+			}
+			if found := find(sec); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	return find(p.main)
+}
@@ -1,10 +1,11 @@
 package wikifier
 
 import (
+	"strconv"
 	"strings"
-)
 
-// TODO: Make list comply to AttributedObject but only accept integer keys
+	"github.com/pkg/errors"
+)
 
 // List represents a list of items.
 // It is a quiki data type as well as the base of many block types.
@@ -123,7 +124,7 @@ func (l *List) handleChar(page *Page, i int, p *listParser, c rune, startedLine
 		// terminates a value
 
 		// store the value
-		valueToStore := fixValuesForStorage(p.values, page, p.pos, true)
+		valueToStore := fixValuesForStorage(p.values, page, p.pos, true, true)
 		l.list = append(l.list, &listEntry{
 			value: valueToStore,               // string, block, or mixed []interface{}
 			typ:   getValueType(valueToStore), // type of value
@@ -200,3 +201,117 @@ func (l *List) mainBlock() block {
 	}
 	return b
 }
+
+// Get fetches an item by its index, starting from 0.
+//
+// The index may be followed by properties of the item (e.g. 0.name).
+//
+// If the index is not a number or is out of range, Get returns (nil, nil).
+func (l *List) Get(key string) (interface{}, error) {
+	index, rest := key, ""
+	if dot := strings.IndexByte(key, '.'); dot != -1 {
+		index, rest = key[:dot], key[dot+1:]
+	}
+	val := l.getOwn(index)
+	if rest == "" || val == nil {
+		return val, nil
+	}
+	obj, err := objValue(val)
+	if err != nil {
+		return nil, errors.Wrap(err, index)
+	}
+	return obj.Get(rest)
+}
+
+// GetStr is like Get except it always returns a string.
+func (l *List) GetStr(key string) (string, error) {
+	val, err := l.Get(key)
+	if err != nil {
+		return "", err
+	}
+	return strValue(val)
+}
+
+// GetBool is like Get except it always returns a boolean.
+func (l *List) GetBool(key string) (bool, error) {
+	val, err := l.Get(key)
+	if err != nil {
+		return false, err
+	}
+	return boolValue(val)
+}
+
+// GetObj is like Get except it always returns an AttributedObject.
+func (l *List) GetObj(key string) (AttributedObject, error) {
+	val, err := l.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return objValue(val)
+}
+
+// GetBlock is like Get except it always returns a block.
+func (l *List) GetBlock(key string) (block, error) {
+	val, err := l.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return blockValue(val)
+}
+
+// Set sets the item at the given index, or appends an item if the index is
+// the length of the list.
+//
+// The index may be followed by properties of the item (e.g. 0.name).
+func (l *List) Set(key string, value interface{}) error {
+	index, rest := key, ""
+	if dot := strings.IndexByte(key, '.'); dot != -1 {
+		index, rest = key[:dot], key[dot+1:]
+	}
+	if i, err := strconv.Atoi(index); err != nil || i < 0 || i > len(l.list) {
+		return errors.New("invalid list index " + index)
+	}
+	if rest == "" {
+		l.setOwn(index, value)
+		return nil
+	}
+	obj, err := l.GetObj(index)
+	if err != nil {
+		return errors.Wrap(err, index)
+	}
+	if obj == nil {
+		obj = NewMap(l.mainBlock())
+		l.setOwn(index, obj)
+	}
+	return obj.Set(rest, value)
+}
+
+func (l *List) setOwn(key string, value interface{}) {
+	i, err := strconv.Atoi(key)
+	if err != nil || i < 0 || i > len(l.list) {
+		return
+	}
+	if i == len(l.list) {
+		l.appendEntry(value, l.openPos)
+		return
+	}
+	l.list[i].value = value
+	l.list[i].typ = getValueType(value)
+}
+
+func (l *List) getOwn(key string) interface{} {
+	i, err := strconv.Atoi(key)
+	if err != nil || i < 0 || i >= len(l.list) {
+		return nil
+	}
+	return l.list[i].value
+}
+
+// appendEntry adds an item to the end of the list
+func (l *List) appendEntry(value interface{}, pos Position) {
+	l.list = append(l.list, &listEntry{
+		value: value,
+		typ:   getValueType(value),
+		pos:   pos,
+	})
+}
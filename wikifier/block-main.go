@@ -70,6 +70,15 @@ func (mb *mainBlock) html(page *Page, el element) {
 		item.html(page, item.el())
 		el.addChild(item.el())
 	}
+
+	// footnotes are listed in references{} or at the end of the page
+	if len(page.footnotes) != 0 {
+		refs := page.references
+		if refs == nil {
+			refs = el.createChild("ol", "references")
+		}
+		page.addReferences(refs)
+	}
 }
 
 func (mb *mainBlock) createSection(page *Page, pcs []posContent) block {
@@ -1,5 +1,10 @@
 package wikifier
 
+import (
+	"regexp"
+	"strings"
+)
+
 var blockAliases = map[string]string{
 	"section":   "sec",
 	"paragraph": "p",
@@ -10,33 +15,57 @@ var blockAliases = map[string]string{
 }
 
 var blockInitializers = map[string]func(name string, b *parserBlock) block{
-	"main":      newMainBlock,
-	"clear":     newClearBlock,
-	"sec":       newSecBlock,
-	"p":         newPBlock,
-	"map":       newMapBlock,
-	"infobox":   newInfobox,
-	"infosec":   newInfosec,
-	"invisible": newInvisibleBlock,
-	"list":      newListBlock,
-	"numlist":   newNumlistBlock,
-	"code":      newCodeBlock,
-	"fmt":       newFmtBlock,
-	"html":      newHTMLBlock,
-	"history":   newHistoryBlock,
-	"style":     newStyleBlock,
-	"imagebox":  newImagebox,
-	"image":     newImageBlock,
-	"model":     newModelBlock,
-	"toc":       newTocBlock,
-	"gallery":   newGalleryBlock,
+	"main":         newMainBlock,
+	"clear":        newClearBlock,
+	"sec":          newSecBlock,
+	"p":            newPBlock,
+	"quote":        newQuoteBlock,
+	"map":          newMapBlock,
+	"infobox":      newInfobox,
+	"infosec":      newInfosec,
+	"invisible":    newInvisibleBlock,
+	"list":         newListBlock,
+	"numlist":      newNumlistBlock,
+	"deflist":      newDeflist,
+	"cliopt":       newCLIOpt,
+	"code":         newCodeBlock,
+	"codecompare":  newCodecompare,
+	"fmt":          newFmtBlock,
+	"html":         newHTMLBlock,
+	"history":      newHistoryBlock,
+	"httpapi":      newHTTPAPI,
+	"style":        newStyleBlock,
+	"imagebox":     newImagebox,
+	"image":        newImageBlock,
+	"include":      newIncludeBlock,
+	"math":         newMathBlock,
+	"diagram":      newDiagramBlock,
+	"plaintext":    newPlaintextBlock,
+	"csv":          newCSVBlock,
+	"tabs":         newTabsBlock,
+	"tab":          newTabBlock,
+	"data":         newDataBlock,
+	"foreach":      newForeachBlock,
+	"events":       newEventsBlock,
+	"contributors": newContributorsBlock,
+	"backlinks":    newBacklinksBlock,
+	"pages-where":  newPagesWhereBlock,
+	"model":        newModelBlock,
+	"references":   newReferencesBlock,
+	"toc":          newTocBlock,
+	"gallery":      newGalleryBlock,
+	"table":        newTableBlock,
+	"terminal":     newTerminalBlock,
+	"tr":           newTrBlock,
+	"tc":           newTcBlock,
+	"th":           newThBlock,
 }
 
 func newBlock(blockType, blockName, headingID string, blockClasses []string, parentBlock block, parentCatch catch, pos Position, page *Page) block {
 	if alias, exist := blockAliases[blockType]; exist {
 		blockType = alias
 	}
-	el := newElement("div", blockType)
+	el := newPageElement(page.elementIDs, "div", blockType)
 	for _, class := range blockClasses {
 		el.addClass("!qc-" + class)
 	}
@@ -57,11 +86,16 @@ func newBlock(blockType, blockName, headingID string, blockClasses []string, par
 
 		// multi
 		if b.multi() {
-			underlying.element = newElements(nil)
+			els := newElements(nil)
+			els.ids = page.elementIDs
+			underlying.element = els
 		}
 
 		return b
 	}
+	if fn := customBlockType(blockType); fn != nil {
+		return newCustomBlock(fn, underlying)
+	}
 	return newUnknownBlock(blockName, underlying)
 }
 
@@ -69,3 +103,142 @@ func generateBlock(b block, page *Page) HTML {
 	b.html(page, b.el()) // FIXME: actual page
 	return b.el().generate()
 }
+
+// blockAttr is an HTML attribute specified in a block type,
+// e.g. sec#overview!data-role(summary)
+type blockAttr struct {
+	name, value string
+}
+
+var blockAttrNameRegex = regexp.MustCompile(`![\w\-]+$`)
+var blockAttrClassRegex = regexp.MustCompile(`^[\w\-]+$`)
+
+// given text ending in ')', finds the start of the !attr(value) it closes.
+// returns -1 if the text does not end with an attribute
+func blockAttrStart(text string) int {
+	depth := 0
+	for i := len(text) - 1; i != -1; i-- {
+		switch text[i] {
+		case ')':
+			depth++
+		case '(':
+			depth--
+		}
+		if depth != 0 {
+			continue
+		}
+
+		// found the opening paren. the attribute name must precede it
+		loc := blockAttrNameRegex.FindStringIndex(text[:i])
+		if loc == nil {
+			return -1
+		}
+		return loc[0]
+	}
+	return -1
+}
+
+// separates element attributes from a block type.
+// the block type and any classes are returned, followed by the attributes
+func splitBlockAttrs(blockType string) (string, []blockAttr) {
+	start := strings.IndexAny(blockType, "#!")
+	if start == -1 {
+		return blockType, nil
+	}
+	typ, rest := blockType[:start], blockType[start:]
+
+	var attrs []blockAttr
+	for rest != "" {
+		switch rest[0] {
+
+		// #id
+		case '#':
+			end := strings.IndexAny(rest[1:], "#!.")
+			if end == -1 {
+				end = len(rest) - 1
+			}
+			attrs = append(attrs, blockAttr{"id", rest[1 : end+1]})
+			rest = rest[end+1:]
+
+		// .class - leave it for the class splitter
+		case '.':
+			end := strings.IndexAny(rest[1:], "#!.")
+			if end == -1 {
+				end = len(rest) - 1
+			}
+			typ += rest[:end+1]
+			rest = rest[end+1:]
+
+		// !name(value)
+		case '!':
+			open := strings.IndexByte(rest, '(')
+			depth, end := 0, -1
+			for i := open; i < len(rest); i++ {
+				if rest[i] == '(' {
+					depth++
+				} else if rest[i] == ')' {
+					depth--
+				}
+				if depth == 0 {
+					end = i
+					break
+				}
+			}
+			if open == -1 || end == -1 {
+				return typ, attrs
+			}
+			attrs = append(attrs, blockAttr{rest[1:open], strings.TrimSpace(rest[open+1 : end])})
+			rest = rest[end+1:]
+
+		default:
+			return typ, attrs
+		}
+	}
+
+	return typ, attrs
+}
+
+// applies element attributes to a block's element
+func applyBlockAttrs(b block, attrs []blockAttr, pos Position) {
+	el := b.el()
+	for _, attr := range attrs {
+		switch {
+
+		// element ID
+		case attr.name == "id":
+			el.setAttr("id", attr.value)
+
+		// additional classes, which are not prefixed like .class
+		case attr.name == "class":
+			for _, class := range strings.Fields(attr.value) {
+				if !blockAttrClassRegex.MatchString(class) {
+					b.warn(pos, "Invalid class '"+class+"'")
+					continue
+				}
+				el.addClass("!" + class)
+			}
+
+		// inline styles
+		case attr.name == "style":
+			for _, decl := range strings.Split(attr.value, ";") {
+				split := strings.SplitN(decl, ":", 2)
+				if len(split) != 2 {
+					continue
+				}
+				name, value := strings.TrimSpace(split[0]), strings.TrimSpace(split[1])
+				if strings.ContainsAny(name+value, `"<>{}`) {
+					b.warn(pos, "Invalid style '"+name+"'")
+					continue
+				}
+				el.setStyle(name, value)
+			}
+
+		// data and ARIA attributes
+		case strings.HasPrefix(attr.name, "data-"), strings.HasPrefix(attr.name, "aria-"):
+			el.setAttr(attr.name, attr.value)
+
+		default:
+			b.warn(pos, "Unsupported attribute '"+attr.name+"'")
+		}
+	}
+}
@@ -3,6 +3,7 @@ package wikifier
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -17,6 +18,7 @@ var (
 type Map struct {
 	noFormatValues bool
 	didParse       bool
+	checkedKeys    bool // unknown keys were warned about
 	mapList        []*mapListEntry
 	*parserBlock
 	*variableScope
@@ -28,6 +30,7 @@ type mapListEntry struct {
 	value    interface{}     // string, html, block, or mixed []interface{}
 	typ      valueType       // value type
 	pos      Position        // position where the item started
+	anon     bool            // no key was given
 	metas    map[string]bool // metadata
 }
 
@@ -66,11 +69,11 @@ func NewMap(mb block) *Map {
 		element:      newElement("div", "map"),
 		genericCatch: &genericCatch{},
 	}
-	return &Map{false, false, nil, underlying, newVariableScope()}
+	return &Map{false, false, false, nil, underlying, newVariableScope()}
 }
 
 func newMapBlock(name string, b *parserBlock) block {
-	return &Map{false, false, nil, b, newVariableScope()}
+	return &Map{false, false, false, nil, b, newVariableScope()}
 }
 
 func (m *Map) parse(page *Page) {
@@ -161,6 +164,7 @@ func (m *Map) handleChar(page *Page, i int, p *mapParser, c rune) {
 
 		strKey, isStrKey := p.key.(string)
 		keyTitle := ""
+		anon := false
 
 		// determine key
 		if (isStrKey && strKey == "") || p.key == nil {
@@ -169,6 +173,7 @@ func (m *Map) handleChar(page *Page, i int, p *mapParser, c rune) {
 
 			strKey = "anon_" + strconv.Itoa(i)
 			p.key = strKey
+			anon = true
 			// no keyTitle
 
 		} else if !p.inValue {
@@ -184,6 +189,7 @@ func (m *Map) handleChar(page *Page, i int, p *mapParser, c rune) {
 			p.values = append(p.values, p.key)
 			strKey = "anon_" + strconv.Itoa(i)
 			p.key = strKey
+			anon = true
 
 			// no keyTitle
 
@@ -207,7 +213,7 @@ func (m *Map) handleChar(page *Page, i int, p *mapParser, c rune) {
 		// fix the value
 		// this returns either a string, block, HTML, or []interface{} combination
 		// strings next to each other are merged; empty strings are removed
-		valueToStore := fixValuesForStorage(p.values, page, p.pos, !m.noFormatValues)
+		valueToStore := fixValuesForStorage(p.values, page, p.pos, !m.noFormatValues, false)
 
 		// if this key exists, rename it to the next available <key>_key_<n>
 		for exist, err := m.Get(strKey); exist != nil && err != nil; {
@@ -239,6 +245,7 @@ func (m *Map) handleChar(page *Page, i int, p *mapParser, c rune) {
 			typ:      getValueType(valueToStore), // type of value
 			key:      strKey,                     // actual underlying key
 			pos:      p.startPos,                 // position where the item started
+			anon:     anon,                       // no key was given
 		})
 
 		// check for warnings once more
@@ -319,6 +326,68 @@ func (m *Map) getEntry(key string) *mapListEntry {
 	return nil
 }
 
+// replaceEntry sets the value of a key, including that of its entry if it was
+// given in quiki source
+func (m *Map) replaceEntry(key string, value interface{}) {
+	m.setOwn(key, value)
+	if entry := m.getEntry(key); entry != nil {
+		entry.value = value
+		entry.typ = getValueType(value)
+	}
+}
+
+// removeEntry removes a key and its value from the map
+func (m *Map) removeEntry(key string) {
+	delete(m.vars, key)
+	for i, entry := range m.mapList {
+		if entry.key == key {
+			m.mapList = append(m.mapList[:i], m.mapList[i+1:]...)
+			break
+		}
+	}
+}
+
+// appendEntry adds a value to the end of the map, normalizing the key as in
+// quiki source
+func (m *Map) appendEntry(keyTitle string, value interface{}, pos Position) {
+	key := keyNormalizer.ReplaceAllString(strings.TrimSpace(keyTitle), "_")
+	m.setOwn(key, value)
+	m.mapList = append(m.mapList, &mapListEntry{
+		keyTitle: keyTitle,
+		key:      key,
+		value:    value,
+		typ:      getValueType(value),
+		pos:      pos,
+	})
+}
+
+// warnUnknownKeys produces a warning for each key other than those given,
+// for blocks based on Map which accept only certain keys.
+//
+// Blocks in variables are parsed more than once, but the warnings are only
+// produced the first time.
+func (m *Map) warnUnknownKeys(known ...string) {
+	if m.checkedKeys {
+		return
+	}
+	m.checkedKeys = true
+	for _, entry := range m.mapList {
+		if entry.anon {
+			continue
+		}
+		found := false
+		for _, key := range known {
+			if entry.key == key {
+				found = true
+				break
+			}
+		}
+		if !found {
+			m.warn(entry.pos, "Unknown key '"+entry.keyTitle+"' in "+m.blockType()+"{} ignored")
+		}
+	}
+}
+
 // getKeyPos returns the position where a key started.
 // If the key doesn't exist, it returns the position where the map started.
 func (m *Map) getKeyPos(key string) Position {
@@ -383,7 +452,8 @@ func (m *Map) Map() map[string]interface{} {
 	return m.vars
 }
 
-// Keys returns a string of actual underlying map keys.
+// Keys returns a string of actual underlying map keys, sorted
+// so that the order is consistent.
 func (m *Map) Keys() []string {
 	keys := make([]string, len(m.vars))
 	i := 0
@@ -391,6 +461,7 @@ func (m *Map) Keys() []string {
 		keys[i] = key
 		i++
 	}
+	sort.Strings(keys)
 	return keys
 }
 
@@ -0,0 +1,124 @@
+package wikifier
+
+import (
+	"bytes"
+	"context"
+	htmlfmt "html"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// math{} displays an equation written in TeX. Like code{}, the contents is
+// not formatted, so use a brace escape.
+//
+//	math {{
+//	    \int_0^1 x^2 \, dx = \frac{1}{3}
+//	}}
+//
+// Equations within text use [math]...[/math].
+type mathBlock struct {
+	*parserBlock
+}
+
+var mathBraceReplacer = strings.NewReplacer(`\{`, "{", `\}`, "}")
+
+// how long the math renderer command may run for each equation
+const mathCommandTimeout = 10 * time.Second
+
+func newMathBlock(name string, b *parserBlock) block {
+	return &mathBlock{parserBlock: b}
+}
+
+func (mb *mathBlock) html(page *Page, el element) {
+	el.setTag("div")
+
+	tex := ""
+	for _, piece := range mb.textContent() {
+		tex += piece
+	}
+	tex = strings.TrimSpace(tex)
+	if tex == "" {
+		mb.warn(mb.openPosition(), "math{} is empty")
+		el.setMeta("noTags", true)
+		return
+	}
+
+	h, rendered := page.renderMath(tex, true, mb.openPosition())
+	if rendered {
+		el.addClass("math-rendered")
+	}
+	el.addHTML(h)
+
+	// number if labeled, like (1)
+	if label := page.blockLabel(mb); label != nil {
+		el.createChild("span", "label-number").addText("(" + strconv.Itoa(label.n) + ")")
+	}
+}
+
+// formats an equation from math{} or [math]. if a renderer is configured, the
+// result is its HTML; otherwise it is the escaped TeX within the delimiters
+// recognized by KaTeX and MathJax. rendered is true if it was rendered
+func (p *Page) renderMath(tex string, display bool, pos Position) (h HTML, rendered bool) {
+	if p.Opt != nil && (p.Opt.Page.Math.Render != nil || p.Opt.Page.Math.Command != "") {
+		render := p.Opt.Page.Math.Render
+		if render == nil {
+			render = mathCommandRenderer(p.Opt.Page.Math.Command)
+		}
+		h, err := render(tex, display)
+		if err == nil {
+			return h, true
+		}
+		p.warn(pos, "Math rendering failed: "+err.Error())
+	}
+
+	escaped := htmlfmt.EscapeString(tex)
+	if display {
+		return HTML(`\[` + escaped + `\]`), false
+	}
+	return HTML(`\(` + escaped + `\)`), false
+}
+
+// returns a math renderer which runs a program, such as the katex command
+// line tool, with the TeX on stdin. --display-mode is added for math{}
+func mathCommandRenderer(command string) func(tex string, display bool) (HTML, error) {
+	return func(tex string, display bool) (HTML, error) {
+		args := strings.Fields(command)
+		if len(args) == 0 {
+			return "", errors.New("no command")
+		}
+		if display {
+			args = append(args, "--display-mode")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), mathCommandTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Stdin = strings.NewReader(tex)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		if err := cmd.Run(); err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return "", errors.New("timed out after " + mathCommandTimeout.String())
+			}
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return "", errors.New(msg)
+			}
+			return "", err
+		}
+		return HTML(strings.TrimSpace(stdout.String())), nil
+	}
+}
+
+// inline [math]. braces are escaped in text, so \{ and \} are TeX groups
+func (p *Page) inlineMath(tex string, pos Position) HTML {
+	tex = strings.TrimSpace(mathBraceReplacer.Replace(tex))
+	h, rendered := p.renderMath(tex, false, pos)
+	class := "q-math"
+	if rendered {
+		class += " q-math-rendered"
+	}
+	return HTML(`<span class="`+class+`">`) + h + HTML("</span>")
+}
@@ -2,10 +2,26 @@ package wikifier
 
 import "path/filepath"
 
+// a block given as a model option. it is generated just once, so that it can
+// be placed within the model
+type modelOptionBlock struct {
+	done bool
+	block
+}
+
+// content given to a model without keys, which the model places with
+// {@content}. it is generated just once, on the page using the model
+type modelContent struct {
+	done    bool
+	entries []*mapListEntry
+	*parserBlock
+}
+
 type modelBlock struct {
 	modelName   string
 	model       *Page
-	includeTags bool // @model.tags - wrap in HTML tags
+	includeTags bool  // @model.tags - wrap in HTML tags
+	body        block // @content
 	*Map
 }
 
@@ -17,6 +33,9 @@ func newModelBlock(name string, b *parserBlock) block {
 func (mb *modelBlock) parse(page *Page) {
 	mb.Map.parse(page)
 
+	// values without keys are the content, not options
+	mb.body = mb.takeContent(page)
+
 	// remember that the page uses this model
 	name := mb.blockName()
 	file := ModelName(name)
@@ -30,8 +49,17 @@ func (mb *modelBlock) parse(page *Page) {
 	// copy wiki opt from this page
 	model.Opt = page.Opt
 
+	// share element identifiers so they are unique within this page
+	model.elementIDs = page.elementIDs
+
 	// assign the underlying Map of the model{} block to @m
 	model.Set("m", mb.Map)
+	model.Set("content", mb.body)
+
+	// wiki variables such as @site.product are available in models too
+	if site, _ := page.GetObj("site"); site != nil {
+		model.Set("site", site)
+	}
 
 	// check if it exists before anything else
 	if !model.Exists() {
@@ -39,6 +67,19 @@ func (mb *modelBlock) parse(page *Page) {
 		return
 	}
 
+	// check the options against the parameters declared by the model. this
+	// is done before parsing so that defaults are available to it
+	mb.checkParams(page, path)
+
+	// blocks given as options are generated once on this page, before the
+	// model places them with {@m.name}
+	for _, key := range mb.Map.OrderedKeys() {
+		blk, ok := mb.Map.getOwn(key).(block)
+		if _, obj := blk.(AttributedObject); ok && !obj {
+			mb.Map.replaceEntry(key, &modelOptionBlock{block: blk})
+		}
+	}
+
 	// parse the page
 	if err := model.Parse(); err != nil {
 		mb.warn(mb.openPos, "Model $"+name+"{} error: "+err.Error())
@@ -55,9 +96,140 @@ func (mb *modelBlock) parse(page *Page) {
 	page.Models[file] = model.modelInfo()
 }
 
+// reads the parameters declared in @model.params, if any, and checks the
+// options against them, setting defaults for those which are missing.
+func (mb *modelBlock) checkParams(page *Page, path string) {
+	name := mb.blockName()
+	vars := NewPage(path)
+	vars.name = name
+	vars.model = true
+	vars.Opt = page.Opt
+	vars.VarsOnly = true
+	vars.Set("m", NewMap(nil))
+
+	// errors are reported when the model is parsed. @model.params usually
+	// comes first, so it is available regardless
+	vars.Parse()
+
+	params, warnings := vars.modelParams()
+	for _, warning := range warnings {
+		mb.warn(mb.openPos, "Model $"+name+"{}: "+warning)
+	}
+	if params == nil {
+		return
+	}
+
+	// unknown options
+	declared := make(map[string]bool, len(params))
+	for _, param := range params {
+		declared[param.Name] = true
+	}
+	for _, key := range mb.Map.OrderedKeys() {
+		if !declared[key] {
+			mb.warn(mb.Map.getKeyPos(key), "Model $"+name+"{}: unknown parameter '"+key+"'")
+		}
+	}
+
+	// missing options and those of the wrong type. missing blocks and those
+	// which are not blocks are empty, so that {@m.name} is not an error
+	for _, param := range params {
+		val := mb.Map.getOwn(param.Name)
+		problem := ""
+		if val != nil {
+			problem = param.check(val)
+		}
+		switch {
+		case problem != "":
+			mb.warn(mb.Map.getKeyPos(param.Name), "Model $"+name+"{}: parameter '"+param.Name+"' "+problem)
+			if param.Type == "block" {
+				mb.Map.replaceEntry(param.Name, mb.emptyBlock(page))
+			}
+		case val != nil:
+			// booleans are converted for conditionals in the model, but
+			// are displayed as given
+			mb.Map.setOwn(param.Name, param.value(val))
+		case param.Required:
+			mb.warn(mb.openPos, "Model $"+name+"{}: missing parameter '"+param.Name+"'")
+		case param.Type == "block" && param.Default == "":
+			mb.Map.setOwn(param.Name, mb.emptyBlock(page))
+		default:
+			if def := param.defaultValue(); def != nil {
+				mb.Map.setOwn(param.Name, def)
+			}
+		}
+	}
+}
+
+// a block which displays nothing
+func (mb *modelBlock) emptyBlock(page *Page) block {
+	return newBlock("invisible", "", "", nil, mb, mb, mb.openPos, page)
+}
+
+// removes the values without keys from the options, returning a block
+// which displays them, or an empty block if there are none
+func (mb *modelBlock) takeContent(page *Page) block {
+	var entries []*mapListEntry
+	for _, entry := range append([]*mapListEntry(nil), mb.Map.mapList...) {
+		if entry.anon {
+			entries = append(entries, entry)
+			mb.Map.removeEntry(entry.key)
+		}
+	}
+	empty := mb.emptyBlock(page)
+	if entries == nil {
+		return empty
+	}
+	return &modelContent{entries: entries, parserBlock: empty.(*invisibleBlock).parserBlock}
+}
+
+func (c *modelContent) html(page *Page, el element) {
+	if c.done {
+		return
+	}
+	c.done = true
+	el.setMeta("noTags", true)
+	for _, entry := range c.entries {
+		c.addValue(page, el, entry.value, entry.pos)
+	}
+}
+
+// text is displayed in paragraphs, and blocks as they are
+func (c *modelContent) addValue(page *Page, el element, value interface{}, pos Position) {
+	switch v := value.(type) {
+	case string:
+		p := newBlock("p", "", "", nil, c, c, pos, page)
+		p.appendContent([]posContent{{v, pos}}, pos)
+		p.parse(page)
+		p.html(page, p.el())
+		el.addChild(p.el())
+	case HTML:
+		el.createChild("p", "p").addHTML(v)
+	case block:
+		v.html(page, v.el())
+		el.addChild(v.el())
+	case []interface{}:
+		for _, item := range v {
+			c.addValue(page, el, item, pos)
+		}
+	}
+}
+
+func (ob *modelOptionBlock) html(page *Page, el element) {
+	if ob.done {
+		return
+	}
+	ob.done = true
+	ob.block.html(page, el)
+}
+
 func (mb *modelBlock) html(page *Page, mbEl element) {
 	mb.Map.html(page, mbEl)
 
+	// the content is generated on this page, before the model places it
+	if mb.body != nil {
+		mb.body.html(page, mb.body.el())
+	}
+
 	// my $model      = $block->{model} or return;
 	// my $main_block = $model->{wikifier}{main_block} or return;
 
@@ -0,0 +1,122 @@
+package wikifier
+
+import (
+	"html"
+	"strconv"
+	"strings"
+)
+
+// pages-where{} lists the pages matching a query, by title.
+//
+//	sec [Latest guides] {
+//	    pages-where {
+//	        category: howto;
+//	        sort: modified;
+//	        limit: 10;
+//	    }
+//	}
+//
+// The query is evaluated each time the page is generated against the page
+// metadata the wiki records, so the list is empty outside of a wiki.
+type pagesWhereBlock struct {
+	*Map
+}
+
+// A PageQuery selects the pages listed by pages-where{}.
+type PageQuery struct {
+	Category string `json:"category,omitempty"` // category the pages belong to
+	Prefix   string `json:"prefix,omitempty"`   // directory the pages are in, such as guides/
+	Author   string `json:"author,omitempty"`   // author of the pages, as in @page.author
+	Sort     string `json:"sort,omitempty"`     // title, author, created, or modified
+	Limit    int    `json:"limit,omitempty"`    // most pages to list, or 0 for all
+}
+
+// A PageQueryResult is a query of a pages-where{} block with the pages it
+// listed.
+type PageQueryResult struct {
+	Query PageQuery
+	Pages []PageInfo
+}
+
+func newPagesWhereBlock(name string, b *parserBlock) block {
+	return &pagesWhereBlock{newMapBlock("", b).(*Map)}
+}
+
+func (pw *pagesWhereBlock) parse(page *Page) {
+	pw.Map.parse(page)
+	pw.warnUnknownKeys("category", "prefix", "author", "sort", "limit")
+}
+
+func (pw *pagesWhereBlock) html(page *Page, el element) {
+	pw.Map.html(page, nil)
+	el.setTag("ul")
+
+	// build the query
+	var q PageQuery
+	for key, ptr := range map[string]*string{
+		"category": &q.Category,
+		"prefix":   &q.Prefix,
+		"author":   &q.Author,
+		"sort":     &q.Sort,
+	} {
+		str, err := pw.GetStr(key)
+		if err != nil {
+			pw.warn(pw.getKeyPos(key), "pages-where{} "+key+" must be text")
+		}
+		*ptr = strings.TrimSpace(html.UnescapeString(str))
+	}
+	switch q.Sort {
+	case "", "title", "author", "created", "modified":
+	default:
+		pw.warn(pw.getKeyPos("sort"), "pages-where{} sort must be one of title, author, created, or modified")
+		q.Sort = ""
+	}
+	if str, _ := pw.GetStr("limit"); str != "" {
+		limit, err := strconv.Atoi(strings.TrimSpace(str))
+		if err != nil || limit < 0 {
+			pw.warn(pw.getKeyPos("limit"), "pages-where{} limit must be a non-negative integer")
+			limit = 0
+		}
+		q.Limit = limit
+	}
+	if q.Category == "" && q.Prefix == "" && q.Author == "" {
+		pw.warn(pw.openPosition(), "pages-where{} requires category, prefix, or author")
+		el.setMeta("noTags", true)
+		return
+	}
+
+	pages := page.findPagesWhere(q)
+	if len(pages) == 0 {
+		el.setMeta("noTags", true)
+		return
+	}
+	for _, info := range pages {
+		title := info.FmtTitle
+		if title == "" {
+			title = HTML(html.EscapeString(info.FileNE))
+		}
+		a := el.createChild("li", "pages-where-page").createChild("a", "link-internal")
+		a.setAttr("href", page.Opt.Root.Page+"/"+info.FileNE)
+		a.addHTML(title)
+	}
+}
+
+// finds the pages matching a query, for pages-where{}
+func (p *Page) findPagesWhere(q PageQuery) []PageInfo {
+	if p.Opt == nil || p.Opt.Page.Query == nil {
+		return nil
+	}
+	pages := p.Opt.Page.Query(p, q)
+	if pages == nil {
+		pages = []PageInfo{}
+	}
+	p.queries = append(p.queries, PageQueryResult{Query: q, Pages: pages})
+	return pages
+}
+
+// Queries returns the queries of the pages-where{} blocks on the page, with
+// the pages each listed. It is nil if the page has no pages-where{} or is
+// not in a wiki. Queries should be called after HTML.
+func (p *Page) Queries() []PageQueryResult {
+	return p.queries
+}
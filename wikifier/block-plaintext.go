@@ -0,0 +1,80 @@
+package wikifier
+
+import (
+	htmlfmt "html"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// plaintext{} displays preformatted text, such as a changelog or license.
+// Brackets, braces, and backslashes must be escaped as in other text, but
+// the text is not otherwise formatted.
+//
+//	plaintext [lines] {
+//	    Version 1.1
+//	    * Fixed \[math\] in footnotes
+//	}
+//
+// Pages from .txt and .log files are translated to a single plaintext{}
+// with the source option, which applies the page.plaintext options.
+type plaintextBlock struct {
+	*parserBlock
+}
+
+var plaintextUnescaper = strings.NewReplacer(`\\`, `\`, `\[`, `[`, `\]`, `]`, `\ `, ` `)
+
+func newPlaintextBlock(name string, b *parserBlock) block {
+	return &plaintextBlock{parserBlock: b}
+}
+
+func (pb *plaintextBlock) html(page *Page, el element) {
+	el.setTag("div")
+
+	// options
+	lines, download := false, false
+	for _, opt := range strings.Split(pb.blockName(), ",") {
+		switch opt = strings.TrimSpace(opt); opt {
+		case "":
+		case "lines":
+			lines = true
+		case "source":
+			lines = lines || page.Opt.Page.Plaintext.LineNumbers
+			download = page.Opt.Page.Plaintext.Download
+		default:
+			pb.warn(pb.openPosition(), "Unknown plaintext{} option '"+opt+"'")
+		}
+	}
+
+	text := ""
+	for _, piece := range pb.textContent() {
+		text += piece
+	}
+	text = strings.TrimRight(plaintextUnescaper.Replace(text), "\n")
+
+	// link to the source file, if it is in the page directory
+	if download && !page.External() {
+		name := filepath.ToSlash(page.Name())
+		link := el.createChild("a", "plaintext-download")
+		link.setAttr("href", page.Opt.Root.Page+"/"+name+"?download")
+		link.setAttr("download", path.Base(name))
+		link.addText("Download")
+	}
+
+	pre := el.createChild("pre", "plaintext-text")
+	pre.setMeta("noIndent", true)
+	if !lines {
+		pre.addText(text)
+		return
+	}
+
+	// each line in a span, numbered by CSS so that the numbers are not copied
+	pre.addClass("plaintext-lines")
+	var b strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		b.WriteString(`<span class="q-plaintext-line">`)
+		b.WriteString(htmlfmt.EscapeString(line))
+		b.WriteString("</span>\n")
+	}
+	pre.addHTML(HTML(strings.TrimSuffix(b.String(), "\n")))
+}
@@ -1 +1,44 @@
 package wikifier
+
+import (
+	"net/url"
+	"strings"
+)
+
+// quote{} is a block quotation. The block name is the source, optionally
+// followed by the URL of the quoted document.
+//
+//	quote [Ada Lovelace | https://example.com/notes] { ... }
+type quoteBlock struct {
+	*parserBlock
+}
+
+func newQuoteBlock(name string, b *parserBlock) block {
+	return &quoteBlock{parserBlock: b}
+}
+
+func (quote *quoteBlock) html(page *Page, el element) {
+	el.setTag("figure")
+	source, cite := quote.blockName(), ""
+	if pipe := strings.LastIndexByte(source, '|'); pipe != -1 {
+		source, cite = strings.TrimSpace(source[:pipe]), strings.TrimSpace(source[pipe+1:])
+	}
+
+	// the quotation
+	bq := el.createChild("blockquote", "quote-text")
+	if cite != "" {
+		if u, err := url.Parse(cite); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			bq.setAttr("cite", cite)
+		} else {
+			quote.warn(quote.openPos, "quote{}: invalid source URL '"+cite+"'")
+		}
+	}
+	addParagraphs(page, quote, quote.posContent(), bq)
+
+	// attribution, which belongs outside the blockquote
+	if source != "" {
+		el.createChild("figcaption", "quote-source").
+			createChild("cite", "").
+			addHTML(page.Fmt(source, quote.openPos))
+	}
+}
@@ -0,0 +1,71 @@
+package wikifier
+
+import "strconv"
+
+// references{} lists the footnotes created with [ref]...[/ref].
+//
+//	sec [References] {
+//	    references {}
+//	}
+//
+// If a page has footnotes but no references{}, they are listed at the end of
+// the page.
+type referencesBlock struct {
+	*parserBlock
+}
+
+func newReferencesBlock(name string, b *parserBlock) block {
+	return &referencesBlock{parserBlock: b}
+}
+
+func (rb *referencesBlock) html(page *Page, el element) {
+	el.setTag("ol")
+
+	// the notes are added after the rest of the page is generated, since
+	// footnotes may appear after this block
+	page.references = el
+}
+
+// a footnote created with [ref]...[/ref]
+type footnote struct {
+	key    string // source position and text, so formatting again reuses it
+	html   HTML
+	refID  string // id of the superscript link to the note
+	noteID string // id of the note in the references list
+}
+
+// creates a footnote, returning the superscript link to it. text which is
+// formatted more than once, such as a section title, refers to one note
+func (p *Page) footnoteRef(text string, pos Position) HTML {
+	key := pos.String() + "\x00" + text
+	n := 0
+	for i, note := range p.footnotes {
+		if note.key == key {
+			n = i + 1
+			break
+		}
+	}
+	if n == 0 {
+		n = len(p.footnotes) + 1
+		num := strconv.Itoa(n)
+		p.footnotes = append(p.footnotes, footnote{
+			key:    key,
+			html:   p.fmtText(text, pos),
+			refID:  "qa-" + p.elementIDs.stable("ref", num),
+			noteID: "qa-" + p.elementIDs.stable("note", num),
+		})
+	}
+	note, num := p.footnotes[n-1], strconv.Itoa(n)
+	return HTML(`<sup class="q-ref" id="` + note.refID + `"><a href="#` + note.noteID + `">[` + num + `]</a></sup>`)
+}
+
+// adds the footnotes to a references{} element, each with a link back to
+// where it was referenced
+func (p *Page) addReferences(el element) {
+	for i, note := range p.footnotes {
+		n := strconv.Itoa(i + 1)
+		li := el.createChild("li", "references-note")
+		li.setAttr("id", note.noteID)
+		li.addHTML(HTML(`<a class="q-references-backlink" href="#`+note.refID+`" aria-label="Back to reference `+n+`">^</a> `) + note.html)
+	}
+}
@@ -76,7 +76,7 @@ func (sec *secBlock) parse(page *Page) {
 	// determine heading ID
 	// heading ID
 	if sec.headingID == "" {
-		sec.headingID = PageNameLink(sec.title)
+		sec.headingID = headingAnchor(sec.title)
 	}
 
 	// this must come last so the section order is correct
@@ -114,18 +114,26 @@ func (sec *secBlock) html(page *Page, el element) {
 		h := el.createChild("h"+strconv.Itoa(level), typ)
 		h.setAttr("id", "qa-"+sec.headingID)
 		h.addHTML(sec.fmtTitle)
+
+		// sections with a heading are labeled by it
+		el.setTag("section")
+		el.setAttr("aria-labelledby", "qa-"+sec.headingID)
 	}
 
 	// CONTENT
+	addParagraphs(page, sec, sec.posContent(), el)
+}
 
-	// iterate over content
+// adds content to the element of a block. text is wrapped in paragraphs,
+// which are separated by blank lines
+func addParagraphs(page *Page, parent block, content []posContent, el element) {
 	var contentToAdd []posContent
-	for _, pc := range sec.posContent() {
+	for _, pc := range content {
 		switch item := pc.content.(type) {
 		case block:
 
 			// create a section with the text up to this point
-			sec.createParagraph(page, el, contentToAdd)
+			createParagraph(page, parent, el, contentToAdd)
 			contentToAdd = nil
 
 			// adopt this block as my own
@@ -137,7 +145,7 @@ func (sec *secBlock) html(page *Page, el element) {
 			// if this is an empty line, create a new paragraph
 			item = strings.TrimSpace(item)
 			if item == "" {
-				sec.createParagraph(page, el, contentToAdd)
+				createParagraph(page, parent, el, contentToAdd)
 				contentToAdd = nil
 				continue
 			}
@@ -151,10 +159,10 @@ func (sec *secBlock) html(page *Page, el element) {
 	}
 
 	// add whatever's left
-	sec.createParagraph(page, el, contentToAdd)
+	createParagraph(page, parent, el, contentToAdd)
 }
 
-func (sec *secBlock) createParagraph(page *Page, el element, pcs []posContent) {
+func createParagraph(page *Page, parent block, el element, pcs []posContent) {
 
 	// this can be passed nothing
 	if len(pcs) == 0 {
@@ -162,7 +170,7 @@ func (sec *secBlock) createParagraph(page *Page, el element, pcs []posContent) {
 	}
 
 	// create a paragraph at first text node position
-	p := newBlock("p", "", "", nil, sec, sec, pcs[0].pos, page)
+	p := newBlock("p", "", "", nil, parent, parent, pcs[0].pos, page)
 	p.appendContent(pcs, pcs[0].pos)
 
 	// parse and generate
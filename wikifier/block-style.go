@@ -12,11 +12,19 @@ type styleBlock struct {
 	*Map
 }
 
+// a rule of the page CSS. applyTo holds quiki selectors, such as p.note,
+// which are converted to the classes quiki uses; selectors holds CSS
+// selectors added with AddCSS, which are used as-is
 type styleEntry struct {
 	mainID        string
 	applyToParent bool
 	applyTo       [][]string
-	rules         map[string]string
+	selectors     []string
+	rules         []styleRule
+}
+
+type styleRule struct {
+	name, value string
 }
 
 func newStyleBlock(name string, b *parserBlock) block {
@@ -39,12 +47,16 @@ func (sb *styleBlock) parse(page *Page) {
 	//     $rules{ $item->{key_title} } = $item->{value};
 	// }
 
-	rules := make(map[string]string, len(sb.mapList))
+	rules := make([]styleRule, 0, len(sb.mapList))
 	for _, entry := range sb.mapList {
-		if str, ok := entry.value.(string); ok {
-			rules[entry.keyTitle] = str
-		} else {
+		str, ok := entry.value.(string)
+		switch {
+		case !ok:
 			sb.warn(entry.pos, "non-string value to style{}")
+		case !validCSS(entry.keyTitle) || !validCSS(str):
+			sb.warn(entry.pos, "Invalid style{} declaration '"+entry.keyTitle+"'")
+		default:
+			rules = append(rules, styleRule{entry.keyTitle, str})
 		}
 	}
 
@@ -65,6 +77,10 @@ func (sb *styleBlock) parse(page *Page) {
 			//         $matcher =~ s/^\s*//g;
 			//         $matcher =~ s/\s*$//g;
 			matcher = strings.TrimSpace(matcher)
+			if !validCSS(matcher) {
+				sb.warn(sb.openPosition(), "Invalid style{} selector '"+matcher+"'")
+				continue
+			}
 
 			//         # this element.
 			//         if ($matcher eq 'this') {
@@ -109,6 +125,11 @@ func (sb *styleBlock) html(page *Page, el element) {
 	// my %style     = %{ $block->{style} };
 	style := sb.style
 
+	// every selector was invalid
+	if !style.applyToParent && len(style.applyTo) == 0 {
+		return
+	}
+
 	// my $parent_el = $block->parent->element;
 	parentEl := sb.parentBlock().el()
 
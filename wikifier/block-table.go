@@ -0,0 +1,331 @@
+package wikifier
+
+import (
+	"strconv"
+	"strings"
+)
+
+// table{} contains tr{} rows
+type tableBlock struct {
+	align []string // alignment of each column
+	*parserBlock
+}
+
+// tr{} contains tc{} and th{} cells
+type trBlock struct {
+	header, footer bool
+	*parserBlock
+}
+
+// tc{} and th{} contain formatted text and blocks
+type tcBlock struct {
+	header           bool
+	align            string
+	colspan, rowspan int
+	*parserBlock
+}
+
+// a row and the page in which context it is generated, which is that of the
+// item for rows of foreach{}
+type tableRow struct {
+	tr   *trBlock
+	page *Page
+}
+
+func newTableBlock(name string, b *parserBlock) block {
+	return &tableBlock{parserBlock: b}
+}
+
+func newTrBlock(name string, b *parserBlock) block {
+	return &trBlock{parserBlock: b}
+}
+
+func newTcBlock(name string, b *parserBlock) block {
+	return &tcBlock{parserBlock: b}
+}
+
+func newThBlock(name string, b *parserBlock) block {
+	return &tcBlock{header: true, parserBlock: b}
+}
+
+func (t *tableBlock) parse(page *Page) {
+	t.parserBlock.parse(page)
+	checkTableContent(t.parserBlock, "table", "tr", "foreach")
+	for _, opt := range tableOptions(t.parserBlock, "align") {
+		for _, align := range strings.Fields(opt[1]) {
+			if align == "-" {
+				align = ""
+			} else if !isTableAlign(align) {
+				t.warn(t.openPosition(), "Invalid table{} alignment '"+align+"'")
+				align = ""
+			}
+			t.align = append(t.align, align)
+		}
+	}
+}
+
+func (t *tableBlock) html(page *Page, el element) {
+	el.setTag("table")
+
+	// number if labeled, like Table 1
+	if label := page.blockLabel(t); label != nil {
+		el.createChild("caption", "label-number").addText(label.String())
+	}
+
+	// rows of foreach{} are generated in the context of their items
+	var rows []tableRow
+	var foreachBlocks []*foreachBlock
+	for _, child := range t.blockContent() {
+		switch child := child.(type) {
+		case *trBlock:
+			rows = append(rows, tableRow{child, page})
+		case *foreachBlock:
+			rows = append(rows, child.rows()...)
+			foreachBlocks = append(foreachBlocks, child)
+		}
+	}
+
+	// sort the rows. header rows are those marked as such and the leading
+	// rows of only th{} cells
+	var head, body, foot []tableRow
+	for _, row := range rows {
+		switch tr := row.tr; {
+		case tr.footer:
+			foot = append(foot, row)
+		case tr.header, len(body) == 0 && tr.allHeaderCells():
+			head = append(head, row)
+		default:
+			body = append(body, row)
+		}
+	}
+
+	for _, section := range []struct {
+		tag, typ string
+		rows     []tableRow
+	}{
+		{"thead", "table-head", head},
+		{"tbody", "table-body", body},
+		{"tfoot", "table-foot", foot},
+	} {
+		if len(section.rows) == 0 {
+			continue
+		}
+		trs := make([]*trBlock, len(section.rows))
+		for i, row := range section.rows {
+			trs[i] = row.tr
+		}
+		t.alignCells(trs)
+		sectionEl := el.createChild(section.tag, section.typ)
+		for _, row := range section.rows {
+			row.tr.html(row.page, row.tr.el())
+			sectionEl.addChild(row.tr.el())
+		}
+	}
+
+	for _, fb := range foreachBlocks {
+		fb.adoptItems(page)
+	}
+}
+
+// determines the column of each cell, accounting for spans, to apply
+// the column alignment to those without their own
+func (t *tableBlock) alignCells(rows []*trBlock) {
+	spanned := make(map[int]int) // column -> rows remaining
+	for _, tr := range rows {
+		col := 0
+		for _, child := range tr.blockContent() {
+			tc, ok := child.(*tcBlock)
+			if !ok {
+				continue
+			}
+			for spanned[col] > 0 {
+				col++
+			}
+			if tc.align == "" && col < len(t.align) {
+				tc.align = t.align[col]
+			}
+			colspan, rowspan := tc.colspan, tc.rowspan
+			if colspan < 1 {
+				colspan = 1
+			}
+			if rowspan > 1 {
+				for i := col; i < col+colspan; i++ {
+					spanned[i] = rowspan
+				}
+			}
+			col += colspan
+		}
+		for i, n := range spanned {
+			if n > 0 {
+				spanned[i] = n - 1
+			}
+		}
+	}
+}
+
+func (tr *trBlock) parse(page *Page) {
+	tr.parserBlock.parse(page)
+	checkTableContent(tr.parserBlock, "tr", "tc", "th")
+	for _, opt := range tableOptions(tr.parserBlock, "header", "footer") {
+		switch opt[0] {
+		case "header":
+			tr.header = true
+		case "footer":
+			tr.footer = true
+		}
+	}
+	if tr.header && tr.footer {
+		tr.warn(tr.openPosition(), "tr{} cannot be both header and footer")
+		tr.footer = false
+	}
+}
+
+func (tr *trBlock) html(page *Page, el element) {
+	el.setTag("tr")
+	tableChildrenHTML(page, el, tr.blockContent(), "tc", "th")
+}
+
+// true if the row has cells and all of them are th{}
+func (tr *trBlock) allHeaderCells() bool {
+	found := false
+	for _, child := range tr.blockContent() {
+		tc, ok := child.(*tcBlock)
+		if !ok {
+			continue
+		}
+		if !tc.header {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+func (tc *tcBlock) parse(page *Page) {
+	tc.parserBlock.parse(page)
+	for _, opt := range tableOptions(tc.parserBlock, "align", "colspan", "rowspan") {
+		name, value := opt[0], opt[1]
+		switch name {
+		case "align":
+			if !isTableAlign(value) {
+				tc.warn(tc.openPosition(), "Invalid "+tc.blockType()+"{} alignment '"+value+"'")
+				continue
+			}
+			tc.align = value
+		case "colspan", "rowspan":
+			span, err := strconv.Atoi(value)
+			if err != nil || span < 1 {
+				tc.warn(tc.openPosition(), "Invalid "+tc.blockType()+"{} "+name+" '"+value+"'")
+				continue
+			}
+			if name == "colspan" {
+				tc.colspan = span
+			} else {
+				tc.rowspan = span
+			}
+		}
+	}
+}
+
+func (tc *tcBlock) html(page *Page, el element) {
+	if tc.header {
+		el.setTag("th")
+	} else {
+		el.setTag("td")
+	}
+	if tc.colspan > 1 {
+		el.setAttr("colspan", strconv.Itoa(tc.colspan))
+	}
+	if tc.rowspan > 1 {
+		el.setAttr("rowspan", strconv.Itoa(tc.rowspan))
+	}
+	if tc.align != "" {
+		el.addClass("align-" + tc.align)
+	}
+
+	for _, pc := range tc.posContent() {
+		switch item := pc.content.(type) {
+		case block:
+			item.html(page, item.el())
+			el.addChild(item.el())
+
+		case string:
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			el.addHTML(page.fmtText(item, pc.pos))
+
+		default:
+			panic("not sure how to handle this content")
+		}
+	}
+}
+
+// parses options from the block name, such as [colspan=2, align=right].
+// returns name and value pairs, warning about unknown names
+func tableOptions(b *parserBlock, names ...string) [][2]string {
+	var opts [][2]string
+	if b.blockName() == "" {
+		return opts
+	}
+OPTS:
+	for _, opt := range strings.Split(b.blockName(), ",") {
+		opt = strings.TrimSpace(opt)
+		name, value := opt, ""
+		if eq := strings.IndexByte(opt, '='); eq != -1 {
+			name, value = strings.TrimSpace(opt[:eq]), strings.TrimSpace(opt[eq+1:])
+		}
+		if name == "" {
+			continue
+		}
+		for _, known := range names {
+			if name == known {
+				opts = append(opts, [2]string{name, value})
+				continue OPTS
+			}
+		}
+		b.warn(b.openPosition(), "Unknown "+b.blockType()+"{} option '"+name+"'")
+	}
+	return opts
+}
+
+func isTableAlign(align string) bool {
+	return align == "left" || align == "center" || align == "right"
+}
+
+// warns about text and blocks of the wrong type within a table or row
+func checkTableContent(b *parserBlock, typ string, childTypes ...string) {
+	for _, pc := range b.posContent() {
+		switch item := pc.content.(type) {
+		case block:
+			if !isTableChild(item, childTypes) {
+				b.warn(item.openPosition(), item.blockType()+"{} not allowed in "+typ+"{}; ignored")
+			}
+		case string:
+			if strings.TrimSpace(item) != "" {
+				b.warn(pc.pos, "Stray text in "+typ+"{}; ignored")
+			}
+		}
+	}
+}
+
+// adds the HTML of each child block of an allowed type
+func tableChildrenHTML(page *Page, el element, children []block, childTypes ...string) {
+	for _, child := range children {
+		if !isTableChild(child, childTypes) {
+			continue
+		}
+		child.html(page, child.el())
+		el.addChild(child.el())
+	}
+}
+
+func isTableChild(b block, childTypes []string) bool {
+	for _, typ := range childTypes {
+		if b.blockType() == typ {
+			return true
+		}
+	}
+	return false
+}
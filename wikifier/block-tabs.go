@@ -0,0 +1,100 @@
+package wikifier
+
+import "strconv"
+
+// tabs{} contains tab{} blocks, one of which is shown at a time.
+//
+//	tabs {
+//	    tab [Linux] {
+//	        Install with your package manager.
+//	    }
+//	    tab [macOS] {
+//	        Install with Homebrew.
+//	    }
+//	}
+//
+// Tabs are switched with radio buttons and CSS which are included in the
+// page, so they work without JavaScript and with any template.
+type tabsBlock struct {
+	*parserBlock
+}
+
+// tab{} contains formatted text and blocks, like sec{} without a heading
+type tabBlock struct {
+	*secBlock
+}
+
+// styles for tabs{}, added to the page once
+const tabsCSS = `.q-tabs { display: flex; flex-wrap: wrap; margin: 16px 0; }
+.q-tabs > .q-tabs-radio { position: absolute; opacity: 0; }
+.q-tabs > .q-tabs-label { order: 1; padding: 6px 12px; cursor: pointer; border-bottom: 2px solid transparent; }
+.q-tabs > .q-tabs-radio:checked + .q-tabs-label { border-bottom-color: currentColor; font-weight: bold; }
+.q-tabs > .q-tabs-radio:focus-visible + .q-tabs-label { outline: 1px dotted; }
+.q-tabs > .q-tab { order: 2; width: 100%; display: none; }
+.q-tabs > .q-tabs-radio:checked + .q-tabs-label + .q-tab { display: block; }`
+
+func newTabsBlock(name string, b *parserBlock) block {
+	return &tabsBlock{parserBlock: b}
+}
+
+func newTabBlock(name string, b *parserBlock) block {
+	return &tabBlock{newSecBlock(name, b).(*secBlock)}
+}
+
+func (tb *tabsBlock) parse(page *Page) {
+	tb.parserBlock.parse(page)
+	checkTableContent(tb.parserBlock, "tabs", "tab")
+}
+
+func (tb *tabsBlock) html(page *Page, el element) {
+	el.setTag("div")
+
+	var tabs []*tabBlock
+	for _, child := range tb.blockContent() {
+		if tab, ok := child.(*tabBlock); ok {
+			tabs = append(tabs, tab)
+		}
+	}
+	if len(tabs) == 0 {
+		tb.warn(tb.openPosition(), "tabs{} has no tab{}")
+		el.setMeta("noTags", true)
+		return
+	}
+
+	if !page.tabsStyles {
+		page.staticStyles = append(page.staticStyles, tabsCSS)
+		page.tabsStyles = true
+	}
+
+	// each tab is a radio button, its label, and its content. they are
+	// identified by title, so links to a tab persist when others change
+	group := "qa-" + el.id()
+	for i, tab := range tabs {
+		id := "qa-" + tab.el().id()
+
+		radio := el.createChild("input", "tabs-radio")
+		radio.setMeta("nonContainer", true)
+		radio.setAttr("type", "radio")
+		radio.setAttr("name", group)
+		radio.setAttr("id", id)
+		radio.setBoolAttr("checked", i == 0)
+
+		title := tab.blockName()
+		if title == "" {
+			tab.warn(tab.openPosition(), "tab{} has no title")
+			title = "Tab " + strconv.Itoa(i+1)
+		}
+		label := el.createChild("label", "tabs-label")
+		label.setAttr("for", id)
+		label.addHTML(page.Fmt(title, tab.openPosition()))
+
+		tab.html(page, tab.el())
+		el.addChild(tab.el())
+	}
+}
+
+// tab{} is parsed as a block rather than a section, so it does not affect
+// the numbering or levels of sections
+func (tab *tabBlock) parse(page *Page) {
+	tab.parserBlock.parse(page)
+}
@@ -0,0 +1,93 @@
+package wikifier
+
+import (
+	htmlfmt "html"
+	"regexp"
+	"strings"
+)
+
+// terminal{} displays a shell session, distinguishing commands from output.
+//
+//	terminal {{
+//	    $ echo hello
+//	    hello
+//	}}
+//
+// Lines beginning with a prompt are commands, and other lines are output.
+// Prompts are detected automatically, or the block name can specify the
+// exact prompt, such as terminal [mysql>].
+type terminalBlock struct {
+	*parserBlock
+}
+
+// prompts recognized by default: $, #, %, or > optionally preceded by
+// user@host:path, PowerShell's PS path>, and Python's >>>
+var terminalPromptRegex = regexp.MustCompile(`^(?:(?:[\w.-]+@[\w.-]+(?::\S*)?\s?)?[$#%>]|PS [^>]*>|>>>)(?: |$)`)
+
+func newTerminalBlock(name string, b *parserBlock) block {
+	return &terminalBlock{parserBlock: b}
+}
+
+func (tb *terminalBlock) html(page *Page, el element) {
+	el.setTag("pre")
+	el.setMeta("noIndent", true)
+
+	text := ""
+	for _, piece := range tb.textContent() {
+		text += piece
+	}
+	text = strings.TrimRight(text, " \t\r\n")
+
+	var b strings.Builder
+	var commands []string
+	continued := false
+	for _, line := range strings.Split(text, "\n") {
+		prompt, command := tb.splitPrompt(line)
+
+		// a command ending in \ continues on the next line
+		if continued {
+			prompt, command = "", line
+		} else if prompt == "" {
+			b.WriteString(`<span class="q-terminal-output">` + htmlfmt.EscapeString(line) + "</span>\n")
+			continue
+		}
+		continued = strings.HasSuffix(command, `\`)
+
+		b.WriteString(`<span class="q-terminal-command">`)
+		if prompt != "" {
+			b.WriteString(`<span class="q-terminal-prompt">` + htmlfmt.EscapeString(prompt) + `</span>`)
+		}
+		b.WriteString(`<span class="q-terminal-input">` + htmlfmt.EscapeString(command) + "</span></span>\n")
+
+		if prompt == "" && len(commands) != 0 {
+			commands[len(commands)-1] += "\n" + command
+		} else {
+			commands = append(commands, command)
+		}
+	}
+
+	// the copy button of quiki.js copies just the commands
+	el.setAttr("data-copy", "commands")
+	el.setAttr("data-commands", strings.Join(commands, "\n"))
+	el.addHTML(HTML(b.String()))
+}
+
+// separates the prompt, including the space after it, from a command.
+// if the line is not a command, the prompt is empty
+func (tb *terminalBlock) splitPrompt(line string) (prompt, command string) {
+	if custom := tb.blockName(); custom != "" {
+		if !strings.HasPrefix(line, custom) {
+			return "", line
+		}
+		prompt = custom
+		if strings.HasPrefix(line[len(prompt):], " ") {
+			prompt += " "
+		}
+		return prompt, line[len(prompt):]
+	}
+	loc := terminalPromptRegex.FindStringIndex(line)
+	if loc == nil {
+		return "", line
+	}
+	return line[:loc[1]], line[loc[1]:]
+}
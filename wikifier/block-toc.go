@@ -1,22 +1,69 @@
 package wikifier
 
+import (
+	"strconv"
+	"strings"
+)
+
+// toc{} displays a table of contents of the page's sections. Options may be
+// given in the block name.
+//
+//	toc [depth=2, numbered]
+//
+// depth limits the levels of sections listed, overriding @page.toc.depth,
+// and numbered numbers them like 1.2.3. Sections with the notoc class, such as
+// ~sec.notoc [Title] { ... }, are not listed, nor are their subsections.
 type tocBlock struct {
 	secCount int
+	depth    int  // levels of sections; 0 for all
+	numbered bool // number the sections
 	*parserBlock
 }
 
 func newTocBlock(name string, b *parserBlock) block {
-	return &tocBlock{0, b}
+	return &tocBlock{parserBlock: b}
+}
+
+func (toc *tocBlock) parse(page *Page) {
+	toc.depth = page.tocDepth()
+	for _, opt := range strings.Split(toc.blockName(), ",") {
+		opt = strings.TrimSpace(opt)
+		name, value := opt, ""
+		if eq := strings.IndexByte(opt, '='); eq != -1 {
+			name, value = strings.TrimSpace(opt[:eq]), strings.TrimSpace(opt[eq+1:])
+		}
+		switch name {
+		case "":
+		case "depth":
+			depth, err := strconv.Atoi(value)
+			if err != nil || depth < 0 {
+				toc.warn(toc.openPos, "Invalid toc{} depth '"+value+"'")
+				continue
+			}
+			toc.depth = depth
+		case "numbered":
+			toc.numbered = true
+		default:
+			toc.warn(toc.openPos, "Unknown toc{} option '"+name+"'")
+		}
+	}
+	toc.parserBlock.parse(page)
 }
 
 func (toc *tocBlock) html(page *Page, el element) {
-	el.setTag("ul")
-	el.addHTML(HTML("<li><strong>Contents</strong></li>"))
+	el.setTag("nav")
+	el.setAttr("aria-label", "Contents")
+	if toc.numbered {
+		el.addClass("toc-numbered")
+	}
+	list := el.createChild("ul", "toc-list")
+	list.addHTML(HTML("<li><strong>Contents</strong></li>"))
 
 	// add each top-level section
+	n := 0
 	for _, child := range page.main.blockContent() {
 		if sec, ok := child.(*secBlock); ok {
-			toc.tocAdd(sec, el, page)
+			toc.tocAdd(sec, list, page, 1, "", &n)
 		}
 	}
 
@@ -26,28 +73,44 @@ func (toc *tocBlock) html(page *Page, el element) {
 	}
 }
 
-func (toc *tocBlock) tocAdd(sec *secBlock, addTo element, page *Page) {
+// adds a section at the given depth. prefix is the number of the parent
+// section, and n is the number of sections listed before this one within it
+func (toc *tocBlock) tocAdd(sec *secBlock, addTo element, page *Page, depth int, prefix string, n *int) {
+	if sec.noTOC() {
+		return
+	}
 	toc.secCount++
 
 	// create an item for this section if it has a title and isn't intro
 	var subList element
 	if !sec.isIntro && sec.title != "" {
 		li := addTo.createChild("li", "")
+		if toc.numbered {
+			*n++
+			prefix += strconv.Itoa(*n)
+			li.createChild("span", "toc-number").addText(prefix)
+			prefix += "."
+		}
 		a := li.createChild("a", "link-internal")
 		a.setAttr("href", "#"+sec.headingID)
-		a.addHTML(page.Fmt(sec.title, sec.openPos))
+		a.addHTML(page.tocTitle(sec))
 		addTo = li
+		n = new(int)
+		depth++
 	} else {
 		subList = addTo
 	}
 
 	// create a sub-list for each section underneath
+	if toc.depth != 0 && depth > toc.depth {
+		return
+	}
 	for _, child := range sec.blockContent() {
 		if secChild, ok := child.(*secBlock); ok {
 			if subList == nil {
 				subList = addTo.createChild("ul", "")
 			}
-			toc.tocAdd(secChild, subList, page)
+			toc.tocAdd(secChild, subList, page, depth, prefix, n)
 		}
 	}
 }
@@ -1,6 +1,8 @@
 package wikifier
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"strings"
 )
@@ -78,6 +80,26 @@ func (b *parserBlock) blockName() string {
 
 func (b *parserBlock) close(pos Position) {
 	b.closePos = pos
+
+	// now that the content is known, the element can be given an
+	// identifier which does not depend on the rest of the page
+	if el, ok := b.element.(*genericElement); ok {
+		el.idKey = b.idKey()
+	}
+}
+
+// returns the key for the block's element identifier: its name if it has
+// one, or a hash of its content
+func (b *parserBlock) idKey() string {
+	if b.name != "" {
+		key := strings.ToLower(b.name)
+		key = strings.Trim(elementIDPrefixRegex.ReplaceAllString(key, "-"), "-")
+		if key != "" {
+			return key
+		}
+	}
+	sum := sha256.Sum256([]byte(b.hierarchy()))
+	return hex.EncodeToString(sum[:4])
 }
 
 func (b *parserBlock) closed() bool {
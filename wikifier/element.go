@@ -1,13 +1,112 @@
 package wikifier
 
 import (
+	"bytes"
 	htmlfmt "html"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 )
 
+// identifiers for elements which are not associated with a page
 var identifiers = make(map[string]int)
 
+// elementIDs generates element identifiers which are unique within a page.
+//
+// identifiers are assigned only when an element's ID is first requested, in
+// the order that HTML is generated, so the same source always produces the
+// same identifiers. blocks are identified by their titles or a hash of their
+// content where possible, so that their identifiers, and any anchors using
+// them, do not change when other parts of the page are edited. the
+// page-scoped prefix keeps them distinct when several pages are displayed in
+// the same document.
+type elementIDs struct {
+	prefix string
+	counts map[string]int
+	used   map[string]bool
+
+	// if true, inline styles of elements are moved into the page CSS as
+	// rules matching their identifiers, rather than style attributes.
+	// styled holds the identifiers of elements whose rules were added, as
+	// an element may be written more than once, such as by the table of
+	// contents counting words
+	scopeStyles bool
+	styles      []styleEntry
+	styled      map[string]bool
+}
+
+func newElementIDs(prefix string) *elementIDs {
+	return &elementIDs{
+		prefix: prefix,
+		counts: make(map[string]int),
+		used:   make(map[string]bool),
+		styled: make(map[string]bool),
+	}
+}
+
+// next returns the next identifier for the given element type
+func (ids *elementIDs) next(typ string) string {
+
+	// not associated with a page
+	if ids == nil {
+		identifiers[typ]++
+		return typ + "-" + strconv.Itoa(identifiers[typ])
+	}
+
+	for {
+		ids.counts[typ]++
+		if id := typ + "-" + strconv.Itoa(ids.counts[typ]); !ids.used[id] {
+			return ids.use(id)
+		}
+	}
+}
+
+// stable returns an identifier for the given element type derived from a
+// key, such as a block title. a number is appended if it is already used
+func (ids *elementIDs) stable(typ, key string) string {
+	if ids == nil {
+		return ids.next(typ)
+	}
+	id := typ + "-" + key
+	for n := 2; ids.used[id]; n++ {
+		id = typ + "-" + key + "-" + strconv.Itoa(n)
+	}
+	return ids.use(id)
+}
+
+// marks an identifier as used and adds the page prefix
+func (ids *elementIDs) use(id string) string {
+	ids.used[id] = true
+	if ids.prefix != "" {
+		id = ids.prefix + "-" + id
+	}
+	return id
+}
+
+// moves the inline styles of an element into the page CSS, once
+func (ids *elementIDs) addStyles(el *genericElement) {
+	if ids.styled[el.id()] {
+		return
+	}
+	ids.styled[el.id()] = true
+	names := make([]string, 0, len(el.styles))
+	for name := range el.styles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	rules := make([]styleRule, 0, len(names))
+	for _, name := range names {
+		if value := el.styles[name]; validCSS(name) && validCSS(value) {
+			rules = append(rules, styleRule{name, value})
+		}
+	}
+	ids.styles = append(ids.styles, styleEntry{
+		applyTo: [][]string{{el.id()}},
+		rules:   rules,
+	})
+}
+
 // HTML encapsulates a string to indicate that it is preformatted HTML.
 // It lets quiki's parsers know not to attempt to format it any further.
 type HTML string
@@ -59,12 +158,14 @@ type element interface {
 
 	// html generation
 	generate() HTML
-	generateIndented(indent int) []indentedLine
+	writeIndented(w *htmlWriter, indent int)
 }
 
 type genericElement struct {
 	_tag          string                 // html tag
 	_id           string                 // unique element identifier
+	idKey         string                 // key for a stable identifier, if any
+	ids           *elementIDs            // identifier generator
 	attrs         map[string]interface{} // html attributes
 	styles        map[string]string      // inline styles
 	metas         map[string]bool        // metadata
@@ -77,10 +178,14 @@ type genericElement struct {
 }
 
 func newElement(tag, typ string) element {
-	identifiers[typ]++
+	return newPageElement(nil, tag, typ)
+}
+
+// create an element whose identifier is unique within a page
+func newPageElement(ids *elementIDs, tag, typ string) element {
 	return &genericElement{
 		_tag:   tag,
-		_id:    typ + "-" + strconv.Itoa(identifiers[typ]),
+		ids:    ids,
 		typ:    typ,
 		attrs:  make(map[string]interface{}),
 		styles: make(map[string]string),
@@ -88,8 +193,13 @@ func newElement(tag, typ string) element {
 	}
 }
 
-// fetch ID
+// fetch ID, assigning one if necessary
 func (el *genericElement) id() string {
+	if el._id == "" && el.idKey != "" {
+		el._id = el.ids.stable(el.typ, el.idKey)
+	} else if el._id == "" {
+		el._id = el.ids.next(el.typ)
+	}
 	return el._id
 }
 
@@ -223,7 +333,7 @@ func (el *genericElement) addChild(child element) {
 
 // create a child element and add it
 func (el *genericElement) createChild(tag, typ string) element {
-	child := newElement(tag, typ)
+	child := newPageElement(el.ids, tag, typ)
 	el.addChild(child)
 	return child
 }
@@ -269,90 +379,118 @@ func (el *genericElement) generate() HTML {
 		return el.cachedHTML
 	}
 
-	el.cachedHTML = generateIndentedLines(el.generateIndented(0))
+	el.cachedHTML = generateHTML(el)
 	return el.cachedHTML
 }
 
-type indentedLine struct {
-	line   string
-	indent int
-}
-
-func (el *genericElement) generateIndented(indent int) []indentedLine {
-	var lines []indentedLine
-
+func (el *genericElement) writeIndented(w *htmlWriter, indent int) {
 	if el.hidden() {
-		return nil
+		return
 	}
 
 	// tags
 	if !el.meta("noTags") {
-		openingTag := "<" + el._tag
+		w.indent(indent)
+		w.WriteByte('<')
+		w.WriteString(el._tag)
+
+		// styles moved into the page CSS are matched by identifier
+		scoped := len(el.styles) != 0 && el.ids != nil && el.ids.scopeStyles
+		if scoped {
+			el.ids.addStyles(el)
+		}
 
 		// classes
-		var classes []string
-		if el.typ == "" {
-			classes = make([]string, len(el.classes))
-		} else {
-			classes = make([]string, len(el.classes)+1)
-			classes[0] = "q-" + el.typ
-		}
-		for i, name := range el.classes {
-			if name[0] == '!' {
-				name = name[1:]
-				classes[i+1] = name
-			} else {
-				classes[i+1] = "q-" + name
+		if el.typ != "" || len(el.classes) != 0 || el.meta("needID") || scoped {
+			w.WriteString(` class="`)
+			sep := false
+			class := func(prefix, name string) {
+				if sep {
+					w.WriteByte(' ')
+				}
+				w.WriteString(prefix)
+				w.WriteString(name)
+				sep = true
 			}
-		}
 
-		// inject ID
-		if el.meta("needID") {
-			classes = append([]string{"q-" + el._id}, classes...)
-		}
-		if len(classes) != 0 {
-			openingTag += ` class="` + strings.Join(classes, " ") + `"`
+			// inject ID
+			if el.meta("needID") || scoped {
+				class("q-", el.id())
+			}
+			if el.typ != "" {
+				class("q-", el.typ)
+			}
+			for _, name := range el.classes {
+				if name[0] == '!' {
+					class("", name[1:])
+				} else {
+					class("q-", name)
+				}
+			}
+			w.WriteByte('"')
 		}
 
 		// styles
-		styles := ""
-		for key, val := range el.styles {
-			styles += key + ": " + val + "; "
-		}
-		if styles != "" {
-			openingTag += ` style="` + strings.TrimSpace(styles) + `"`
+		// styles and attributes are sorted so that output is consistent
+		if len(el.styles) != 0 && !scoped {
+			styleNames := make([]string, 0, len(el.styles))
+			for key := range el.styles {
+				styleNames = append(styleNames, key)
+			}
+			sort.Strings(styleNames)
+			w.WriteString(` style="`)
+			for i, key := range styleNames {
+				if i != 0 {
+					w.WriteByte(' ')
+				}
+				w.WriteString(key)
+				w.WriteString(": ")
+				w.WriteString(el.styles[key])
+				w.WriteByte(';')
+			}
+			w.WriteByte('"')
 		}
 
 		// other attributes
-		for key, val := range el.attrs {
-			switch v := val.(type) {
-			case string:
-				openingTag += " " + key + `="` + htmlfmt.EscapeString(v) + `"`
-			case bool:
-				openingTag += " " + key
+		if len(el.attrs) != 0 {
+			attrNames := make([]string, 0, len(el.attrs))
+			for key := range el.attrs {
+				attrNames = append(attrNames, key)
+			}
+			sort.Strings(attrNames)
+			for _, key := range attrNames {
+				switch v := el.attrs[key].(type) {
+				case string:
+					w.WriteByte(' ')
+					w.WriteString(key)
+					w.WriteString(`="`)
+					w.WriteString(htmlfmt.EscapeString(v))
+					w.WriteByte('"')
+				case bool:
+					w.WriteByte(' ')
+					w.WriteString(key)
+				}
 			}
 		}
 
 		// non-container
 		if el.meta("nonContainer") {
-			lines = append(lines, indentedLine{openingTag + " />", indent})
-			return lines
+			w.WriteString(" />\n")
+			return
 		}
 
 		// container
-		lines = append(lines, indentedLine{openingTag + ">", indent})
+		w.WriteString(">\n")
+	}
+
+	// determine indent for inner content
+	myIndent := indent + 1
+	if el.meta("noIndent") {
+		myIndent = 0
 	}
 
 	// inner content
 	for _, textOrEl := range el.content {
-
-		// determine indent for this item
-		myIndent := indent + 1
-		if el.meta("noIndent") {
-			myIndent = 0
-		}
-
-		var addLines []indentedLine
 		switch v := textOrEl.(type) {
 
 		case element:
@@ -364,53 +502,66 @@ func (el *genericElement) generateIndented(indent int) []indentedLine {
 
 			if v.meta("noIndent") {
 				// this element says not to indent its content
-				addLines = v.generateIndented(0)
+				v.writeIndented(w, 0)
 			} else {
-				addLines = v.generateIndented(indent + 1)
+				v.writeIndented(w, indent+1)
 			}
 
 		case string:
-			stringLines := strings.Split(htmlfmt.EscapeString(v), "\n")
-			for i, line := range stringLines {
-				if line == "" && i == len(stringLines)-1 {
-					continue
-				}
-				addLines = append(addLines, indentedLine{line, myIndent})
-			}
+			w.lines(htmlfmt.EscapeString(v), myIndent)
 
 		case HTML:
-			htmlLines := strings.Split(string(v), "\n")
-			for i, line := range htmlLines {
-				if line == "" && i == len(htmlLines)-1 {
-					continue
-				}
-				addLines = append(addLines, indentedLine{line, myIndent})
-			}
-
+			w.lines(string(v), myIndent)
 		}
-
-		lines = append(lines, addLines...)
 	}
 
 	// close it off
 	if !el.meta("noTags") && !el.meta("noClose") {
-		lines = append(lines, indentedLine{"</" + el._tag + ">", indent})
+		w.indent(indent)
+		w.WriteString("</")
+		w.WriteString(el._tag)
+		w.WriteString(">\n")
 	}
+}
 
-	return lines
+// htmlWriter writes indented HTML into a buffer which is reused between pages
+type htmlWriter struct {
+	*bytes.Buffer
 }
 
-func generateIndentedLines(lines []indentedLine) HTML {
-	generated := ""
-	for _, line := range lines {
-		generated += strings.Repeat("    ", line.indent) + line.line
-		// if len(lines) >= i+2 && lines[i+1].indent == 0 {
-		// 	// next peice is noIndent, so no newline before either
-		// 	continue
-		// }
-		if line.line == "" || line.line[len(line.line)-1] != '\n' {
-			generated += "\n"
+var htmlBufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// generates the HTML for an element and its children
+func generateHTML(el element) HTML {
+	buf := htmlBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	el.writeIndented(&htmlWriter{buf}, 0)
+	generated := HTML(buf.String())
+	htmlBufferPool.Put(buf)
+	return generated
+}
+
+// writes the indent for a line
+func (w *htmlWriter) indent(indent int) {
+	for i := 0; i < indent; i++ {
+		w.WriteString("    ")
+	}
+}
+
+// writes text line by line with an indent, omitting a trailing empty line
+func (w *htmlWriter) lines(text string, indent int) {
+	for text != "" {
+		line := text
+		nl := strings.IndexByte(text, '\n')
+		if nl == -1 {
+			text = ""
+		} else {
+			line, text = text[:nl], text[nl+1:]
 		}
+		w.indent(indent)
+		w.WriteString(line)
+		w.WriteByte('\n')
 	}
-	return HTML(generated)
 }
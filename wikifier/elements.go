@@ -7,6 +7,7 @@ type elements struct {
 	cachedHTML    HTML
 	parentElement element
 	shouldHide    bool
+	ids           *elementIDs
 }
 
 // Creates a collection of elements.
@@ -132,7 +133,7 @@ func (els *elements) addChild(child element) {
 
 // Creates an element and adds it.
 func (els *elements) createChild(tag, typ string) element {
-	child := newElement(tag, typ)
+	child := newPageElement(els.ids, tag, typ)
 	els.addChild(child)
 	return child
 }
@@ -173,23 +174,18 @@ func (els *elements) generate() HTML {
 		return els.cachedHTML
 	}
 
-	els.cachedHTML = generateIndentedLines(els.generateIndented(0))
+	els.cachedHTML = generateHTML(els)
 	return els.cachedHTML
 }
 
-// Generates and returns HTML for the elements with an indent applied.
-func (els *elements) generateIndented(indent int) []indentedLine {
-	var lines []indentedLine
-
+// Writes HTML for the elements with an indent applied.
+func (els *elements) writeIndented(w *htmlWriter, indent int) {
 	if els.hidden() {
-		return nil
+		return
 	}
 
 	// add each
 	for _, el := range els.elements {
-		theirLines := el.generateIndented(indent)
-		lines = append(lines, theirLines...)
+		el.writeIndented(w, indent)
 	}
-
-	return lines
 }
@@ -0,0 +1,72 @@
+package wikifier
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// A FormatFunc generates HTML for a custom formatting tag.
+//
+// For a tag like [yt:VIDEOID], arg is everything after the first colon
+// (VIDEOID), with surrounding whitespace removed. If the tag has no colon,
+// arg is empty.
+//
+// The page provides access to page options (page.Opt) and variables, and
+// o.Pos is the position of the tag for use in warnings.
+type FormatFunc func(page *Page, arg string, o *FmtOpt) HTML
+
+var (
+	customFormats     = make(map[string]FormatFunc)
+	customFormatsLock sync.RWMutex
+	formatNameRegex   = regexp.MustCompile(`^\w[\w\-]*$`)
+)
+
+// RegisterFormat registers a handler for a custom inline formatting tag.
+//
+// For example, after
+//
+//	wikifier.RegisterFormat("yt", handler)
+//
+// the text [yt:VIDEOID] is converted to HTML by handler.
+//
+// Names are case-insensitive and may consist of word-like characters and
+// hyphens. Built-in formatting tags cannot be overridden, and a name can only
+// be registered once.
+func RegisterFormat(name string, handler FormatFunc) error {
+	name = strings.ToLower(name)
+
+	if handler == nil {
+		return errors.New("RegisterFormat: nil handler")
+	}
+	if !formatNameRegex.MatchString(name) {
+		return errors.New("RegisterFormat: invalid format name '" + name + "'")
+	}
+	if _, exists := staticFormats[name]; exists || name == "html" || colors[name] != "" {
+		return errors.New("RegisterFormat: '" + name + "' is a built-in format")
+	}
+
+	customFormatsLock.Lock()
+	defer customFormatsLock.Unlock()
+
+	if _, exists := customFormats[name]; exists {
+		return errors.New("RegisterFormat: '" + name + "' is already registered")
+	}
+	customFormats[name] = handler
+	return nil
+}
+
+// finds a custom format handler for the given format type.
+// returns nil if there is none
+func customFormat(formatType string) (FormatFunc, string) {
+	name, arg := formatType, ""
+	if colon := strings.IndexByte(formatType, ':'); colon != -1 {
+		name, arg = formatType[:colon], strings.TrimSpace(formatType[colon+1:])
+	}
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	customFormatsLock.RLock()
+	defer customFormatsLock.RUnlock()
+	return customFormats[name], arg
+}
@@ -0,0 +1,240 @@
+package wikifier
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	strip "github.com/grokify/html-strip-tags-go"
+	"github.com/pkg/errors"
+)
+
+// a function call in a variable, like [@upper(page.title)]
+var variableFuncRegex = regexp.MustCompile(`^(\w+)\((.*)\)$`)
+
+// a variableFunc computes a value from its arguments, which are strings,
+// bools, or objects such as lists and maps. HTML arguments are converted to
+// plain text beforehand
+type variableFunc func(args []interface{}) (interface{}, error)
+
+var variableFuncs map[string]variableFunc
+
+func init() {
+	variableFuncs = map[string]variableFunc{
+		"len":   funcLen,
+		"upper": funcString(strings.ToUpper),
+		"lower": funcString(strings.ToLower),
+		"title": funcString(funcTitle),
+		"trim":  funcString(strings.TrimSpace),
+		"join":  funcJoin,
+		"add":   funcMath(func(a, b float64) (float64, error) { return a + b, nil }),
+		"sub":   funcMath(func(a, b float64) (float64, error) { return a - b, nil }),
+		"mul":   funcMath(func(a, b float64) (float64, error) { return a * b, nil }),
+		"div": funcMath(func(a, b float64) (float64, error) {
+			if b == 0 {
+				return 0, errors.New("division by zero")
+			}
+			return a / b, nil
+		}),
+	}
+}
+
+// formats a variable with a function call, like [@add(count, 1)]
+func (p *Page) formatVariableFunc(formatType string, o *FmtOpt) HTML {
+	val, err := p.callVariableFunc(formatType[1:])
+	if err != nil {
+		if !o.NoWarnings {
+			p.warn(o.Pos, "Variable "+formatType+": "+err.Error())
+		}
+		return HTML("(error: " + html.EscapeString(formatType) + ": " + html.EscapeString(err.Error()) + ")")
+	}
+	if str, ok := val.(string); ok {
+		return HTML(html.EscapeString(str))
+	}
+	return HTML(html.EscapeString(humanReadableValue(val)))
+}
+
+// evaluates a function call. each argument is a quoted string, a number, a
+// variable name without the @, or another function call
+func (p *Page) callVariableFunc(call string) (interface{}, error) {
+	match := variableFuncRegex.FindStringSubmatch(call)
+	if match == nil {
+		return nil, errors.New("invalid function call " + call)
+	}
+	name := strings.ToLower(match[1])
+	fn := variableFuncs[name]
+	if fn == nil {
+		return nil, errors.New("no such function " + name + "()")
+	}
+
+	var args []interface{}
+	for _, arg := range splitFuncArgs(match[2]) {
+		val, err := p.variableFuncArg(arg)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, val)
+	}
+
+	val, err := fn(args)
+	if err != nil {
+		return nil, errors.Wrap(err, name+"()")
+	}
+	return val, nil
+}
+
+func (p *Page) variableFuncArg(arg string) (interface{}, error) {
+	switch {
+
+	// string literal
+	case len(arg) >= 2 && arg[0] == '"' && arg[len(arg)-1] == '"':
+		return arg[1 : len(arg)-1], nil
+
+	// number literal
+	case isFuncNumber(arg):
+		return arg, nil
+
+	// nested function call
+	case variableFuncRegex.MatchString(arg):
+		return p.callVariableFunc(arg)
+	}
+
+	// variable
+	name := strings.TrimPrefix(arg, "@")
+	val, err := p.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	switch v := val.(type) {
+	case nil:
+		return nil, errors.New("variable @" + name + " is undefined")
+	case HTML:
+		return html.UnescapeString(strip.StripTags(string(v))), nil
+	}
+	return val, nil
+}
+
+// splits function arguments at commas which are not within quotes or
+// nested function calls
+func splitFuncArgs(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	var args []string
+	depth, quoted, start := 0, false, 0
+	for i, c := range s {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			args = append(args, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(args, strings.TrimSpace(s[start:]))
+}
+
+func isFuncNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil && strings.ContainsAny(s, "0123456789")
+}
+
+// len(x) is the number of items in a list or map, or characters in a string
+func funcLen(args []interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, errors.New("expected 1 argument")
+	}
+	switch v := args[0].(type) {
+	case string:
+		return strconv.Itoa(utf8.RuneCountInString(v)), nil
+	case *List:
+		return strconv.Itoa(len(v.list)), nil
+	case *Map:
+		return strconv.Itoa(len(v.vars)), nil
+	}
+	return nil, errors.New("not a string, list, or map (" + humanReadableValue(args[0]) + ")")
+}
+
+// functions of one string
+func funcString(f func(string) string) variableFunc {
+	return func(args []interface{}) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, errors.New("expected 1 argument")
+		}
+		str, ok := args[0].(string)
+		if !ok {
+			return nil, errors.New("not a string (" + humanReadableValue(args[0]) + ")")
+		}
+		return f(str), nil
+	}
+}
+
+// capitalizes the first letter of each word
+func funcTitle(s string) string {
+	prev := ' '
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(prev) {
+			r = unicode.ToUpper(r)
+		}
+		prev = r
+		return r
+	}, s)
+}
+
+// join(list, sep) joins the strings in a list, separated by ", " by default
+func funcJoin(args []interface{}) (interface{}, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, errors.New("expected 1 or 2 arguments")
+	}
+	list, ok := args[0].(*List)
+	if !ok {
+		return nil, errors.New("not a list (" + humanReadableValue(args[0]) + ")")
+	}
+	sep := ", "
+	if len(args) == 2 {
+		if sep, ok = args[1].(string); !ok {
+			return nil, errors.New("separator is not a string")
+		}
+	}
+	var strs []string
+	for _, entry := range list.list {
+		switch v := entry.value.(type) {
+		case string:
+			strs = append(strs, v)
+		case HTML:
+			strs = append(strs, html.UnescapeString(strip.StripTags(string(v))))
+		}
+	}
+	return strings.Join(strs, sep), nil
+}
+
+// arithmetic on two or more numbers, from left to right
+func funcMath(f func(a, b float64) (float64, error)) variableFunc {
+	return func(args []interface{}) (interface{}, error) {
+		if len(args) < 2 {
+			return nil, errors.New("expected at least 2 arguments")
+		}
+		var result float64
+		for i, arg := range args {
+			str, _ := arg.(string)
+			n, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+			if err != nil {
+				return nil, errors.New("not a number (" + humanReadableValue(arg) + ")")
+			}
+			if i == 0 {
+				result = n
+			} else if result, err = f(result, n); err != nil {
+				return nil, err
+			}
+		}
+		return strconv.FormatFloat(result, 'f', -1, 64), nil
+	}
+}
@@ -3,6 +3,7 @@ package wikifier
 import (
 	"fmt"
 	"html"
+	"net/url"
 	"regexp"
 	"strings"
 )
@@ -209,6 +210,7 @@ type FmtOpt struct {
 	NoEntities  bool     // disables html entity conversion
 	NoWarnings  bool     // silence warnings for undefined variables
 	noVariables bool     // set internally to prevent recursive interpolation
+	mentions    bool     // link @username mentions, for body text only
 }
 
 // Fmt generates HTML from a quiki-encoded formatted string.
@@ -216,6 +218,12 @@ func (p *Page) Fmt(text string, pos Position) HTML {
 	return p._parseFormattedText(text, &FmtOpt{Pos: pos})
 }
 
+// formats body text, such as paragraphs, list items, and table cells. unlike
+// titles, headings, and link text, it may mention users
+func (p *Page) fmtText(text string, pos Position) HTML {
+	return p._parseFormattedText(text, &FmtOpt{Pos: pos, mentions: true})
+}
+
 // FmtOpts is like Fmt except you can specify additional options with the FmtOpt argument.
 func (p *Page) FmtOpts(text string, pos Position, o FmtOpt) HTML {
 	o.Pos = pos
@@ -240,6 +248,11 @@ func (p *Page) _parseFormattedText(text string, o *FmtOpt) HTML {
 	formatType := "" // format name such as 'i' or '/b'
 	formatDepth := 0 // how far [[in]] we are
 	escaped := false // character escaped
+	inRef := false   // inside [ref]...[/ref]
+	refText := ""    // unformatted text of the [ref] so far
+	inMath := false  // inside [math]...[/math]
+	mathText := ""   // TeX of the [math] so far
+	var refPos, mathPos Position
 
 	for _, char := range text {
 
@@ -251,6 +264,17 @@ func (p *Page) _parseFormattedText(text string, o *FmtOpt) HTML {
 			o.Pos.Column++
 		}
 
+		// everything up to [/math] is TeX, which has its own brackets and
+		// backslashes
+		if inMath {
+			mathText += string(char)
+			if n := len(mathText) - len("[/math]"); n >= 0 && strings.EqualFold(mathText[n:], "[/math]") {
+				items = append(items, p.inlineMath(mathText[:n], mathPos))
+				inMath = false
+			}
+			continue
+		}
+
 		if char == '[' && !escaped {
 			// marks the beginning of a formatting element
 			formatDepth++
@@ -273,26 +297,66 @@ func (p *Page) _parseFormattedText(text string, o *FmtOpt) HTML {
 			// marks the end of a formatting element
 			formatDepth--
 			if formatDepth == 0 {
-				items = append(items, p.parseFormatType(formatType, o))
+				switch {
+
+				// end of a footnote
+				case inRef && strings.EqualFold(formatType, "/ref"):
+					items = append(items, p.footnoteRef(refText, refPos))
+					inRef = false
+
+				// formatting within a footnote is formatted with the note
+				case inRef:
+					refText += "[" + formatType + "]"
+
+				// start of a footnote
+				case strings.EqualFold(formatType, "ref"):
+					inRef, refText, refPos = true, "", o.Pos
+
+				// start of an equation
+				case strings.EqualFold(formatType, "math"):
+					inMath, mathText, mathPos = true, "", o.Pos
+
+				default:
+					items = append(items, p.parseFormatType(formatType, o))
+				}
 				continue
 			}
 		}
 
-		// an unescaped backslash should not appear in the result
+		// an unescaped backslash should not appear in the result,
+		// except in a footnote which is formatted later
 		escaped = char == '\\' && !escaped
 		if escaped && formatDepth == 0 {
+			if inRef {
+				refText += string(char)
+			}
 			continue
 		}
 
 		// if we're in the format type, append to it
 		if formatDepth != 0 {
 			formatType += string(char)
+		} else if inRef {
+			// or to the footnote
+			refText += string(char)
 		} else {
 			// otherwise, add to the string
 			str += string(char)
 		}
 	}
 
+	// unterminated footnote
+	if inRef {
+		p.warn(refPos, "[ref] without [/ref]")
+		items = append(items, p.footnoteRef(refText, refPos))
+	}
+
+	// unterminated equation
+	if inMath {
+		p.warn(mathPos, "[math] without [/math]")
+		items = append(items, p.inlineMath(mathText, mathPos))
+	}
+
 	// add the final string
 	if str != "" {
 		if o.NoEntities {
@@ -308,11 +372,23 @@ func (p *Page) _parseFormattedText(text string, o *FmtOpt) HTML {
 
 	// join the parts together, converting entities as needed
 	final := ""
+	inCode := false
 	for _, piece := range items {
 		switch v := piece.(type) {
 		case string:
-			final += html.EscapeString(v)
+			if o.mentions && !inCode && p.Opt.Page.EnableMentions {
+				final += string(p.formatMentions(v, o.Pos))
+			} else {
+				final += html.EscapeString(v)
+			}
 		case HTML:
+			// no mentions in inline code
+			switch v {
+			case HTML(staticFormats["c"]):
+				inCode = true
+			case HTML(staticFormats["/c"]):
+				inCode = false
+			}
 			final += string(v)
 		}
 	}
@@ -327,8 +403,28 @@ func (p *Page) parseFormatType(formatType string, o *FmtOpt) HTML {
 		return HTML(format)
 	}
 
+	// [ref:label] - figure, table, or equation
+	if len(formatType) > 4 && strings.EqualFold(formatType[:4], "ref:") {
+		return p.labelRef(formatType[4:], o)
+	}
+
+	// [todo:text] or [review:text] - note to editors
+	if colon := strings.IndexByte(formatType, ':'); colon != -1 {
+		if kind := strings.ToLower(formatType[:colon]); editorNoteKinds[kind] != "" {
+			return p.editorNote(kind, formatType[colon+1:], o)
+		}
+	}
+
+	// custom format registered with RegisterFormat
+	if handler, arg := customFormat(formatType); handler != nil {
+		return handler(p, arg, o)
+	}
+
 	// variable
 	if !o.noVariables {
+		if formatType[0] == '@' && variableFuncRegex.MatchString(formatType[1:]) {
+			return p.formatVariableFunc(formatType, o)
+		}
 		if variableRegex.MatchString(formatType) {
 
 			// fetch the value
@@ -426,6 +522,11 @@ func (p *Page) parseFormatType(formatType string, o *FmtOpt) HTML {
 		invalid := ""
 		if !ok {
 			invalid = " invalid"
+
+			// red link to a page which does not exist
+			if linkType == "internal" {
+				invalid += " q-link-missing"
+			}
 		}
 		if tooltip != "" {
 			tooltip = ` title="` + tooltip + `"`
@@ -439,13 +540,6 @@ func (p *Page) parseFormatType(formatType string, o *FmtOpt) HTML {
 		))
 	}
 
-	// TODO: fake references.
-	// if ($type eq 'ref') {
-	//     $page->{reference_number} ||= 1;
-	//     my $ref = $page->{reference_number}++;
-	//     return qq{<sup style="font-size: 75%"><a href="#wiki-ref-$ref" class="wiki-ref-anchor">[$ref]</a></sup>};
-	// }
-
 	// color name
 	if color, exists := colors[strings.ToLower(formatType)]; exists {
 		return HTML(`<span style="color: "` + color + `";">`)
@@ -456,17 +550,16 @@ func (p *Page) parseFormatType(formatType string, o *FmtOpt) HTML {
 		return HTML(`<span style="color: "` + formatType + `";">`)
 	}
 
-	// TODO: real references.
-	// if ($type =~ m/^\d+$/) {
-	//     return qq{<sup style="font-size: 75%"><a href="#wiki-ref-$type" class="wiki-ref-anchor">[$type]</a></sup>};
-	// }
-
 	// inline html
 	// [html:x<sup>2</sup>]
 	if strings.HasPrefix(formatType, "html:") {
-		return HTML(strings.TrimPrefix(formatType, "html:"))
+		return HTML(p.sanitizeHTML(strings.TrimPrefix(formatType, "html:")))
 	}
 
+	// not built-in or registered with RegisterFormat
+	if !o.NoWarnings {
+		p.warn(o.Pos, "Unknown formatting tag ["+formatType+"]")
+	}
 	return HTML("")
 }
 
@@ -507,6 +600,11 @@ func (p *Page) parseLink(link string, o *FmtOpt) (ok bool, target, linkType, too
 			target = displayDefault
 		}
 
+		// web addresses are checked by the wiki for dead links
+		if scheme := strings.ToLower(matches[2]); scheme == "http" || scheme == "https" {
+			p.addExternalLink(target, o.Pos)
+		}
+
 	} else if strings.HasPrefix(target, "mailto:") {
 		// mailto:someone@example.com
 
@@ -564,7 +662,7 @@ func (p *Page) parseLink(link string, o *FmtOpt) (ok bool, target, linkType, too
 		// section
 		sec := ""
 		if hashIdx := strings.IndexByte(target, '#'); hashIdx != -1 && len(target) >= hashIdx {
-			sec = PageNameNE(strings.TrimSpace(target[hashIdx+1:]))
+			sec = headingAnchor(target[hashIdx+1:])
 			target = strings.TrimSpace(target[:hashIdx])
 			tooltip = target + " § " + sec
 			sec = "#" + sec
@@ -576,7 +674,7 @@ func (p *Page) parseLink(link string, o *FmtOpt) (ok bool, target, linkType, too
 			target = sec
 		} else {
 			// other page link
-			target = p.Opt.Root.Page + "/" + pfx + PageNameNE(target) + sec
+			target = p.Opt.Root.Page + "/" + pfx + p.Opt.PageNameNE(target) + sec
 		}
 
 		handler = p.Opt.Link.ParseInternal
@@ -593,6 +691,11 @@ func (p *Page) parseLink(link string, o *FmtOpt) (ok bool, target, linkType, too
 		})
 	}
 
+	// external wiki pages are checked like other web addresses
+	if ok && linkType == "external" && linkRegex.MatchString(target) {
+		p.addExternalLink(html.UnescapeString(target), o.Pos)
+	}
+
 	// pipe was not present
 	if display == "" {
 		display = HTML(html.EscapeString(displayDefault))
@@ -605,6 +708,11 @@ func (p *Page) parseLink(link string, o *FmtOpt) (ok bool, target, linkType, too
 	return
 }
 
+// records a link to a web address
+func (p *Page) addExternalLink(target string, pos Position) {
+	p.ExternalLinks[target] = append(p.ExternalLinks[target], pos.Line)
+}
+
 func defaultExternalLink(p *Page, o *PageOptLinkOpts) {
 	// note: the wiki shortcode is in tooltip for now
 	// the target is in displayDefault
@@ -628,13 +736,22 @@ func defaultExternalLink(p *Page, o *PageOptLinkOpts) {
 		*o.Tooltip = *o.Target + " § " + section
 	}
 
+	// page URL from @interwiki
+	if ext.URL != "" {
+		*o.Target = strings.Replace(ext.URL, "$1", html.EscapeString((&url.URL{Path: *o.Target}).EscapedPath()), -1)
+		if section != "" {
+			*o.Target += "#" + html.EscapeString(url.PathEscape(section))
+		}
+		return
+	}
+
 	// normalize based on type
 	switch ext.Type {
 
 	// convert all non-alphanumerics to underscore
 	case PageOptExternalTypeQuiki:
 		*o.Target = PageNameLink(*o.Target)
-		section = PageNameLink(section)
+		section = headingAnchor(section)
 
 	// convert space to underscore, URI escape the rest
 	case PageOptExternalTypeMediaWiki:
@@ -0,0 +1,101 @@
+package wikifier
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// @username, not preceded by a word character as in an email address
+var mentionRegex = regexp.MustCompile(`(?:^|[^\w@/&.:\-])@(\w(?:[\w.\-]*\w)?)`)
+
+// FindMentions returns the usernames mentioned as @username in plain text,
+// such as a commit message, in order of first appearance.
+func FindMentions(text string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, m := range mentionRegex.FindAllStringSubmatch(text, -1) {
+		if key := strings.ToLower(m[1]); !seen[key] {
+			seen[key] = true
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// blocks whose text is body text, which may mention users
+var mentionBlockTypes = map[string]bool{
+	"main": true, "sec": true, "p": true, "quote": true,
+	"list": true, "numlist": true, "tc": true, "th": true,
+}
+
+// inline code, links, and other formatting tags, which are not searched for
+// mentions
+var mentionSkipRegex = regexp.MustCompile(`(?is)\[c\].*?\[/c\]|\[\[.*?\]\]|\[[^\]]*\]`)
+
+// SourceMentions returns the usernames mentioned in the body text of a
+// parsed page, in order of first appearance. Unlike Page.Mentions, this does
+// not require generating HTML.
+func (p *Page) SourceMentions() []string {
+	if p.main == nil {
+		return nil
+	}
+	var text strings.Builder
+	var walk func(b block)
+	walk = func(b block) {
+		if mentionBlockTypes[b.blockType()] {
+			for _, s := range b.textContent() {
+				text.WriteString(mentionSkipRegex.ReplaceAllString(s, " "))
+				text.WriteByte('\n')
+			}
+		}
+		for _, child := range b.blockContent() {
+			walk(child)
+		}
+	}
+	walk(p.main)
+	return FindMentions(text.String())
+}
+
+// escapes text, linking @username mentions to author pages. mentions of
+// groups are highlighted but not linked
+func (p *Page) formatMentions(text string, pos Position) HTML {
+	matches := mentionRegex.FindAllStringSubmatchIndex(text, -1)
+	if matches == nil {
+		return HTML(html.EscapeString(text))
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		at, name := m[2]-1, text[m[2]:m[3]]
+		b.WriteString(html.EscapeString(text[last:at]))
+		if _, isGroup := p.Opt.Groups[name]; isGroup {
+			b.WriteString(`<span class="q-mention q-mention-group">@` + html.EscapeString(name) + `</span>`)
+		} else {
+			b.WriteString(`<a class="q-mention" href="` +
+				html.EscapeString(p.Opt.Root.Author+"/"+CategoryNameNE(name)) + `">@` +
+				html.EscapeString(name) + `</a>`)
+		}
+		p.addMention(name, pos)
+		last = m[3]
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return HTML(b.String())
+}
+
+// records a mention, once for each line. names which differ only in case
+// are the same user
+func (p *Page) addMention(name string, pos Position) {
+	for existing := range p.Mentions {
+		if strings.EqualFold(existing, name) {
+			name = existing
+			break
+		}
+	}
+	for _, line := range p.Mentions[name] {
+		if line == pos.Line {
+			return
+		}
+	}
+	p.Mentions[name] = append(p.Mentions[name], pos.Line)
+}
@@ -1,6 +1,12 @@
 package wikifier
 
-import "time"
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	strip "github.com/grokify/html-strip-tags-go"
+)
 
 // ModelInfo represents metadata associated with a model.
 type ModelInfo struct {
@@ -12,8 +18,25 @@ type ModelInfo struct {
 	Path        string     `json:"path"`
 	Created     *time.Time `json:"created,omitempty"`  // creation time
 	Modified    *time.Time `json:"modified,omitempty"` // modify time
+
+	// parameters declared in @model.params, if any
+	Params []ModelParam `json:"params,omitempty"`
+}
+
+// ModelParam represents a parameter declared by a model in @model.params.
+//
+// Each key of @model.params is a parameter name, and its value is the type,
+// optionally followed by options, e.g. number, default=1.
+type ModelParam struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`              // text, number, bool, block, list, map, or any
+	Default  string `json:"default,omitempty"` // default value, if not required
+	Required bool   `json:"required,omitempty"`
 }
 
+// model parameter types
+var modelParamTypes = []string{"text", "number", "bool", "block", "list", "map", "any"}
+
 // modelInfo is like (wikifier.Page).Info() but used internally
 // to instead return a ModelInfo
 func (p *Page) modelInfo() ModelInfo {
@@ -32,5 +55,143 @@ func (p *Page) modelInfo() ModelInfo {
 	if !create.IsZero() {
 		info.Created = &create
 	}
+	info.Params, _ = p.modelParams()
 	return info
 }
+
+// modelParams returns the parameters declared in @model.params, if any,
+// and warnings about the declarations
+func (p *Page) modelParams() ([]ModelParam, []string) {
+	obj, err := p.GetObj("model.params")
+	if err != nil {
+		return nil, []string{"@model.params: " + err.Error()}
+	}
+	paramMap, ok := obj.(*Map)
+	if !ok {
+		return nil, nil
+	}
+	var params []ModelParam
+	var warnings []string
+	for _, name := range paramMap.OrderedKeys() {
+		spec, err := paramMap.GetStr(name)
+		if err != nil {
+			warnings = append(warnings, "@model.params."+name+": "+err.Error())
+			continue
+		}
+		param, warning := parseModelParam(name, spec)
+		if warning != "" {
+			warnings = append(warnings, "@model.params."+name+": "+warning)
+		}
+		params = append(params, param)
+	}
+	return params, warnings
+}
+
+// parses a parameter declaration, such as: number, default=1
+func parseModelParam(name, spec string) (ModelParam, string) {
+	param := ModelParam{Name: name, Type: "any", Required: true}
+	opts := strings.Split(spec, ",")
+	var warning string
+	if typ := strings.TrimSpace(opts[0]); typ != "" {
+		param.Type = typ
+	}
+	if !modelParamTypeOK(param.Type) {
+		warning = "unknown type '" + param.Type + "'"
+		param.Type = "any"
+	}
+	for i := 1; i < len(opts); i++ {
+		opt := strings.TrimSpace(opts[i])
+		switch {
+		case opt == "optional":
+			param.Required = false
+		case strings.HasPrefix(opt, "default="):
+			// the default is the rest, which may contain commas
+			param.Default = strings.TrimSpace(strings.Join(append([]string{strings.TrimPrefix(opt, "default=")}, opts[i+1:]...), ","))
+			param.Required = false
+			i = len(opts)
+		default:
+			warning = "unknown option '" + opt + "'"
+		}
+	}
+	return param, warning
+}
+
+func modelParamTypeOK(typ string) bool {
+	for _, t := range modelParamTypes {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// returns an error message if the value is not of the parameter's type
+func (param ModelParam) check(val interface{}) string {
+	switch param.Type {
+	case "text":
+		if _, err := strValue(val); err != nil {
+			return "should be text"
+		}
+	case "number":
+		str, err := strValue(val)
+		if err == nil {
+			_, err = strconv.ParseFloat(strings.TrimSpace(strip.StripTags(str)), 64)
+		}
+		if err != nil {
+			return "should be a number"
+		}
+	case "bool":
+		if _, ok := modelParamBool(val); !ok {
+			return "should be a boolean"
+		}
+	case "block":
+		if _, err := blockValue(val); err != nil {
+			return "should be a block"
+		}
+	case "list":
+		if _, ok := val.(*List); !ok {
+			return "should be a list"
+		}
+	case "map":
+		if _, ok := val.(*Map); !ok {
+			return "should be a map"
+		}
+	}
+	return ""
+}
+
+// returns the value as stored in @m. booleans given as text are converted
+// so that they work in conditionals
+func (param ModelParam) value(val interface{}) interface{} {
+	if param.Type != "bool" {
+		return val
+	}
+	b, _ := modelParamBool(val)
+	return b
+}
+
+// returns the default value as stored in @m, or nil if there is none
+func (param ModelParam) defaultValue() interface{} {
+	switch {
+	case param.Type == "bool":
+		return param.value(param.Default)
+	case param.Default != "" || param.Type == "text":
+		return param.Default
+	}
+	return nil
+}
+
+// booleans are true, false, yes, or no
+func modelParamBool(val interface{}) (bool, bool) {
+	if b, ok := val.(bool); ok {
+		return b, true
+	}
+	str, _ := strValue(val)
+	switch strings.ToLower(strings.TrimSpace(strip.StripTags(str))) {
+	case "true", "yes":
+		return true, true
+	case "false", "no", "":
+		return false, true
+	}
+	return false, false
+}
@@ -1,15 +1,37 @@
 package wikifier
 
-import "strings"
+import (
+	"errors"
+	"strings"
+)
 
 // CSS generates and returns the CSS code for the page's inline styles.
+//
+// This includes the rules of style{} blocks, rules added by blocks with
+// AddCSS, and the styles of blocks such as code{}. If
+// @page.enable.scoped_styles is set, it also includes the inline styles of
+// elements, which are then omitted from their style attributes.
+//
+// Blocks contribute to the CSS as the page is generated, so CSS generates
+// the HTML if it has not been already.
 func (p *Page) CSS() string {
+	if p.main != nil {
+		p.HTML()
+	}
+	styles := p.styles
+	if p.elementIDs != nil {
+		styles = append(styles[:len(styles):len(styles)], p.elementIDs.styles...)
+	}
+
 	generated := ""
-	for _, style := range p.styles {
-		applyTo := p.cssApplyString(style.applyTo)
-		generated += applyTo + " {\n"
-		for rule, value := range style.rules {
-			generated += "    " + rule + ": " + value + ";\n"
+	for _, style := range styles {
+		if len(style.selectors) != 0 {
+			generated += p.cssSelectorString(style.selectors) + " {\n"
+		} else {
+			generated += p.cssApplyString(style.applyTo) + " {\n"
+		}
+		for _, rule := range style.rules {
+			generated += "    " + rule.name + ": " + rule.value + ";\n"
 		}
 		generated += "}\n"
 	}
@@ -19,11 +41,79 @@ func (p *Page) CSS() string {
 	return generated
 }
 
+// AddCSS adds a rule to the page CSS. It is intended for the handlers of
+// block types registered with RegisterBlockType, which can style the HTML
+// they return without style attributes.
+//
+// The selector may list several CSS selectors separated by commas, each of
+// which is scoped to the page, so the rule cannot affect the rest of the
+// document in which the page is displayed. Declarations are in the form
+// "name: value". For example,
+//
+//	page.AddCSS(".price, .price-note", "color: green", "font-weight: bold")
+//
+// AddCSS returns an error if the selector or a declaration is invalid.
+func (p *Page) AddCSS(selector string, declarations ...string) error {
+	if !validCSS(selector) {
+		return errors.New("AddCSS: invalid selector '" + selector + "'")
+	}
+	var selectors []string
+	for _, sel := range strings.Split(selector, ",") {
+		if sel = strings.TrimSpace(sel); sel != "" {
+			selectors = append(selectors, sel)
+		}
+	}
+	if len(selectors) == 0 {
+		return errors.New("AddCSS: empty selector")
+	}
+
+	rules := make([]styleRule, 0, len(declarations))
+	for _, decl := range declarations {
+		split := strings.SplitN(decl, ":", 2)
+		if len(split) != 2 || !validCSS(decl) {
+			return errors.New("AddCSS: invalid declaration '" + decl + "'")
+		}
+		rules = append(rules, styleRule{strings.TrimSpace(split[0]), strings.TrimSpace(split[1])})
+	}
+
+	p.styles = append(p.styles, styleEntry{selectors: selectors, rules: rules})
+	return nil
+}
+
+// true if a selector or declaration cannot end its rule or the style
+// element, so it cannot escape the page scope
+func validCSS(s string) bool {
+	return !strings.ContainsAny(s, "{}<>;")
+}
+
+// true if the inline styles of elements are moved into the page CSS, from
+// @page.enable.scoped_styles or the wiki option
+func (p *Page) scopedStyles() bool {
+	if on, _ := p.GetBool("page.enable.scoped_styles"); on {
+		return true
+	}
+	return p.Opt != nil && p.Opt.Page.EnableScopedStyles
+}
+
+// scopes CSS selectors to the page
+func (p *Page) cssSelectorString(selectors []string) string {
+	scope := ".q-" + p.main.el().id()
+	parts := make([]string, len(selectors))
+	for i, sel := range selectors {
+		parts[i] = scope + " " + sel
+	}
+	return strings.Join(parts, ",\n")
+}
+
 func (p *Page) cssApplyString(sets [][]string) string {
+	mainPfx := ".q-main-"
+	if p.elementIDs != nil && p.elementIDs.prefix != "" {
+		mainPfx = ".q-" + p.elementIDs.prefix + "-main-"
+	}
 	parts := make([]string, len(sets))
 	for i, set := range sets {
 		str := p.cssSetString(set)
-		if !strings.HasPrefix(str, ".q-main") {
+		if !strings.HasPrefix(str, mainPfx) {
 			id := p.main.el().id()
 			str = ".q-" + id + " " + str
 		}
@@ -33,10 +123,11 @@ func (p *Page) cssApplyString(sets [][]string) string {
 }
 
 func (p *Page) cssSetString(set []string) string {
+	items := make([]string, len(set))
 	for i, item := range set {
-		set[i] = p.cssItemString([]rune(item))
+		items[i] = p.cssItemString([]rune(item))
 	}
-	return strings.Join(set, " ")
+	return strings.Join(items, " ")
 }
 
 func (p *Page) cssItemString(chars []rune) string {
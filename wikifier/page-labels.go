@@ -0,0 +1,120 @@
+package wikifier
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// a numbered figure, table, or equation, which [ref:label] refers to. the
+// label is the element ID of the block, as in imagebox#cat { ... }
+type blockLabel struct {
+	kind  string // Figure, Table, or Equation
+	n     int    // number among those of the same kind on the page
+	block block  // the labeled block
+}
+
+// a [ref:label] formatted before the labels were numbered, such as in a
+// list item or infobox value, which is resolved once the HTML is generated
+type pendingLabelRef struct {
+	label      string
+	pos        Position
+	noWarnings bool
+}
+
+// stands in for a pending [ref:label] by its index
+var labelRefPlaceholderRegex = regexp.MustCompile(`<!--q-label-ref:(\d+)-->`)
+
+func (l *blockLabel) String() string {
+	return l.kind + " " + strconv.Itoa(l.n)
+}
+
+// kinds of blocks which are numbered when labeled
+var labelKinds = map[string]string{
+	"image":    "Figure",
+	"imagebox": "Figure",
+	"table":    "Table",
+	"math":     "Equation",
+}
+
+// numbers the labeled figures, tables, and equations in the order they
+// appear, so that references to them can come before or after them
+func (p *Page) numberLabels() {
+	counts := make(map[string]int)
+	var number func(blk block)
+	number = func(blk block) {
+		for _, child := range blk.blockContent() {
+			number(child)
+			kind := labelKinds[child.blockType()]
+			if kind == "" {
+				continue
+			}
+			id := child.el().attr("id")
+			if id == "" {
+				continue
+			}
+			if _, exists := p.labels[id]; exists {
+				child.warn(child.openPosition(), "Label '"+id+"' is used more than once")
+				continue
+			}
+			if p.labels == nil {
+				p.labels = make(map[string]*blockLabel)
+			}
+			counts[kind]++
+			p.labels[id] = &blockLabel{kind, counts[kind], child}
+		}
+	}
+	number(p.main)
+	p.labelsNumbered = true
+}
+
+// returns the number of a labeled block, or nil if it has none. a block
+// whose label is used by an earlier block is not numbered
+func (p *Page) blockLabel(b block) *blockLabel {
+	if id := b.el().attr("id"); id != "" {
+		if l := p.labels[id]; l != nil && l.block.el() == b.el() {
+			return l
+		}
+	}
+	return nil
+}
+
+// [ref:label] - a link to a numbered figure, table, or equation
+func (p *Page) labelRef(label string, o *FmtOpt) HTML {
+	label = strings.TrimSpace(label)
+
+	// not numbered yet, so leave a placeholder
+	if !p.labelsNumbered {
+		p.labelRefs = append(p.labelRefs, pendingLabelRef{label, o.Pos, o.NoWarnings})
+		return HTML("<!--q-label-ref:" + strconv.Itoa(len(p.labelRefs)-1) + "-->")
+	}
+	return p.labelRefHTML(label, o.NoWarnings, o.Pos)
+}
+
+func (p *Page) labelRefHTML(label string, noWarnings bool, pos Position) HTML {
+	l := p.labels[label]
+	if l == nil {
+		if !noWarnings {
+			p.warn(pos, "No figure, table, or equation labeled '"+label+"'")
+		}
+		return HTML(`<span class="q-label-ref invalid">` + html.EscapeString(label) + `</span>`)
+	}
+	return HTML(`<a class="q-label-ref" href="#` + html.EscapeString(label) + `">` + l.String() + `</a>`)
+}
+
+// replaces the placeholders of [ref:label]s which were formatted before the
+// labels were numbered
+func (p *Page) resolveLabelRefs(h HTML) HTML {
+	if len(p.labelRefs) == 0 {
+		return h
+	}
+	return HTML(labelRefPlaceholderRegex.ReplaceAllStringFunc(string(h), func(s string) string {
+		i, _ := strconv.Atoi(labelRefPlaceholderRegex.FindStringSubmatch(s)[1])
+		if i >= len(p.labelRefs) {
+			return ""
+		}
+		ref := p.labelRefs[i]
+		return string(p.labelRefHTML(ref.label, ref.noWarnings, ref.pos))
+	}))
+}
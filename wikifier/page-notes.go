@@ -0,0 +1,84 @@
+package wikifier
+
+import (
+	"html"
+	"strings"
+)
+
+// An EditorNote is a [todo:...] or [review:...] marker, which is a note to
+// the editors of a page. Notes are rendered within the page HTML, but they
+// are meant only for editors; see StripEditorNotes.
+type EditorNote struct {
+	Kind string   `json:"kind"` // todo or review
+	Text string   `json:"text"`
+	Pos  Position `json:"pos"`
+}
+
+// delimiters of editor notes in page HTML
+const (
+	editorNoteStart = "<!--q-editor-note-->"
+	editorNoteEnd   = "<!--/q-editor-note-->"
+)
+
+// kinds of editor notes and the labels displayed with them
+var editorNoteKinds = map[string]string{
+	"todo":   "TODO",
+	"review": "Review",
+}
+
+// [todo:text] or [review:text] - a note to editors
+func (p *Page) editorNote(kind, text string, o *FmtOpt) HTML {
+	text = strings.TrimSpace(text)
+	if text == "" && !o.NoWarnings {
+		p.warn(o.Pos, "["+kind+":] is empty")
+	}
+
+	// the same text may be formatted more than once
+	note := EditorNote{Kind: kind, Text: text, Pos: o.Pos}
+	exists := false
+	for _, n := range p.notes {
+		if n == note {
+			exists = true
+			break
+		}
+	}
+	if !exists {
+		p.notes = append(p.notes, note)
+	}
+
+	return HTML(editorNoteStart +
+		`<span class="q-editor-note q-editor-note-` + kind + `">` +
+		`<span class="q-editor-note-kind">` + editorNoteKinds[kind] + `</span> ` +
+		html.EscapeString(text) +
+		`</span>` + editorNoteEnd)
+}
+
+// EditorNotes returns the [todo:...] and [review:...] markers on the page,
+// in the order they were formatted. EditorNotes should be called after HTML.
+func (p *Page) EditorNotes() []EditorNote {
+	return p.notes
+}
+
+// StripEditorNotes removes [todo:...] and [review:...] markers from page
+// HTML, for readers who are not editors.
+func StripEditorNotes(h HTML) HTML {
+	s := string(h)
+	if !strings.Contains(s, editorNoteStart) {
+		return h
+	}
+	var b strings.Builder
+	for {
+		start := strings.Index(s, editorNoteStart)
+		if start == -1 {
+			break
+		}
+		end := strings.Index(s[start:], editorNoteEnd)
+		if end == -1 {
+			break
+		}
+		b.WriteString(s[:start])
+		s = s[start+end+len(editorNoteEnd):]
+	}
+	b.WriteString(s)
+	return HTML(b.String())
+}
@@ -1,13 +1,21 @@
 package wikifier
 
 import (
+	"html"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
 )
 
+var imageSizeRegex = regexp.MustCompile(`^\d+x\d+$`)
+var versionNameRegex = regexp.MustCompile(`^[\w.\-]+$`)
+
+// extensions of page source files, such as .txt
+var pageExtensionRegex = regexp.MustCompile(`^\.[\w\-]+$`)
+
 // # default options.
 // our %wiki_defaults = (
 //     'external.wp.name'      => 'Wikipedia',
@@ -18,29 +26,77 @@ import (
 
 // PageOpt describes wiki/website options to a Page.
 type PageOpt struct {
-	Name         string // wiki name
-	Logo         string // logo filename, relative to image dir
-	MainPage     string // name of main page
-	ErrorPage    string // name of error page
-	Template     string // name of template
-	MainRedirect bool   // redirect on main page rather than serve root
-	Page         PageOptPage
-	Host         PageOptHost
-	Dir          PageOptDir
-	Root         PageOptRoot
-	Image        PageOptImage
-	Category     PageOptCategory
-	Search       PageOptSearch
-	Link         PageOptLink
-	External     map[string]PageOptExternal
-	Navigation   []PageOptNavigation
+	Name          string // wiki name
+	Logo          string // logo filename, relative to image dir
+	MainPage      string // name of main page
+	ErrorPage     string // name of error page
+	Template      string // name of template
+	MainRedirect  bool   // redirect on main page rather than serve root
+	Deterministic bool   // produce byte-for-byte reproducible output
+	Page          PageOptPage
+	Host          PageOptHost
+	Dir           PageOptDir
+	Root          PageOptRoot
+	Image         PageOptImage
+	Category      PageOptCategory
+	Search        PageOptSearch
+	Review        PageOptReview
+	Captcha       PageOptCaptcha
+	Filter        PageOptFilter
+	HTML          PageOptHTML
+	Notify        PageOptNotify
+	CDN           PageOptCDN
+	Version       PageOptVersion
+	Groups        map[string][]string // usernames of the members of each group
+	Permissions   map[string][]string // page patterns which only members of each group can edit
+	Schema        map[string]PageOptSchema
+	Link          PageOptLink
+	External      map[string]PageOptExternal
+	Navigation    []PageOptNavigation
 }
 
 // PageOptPage describes option relating to a page.
 type PageOptPage struct {
-	EnableTitle bool        // enable page title headings
-	EnableCache bool        // enable page caching
-	Code        PageOptCode // `code{}` block options
+	EnableTitle bool             // enable page title headings
+	EnableCache bool             // enable page caching
+	TOCDepth    int              // levels of sections in tables of contents; 0 for all
+	Code        PageOptCode      // `code{}` block options
+	Math        PageOptMath      // `math{}` block and [math] options
+	Diagram     PageOptDiagram   // `diagram{}` block options
+	Plaintext   PageOptPlaintext // pages from plain text files
+	Lint        PageOptLint      // source lint rules
+	Extensions  []PageOptExtension
+
+	// link @username mentions in text to author pages
+	EnableMentions bool
+
+	// move the inline styles of elements into the page CSS, so that pages
+	// can be displayed without style attributes
+	EnableScopedStyles bool
+
+	// returns the names of the people who have edited a page, for
+	// contributors{}. the wiki finds them in the revision history
+	Contributors func(page *Page) []string
+
+	// returns the pages which link to a page, for backlinks{}. the wiki
+	// finds them in the links it records when generating pages
+	Backlinks func(page *Page) []PageInfo
+
+	// returns the pages matching a query, for pages-where{}. the wiki
+	// finds them in the page metadata it records when generating pages
+	Query func(page *Page, q PageQuery) []PageInfo
+}
+
+// PageOptExtension maps an extension of page source files to the translator
+// which converts them to quiki source.
+type PageOptExtension struct {
+	Ext        string // extension including the dot, such as .md
+	Translator string // name of a translator, such as markdown
+}
+
+// PageOptLint describes lint rules which produce warnings for a page.
+type PageOptLint struct {
+	ImageAlt bool // warn about images without alt text
 }
 
 // PageOptHost describes HTTP hosts for a wiki.
@@ -54,6 +110,34 @@ type PageOptCode struct {
 	Style string
 }
 
+// PageOptMath describes options for `math{}` blocks and [math] formatting.
+//
+// By default, TeX is output within \( \) or \[ \] delimiters for rendering
+// in the browser, such as by KaTeX's auto-render extension. If Render or
+// Command is set, it is rendered on the server instead.
+type PageOptMath struct {
+	Command string                                       // program which renders TeX from stdin to HTML, such as katex
+	Render  func(tex string, display bool) (HTML, error) // renders TeX to HTML, taking precedence over Command
+}
+
+// PageOptDiagram describes options for `diagram{}` blocks.
+//
+// By default, the diagram source is output for rendering in the browser, such
+// as by mermaid.js. If Render or a command for the language is set, it is
+// rendered to SVG on the server instead.
+type PageOptDiagram struct {
+	Mermaid  string                                       // program which renders Mermaid from stdin to SVG, such as mmdc
+	Graphviz string                                       // program which renders DOT from stdin to SVG, such as dot -Tsvg
+	Render   func(lang, src string) (svg HTML, err error) // renders a diagram to SVG, taking precedence over the commands
+}
+
+// PageOptPlaintext describes options for pages from plain text files, such as
+// .txt and .log files.
+type PageOptPlaintext struct {
+	LineNumbers bool // show line numbers
+	Download    bool // link to download the file
+}
+
 // PageOptDir describes actual filepaths to wiki resources.
 type PageOptDir struct {
 	Wiki     string // path to wiki root directory
@@ -70,16 +154,63 @@ type PageOptRoot struct {
 	Wiki     string // wiki root path
 	Image    string // image root path
 	Category string // category root path
+	Author   string // author root path
 	Page     string // page root path
 	File     string // file index path
+	Ext      string // external URL of the HTTP root, for absolute links
 }
 
 // PageOptImage describes wiki imaging options.
 type PageOptImage struct {
-	Retina     []int
-	SizeMethod string
-	Calc       func(file string, width, height int, page *Page) (w, h int, fullSize bool)
-	Sizer      func(file string, width, height int, page *Page) (path string)
+	Retina       []int
+	SizeMethod   string
+	Calc         func(file string, width, height int, page *Page) (w, h int, fullSize bool)
+	Sizer        func(file string, width, height int, page *Page) (path string)
+	ResizeSecret string   // key for signing URLs to resize images on demand
+	ResizeSizes  []string // dimensions such as 300x200 which can be requested without a signature
+
+	// returns the path of an image cropped to the dimensions around a
+	// focal point, given in percent from the top left
+	Cropper func(file string, width, height, focusX, focusY int, page *Page) (path string)
+
+	// commands which convert generated images to other formats, offered
+	// alongside the original in <picture>. $in and $out are replaced with
+	// the source and destination paths
+	AVIF string // as in avifenc $in $out
+	WebP string // as in cwebp -quiet $in -o $out
+
+	// converts the image at the path returned by Sizer or Cropper to a
+	// format, returning whether it is available in that format. images are
+	// only offered in formats which this reports
+	Converter func(path, format string, page *Page) bool
+
+	AutoOrient    bool // rotate generated images by their EXIF orientation
+	StripMetadata bool // serve images without EXIF and other metadata
+}
+
+// Formats returns the formats which images are converted to, most
+// preferred first.
+func (opt PageOptImage) Formats() []string {
+	var formats []string
+	if opt.AVIF != "" {
+		formats = append(formats, "avif")
+	}
+	if opt.WebP != "" {
+		formats = append(formats, "webp")
+	}
+	return formats
+}
+
+// FormatCommand returns the command which converts images to the given
+// format, or an empty string if the format is not enabled.
+func (opt PageOptImage) FormatCommand(format string) string {
+	switch format {
+	case "avif":
+		return opt.AVIF
+	case "webp":
+		return opt.WebP
+	}
+	return ""
 }
 
 // PageOptCategory describes wiki category options.
@@ -92,6 +223,82 @@ type PageOptSearch struct {
 	Enable bool
 }
 
+// PageOptReview describes wiki moderation options.
+type PageOptReview struct {
+	Enable    bool     // require edits to be approved by an editor
+	Editors   []string // usernames of users who can edit directly and approve edits
+	Anonymous bool     // allow unauthenticated users to propose edits
+}
+
+// PageOptCaptcha describes the challenge which protects public forms, such
+// as anonymous edit proposals, from spam.
+type PageOptCaptcha struct {
+	Provider  string // math, hcaptcha, or turnstile
+	SiteKey   string // hcaptcha or turnstile site key
+	SecretKey string // hcaptcha or turnstile secret key
+}
+
+// PageOptFilter describes the filters which check edits for spam and abuse.
+type PageOptFilter struct {
+	Blocklist       string // file of patterns which edits may not add, relative to wiki dir
+	MaxLinks        int    // most external links an edit may add, or 0 for no limit
+	NewUserEdits    int    // users with fewer revisions than this are new users
+	NewUserMaxLinks int    // most external links an edit by a new user may add
+	Action          string // what to do with edits which fail a filter: reject or review
+}
+
+// PageOptHTML describes the policy for raw HTML in html{} blocks and
+// [html:] tags.
+type PageOptHTML struct {
+	Sanitize    bool     // remove elements and attributes not allowed by the policy
+	Elements    []string // elements allowed in addition to the defaults
+	Attributes  []string // attributes allowed in addition to the defaults, as attr or element.attr
+	IframeHosts []string // hosts from which iframes are allowed
+}
+
+// PageOptSchema describes the variables and blocks required of the pages in
+// a directory or category.
+type PageOptSchema struct {
+	Pages      []string // page patterns, as in permissions.[name]
+	Categories []string // categories whose pages must conform
+	Vars       []string // variables which must be set
+	Blocks     []string // types of blocks which must be present
+	Action     string   // what to do with edits which do not conform: warn or reject
+}
+
+// PageOptNotify describes chat notification options.
+type PageOptNotify struct {
+	Slack   string // Slack incoming webhook URL
+	Discord string // Discord webhook URL
+	Matrix  string // Matrix room message URL, including access token
+	URL     string // base URL of the wiki, used for links in notifications
+	DiffURL string // URL of a diff, where $commit and $file are replaced
+}
+
+// PageOptCDN describes content delivery networks whose caches are purged
+// when pages change.
+type PageOptCDN struct {
+	CloudFrontDistribution string // CloudFront distribution ID
+	CloudFrontAccessKey    string // AWS access key ID
+	CloudFrontSecretKey    string // AWS secret access key
+	FastlyKey              string // Fastly API token
+	CloudflareZone         string // Cloudflare zone ID
+	CloudflareToken        string // Cloudflare API token
+}
+
+// PageOptVersion describes versions of a wiki which are served alongside it,
+// such as documentation for older releases.
+type PageOptVersion struct {
+	Name string              // name of the version served at the wiki root
+	List []PageOptVersionRef // other versions, in order
+}
+
+// PageOptVersionRef represents a version of a wiki at a git branch or tag.
+type PageOptVersionRef struct {
+	Name string // version name, used in URLs such as /v1/
+	Ref  string // git branch or tag
+}
+
 // A PageOptLinkFunction sanitizes a link target.
 type PageOptLinkFunction func(page *Page, opts *PageOptLinkOpts)
 
@@ -131,6 +338,10 @@ type PageOptExternal struct {
 	Name string              // long name (e.g. Wikipedia)
 	Root string              // wiki page root (no trailing slash)
 	Type PageOptExternalType // wiki type
+
+	// page URL with $1 in place of the page name, from @interwiki.
+	// if present, this is used instead of Root and Type
+	URL string
 }
 
 // PageOptNavigation represents an ordered navigation item.
@@ -147,6 +358,19 @@ var defaultPageOpt = PageOpt{
 		Code: PageOptCode{
 			Style: "monokailight",
 		},
+		Lint: PageOptLint{
+			ImageAlt: true,
+		},
+		Extensions: []PageOptExtension{
+			{".page", "quiki"},
+			{".md", "markdown"},
+			{".txt", "plaintext"},
+			{".log", "plaintext"},
+			{".csv", "csv"},
+		},
+		Plaintext: PageOptPlaintext{
+			Download: true,
+		},
 	},
 	Host: PageOptHost{
 		Wiki: "", // aka all hosts
@@ -164,6 +388,7 @@ var defaultPageOpt = PageOpt{
 		Page:     "", // aka /
 		Image:    "/images",
 		Category: "/topic",
+		Author:   "/author",
 		File:     "",
 	},
 	Image: PageOptImage{
@@ -171,10 +396,14 @@ var defaultPageOpt = PageOpt{
 		SizeMethod: "javascript",
 		Calc:       nil,
 		Sizer:      nil,
+		AutoOrient: true,
 	},
 	Category: PageOptCategory{
 		PerPage: 5,
 	},
+	Version: PageOptVersion{
+		Name: "latest",
+	},
 	Search: PageOptSearch{
 		Enable: true,
 	},
@@ -184,7 +413,7 @@ var defaultPageOpt = PageOpt{
 		ParseCategory: nil,
 	},
 	External: map[string]PageOptExternal{
-		"wp": {"Wikipedia", "https://en.wikipedia.org/wiki", PageOptExternalTypeMediaWiki},
+		"wp": {Name: "Wikipedia", Root: "https://en.wikipedia.org/wiki", Type: PageOptExternalTypeMediaWiki},
 	},
 }
 
@@ -194,20 +423,49 @@ func InjectPageOpt(page *Page, opt *PageOpt) error {
 
 	// easy string options
 	pageOptString := map[string]*string{
-		"name":            &opt.Name,            // wiki name
-		"logo":            &opt.Logo,            // logo filename, relative to image dir
-		"main_page":       &opt.MainPage,        // main page name
-		"error_page":      &opt.ErrorPage,       // error page name
-		"template":        &opt.Template,        // template name
-		"host.wiki":       &opt.Host.Wiki,       // wiki host
-		"dir.wiki":        &opt.Dir.Wiki,        // wiki directory
-		"root.wiki":       &opt.Root.Wiki,       // http path to wiki
-		"root.image":      &opt.Root.Image,      // http path to images
-		"root.category":   &opt.Root.Category,   // http path to categories
-		"root.page":       &opt.Root.Page,       // http path to pages
-		"root.file":       &opt.Root.File,       // http path to file index
-		"page.code.lang":  &opt.Page.Code.Lang,  // code{} language
-		"page.code.style": &opt.Page.Code.Style, // code{} style
+		"name":              &opt.Name,              // wiki name
+		"logo":              &opt.Logo,              // logo filename, relative to image dir
+		"main_page":         &opt.MainPage,          // main page name
+		"error_page":        &opt.ErrorPage,         // error page name
+		"template":          &opt.Template,          // template name
+		"host.wiki":         &opt.Host.Wiki,         // wiki host
+		"dir.wiki":          &opt.Dir.Wiki,          // wiki directory
+		"root.wiki":         &opt.Root.Wiki,         // http path to wiki
+		"root.image":        &opt.Root.Image,        // http path to images
+		"root.category":     &opt.Root.Category,     // http path to categories
+		"root.author":       &opt.Root.Author,       // http path to authors
+		"root.page":         &opt.Root.Page,         // http path to pages
+		"root.file":         &opt.Root.File,         // http path to file index
+		"root.ext":          &opt.Root.Ext,          // external URL of http root
+		"page.code.lang":    &opt.Page.Code.Lang,    // code{} language
+		"page.code.style":   &opt.Page.Code.Style,   // code{} style
+		"page.math.command": &opt.Page.Math.Command, // math{} renderer
+		"notify.slack":      &opt.Notify.Slack,      // slack webhook
+		"notify.discord":    &opt.Notify.Discord,    // discord webhook
+		"notify.matrix":     &opt.Notify.Matrix,     // matrix room message URL
+		"notify.url":        &opt.Notify.URL,        // wiki URL for notification links
+		"notify.diff_url":   &opt.Notify.DiffURL,    // diff URL for notification links
+		"version.name":      &opt.Version.Name,      // name of the current version
+
+		"captcha.provider":   &opt.Captcha.Provider,  // captcha provider
+		"captcha.site_key":   &opt.Captcha.SiteKey,   // hosted captcha site key
+		"captcha.secret_key": &opt.Captcha.SecretKey, // hosted captcha secret key
+
+		"filter.blocklist": &opt.Filter.Blocklist, // file of blocked patterns
+		"filter.action":    &opt.Filter.Action,    // action on filtered edits
+
+		"page.diagram.mermaid":  &opt.Page.Diagram.Mermaid,  // diagram{} mermaid renderer
+		"page.diagram.graphviz": &opt.Page.Diagram.Graphviz, // diagram{} graphviz renderer
+
+		"image.format.avif": &opt.Image.AVIF, // avif converter
+		"image.format.webp": &opt.Image.WebP, // webp converter
+
+		"cdn.cloudfront.distribution": &opt.CDN.CloudFrontDistribution, // cloudfront distribution ID
+		"cdn.cloudfront.access_key":   &opt.CDN.CloudFrontAccessKey,    // aws access key ID
+		"cdn.cloudfront.secret_key":   &opt.CDN.CloudFrontSecretKey,    // aws secret access key
+		"cdn.fastly.key":              &opt.CDN.FastlyKey,              // fastly API token
+		"cdn.cloudflare.zone":         &opt.CDN.CloudflareZone,         // cloudflare zone ID
+		"cdn.cloudflare.token":        &opt.CDN.CloudflareToken,        // cloudflare API token
 	}
 	for name, ptr := range pageOptString {
 		str, err := page.GetStr(name)
@@ -230,15 +488,30 @@ func InjectPageOpt(page *Page, opt *PageOpt) error {
 	opt.Root.Wiki = filepath.ToSlash(opt.Root.Wiki)
 	opt.Root.Image = filepath.ToSlash(opt.Root.Image)
 	opt.Root.Category = filepath.ToSlash(opt.Root.Category)
+	opt.Root.Author = filepath.ToSlash(opt.Root.Author)
 	opt.Root.Page = filepath.ToSlash(opt.Root.Page)
 	opt.Root.File = filepath.ToSlash(opt.Root.File)
 
 	// easy bool options
 	pageOptBool := map[string]*bool{
-		"main_redirect":     &opt.MainRedirect,     // redirect root to main page
-		"page.enable.title": &opt.Page.EnableTitle, // enable page title headings
-		"page.enable.cache": &opt.Page.EnableCache, // enable page caching
-		"search.enable":     &opt.Search.Enable,    // enable search optimization
+		"main_redirect":       &opt.MainRedirect,       // redirect root to main page
+		"deterministic":       &opt.Deterministic,      // reproducible output
+		"page.enable.title":   &opt.Page.EnableTitle,   // enable page title headings
+		"page.enable.cache":   &opt.Page.EnableCache,   // enable page caching
+		"search.enable":       &opt.Search.Enable,      // enable search optimization
+		"review.enable":       &opt.Review.Enable,      // enable moderation
+		"review.anonymous":    &opt.Review.Anonymous,   // enable anonymous edit proposals
+		"page.lint.image_alt": &opt.Page.Lint.ImageAlt, // warn about images without alt text
+
+		"page.enable.mentions":      &opt.Page.EnableMentions,     // link @username mentions
+		"page.enable.scoped_styles": &opt.Page.EnableScopedStyles, // element styles in page CSS
+
+		"page.plaintext.line_numbers": &opt.Page.Plaintext.LineNumbers, // line numbers on plain text pages
+		"page.plaintext.download":     &opt.Page.Plaintext.Download,    // download link on plain text pages
+
+		"image.auto_orient":    &opt.Image.AutoOrient,    // rotate images by exif orientation
+		"image.strip_metadata": &opt.Image.StripMetadata, // serve images without metadata
+		"html.sanitize":        &opt.HTML.Sanitize,       // sanitize raw html
 	}
 	for name, ptr := range pageOptBool {
 		val, err := page.Get(name)
@@ -288,6 +561,27 @@ func InjectPageOpt(page *Page, opt *PageOpt) error {
 		opt.Image.SizeMethod = str
 	}
 
+	// image.resize.secret - key for signing on-demand resize URLs
+	str, err = page.GetStr("image.resize.secret")
+	if err != nil {
+		return errors.Wrap(err, "image.resize.secret")
+	}
+	opt.Image.ResizeSecret = str
+
+	// image.resize.sizes - dimensions allowed without a signature
+	str, err = page.GetStr("image.resize.sizes")
+	if err != nil {
+		return errors.Wrap(err, "image.resize.sizes")
+	}
+	if str != "" {
+		opt.Image.ResizeSizes = commaList(str)
+		for _, size := range opt.Image.ResizeSizes {
+			if !imageSizeRegex.MatchString(size) {
+				return errors.New("image.resize.sizes: must be list of dimensions like 300x200")
+			}
+		}
+	}
+
 	// cat.per_page - how many posts to show on each page of /topic
 	str, err = page.GetStr("cat.per_page")
 	if err != nil {
@@ -301,15 +595,181 @@ func InjectPageOpt(page *Page, opt *PageOpt) error {
 		opt.Category.PerPage = intVal
 	}
 
+	// page.toc.depth - levels of sections in tables of contents
+	str, err = page.GetStr("page.toc.depth")
+	if err != nil {
+		return errors.Wrap(err, "page.toc.depth")
+	}
+	if str != "" {
+		intVal, err := strconv.Atoi(str)
+		if err != nil || intVal < 0 {
+			return errors.New("page.toc.depth: must be a non-negative integer")
+		}
+		opt.Page.TOCDepth = intVal
+	}
+
+	// review.editors - users who can approve edits
+	str, err = page.GetStr("review.editors")
+	if err != nil {
+		return errors.Wrap(err, "review.editors")
+	}
+	if str != "" {
+		opt.Review.Editors = commaList(str)
+	}
+
+	// captcha.provider - which captcha protects public forms
+	switch opt.Captcha.Provider {
+	case "", "math", "hcaptcha", "turnstile":
+	default:
+		return errors.New("captcha.provider: must be one of 'math', 'hcaptcha', or 'turnstile'")
+	}
+
+	// filter.action - what to do with edits which fail a filter
+	switch opt.Filter.Action {
+	case "", "reject", "review":
+	default:
+		return errors.New("filter.action: must be one of 'reject' or 'review'")
+	}
+
+	// filter.max_links - most external links an edit may add
+	// filter.new_user.edits - revisions before a user is no longer new
+	// filter.new_user.max_links - most external links a new user may add
+	for optName, ptr := range map[string]*int{
+		"filter.max_links":          &opt.Filter.MaxLinks,
+		"filter.new_user.edits":     &opt.Filter.NewUserEdits,
+		"filter.new_user.max_links": &opt.Filter.NewUserMaxLinks,
+	} {
+		str, err := page.GetStr(optName)
+		if err != nil {
+			return errors.Wrap(err, optName)
+		}
+		if str == "" {
+			continue
+		}
+		intVal, err := strconv.Atoi(str)
+		if err != nil || intVal < 0 {
+			return errors.New(optName + ": must be a non-negative integer")
+		}
+		*ptr = intVal
+	}
+
+	// html.elements - elements allowed in raw html
+	// html.attributes - attributes allowed in raw html
+	// html.iframe_hosts - hosts from which iframes are allowed
+	for optName, ptr := range map[string]*[]string{
+		"html.elements":     &opt.HTML.Elements,
+		"html.attributes":   &opt.HTML.Attributes,
+		"html.iframe_hosts": &opt.HTML.IframeHosts,
+	} {
+		str, err := page.GetStr(optName)
+		if err != nil {
+			return errors.Wrap(err, optName)
+		}
+		if str != "" {
+			*ptr = commaList(strings.ToLower(str))
+		}
+	}
+	if err := checkHTMLPolicy(opt.HTML); err != nil {
+		return err
+	}
+
+	// groups.[name] - users in each group
+	// permissions.[name] - page patterns restricted to each group
+	for optName, ptr := range map[string]*map[string][]string{
+		"groups":      &opt.Groups,
+		"permissions": &opt.Permissions,
+	} {
+		obj, err := page.GetObj(optName)
+		if err != nil {
+			return errors.Wrap(err, optName)
+		}
+		if obj == nil {
+			continue
+		}
+		groupMap, ok := obj.(*Map)
+		if !ok {
+			return errors.New(optName + ": must be map{}")
+		}
+		*ptr = make(map[string][]string)
+		for _, group := range groupMap.Keys() {
+			str, err := groupMap.GetStr(group)
+			if err != nil {
+				return errors.Wrap(err, optName+"."+group+": must be string")
+			}
+			(*ptr)[group] = commaList(str)
+		}
+	}
+
+	// schema.[name] - variables and blocks required of some pages
+	obj, err := page.GetObj("schema")
+	if err != nil {
+		return errors.Wrap(err, "schema")
+	}
+	if obj != nil {
+		schemaMap, ok := obj.(*Map)
+		if !ok {
+			return errors.New("schema: must be map{}")
+		}
+		opt.Schema = make(map[string]PageOptSchema)
+		for _, name := range schemaMap.Keys() {
+			prefix := "schema." + name
+			var schema PageOptSchema
+			for key, ptr := range map[string]*[]string{
+				"pages":      &schema.Pages,
+				"categories": &schema.Categories,
+				"vars":       &schema.Vars,
+				"blocks":     &schema.Blocks,
+			} {
+				str, err := page.GetStr(prefix + "." + key)
+				if err != nil {
+					return errors.Wrap(err, prefix+"."+key+": must be string")
+				}
+				*ptr = commaList(html.UnescapeString(str))
+			}
+			if len(schema.Pages) == 0 && len(schema.Categories) == 0 {
+				return errors.New(prefix + ": pages or categories required")
+			}
+			schema.Action, err = page.GetStr(prefix + ".action")
+			if err != nil {
+				return errors.Wrap(err, prefix+".action")
+			}
+			switch schema.Action {
+			case "":
+				schema.Action = "warn"
+			case "warn", "reject":
+			default:
+				return errors.New(prefix + ".action: must be one of 'warn' or 'reject'")
+			}
+			opt.Schema[name] = schema
+		}
+	}
+
 	// navigation - ordered navigation items
-	obj, err := page.GetObj("navigation")
+	obj, err = page.GetObj("navigation")
 	if err != nil {
 		return errors.Wrap(err, "navigation")
 	}
-	if obj != nil {
+	if navList, ok := obj.(*List); ok {
+
+		// list of items like Display | link, such as from @navigation+:
+		items, _ := page.GetStrList("navigation")
+		if len(items) != len(navList.list) {
+			return errors.New("navigation: list values must be string")
+		}
+		for _, item := range items {
+			pipe := strings.LastIndexByte(item, '|')
+			if pipe == -1 {
+				return errors.New("navigation: list items must be like Display | link")
+			}
+			opt.Navigation = append(opt.Navigation, PageOptNavigation{
+				Display: html.UnescapeString(strings.TrimSpace(item[:pipe])),
+				Link:    html.UnescapeString(strings.TrimSpace(item[pipe+1:])),
+			})
+		}
+	} else if obj != nil {
 		navMap, ok := obj.(*Map)
 		if !ok {
-			return errors.New("navigation: must be map{}")
+			return errors.New("navigation: must be map{} or list{}")
 		}
 
 		for _, display := range navMap.OrderedKeys() {
@@ -325,7 +785,156 @@ func InjectPageOpt(page *Page, opt *PageOpt) error {
 		}
 	}
 
-	// TODO: External wikis
+	// version.list - other versions of the wiki, mapped to git refs
+	obj, err = page.GetObj("version.list")
+	if err != nil {
+		return errors.Wrap(err, "version.list")
+	}
+	if obj != nil {
+		versionMap, ok := obj.(*Map)
+		if !ok {
+			return errors.New("version.list: must be map{}")
+		}
+		opt.Version.List = nil
+		// keys are normalized, so use the key as written for the URL
+		for _, entry := range versionMap.mapList {
+			name := entry.keyTitle
+			ref, err := versionMap.GetStr(entry.key)
+			if err != nil {
+				return errors.Wrap(err, "version.list: map values must be string")
+			}
+			if !versionNameRegex.MatchString(name) {
+				return errors.New("version.list: invalid version name '" + name + "'")
+			}
+			opt.Version.List = append(opt.Version.List, PageOptVersionRef{
+				Name: name,
+				Ref:  strings.TrimSpace(ref),
+			})
+		}
+	}
+
+	// page.extensions - page source extensions, mapped to translators
+	obj, err = page.GetObj("page.extensions")
+	if err != nil {
+		return errors.Wrap(err, "page.extensions")
+	}
+	if obj != nil {
+		extMap, ok := obj.(*Map)
+		if !ok {
+			return errors.New("page.extensions: must be map{}")
+		}
+		// copy so the defaults are not modified
+		exts := append([]PageOptExtension(nil), opt.Page.Extensions...)
+		for _, entry := range extMap.mapList {
+			ext := "." + strings.TrimPrefix(entry.keyTitle, ".")
+			if !pageExtensionRegex.MatchString(ext) {
+				return errors.New("page.extensions: invalid extension '" + entry.keyTitle + "'")
+			}
+			name, err := extMap.GetStr(entry.key)
+			if err != nil {
+				return errors.Wrap(err, "page.extensions: map values must be string")
+			}
+			name = strings.TrimSpace(name)
+			if _, ok := pageTranslator(name); !ok {
+				return errors.New("page.extensions: no translator named '" + name + "'")
+			}
+			if ext == ".model" || ext == ".conf" || ext == ".cat" {
+				return errors.New("page.extensions: " + ext + " is not available for pages")
+			}
+
+			// replace or add
+			found := false
+			for i, e := range exts {
+				if e.Ext == ext {
+					exts[i].Translator, found = name, true
+				}
+			}
+			if !found {
+				exts = append(exts, PageOptExtension{ext, name})
+			}
+		}
+		opt.Page.Extensions = exts
+	}
+
+	// external.[wiki_id] - external wikis
+	// interwiki.[wiki_id] - external wikis by page URL, like .../wiki/$1
+	for _, optName := range []string{"external", "interwiki"} {
+		obj, err := page.GetObj(optName)
+		if err != nil {
+			return errors.Wrap(err, optName)
+		}
+		if obj == nil {
+			continue
+		}
+		extMap, ok := obj.(*Map)
+		if !ok {
+			return errors.New(optName + ": must be map{}")
+		}
+
+		// copy so that the defaults are not modified
+		exts := make(map[string]PageOptExternal, len(opt.External))
+		for id, ext := range opt.External {
+			exts[id] = ext
+		}
+		opt.External = exts
+
+		for _, id := range extMap.Keys() {
+			prefix := optName + "." + id
+			if optName == "interwiki" {
+				url, err := page.GetStr(prefix)
+				if err != nil {
+					return errors.Wrap(err, prefix+": must be string")
+				}
+				if !strings.Contains(url, "$1") {
+					return errors.New(prefix + ": must contain $1 in place of the page name")
+				}
+				name := opt.External[id].Name
+				if name == "" {
+					name = id
+				}
+				opt.External[id] = PageOptExternal{Name: name, URL: url}
+				continue
+			}
+
+			ext := PageOptExternal{Name: id, Type: PageOptExternalTypeQuiki}
+			for key, ptr := range map[string]*string{"name": &ext.Name, "root": &ext.Root} {
+				str, err := page.GetStr(prefix + "." + key)
+				if err != nil {
+					return errors.Wrap(err, prefix+"."+key)
+				}
+				if str != "" {
+					*ptr = str
+				}
+			}
+			if ext.Root == "" {
+				return errors.New(prefix + ".root: required")
+			}
+			ext.Root = strings.TrimSuffix(ext.Root, "/")
+			typ, err := page.GetStr(prefix + ".type")
+			if err != nil {
+				return errors.Wrap(err, prefix+".type")
+			}
+			switch t := PageOptExternalType(typ); t {
+			case "":
+			case PageOptExternalTypeQuiki, PageOptExternalTypeMediaWiki, PageOptExternalTypeNone:
+				ext.Type = t
+			default:
+				return errors.New(prefix + ".type: must be one of 'quiki', 'mediawiki', or 'none'")
+			}
+			opt.External[id] = ext
+		}
+	}
 
 	return nil
 }
+
+// splits a comma-separated list, ignoring whitespace and empty items
+func commaList(str string) []string {
+	var list []string
+	for _, item := range strings.Split(str, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,26 @@
+package wikifier
+
+import "strings"
+
+// Slides returns the HTML of each top-level block of the page, such as each
+// top-level section, for presenting the page as slides. Blocks which display
+// nothing are omitted.
+//
+// Each slide is wrapped in an element with the class of the main block, so
+// that the page CSS applies to it. The page must be generated with HTML
+// first.
+func (p *Page) Slides() []HTML {
+	if p.main == nil {
+		return nil
+	}
+	wrapStart := HTML(`<div class="q-` + p.main.el().id() + `">` + "\n")
+	var slides []HTML
+	for _, blk := range p.main.blockContent() {
+		html := blk.el().generate()
+		if strings.TrimSpace(string(html)) == "" {
+			continue
+		}
+		slides = append(slides, wrapStart+html+"</div>\n")
+	}
+	return slides
+}
@@ -0,0 +1,160 @@
+package wikifier
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	strip "github.com/grokify/html-strip-tags-go"
+)
+
+// A TOCEntry is a section in a page's table of contents.
+type TOCEntry struct {
+	Title    string     `json:"title"`              // section title without formatting
+	FmtTitle HTML       `json:"fmt_title"`          // section title with formatting
+	ID       string     `json:"id"`                 // heading ID; the anchor is #qa-ID
+	Sections []TOCEntry `json:"sections,omitempty"` // subsections
+}
+
+// A SectionInfo describes a section of a page, such as for a reading progress
+// indicator.
+type SectionInfo struct {
+	ID    string `json:"id"`    // heading ID; the anchor is #qa-ID
+	Title string `json:"title"` // section title without formatting
+	Depth int    `json:"depth"` // 1 for top-level sections
+	Words int    `json:"words"` // number of words, not including subsections
+}
+
+// TOC returns the table of contents for the page.
+//
+// Like toc{}, untitled sections and the intro section are omitted, and their
+// subsections are listed in their place. Sections with the notoc class are
+// omitted along with their subsections, and no more than @page.toc.depth
+// levels are listed. TOC should be called after HTML, so that heading IDs
+// which appear more than once are made unique.
+func (p *Page) TOC() []TOCEntry {
+	if p.main == nil {
+		return nil
+	}
+	var toc []TOCEntry
+	limit := p.tocDepth()
+	for _, child := range p.main.blockContent() {
+		if sec, ok := child.(*secBlock); ok {
+			toc = p.tocAdd(sec, toc, 1, limit)
+		}
+	}
+	return toc
+}
+
+func (p *Page) tocAdd(sec *secBlock, toc []TOCEntry, depth, limit int) []TOCEntry {
+	if sec.noTOC() {
+		return toc
+	}
+	listed := !sec.isIntro && sec.title != ""
+
+	// subsections
+	var sections []TOCEntry
+	subDepth := depth
+	if listed {
+		subDepth++
+	}
+	if limit == 0 || subDepth <= limit {
+		for _, child := range sec.blockContent() {
+			if secChild, ok := child.(*secBlock); ok {
+				sections = p.tocAdd(secChild, sections, subDepth, limit)
+			}
+		}
+	}
+
+	// lift them if this one is not listed
+	if !listed {
+		return append(toc, sections...)
+	}
+
+	fmtTitle := p.tocTitle(sec)
+	return append(toc, TOCEntry{
+		Title:    strip.StripTags(string(fmtTitle)),
+		FmtTitle: fmtTitle,
+		ID:       sec.headingID,
+		Sections: sections,
+	})
+}
+
+// formats a section title for a table of contents. entries are links, so
+// links and footnote references are removed from the title
+func (p *Page) tocTitle(sec *secBlock) HTML {
+	fmtTitle := sec.fmtTitle
+	if fmtTitle == "" {
+		fmtTitle = p.Fmt(sec.title, sec.openPos)
+	}
+	return HTML(tocStripRegex.ReplaceAllString(string(fmtTitle), ""))
+}
+
+var tocStripRegex = regexp.MustCompile(`<sup class="q-ref"[^>]*>.*?</sup>|</?a\b[^>]*>`)
+
+// levels of sections in tables of contents, from @page.toc.depth or the
+// wiki option. 0 means all
+func (p *Page) tocDepth() int {
+	if str, _ := p.GetStr("page.toc.depth"); str != "" {
+		if depth, err := strconv.Atoi(str); err == nil && depth >= 0 {
+			return depth
+		}
+	}
+	return p.Opt.Page.TOCDepth
+}
+
+// true if the section is not listed in tables of contents
+func (sec *secBlock) noTOC() bool {
+	for _, class := range sec.classes {
+		if class == "notoc" {
+			return true
+		}
+	}
+	return false
+}
+
+// Sections returns information about every section of the page in the order
+// they appear, including untitled sections and the intro section. Like TOC,
+// Sections should be called after HTML.
+func (p *Page) Sections() []SectionInfo {
+	if p.main == nil {
+		return nil
+	}
+	var sections []SectionInfo
+	var add func(blk block, depth int)
+	add = func(blk block, depth int) {
+		for _, child := range blk.blockContent() {
+			sec, ok := child.(*secBlock)
+			if !ok {
+				continue
+			}
+
+			// words in this section, less those in subsections
+			words := countWords(sec.el().generate())
+			for _, secChild := range sec.blockContent() {
+				if _, ok := secChild.(*secBlock); ok {
+					words -= countWords(secChild.el().generate())
+				}
+			}
+
+			fmtTitle := sec.fmtTitle
+			if fmtTitle == "" && sec.title != "" {
+				fmtTitle = p.Fmt(sec.title, sec.openPos)
+			}
+			sections = append(sections, SectionInfo{
+				ID:    sec.headingID,
+				Title: html.UnescapeString(strip.StripTags(string(fmtTitle))),
+				Depth: depth,
+				Words: words,
+			})
+			add(sec, depth+1)
+		}
+	}
+	add(p.main, 1)
+	return sections
+}
+
+func countWords(h HTML) int {
+	return len(strings.Fields(html.UnescapeString(strip.StripTags(string(h)))))
+}
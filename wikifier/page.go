@@ -6,9 +6,9 @@ import (
 	"errors"
 	"html"
 	"io"
-	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -18,32 +18,61 @@ import (
 	strip "github.com/grokify/html-strip-tags-go"
 )
 
+var elementIDPrefixRegex = regexp.MustCompile(`[^a-z0-9_]+`)
+
 // Page represents a single page or article, generally associated with a .page file.
 // It provides the most basic public interface to parsing with the wikifier engine.
 type Page struct {
-	Source       string   // source content
-	FilePath     string   // Path to the .page file
-	VarsOnly     bool     // True if Parse() should only extract variables
-	Opt          *PageOpt // page options
-	styles       []styleEntry
-	staticStyles []string
-	codeStyles   bool
-	parser       *parser              // wikifier parser instance
-	main         block                // main block
-	Images       map[string][][]int   // references to images
-	Models       map[string]ModelInfo // references to models
-	PageLinks    map[string][]int     // references to other pages
-	sectionN     int
-	name         string
-	headingIDs   map[string]int
-	Wiki         interface{} // only available during Parse() and HTML()
-	Markdown     bool        // true if this is a markdown source
-	model        bool        // true if this is a model being generated
-	Warnings     []Warning   // parser warnings
-	Error        *Warning    // parser error, as an encodable Warning
-	_html        HTML
-	_text        string
-	_preview     string
+	Source        string   // source content
+	FilePath      string   // Path to the .page file
+	VarsOnly      bool     // True if Parse() should only extract variables
+	Opt           *PageOpt // page options
+	Tracer        Tracer   // receives parser events, if set
+	styles        []styleEntry
+	staticStyles  []string
+	codeStyles    bool
+	tabsStyles    bool
+	contributors  []string             // from Opt.Page.Contributors, once fetched
+	parser        *parser              // wikifier parser instance
+	main          block                // main block
+	Images        map[string][][]int   // references to images
+	Galleries     map[string]int       // references to images within galleries
+	Models        map[string]ModelInfo // references to models
+	PageLinks     map[string][]int     // references to other pages
+	ExternalLinks map[string][]int     // references to external URLs
+	DataFiles     map[string][]int     // references to data files
+	Mentions      map[string][]int     // users and groups mentioned as @username
+	sectionN      int
+	name          string
+	headingIDs    map[string]int
+	elementIDs    *elementIDs
+	Wiki          interface{} // only available during Parse() and HTML()
+	Markdown      bool        // true if this is a markdown source, regardless of extension
+	model         bool        // true if this is a model being generated
+	includes      []string    // paths of the pages including this one
+	footnotes     []footnote  // [ref] notes, in order
+	references    element     // references{} element, if any
+	Warnings      []Warning   // parser warnings
+	Error         *Warning    // parser error, as an encodable Warning
+	_html         HTML
+	_text         string
+	_preview      string
+
+	labels map[string]*blockLabel // numbered figures, tables, and equations by label
+	notes  []EditorNote           // [todo:...] and [review:...] markers
+
+	labelsNumbered bool              // true once labels are numbered
+	labelRefs      []pendingLabelRef // [ref:label]s formatted before then
+
+	// pages linking to this one, if listed by backlinks{}
+	backlinks []PageInfo
+
+	// results of pages-where{} queries, in order
+	queries []PageQueryResult
+
+	// foreach{} items expanded so far, shared with the pages of the items
+	foreachItems *int
+
 	*variableScope
 }
 
@@ -64,8 +93,14 @@ type PageInfo struct {
 	Description string     `json:"desc,omitempty"`      // description
 	Keywords    []string   `json:"keywords,omitempty"`  // keywords
 	Preview     string     `json:"preview,omitempty"`   // first 25 words or 150 chars. empty w/ description
+	Hash        string     `json:"hash,omitempty"`      // SHA-256 of the generated HTML. set by the wiki
 	Warnings    []Warning  `json:"warnings,omitempty"`  // parser warnings
 	Error       *Warning   `json:"error,omitempty"`     // parser error, as an encodable warning
+
+	// names of the people who have edited the page, with those who have
+	// made the most revisions first. set by the wiki if the page has
+	// contributors{}
+	Contributors []string `json:"contributors,omitempty"`
 }
 
 // Warning represents a warning on a page.
@@ -82,10 +117,13 @@ func NewPage(filePath string) *Page {
 		Opt:           &myOpt,
 		variableScope: newVariableScope(),
 		Images:        make(map[string][][]int),
+		Galleries:     make(map[string]int),
 		Models:        make(map[string]ModelInfo),
 		PageLinks:     make(map[string][]int),
+		ExternalLinks: make(map[string][]int),
+		DataFiles:     make(map[string][]int),
+		Mentions:      make(map[string][]int),
 		headingIDs:    make(map[string]int),
-		Markdown:      strings.HasSuffix(filePath, ".md"),
 	}
 }
 
@@ -106,6 +144,11 @@ func NewPagePath(filePath, name string) *Page {
 // Parse opens the page file and attempts to parse it, returning any errors encountered.
 func (p *Page) Parse() error {
 
+	// element identifiers are scoped to the page
+	if p.elementIDs == nil {
+		p.elementIDs = newElementIDs(p.elementIDPrefix())
+	}
+
 	// create parser
 	p.parser = newParser(p)
 	p.main = p.parser.block
@@ -114,6 +157,7 @@ func (p *Page) Parse() error {
 	// call underlying parse
 	err := p._parse()
 	if err == nil {
+		p.checkPageVars()
 		return err
 	}
 
@@ -126,6 +170,18 @@ func (p *Page) Parse() error {
 		perr = &ParserError{Pos: p.parser.pos, Err: err}
 	}
 
+	// add context
+	if perr.File == "" {
+		// within a wiki, the name alone, since the error may be displayed
+		perr.File = p.FilePath
+		if !p.External() {
+			perr.File = p.Name()
+		}
+	}
+	if perr.Catch == "" && p.parser.catch != nil {
+		perr.Catch = catchContext(p.parser.catch)
+	}
+
 	// convert to Warning for p.Error
 	p.Error = &Warning{
 		Message: perr.Err.Error(),
@@ -144,20 +200,35 @@ func (p *Page) _parse() error {
 		reader = bytes.NewReader(d)
 	} else if p.Source != "" {
 		reader = strings.NewReader(p.Source)
-	} else if p.Markdown && p.FilePath != "" {
-		md, err := ioutil.ReadFile(p.FilePath)
+	} else if p.FilePath != "" {
+		file, err := os.Open(p.FilePath)
 		if err != nil {
 			return err
 		}
-		d := markdown.Run(md)
-		reader = bytes.NewReader(d)
-	} else if p.FilePath != "" {
-		file, err := os.Open(p.FilePath)
+
+		// find the translator, if it is not quiki source
+		translate, err := p.Opt.translatorFor(p.FilePath)
+		if p.Markdown {
+			translate, err = convertMarkdown, nil
+		}
 		if err != nil {
+			file.Close()
 			return err
 		}
-		defer file.Close()
-		reader = file
+
+		if translate == nil {
+			defer file.Close()
+			reader = file
+		} else {
+			// translate as the parser reads
+			pr, pw := io.Pipe()
+			go func() {
+				defer file.Close()
+				pw.CloseWithError(translate(file, pw))
+			}()
+			defer pr.Close()
+			reader = pr
+		}
 	} else {
 		return errors.New("neither Source nor FilePath provided")
 	}
@@ -185,6 +256,7 @@ func (p *Page) _parse() error {
 	// parse the blocks, unless we only want vars
 	if !p.VarsOnly {
 		p.main.parse(p)
+		p.numberLabels()
 	}
 
 	return nil
@@ -194,7 +266,10 @@ func (p *Page) _parse() error {
 // The page must be parsed with Parse before attempting this method.
 func (p *Page) HTML() HTML {
 	if p._html == "" {
-		p._html = generateBlock(p.main, p)
+		if p.elementIDs != nil {
+			p.elementIDs.scopeStyles = p.scopedStyles()
+		}
+		p._html = p.resolveLabelRefs(generateBlock(p.main, p))
 	}
 	return p._html
 }
@@ -205,7 +280,7 @@ func (p *Page) Text() string {
 	if p._text != "" {
 		return p._text
 	}
-	p._text = html.UnescapeString(strip.StripTags(string(p.HTML())))
+	p._text = html.UnescapeString(strip.StripTags(string(StripEditorNotes(p.HTML()))))
 	return p._text
 }
 
@@ -308,7 +383,7 @@ func (p *Page) OSName() string {
 
 // NameNE returns the resolved page name with No Extension.
 func (p *Page) NameNE() string {
-	return PageNameNE(p.Name())
+	return p.Opt.PageNameNE(p.Name())
 }
 
 // OSNameNE is like NameNE, except it uses the native path separator.
@@ -367,7 +442,7 @@ func (p *Page) RelName() string {
 // This does NOT take symbolic links into account.
 // It is not guaranteed to exist.
 func (p *Page) RelNameNE() string {
-	return PageNameNE(p.RelName())
+	return p.Opt.PageNameNE(p.RelName())
 }
 
 // RelPath returns the unresolved file path to the page.
@@ -408,10 +483,11 @@ func (p *Page) Redirect() string {
 	}
 
 	// @page.redirect
-	if link, err := p.getPageStr("redirect"); err != nil {
-		// FIXME: is there anyway to produce a warning for wrong variable type?
-	} else if ok, target, _, _, _ := p.parseLink(link, &FmtOpt{}); ok {
-		return target
+	// (checkPageVars warns if it is the wrong type)
+	if link, err := p.getPageStr("redirect"); err == nil {
+		if ok, target, _, _, _ := p.parseLink(link, &FmtOpt{}); ok {
+			return target
+		}
 	}
 
 	return ""
@@ -520,6 +596,18 @@ func (p *Page) Author() string {
 	return s
 }
 
+// Contributors returns the names of the people who have edited the page,
+// as provided by the Opt.Page.Contributors function.
+func (p *Page) Contributors() []string {
+	if p.contributors == nil && p.Opt != nil && p.Opt.Page.Contributors != nil {
+		p.contributors = p.Opt.Page.Contributors(p)
+		if p.contributors == nil {
+			p.contributors = []string{}
+		}
+	}
+	return p.contributors
+}
+
 // FmtTitle returns the page title, preserving any possible text formatting.
 func (p *Page) FmtTitle() HTML {
 	s, _ := p.getPageStr("title")
@@ -567,6 +655,27 @@ func (p *Page) Categories() []string {
 	return catMap.Keys()
 }
 
+// HasBlock returns true if the page contains a block of the given type,
+// such as infobox. HasBlock must be called after Parse.
+func (p *Page) HasBlock(blockType string) bool {
+	if p.main == nil {
+		return false
+	}
+	if alias := blockAliases[blockType]; alias != "" {
+		blockType = alias
+	}
+	var find func(blk block) bool
+	find = func(blk block) bool {
+		for _, child := range blk.blockContent() {
+			if child.blockType() == blockType || find(child) {
+				return true
+			}
+		}
+		return false
+	}
+	return find(p.main)
+}
+
 // Info returns the PageInfo for the page.
 func (p *Page) Info() PageInfo {
 
@@ -595,6 +704,9 @@ func (p *Page) Info() PageInfo {
 		Error:       p.Error,
 	}
 
+	// only found if the page lists them with contributors{}
+	info.Contributors = p.contributors
+
 	// file times
 	mod, create := p.Modified(), p.Created()
 	if !mod.IsZero() {
@@ -608,6 +720,15 @@ func (p *Page) Info() PageInfo {
 	return info
 }
 
+// prefix for element identifiers, derived from the page name
+func (p *Page) elementIDPrefix() string {
+	if p.FilePath == "" && p.name == "" {
+		return ""
+	}
+	name := strings.ToLower(p.NameNE())
+	return strings.Trim(elementIDPrefixRegex.ReplaceAllString(name, "-"), "-")
+}
+
 // create a page warning
 func (p *Page) warn(pos Position, warning string) {
 	w := Warning{warning, pos}
@@ -618,6 +739,36 @@ func (p *Page) mainBlock() block {
 	return p.main
 }
 
+// page variables which must be strings or booleans
+var (
+	pageStrVars  = []string{"title", "author", "desc", "description", "created", "redirect"}
+	pageBoolVars = []string{"draft", "generated"}
+)
+
+// produces warnings for @page variables of the wrong type, which would
+// otherwise be quietly ignored
+func (p *Page) checkPageVars() {
+	prefix := "page."
+	if p.model {
+		prefix = "model."
+	}
+	for _, key := range pageStrVars {
+		if _, err := p.getPageStr(key); err != nil {
+			p.warn(p.parser.varPositions[prefix+key], "@"+prefix+key+" ignored: "+err.Error())
+		}
+	}
+	for _, key := range pageBoolVars {
+		if _, err := p.getPageBool(key); err != nil {
+			p.warn(p.parser.varPositions[prefix+key], "@"+prefix+key+" ignored: "+err.Error())
+		}
+	}
+	if _, err := p.GetStrList("page.keywords"); err != nil {
+		if val, _ := p.Get("page.keywords"); val != nil {
+			p.warn(p.parser.varPositions["page.keywords"], "@page.keywords ignored: "+err.Error())
+		}
+	}
+}
+
 // resets the parser
 func (p *Page) resetParseState() {
 	p.parser = nil
@@ -6,7 +6,7 @@ type braceEscape struct {
 }
 
 func newBraceEscape(pos Position) *braceEscape {
-	return &braceEscape{genericCatch: &genericCatch{}}
+	return &braceEscape{genericCatch: newPooledCatch()}
 }
 
 func (be *braceEscape) catchType() catchType {
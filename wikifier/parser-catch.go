@@ -1,7 +1,9 @@
 package wikifier
 
 import (
+	"bytes"
 	"strings"
+	"sync"
 )
 
 type catchType string
@@ -16,12 +18,14 @@ const (
 type catch interface {
 	parentCatch() catch
 	posContent() []posContent
+	empty() bool
 	positionedPrefixContent() []posContent
 	content() []interface{}
 	prefixContent() []interface{}
 	lastString() string
 	setLastContent(item interface{})
 	appendContent(item interface{}, pos Position)
+	appendString(s string, pos Position)
 	appendContents(pc []posContent)
 	byteOK(b byte) bool
 	shouldSkipByte(b byte) bool
@@ -32,7 +36,11 @@ type genericCatch struct {
 	positioned       []posContent
 	positionedPrefix []posContent
 
-	line         string
+	// text appended to the last string, which is joined with it when the
+	// content is next used rather than reallocating the string for each byte
+	tail []byte
+
+	line         []byte // the current line, until the indent is found
 	firstNewline bool
 	removeIndent string
 }
@@ -42,21 +50,57 @@ type posContent struct {
 	pos     Position
 }
 
+// catches for variables and brace escapes only live until their content is
+// moved elsewhere, so they and their content slices are reused across parses
+var catchPool = sync.Pool{
+	New: func() interface{} { return new(genericCatch) },
+}
+
+func newPooledCatch() *genericCatch {
+	return catchPool.Get().(*genericCatch)
+}
+
+// returns a catch to the pool. its content must not be used afterward
+func releaseCatch(c *genericCatch) {
+	for i := range c.positioned {
+		c.positioned[i] = posContent{}
+	}
+	for i := range c.positionedPrefix {
+		c.positionedPrefix[i] = posContent{}
+	}
+	*c = genericCatch{
+		positioned:       c.positioned[:0],
+		positionedPrefix: c.positionedPrefix[:0],
+		tail:             c.tail[:0],
+		line:             c.line[:0],
+	}
+	catchPool.Put(c)
+}
+
+// joins the tail to the last string
+func (c *genericCatch) flushTail() {
+	if len(c.tail) == 0 {
+		return
+	}
+	last := &c.positioned[len(c.positioned)-1]
+	last.content = last.content.(string) + string(c.tail)
+	c.tail = c.tail[:0]
+}
+
 func (c *genericCatch) setLastContent(content interface{}) {
+	c.flushTail()
 	c.positioned[len(c.positioned)-1].content = content
 }
 
 func (c *genericCatch) lastContent() interface{} {
-	if c.positioned == nil {
+	c.flushTail()
+	if len(c.positioned) == 0 {
 		return nil
 	}
 	return c.positioned[len(c.positioned)-1].content
 }
 
 func (c *genericCatch) lastString() string {
-	if c.positioned == nil {
-		return ""
-	}
 	content, ok := c.lastContent().(string)
 	if !ok {
 		return ""
@@ -83,22 +127,27 @@ func (c *genericCatch) appendContent(content interface{}, pos Position) {
 }
 
 func (c *genericCatch) appendContents(pc []posContent) {
+	c.flushTail()
 	c.positioned = append(c.positioned, pc...)
 }
 
 // append an existing string if the last item is one
 func (c *genericCatch) appendString(s string, pos Position) {
-	c.line += s
+
+	// the first line is kept to determine the indent
+	if !c.firstNewline {
+		c.line = append(c.line, s...)
+	}
 
 	// if it ends in a newline
 	if s[len(s)-1] == '\n' {
 		if !c.firstNewline && len(c.line) > 2 {
 			c.firstNewline = true // start a new one if the previous one ended in newline
 
-			afterTrim := strings.TrimLeft(c.line, "\t ")
+			afterTrim := bytes.TrimLeft(c.line, "\t ")
 			difference := len(c.line) - len(afterTrim)
 			if difference != 0 {
-				c.removeIndent = c.line[:difference]
+				c.removeIndent = string(c.line[:difference])
 			}
 		}
 		c.finishLine()
@@ -111,22 +160,22 @@ func (c *genericCatch) appendString(s string, pos Position) {
 	}
 
 	// append an existing string
-	switch v := c.lastContent().(type) {
-	case string:
-		if v != "" && v[len(v)-1] == '\n' {
-			// start a new one if the previous one ended in newline
-			c.pushContent(s, pos)
-		} else {
-			// other append the current string
-			c.positioned[len(c.positioned)-1].content = v + s
-		}
-	default:
+	v, ok := c.positioned[len(c.positioned)-1].content.(string)
+	switch {
+	case !ok:
+		c.pushContent(s, pos)
+	case len(c.tail) != 0 && c.tail[len(c.tail)-1] == '\n',
+		len(c.tail) == 0 && v != "" && v[len(v)-1] == '\n':
+		// start a new one if the previous one ended in newline
 		c.pushContent(s, pos)
+	default:
+		// otherwise append to the current string
+		c.tail = append(c.tail, s...)
 	}
 }
 
 func (c *genericCatch) finishLine() {
-	c.line = ""
+	c.line = c.line[:0]
 
 	// not working on a string..
 	lastStr, ok := c.lastContent().(string)
@@ -153,10 +202,16 @@ func (c *genericCatch) finishLine() {
 }
 
 func (c *genericCatch) pushContent(item interface{}, pos Position) {
+	c.flushTail()
 	c.positioned = append(c.positioned, posContent{item, pos})
 }
 
+func (c *genericCatch) empty() bool {
+	return len(c.positioned) == 0
+}
+
 func (c *genericCatch) posContent() []posContent {
+	c.flushTail()
 	return c.positioned
 }
 
@@ -165,6 +220,7 @@ func (c *genericCatch) positionedPrefixContent() []posContent {
 }
 
 func (c *genericCatch) content() []interface{} {
+	c.flushTail()
 	content := make([]interface{}, len(c.positioned))
 	for i, pc := range c.positioned {
 		content[i] = pc.content
@@ -0,0 +1,79 @@
+package wikifier
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A Tracer receives events as a page is parsed, for debugging the parser
+// or page source. Set Page.Tracer to enable tracing; parsing without a
+// tracer does no extra work.
+type Tracer interface {
+	Trace(event TraceEvent)
+}
+
+// A TraceEvent describes the parser entering or leaving a catch: a block,
+// variable name or value, or brace escape.
+type TraceEvent struct {
+	Pos   Position // position of the byte which caused the transition
+	Enter bool     // true if entering a catch, false if returning to a parent
+	Catch string   // the catch entered or returned to, such as "p{}"
+	Name  string   // block name, if entering a named block
+	Depth int      // depth of the catch entered or returned to; main{} is 0
+}
+
+// String returns a description of the event, indented by depth.
+func (e TraceEvent) String() string {
+	s := e.Pos.String() + " " + strings.Repeat("  ", e.Depth)
+	if e.Enter {
+		s += "enter " + e.Catch
+	} else {
+		s += "back to " + e.Catch
+	}
+	if e.Name != "" {
+		s += " [" + e.Name + "]"
+	}
+	return s
+}
+
+// WriterTracer is a Tracer which writes each event on a line.
+type WriterTracer struct {
+	W io.Writer
+}
+
+// Trace writes the event.
+func (t WriterTracer) Trace(event TraceEvent) {
+	fmt.Fprintln(t.W, event)
+}
+
+// reports a change of catch to the page tracer. prev is the catch before the
+// byte at the current position was handled
+func (p *parser) trace(page *Page, prev catch) {
+	depth := 0
+	for c := p.catch.parentCatch(); c != nil; c = c.parentCatch() {
+		depth++
+	}
+
+	// returning if the new catch contains the previous one; otherwise
+	// entering, possibly after leaving the previous one, as from a variable
+	// name to its value
+	enter := true
+	for c := prev; c != nil; c = c.parentCatch() {
+		if c == p.catch {
+			enter = false
+			break
+		}
+	}
+
+	event := TraceEvent{
+		Pos:   p.pos,
+		Enter: enter,
+		Catch: catchContext(p.catch),
+		Depth: depth,
+	}
+	if blk, ok := p.catch.(block); ok && event.Enter {
+		event.Name = blk.blockName()
+	}
+	page.Tracer.Trace(event)
+}
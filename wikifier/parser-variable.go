@@ -1,15 +1,14 @@
 package wikifier
 
-import "regexp"
-
 type variableName struct {
 	parent catch
 	*genericCatch
 }
 
 func newVariableName(pfx string, pos Position) *variableName {
-	pc := []posContent{{pfx, pos}}
-	return &variableName{genericCatch: &genericCatch{positionedPrefix: pc}}
+	c := newPooledCatch()
+	c.positionedPrefix = append(c.positionedPrefix, posContent{pfx, pos})
+	return &variableName{genericCatch: c}
 }
 
 func (vn *variableName) catchType() catchType {
@@ -22,14 +21,12 @@ func (vn *variableName) parentCatch() catch {
 
 // word-like chars and periods are OK in var names
 func (vn *variableName) byteOK(b byte) bool {
-	ok, _ := regexp.Match(`[\w\.\/]`, []byte{b})
-	return ok
+	return isWordByte(b) || b == '.' || b == '/'
 }
 
 // skip whitespace in variable name
 func (vn *variableName) shouldSkipByte(b byte) bool {
-	skip, _ := regexp.Match(`\s`, []byte{b})
-	return skip
+	return isSpaceByte(b)
 }
 
 type variableValue struct {
@@ -38,7 +35,7 @@ type variableValue struct {
 }
 
 func newVariableValue() *variableValue {
-	return &variableValue{genericCatch: &genericCatch{}}
+	return &variableValue{genericCatch: newPooledCatch()}
 }
 
 func (vv *variableValue) catchType() catchType {
@@ -50,11 +47,7 @@ func (vv *variableValue) parentCatch() catch {
 }
 
 func (vv *variableValue) byteOK(b byte) bool {
-	if b == '\n' {
-		return true
-	}
-	ok, _ := regexp.Match(`.`, []byte{b})
-	return ok
+	return true
 }
 
 // skip whitespace in variable name
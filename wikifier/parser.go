@@ -1,10 +1,10 @@
 package wikifier
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"regexp"
 	"strings"
 	"unicode"
 )
@@ -23,17 +23,23 @@ type parser struct {
 	catch catch // current parser catch
 	block block // current parser block
 
-	commentLevel int // comment depth
-	braceLevel   int // brace escape depth
+	commentLevel int  // comment depth
+	braceLevel   int  // brace escape depth
+	rawClose     bool // the brace escape ends with the block, as in foreach{}
 
 	varName            string
 	varNotInterpolated bool
 	varNegated         bool
+	varAppend          bool                // +: appends to a list
+	varPos             Position            // position of the current variable declaration
+	varPositions       map[string]Position // where each variable was set, for warnings
 
 	conditional       bool // current conditional
 	conditionalExists bool
 
 	lineHasStarted bool // true once the first non-space has occurred
+
+	finished []*genericCatch // catches to return to the pool after this byte
 }
 
 // Position represents a line and column position within a quiki source file.
@@ -80,14 +86,14 @@ func (pos *Position) UnmarshalJSON(data []byte) error {
 
 func newParser(page *Page) *parser {
 	mb := newBlock("main", "", "", nil, nil, nil, Position{}, page)
-	return &parser{block: mb, catch: mb}
+	return &parser{block: mb, catch: mb, varPositions: make(map[string]Position)}
 }
 
 func (p *parser) parseLine(line []byte, page *Page) error {
 	p.pos.Line++
 
 	// this is a hack to fix extra whitespace in blocks just before they close
-	if p.braceLevel == 0 && strings.TrimSpace(string(line)) == "}" {
+	if p.braceLevel == 0 && string(bytes.TrimSpace(line)) == "}" {
 		line = []byte{'}', '\n'}
 	}
 
@@ -123,9 +129,14 @@ func (p *parser) parseLine(line []byte, page *Page) error {
 		}
 
 		// handle this byte and give up if error occurred
+		prev := p.catch
 		if err := p.parseByte(b, page); err != nil {
 			return err
 		}
+		if page.Tracer != nil && p.catch != prev {
+			p.trace(page, prev)
+		}
+		p.releaseFinished()
 
 		// that was the very first non-space character on the line (quiki#3)
 		if !p.lineHasStarted && !unicode.IsSpace(rune(b)) {
@@ -137,12 +148,24 @@ func (p *parser) parseLine(line []byte, page *Page) error {
 
 // ParserError represents an error in parsing with positional info.
 type ParserError struct {
-	Pos Position
-	Err error
+	File  string   // name of the page file, if any
+	Pos   Position // line and column
+	Catch string   // what was being parsed, such as "p{}" or "Variable value"
+	Err   error
 }
 
+// Error returns the message with its context, as in
+//
+//	mypage.page {3 5} p{}: Unterminated block
 func (e *ParserError) Error() string {
-	return fmt.Sprintf("{%d %d} %s", e.Pos.Line, e.Pos.Column, e.Err.Error())
+	s := fmt.Sprintf("{%d %d} ", e.Pos.Line, e.Pos.Column)
+	if e.File != "" {
+		s = e.File + " " + s
+	}
+	if e.Catch != "" {
+		s += e.Catch + ": "
+	}
+	return s + e.Err.Error()
 }
 
 func (e *ParserError) Unwrap() error {
@@ -154,12 +177,37 @@ func parserError(pos Position, msg string) *ParserError {
 	return &ParserError{Pos: pos, Err: errors.New(msg)}
 }
 
+// creates a ParserError for the current variable declaration. the variable
+// catch has already ended, so its type is given
+func (p *parser) variableError(typ catchType, msg string) *ParserError {
+	return &ParserError{Pos: p.varPos, Catch: string(typ), Err: errors.New(msg)}
+}
+
+// describes a catch for ParserError
+func catchContext(c catch) string {
+	if blk, ok := c.(block); ok {
+		return blk.blockType() + "{}"
+	}
+	return string(c.catchType())
+}
+
+// like \w in a regular expression
+func isWordByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b == '_'
+}
+
+// like \s in a regular expression
+func isSpaceByte(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\f' || b == '\r'
+}
+
 var variableTokens = map[byte]bool{
 	'@': true,
 	'%': true,
 	':': true,
 	';': true,
 	'-': true,
+	'+': true,
 }
 
 func (p *parser) parseByte(b byte, page *Page) error {
@@ -182,17 +230,21 @@ func (p *parser) parseByte(b byte, page *Page) error {
 			// if this was the last brace, clear the brace escape catch
 			if p.braceLevel == 0 {
 				p.block.appendContents(p.catch.posContent())
+				p.finishCatch(p.catch)
 				p.catch = p.catch.parentCatch()
 			}
 		}
 
-		// proceed to the next byte if this was the first or last brace
-		if p.braceLevel == 0 {
+		// the last brace of foreach{} also closes the block
+		if p.braceLevel == 0 && p.rawClose {
+			p.rawClose = false
+		} else if p.braceLevel == 0 {
+			// proceed to the next byte if this was the first or last brace
 			return p.nextByte(b)
+		} else {
+			// otherwise, proceed to the catch
+			return p.handleByte(b)
 		}
-
-		// otherwise, proceed to the catch
-		return p.handleByte(b)
 	}
 
 	// COMMENTS
@@ -242,6 +294,7 @@ func (p *parser) parseByte(b byte, page *Page) error {
 		}
 
 		var blockClasses []string
+		var blockAttrs []blockAttr
 		var blockType, blockName, headingID string
 		var inHeadingID bool
 
@@ -255,7 +308,7 @@ func (p *parser) parseByte(b byte, page *Page) error {
 
 			// if there is no lastContent, give up because the block has no type
 			if len(lastContent) == 0 {
-				return errors.New("Block has no type")
+				return parserError(p.pos, "Block has no type")
 			}
 
 			// scan the text backward to find the block type and name
@@ -291,20 +344,35 @@ func (p *parser) parseByte(b byte, page *Page) error {
 				// block type/name
 				if inBlockName != 0 {
 					// we're currently in the block name
-					blockName = string(lastChar) + blockName
+					blockName = lastContent[i:i+1] + blockName
 				} else if inHeadingID {
 					// we're currently in the heading ID
 					if lastChar != ' ' && lastChar != '\t' {
-						headingID = string(lastChar) + headingID
+						headingID = lastContent[i:i+1] + headingID
 					}
-				} else if matched, _ := regexp.Match(`[\w\-\$\.]`, []byte{lastChar}); matched {
+				} else if lastChar == '#' && len(blockType) != 0 {
+					// element ID following the block type, e.g. sec#overview
+					blockType = "#" + blockType
+					continue
+				} else if lastChar == ')' {
+					// attribute with a value, e.g. sec!style(color: red)
+					start := blockAttrStart(lastContent[:i+1])
+					if start == -1 {
+						charsScanned--
+						break
+					}
+					blockType = lastContent[start:i+1] + blockType
+					charsScanned += i - start
+					i = start
+					continue
+				} else if isWordByte(lastChar) || lastChar == '-' || lastChar == '$' || lastChar == '.' {
 					// this could be part of the block type
 					blockType = string(lastChar) + blockType
 					continue
 				} else if lastChar == '~' && len(blockType) != 0 {
 					// tilde terminates block type
 					break
-				} else if matched, _ := regexp.Match(`\s`, []byte{lastChar}); matched && len(blockType) == 0 {
+				} else if isSpaceByte(lastChar) && len(blockType) == 0 {
 					// space between things
 					continue
 				} else {
@@ -317,6 +385,9 @@ func (p *parser) parseByte(b byte, page *Page) error {
 			// overwrite last content with the title and name stripped out
 			p.catch.setLastContent(lastContent[:len(lastContent)-charsScanned])
 
+			// extract element attributes
+			blockType, blockAttrs = splitBlockAttrs(blockType)
+
 			// if the block contains dots, it has classes
 			if split := strings.Split(string(blockType), "."); len(split) > 1 {
 				blockType, blockClasses = split[0], split[1:]
@@ -346,6 +417,7 @@ func (p *parser) parseByte(b byte, page *Page) error {
 
 		// create the block
 		block := newBlock(blockType, blockName, headingID, blockClasses, p.block, p.catch, p.pos, page)
+		applyBlockAttrs(block, blockAttrs, p.pos)
 
 		// TODO: produce a warning if the block has a name but the type does not support it
 
@@ -353,6 +425,18 @@ func (p *parser) parseByte(b byte, page *Page) error {
 		p.block = block
 		p.catch = block
 
+		// foreach{} content is parsed for each item, so it is kept as is
+		// like a brace escaped block, but closed with a single brace
+		if block.blockType() == "foreach" && p.next != '{' {
+			p.braceLevel++
+			p.rawClose = true
+
+			// start the brace escape catch
+			catch := newBraceEscape(p.pos)
+			catch.parent = p.catch
+			p.catch = catch
+		}
+
 		// if the next char is a brace, this is a brace escaped block
 		if p.next == '{' {
 			p.braceLevel++
@@ -388,7 +472,7 @@ func (p *parser) parseByte(b byte, page *Page) error {
 
 		// we cannot close the main block
 		if p.block.blockType() == "main" {
-			return errors.New("Attempted to close main block")
+			return parserError(p.pos, "Attempted to close main block")
 		}
 
 		// if{}, elsif{}, else{}, {@vars}
@@ -525,6 +609,16 @@ func (p *parser) parseByte(b byte, page *Page) error {
 			// disable interpolation if it's %var
 			p.varNotInterpolated = b == '%'
 
+			// only negated if - came immediately before; otherwise it was
+			// stray text starting some earlier line
+			p.varNegated = p.varNegated && p.last == '-'
+
+			// errors are reported at the start of the declaration
+			p.varPos = p.pos
+			if p.varNegated {
+				p.varPos.Column--
+			}
+
 			// catch the var name
 			catch := newVariableName(string(b), p.pos)
 			catch.parent = p.catch
@@ -533,17 +627,24 @@ func (p *parser) parseByte(b byte, page *Page) error {
 			return p.nextByte(b)
 		}
 
+		// +: appends to a list
+		if b == '+' && p.next == ':' && p.catch.catchType() == catchTypeVariableName {
+			p.varAppend = true
+			return p.nextByte(b)
+		}
+
 		// terminate variable name, enter value
 		if b == ':' && p.catch.catchType() == catchTypeVariableName {
 			// starts a variable value
 
 			// fetch var name, clear the catch
 			p.varName = p.catch.lastString()
+			p.finishCatch(p.catch)
 			p.catch = p.catch.parentCatch()
 
 			// no var name
 			if len(p.varName) == 0 {
-				return errors.New("Variable has no name")
+				return p.variableError(catchTypeVariableName, "Variable has no name")
 			}
 
 			// now catch the value
@@ -559,15 +660,17 @@ func (p *parser) parseByte(b byte, page *Page) error {
 
 			// fetch var name, clear the catch
 			p.varName = p.catch.lastString()
+			p.finishCatch(p.catch)
 			p.catch = p.catch.parentCatch()
 
 			// no var name
 			if len(p.varName) == 0 {
-				return errors.New("Variable has no name")
+				return p.variableError(catchTypeVariableName, "Variable has no name")
 			}
 
 			// set the value
 			page.Set(p.varName, !p.varNegated)
+			p.varPositions[p.varName] = p.varPos
 
 			p.clearVariableState()
 			return p.nextByte(b)
@@ -578,16 +681,17 @@ func (p *parser) parseByte(b byte, page *Page) error {
 
 			// we have to also check this here in case it was something like @;
 			if len(p.varName) == 0 {
-				return errors.New("Variable has no name")
+				return p.variableError(catchTypeVariableName, "Variable has no name")
 			}
 
 			// fetch content and clear catch
-			value := fixValuesForStorage(p.catch.content(), page, p.pos, !p.varNotInterpolated)
+			value := fixValuesForStorage(p.catch.content(), page, p.pos, !p.varNotInterpolated, false)
+			p.finishCatch(p.catch)
 			p.catch = p.catch.parentCatch()
 
 			switch val := value.(type) {
 			case []interface{}:
-				return fmt.Errorf("Variable '%s' contains both text and blocks", p.varName)
+				return p.variableError(catchTypeVariableValue, "Variable @"+p.varName+" contains both text and blocks")
 
 			case string, HTML:
 				// do nothing
@@ -603,16 +707,28 @@ func (p *parser) parseByte(b byte, page *Page) error {
 				// just cuz there is no way to tell that it has been done already
 				val.parse(page)
 
+				// data{} is replaced with the data it loaded
+				if data, ok := val.(*dataBlock); ok && data.value != nil {
+					value = data.value
+				}
+
 			case nil:
 				// empty string
 				value = ""
 
 			default:
-				return fmt.Errorf("Not sure what to do with: %v", val)
+				return p.variableError(catchTypeVariableValue, fmt.Sprintf("Not sure what to do with: %v", val))
 			}
 
-			// set the value
-			page.Set(p.varName, value)
+			// append the value to a list, or set it
+			if p.varAppend {
+				if err := p.appendVariable(page, value); err != nil {
+					return err
+				}
+			} else {
+				page.Set(p.varName, value)
+				p.varPositions[p.varName] = p.varPos
+			}
 
 			p.clearVariableState()
 			return p.nextByte(b)
@@ -638,14 +754,14 @@ func (p *parser) handleByte(b byte) error {
 	if p.catch == nil {
 		// nothing to catch! I don't think this can ever happen since the main block
 		// is the top-level catch and cannot be closed, but it's here just in case
-		return errors.New("Nothing to catch byte: " + string(b))
+		return parserError(p.pos, "Nothing to catch byte: "+string(b))
 	}
 
 	// at this point, anything that needs escaping should have been handled.
 	// so, if this byte is escaped and reached all the way to here, we will
 	// pretend it's not escaped by reinjecting a backslash. this allows
 	// further parsers to handle escapes (in particular, Formatter.)
-	add := string(b)
+	add := string([]byte{b})
 	if p.escape && !p.parserChar {
 		add = string([]byte{p.last, b})
 	}
@@ -662,6 +778,7 @@ func (p *parser) handleByte(b byte) error {
 		}
 
 		// revert to the parent catch, and add our stuff to it
+		p.finishCatch(p.catch)
 		p.catch = p.catch.parentCatch()
 		p.catch.appendContents(pc)
 
@@ -676,17 +793,17 @@ func (p *parser) handleByte(b byte) error {
 		if str := p.catch.lastString(); str != "" {
 			err += " Partial: " + str
 		}
-		return errors.New(err)
+		return parserError(p.pos, err)
 	}
 
 	// so um, if the content is whitespace/newline
 	// and the catch has no content yet, ignore this
-	if len(p.catch.content()) == 0 && (b == '\n') {
+	if p.catch.empty() && (b == '\n') {
 		return p.nextByte(b)
 	}
 
 	// append
-	p.catch.appendContent(add, p.pos)
+	p.catch.appendString(add, p.pos)
 
 	return p.nextByte(b)
 }
@@ -749,8 +866,50 @@ func (p *parser) getConditional(blk block, page *Page, condition string) bool {
 	return false
 }
 
+// marks a catch as finished. pooled catches are released after the current
+// byte, once the tracer is done with them
+func (p *parser) finishCatch(c catch) {
+	switch c := c.(type) {
+	case *variableName:
+		p.finished = append(p.finished, c.genericCatch)
+	case *variableValue:
+		p.finished = append(p.finished, c.genericCatch)
+	case *braceEscape:
+		p.finished = append(p.finished, c.genericCatch)
+	}
+}
+
+func (p *parser) releaseFinished() {
+	for i, c := range p.finished {
+		releaseCatch(c)
+		p.finished[i] = nil
+	}
+	p.finished = p.finished[:0]
+}
+
 func (p *parser) clearVariableState() {
 	p.varName = ""
 	p.varNotInterpolated = false
 	p.varNegated = false
+	p.varAppend = false
+	p.varPos = Position{}
+}
+
+// appends a value to the list variable being assigned, creating the list if
+// the variable does not exist yet
+func (p *parser) appendVariable(page *Page, value interface{}) *ParserError {
+	existing, err := page.Get(p.varName)
+	if err != nil {
+		return p.variableError(catchTypeVariableValue, "Variable @"+p.varName+": "+err.Error())
+	}
+	list, ok := existing.(*List)
+	if existing == nil {
+		list = NewList(page.mainBlock())
+		page.Set(p.varName, list)
+		p.varPositions[p.varName] = p.varPos
+	} else if !ok {
+		return p.variableError(catchTypeVariableValue, "Variable @"+p.varName+" is not a list ("+humanReadableValue(existing)+")")
+	}
+	list.appendEntry(value, p.varPos)
+	return nil
 }
@@ -0,0 +1,219 @@
+package wikifier
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// elements allowed in raw HTML by default when html.sanitize is enabled
+var sanitizeElements = makeSet(
+	"a", "abbr", "b", "bdi", "bdo", "blockquote", "br", "caption", "cite",
+	"code", "col", "colgroup", "dd", "del", "details", "dfn", "div", "dl",
+	"dt", "em", "figcaption", "figure", "h1", "h2", "h3", "h4", "h5", "h6",
+	"hr", "i", "img", "ins", "kbd", "li", "mark", "ol", "p", "pre", "q", "rp",
+	"rt", "ruby", "s", "samp", "small", "span", "strike", "strong", "sub",
+	"summary", "sup", "table", "tbody", "td", "tfoot", "th", "thead", "time",
+	"tr", "u", "ul", "var", "wbr",
+)
+
+// attributes allowed by default, as attr for any element or element.attr
+var sanitizeAttributes = makeSet(
+	"class", "id", "title", "lang", "dir",
+	"a.href", "a.name", "img.src", "img.alt", "img.width", "img.height",
+	"td.colspan", "td.rowspan", "th.colspan", "th.rowspan", "th.scope",
+	"col.span", "colgroup.span", "ol.start", "ol.reversed", "ol.type",
+	"li.value", "time.datetime", "details.open",
+	"blockquote.cite", "q.cite", "del.cite", "ins.cite",
+	"iframe.src", "iframe.width", "iframe.height", "iframe.allow",
+	"iframe.allowfullscreen", "iframe.loading", "iframe.referrerpolicy",
+)
+
+// elements which are removed along with their content when not allowed
+var sanitizeDropContent = makeSet(
+	"script", "style", "iframe", "object", "embed", "template", "textarea",
+	"title", "xmp", "noembed", "noframes", "noscript", "plaintext", "svg",
+	"math", "select",
+)
+
+// elements which cannot be allowed by html.elements, since they can run
+// scripts or change the document outside of the page content. iframes are
+// allowed by html.iframe_hosts instead
+var sanitizeForbidden = makeSet(
+	"script", "style", "iframe", "object", "embed", "applet", "base",
+	"link", "meta", "frame", "frameset", "form", "svg", "math",
+)
+
+// attributes whose values are URLs
+var sanitizeURLAttributes = makeSet("href", "src", "cite")
+
+// styles produced by formatting tags such as [b], which are allowed even if
+// style attributes are not
+var sanitizeFormatStyle = regexp.MustCompile(`^(font-style: italic|font-weight: bold|text-decoration: line-through);$`)
+
+// URL schemes allowed in links and images
+var sanitizeSchemes = makeSet("", "http", "https", "mailto")
+
+// returns an error if a raw HTML policy allows something which cannot be
+// made safe
+func checkHTMLPolicy(opt PageOptHTML) error {
+	for _, name := range opt.Elements {
+		if sanitizeForbidden[name] {
+			return errors.New("html.elements: '" + name + "' cannot be allowed")
+		}
+	}
+	for _, name := range opt.Attributes {
+		attr := name[strings.IndexByte(name, '.')+1:]
+		if strings.HasPrefix(attr, "on") || attr == "srcdoc" || attr == "formaction" {
+			return errors.New("html.attributes: '" + name + "' cannot be allowed")
+		}
+	}
+	return nil
+}
+
+// sanitizeHTML removes the elements and attributes of raw HTML which are not
+// allowed by the html.* options. if html.sanitize is disabled, the HTML is
+// returned as-is
+func (p *Page) sanitizeHTML(s string) string {
+	opt := p.Opt.HTML
+	if !opt.Sanitize {
+		return s
+	}
+	extraElements, extraAttributes, iframeHosts :=
+		makeSet(opt.Elements...), makeSet(opt.Attributes...), makeSet(opt.IframeHosts...)
+
+	allowElement := func(name string) bool {
+		if name == "iframe" {
+			return len(iframeHosts) != 0
+		}
+		return sanitizeElements[name] || extraElements[name]
+	}
+	allowAttribute := func(element, attr string) bool {
+		if strings.HasPrefix(attr, "on") {
+			return false
+		}
+		return sanitizeAttributes[attr] || sanitizeAttributes[element+"."+attr] ||
+			extraAttributes[attr] || extraAttributes[element+"."+attr]
+	}
+
+	var b strings.Builder
+	skip, skipDepth := "", 0
+	z := html.NewTokenizer(strings.NewReader(s))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return b.String()
+		}
+		tok := z.Token()
+
+		// inside an element which is removed with its content
+		if skip != "" {
+			if tok.Data == skip && tt == html.StartTagToken {
+				skipDepth++
+			} else if tok.Data == skip && tt == html.EndTagToken {
+				skipDepth--
+			}
+			if skipDepth == 0 {
+				skip = ""
+			}
+			continue
+		}
+
+		switch tt {
+
+		// text is escaped again, so that removing a tag cannot create a
+		// new one from the text around it
+		case html.TextToken:
+			b.WriteString(html.EscapeString(tok.Data))
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			allowed := allowElement(tok.Data)
+
+			// iframes must come from an allowed host
+			if allowed && tok.Data == "iframe" {
+				allowed = false
+				for _, attr := range tok.Attr {
+					if attr.Key == "src" && sanitizeIframeSrc(attr.Val, iframeHosts) {
+						allowed = true
+					}
+				}
+			}
+
+			if !allowed {
+				if tt == html.StartTagToken && sanitizeDropContent[tok.Data] {
+					skip, skipDepth = tok.Data, 1
+				}
+				continue
+			}
+
+			b.WriteString("<" + tok.Data)
+			for _, attr := range tok.Attr {
+				if attr.Namespace != "" {
+					continue
+				}
+				if !allowAttribute(tok.Data, attr.Key) &&
+					!(attr.Key == "style" && sanitizeFormatStyle.MatchString(attr.Val)) {
+					continue
+				}
+				if sanitizeURLAttributes[attr.Key] && !sanitizeURL(attr.Val) {
+					continue
+				}
+				if attr.Key == "style" && !sanitizeStyle(attr.Val) {
+					continue
+				}
+				b.WriteString(" " + attr.Key + `="` + html.EscapeString(attr.Val) + `"`)
+			}
+			if tt == html.SelfClosingTagToken {
+				b.WriteString(" />")
+			} else {
+				b.WriteString(">")
+			}
+
+		case html.EndTagToken:
+			if allowElement(tok.Data) {
+				b.WriteString("</" + tok.Data + ">")
+			}
+
+		// comments and doctypes are removed
+		default:
+		}
+	}
+}
+
+// returns true if a URL uses an allowed scheme
+func sanitizeURL(val string) bool {
+	u, err := url.Parse(strings.TrimSpace(val))
+	return err == nil && sanitizeSchemes[strings.ToLower(u.Scheme)]
+}
+
+// returns true if an iframe source is an https URL on an allowed host
+func sanitizeIframeSrc(val string, hosts map[string]bool) bool {
+	u, err := url.Parse(strings.TrimSpace(val))
+	if err != nil {
+		return false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	return scheme == "https" && hosts[strings.ToLower(u.Hostname())]
+}
+
+// returns true if a style attribute cannot load resources or run scripts
+func sanitizeStyle(val string) bool {
+	val = strings.ToLower(val)
+	for _, bad := range []string{"url(", "expression(", "javascript:", "@import", "behavior:", "\\"} {
+		if strings.Contains(val, bad) {
+			return false
+		}
+	}
+	return true
+}
+
+func makeSet(items ...string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
@@ -0,0 +1,123 @@
+package wikifier
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SnapshotResult is the result of comparing a single page fixture to its
+// golden file.
+type SnapshotResult struct {
+	Name    string // fixture name, without extension
+	Page    string // path to the .page fixture
+	Golden  string // path to the .html golden file
+	Got     string // output rendered from the fixture
+	Want    string // contents of the golden file, if any
+	Updated bool   // true if the golden file was written
+	Err     error  // error parsing the fixture or reading the golden file
+}
+
+// OK returns true if the fixture rendered successfully and matches its
+// golden file.
+func (r SnapshotResult) OK() bool {
+	return r.Err == nil && r.Got == r.Want
+}
+
+// RunSnapshots renders each .page file in dir and compares the output to the
+// .html golden file of the same name.
+//
+// If update is true, golden files are written with the current output
+// rather than compared, so that rendering changes can be reviewed as diffs.
+// Golden files which do not exist are treated as empty.
+//
+// Fixtures are parsed with default page options. The returned error is only
+// non-nil if dir cannot be read; errors for individual fixtures are recorded
+// in their results.
+func RunSnapshots(dir string, update bool) ([]SnapshotResult, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.page"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	results := make([]SnapshotResult, len(files))
+	for i, path := range files {
+		results[i] = runSnapshot(path, update)
+	}
+	return results, nil
+}
+
+func runSnapshot(path string, update bool) SnapshotResult {
+	name := strings.TrimSuffix(filepath.Base(path), ".page")
+	r := SnapshotResult{
+		Name:   name,
+		Page:   path,
+		Golden: strings.TrimSuffix(path, ".page") + ".html",
+	}
+
+	// render
+	r.Got, r.Err = RenderSnapshot(path)
+	if r.Err != nil {
+		return r
+	}
+
+	// write golden file
+	if update {
+		r.Want = r.Got
+		if r.Err = ioutil.WriteFile(r.Golden, []byte(r.Got), 0644); r.Err == nil {
+			r.Updated = true
+		}
+		return r
+	}
+
+	// read golden file
+	want, err := ioutil.ReadFile(r.Golden)
+	if err != nil && !os.IsNotExist(err) {
+		r.Err = err
+	}
+	r.Want = string(want)
+	return r
+}
+
+// RenderSnapshot parses the page at the given path with default options and
+// returns its output in the format used for golden files: the generated HTML,
+// followed by the CSS and warnings, if any.
+//
+// The page is named after the file alone, so the output does not depend on
+// the directory in which it is located.
+func RenderSnapshot(path string) (string, error) {
+	page := NewPagePath(path, filepath.Base(path))
+	if err := page.Parse(); err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	b.WriteString(string(page.HTML()))
+
+	// sections are collected first, as counting their words writes their
+	// elements again, which must not change the CSS
+	page.Sections()
+
+	// css
+	if css := page.CSS(); css != "" {
+		b.WriteString("<!-- css -->\n")
+		b.WriteString(css)
+	}
+
+	// warnings
+	if len(page.Warnings) != 0 {
+		b.WriteString("<!-- warnings -->\n")
+		for _, warn := range page.Warnings {
+			b.WriteString(warn.Pos.String() + " " + warn.Message + "\n")
+		}
+	}
+
+	return b.String(), nil
+}
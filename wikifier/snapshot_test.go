@@ -0,0 +1,34 @@
+package wikifier
+
+import (
+	"flag"
+	"testing"
+)
+
+var updateSnapshots = flag.Bool("update", false, "write golden files rather than comparing")
+
+// compares the rendering of each fixture in testdata/snapshots with its
+// golden file. after a deliberate change, run with -update and review the
+// golden files with git diff
+func TestSnapshots(t *testing.T) {
+	results, err := RunSnapshots("testdata/snapshots", *updateSnapshots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) == 0 {
+		t.Fatal("no fixtures in testdata/snapshots")
+	}
+	for _, r := range results {
+		r := r
+		t.Run(r.Name, func(t *testing.T) {
+			switch {
+			case r.Err != nil:
+				t.Fatal(r.Err)
+			case r.Updated:
+				t.Logf("updated %s", r.Golden)
+			case !r.OK():
+				t.Errorf("output differs from %s\n--- want\n%s--- got\n%s", r.Golden, r.Want, r.Got)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package wikifier
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/cooper/quiki/markdown"
+)
+
+// A PageTranslator converts page source in another format, such as
+// Markdown, to quiki source as it is read.
+type PageTranslator func(src io.Reader, dst io.Writer) error
+
+var (
+	translatorLock sync.RWMutex
+
+	// translators by name, for the page.extensions option.
+	// quiki source needs no translation
+	pageTranslators = map[string]PageTranslator{
+		"quiki":     nil,
+		"markdown":  convertMarkdown,
+		"text":      convertText,
+		"plaintext": convertPlaintext,
+		"csv":       convertCSV,
+	}
+
+	// extensions which are stripped from page names in any wiki. others are
+	// configured per wiki with page.extensions
+	pageExtensions = map[string]bool{
+		".page": true,
+		".md":   true,
+	}
+)
+
+// RegisterPageTranslator makes a translator available by name to the
+// page.extensions option, such as
+//
+//	@page.extensions: map{ rst: restructuredtext; };
+func RegisterPageTranslator(name string, t PageTranslator) {
+	translatorLock.Lock()
+	defer translatorLock.Unlock()
+	pageTranslators[name] = t
+}
+
+// looks up a translator. ok is false if there is none by this name
+func pageTranslator(name string) (t PageTranslator, ok bool) {
+	translatorLock.RLock()
+	defer translatorLock.RUnlock()
+	t, ok = pageTranslators[name]
+	return
+}
+
+func isPageExtension(ext string) bool {
+	return pageExtensions[ext]
+}
+
+// PageExtensions returns the extensions of page source files, in the order
+// they are preferred when a page exists in more than one format.
+func (opt *PageOpt) PageExtensions() []string {
+	exts := make([]string, len(opt.Page.Extensions))
+	for i, e := range opt.Page.Extensions {
+		exts[i] = e.Ext
+	}
+	return exts
+}
+
+// PageName is like the PageName function, except it also recognizes the
+// page extensions of this wiki.
+func (opt *PageOpt) PageName(name string) string {
+	if opt.hasPageExtension(name) {
+		return PageNameLink(name)
+	}
+	return PageName(name)
+}
+
+// PageNameNE is like the PageNameNE function, except it also removes the
+// page extensions of this wiki.
+func (opt *PageOpt) PageNameNE(name string) string {
+	name = PageNameNE(name)
+	for _, e := range opt.Page.Extensions {
+		if strings.HasSuffix(name, e.Ext) && len(name) > len(e.Ext) {
+			return strings.TrimSuffix(name, e.Ext)
+		}
+	}
+	return name
+}
+
+func (opt *PageOpt) hasPageExtension(name string) bool {
+	for _, e := range opt.Page.Extensions {
+		if strings.HasSuffix(name, e.Ext) && len(name) > len(e.Ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// PageTranslatorName returns the name of the translator for a page source
+// file, such as markdown, or an empty string if the extension is not one of
+// the page extensions.
+func (opt *PageOpt) PageTranslatorName(path string) string {
+	for _, e := range opt.Page.Extensions {
+		if strings.HasSuffix(path, e.Ext) {
+			return e.Translator
+		}
+	}
+	return ""
+}
+
+// returns the translator for a page source file, or nil if it is quiki
+// source
+func (opt *PageOpt) translatorFor(path string) (PageTranslator, error) {
+	name := opt.PageTranslatorName(path)
+	if name == "" {
+		return nil, nil
+	}
+	t, ok := pageTranslator(name)
+	if !ok {
+		return nil, errors.New("no page translator named '" + name + "'")
+	}
+	return t, nil
+}
+
+// TranslateSource converts the source of a page source file which may not
+// have been written yet to quiki source, as if it were read from path.
+// ok is false if path does not have one of the page extensions.
+func (opt *PageOpt) TranslateSource(path, source string) (quiki string, ok bool, err error) {
+	if opt.PageTranslatorName(path) == "" {
+		return "", false, nil
+	}
+	translate, err := opt.translatorFor(path)
+	if err != nil || translate == nil {
+		return source, true, err
+	}
+	var buf bytes.Buffer
+	err = translate(strings.NewReader(source), &buf)
+	return buf.String(), true, err
+}
+
+func convertMarkdown(src io.Reader, dst io.Writer) error {
+	return markdown.Convert(src, dst)
+}
+
+// translates plain text to quiki source, with a paragraph for each group
+// of lines separated by a blank line
+func convertText(src io.Reader, dst io.Writer) error {
+	w := bufio.NewWriter(dst)
+	scanner := bufio.NewScanner(src)
+	inParagraph := false
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		switch {
+		case line == "" && inParagraph:
+			w.WriteString("}\n\n")
+			inParagraph = false
+		case line == "":
+		default:
+			if !inParagraph {
+				w.WriteString("p {\n")
+				inParagraph = true
+			}
+			w.WriteString(textEscaper.Replace(line) + "\n")
+		}
+	}
+	if inParagraph {
+		w.WriteString("}\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// translates plain text to quiki source which displays it preformatted, as
+// with plaintext{}
+func convertPlaintext(src io.Reader, dst io.Writer) error {
+	return convertToBlock(src, dst, "plaintext [source]")
+}
+
+// translates comma-separated values to quiki source which displays them as a
+// table, as with csv{}
+func convertCSV(src io.Reader, dst io.Writer) error {
+	return convertToBlock(src, dst, "csv")
+}
+
+// translates text to quiki source with a single block containing the
+// escaped text
+func convertToBlock(src io.Reader, dst io.Writer, blockType string) error {
+	w := bufio.NewWriter(dst)
+	scanner := bufio.NewScanner(src)
+	scanner.Buffer(nil, 1024*1024)
+	w.WriteString(blockType + " {\n")
+	indentKnown := false
+	for scanner.Scan() {
+		line := textEscaper.Replace(strings.TrimRight(scanner.Text(), "\r"))
+
+		// the indent of the first line is removed from all lines in a
+		// block, so escape it to keep it
+		if !indentKnown && strings.TrimSpace(line) != "" {
+			indentKnown = true
+			if line[0] == ' ' || line[0] == '\t' {
+				line = `\` + line
+			}
+		}
+		w.WriteString(line + "\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	w.WriteString("}\n")
+	return w.Flush()
+}
+
+// escapes characters with meaning in quiki source
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`[`, `\[`,
+	`]`, `\]`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`/*`, `\/*`,
+)
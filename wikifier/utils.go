@@ -12,6 +12,11 @@ import (
 
 var nonAlphaRegex = regexp.MustCompile(`[^\w\.\-\/]`)
 
+// like nonAlphaRegex, except letters and numbers in any script are kept, as
+// in the anchors generated for Markdown headings. this is only for heading
+// anchors, so that the names of page files are not changed
+var nonAlphaAnchorRegex = regexp.MustCompile(`[^\p{L}\p{M}\p{Nd}_\.\-\/]`)
+
 // Represents a quiki value type.
 type valueType int
 
@@ -119,8 +124,9 @@ func humanReadableValue(i interface{}) string {
 
 // fix a value before storing it in a list or map
 // this returns either a string, block, or []interface{} of both
-// strings next to each other are merged; empty strings are removed
-func fixValuesForStorage(values []interface{}, pageMaybe *Page, pos Position, fmtText bool) interface{} {
+// strings next to each other are merged; empty strings are removed.
+// with mentions, formatted text is body text which may mention users
+func fixValuesForStorage(values []interface{}, pageMaybe *Page, pos Position, fmtText, mentions bool) interface{} {
 
 	// no items
 	if len(values) == 0 {
@@ -129,7 +135,7 @@ func fixValuesForStorage(values []interface{}, pageMaybe *Page, pos Position, fm
 
 	// one value in; one value out!
 	if len(values) == 1 {
-		return fixSingleValue(values[0], pageMaybe, pos, fmtText)
+		return fixSingleValue(values[0], pageMaybe, pos, fmtText, mentions)
 	}
 
 	// multiple values
@@ -138,7 +144,7 @@ func fixValuesForStorage(values []interface{}, pageMaybe *Page, pos Position, fm
 	for _, value := range values {
 
 		// fix this value; then skip it if it's nothin
-		value = fixSingleValue(value, pageMaybe, pos, fmtText)
+		value = fixSingleValue(value, pageMaybe, pos, fmtText, mentions)
 		if value == nil {
 			continue
 		}
@@ -173,7 +179,7 @@ func fixValuesForStorage(values []interface{}, pageMaybe *Page, pos Position, fm
 	return valuesToStore
 }
 
-func fixSingleValue(value interface{}, pageMaybe *Page, pos Position, fmtText bool) interface{} {
+func fixSingleValue(value interface{}, pageMaybe *Page, pos Position, fmtText, mentions bool) interface{} {
 	switch v := value.(type) {
 	case HTML:
 		return v
@@ -183,7 +189,7 @@ func fixSingleValue(value interface{}, pageMaybe *Page, pos Position, fmtText bo
 			return nil
 		}
 		if fmtText && pageMaybe != nil {
-			return pageMaybe.Fmt(v, pos)
+			return pageMaybe.FmtOpts(v, pos, FmtOpt{mentions: mentions})
 		}
 		return v
 	case block:
@@ -329,11 +335,11 @@ func PageName(name string) string {
 
 // PageNameNE returns a clean page name with No Extension.
 func PageNameNE(name string) string {
-	// TODO: make this less ugly
-	name = strings.TrimSuffix(PageName(name), ".page")
-	name = strings.TrimSuffix(name, ".model")
-	name = strings.TrimSuffix(name, ".conf")
-	name = strings.TrimSuffix(name, ".md")
+	name = PageName(name)
+	ext := filepath.Ext(name)
+	if isPageExtension(ext) || ext == ".model" || ext == ".conf" {
+		name = strings.TrimSuffix(name, ext)
+	}
 	return name
 }
 
@@ -352,8 +358,7 @@ func PageNameExt(name, ext string) string {
 	lastDot := strings.LastIndexByte(name, '.')
 	if lastDot != -1 && lastDot < len(name)-1 {
 		existing := name[lastDot:]
-		if existing != ".page" && existing != ".model" && existing != ".conf" && existing != ".md" {
-			// TODO: make above prettier
+		if !isPageExtension(existing) && existing != ".model" && existing != ".conf" {
 			name += ext
 		}
 	} else {
@@ -382,6 +387,13 @@ func PageNameLink(name string) string {
 	return name
 }
 
+// returns the anchor of a heading, or of a section in a link, like
+// PageNameLink except that letters in any script are kept
+func headingAnchor(name string) string {
+	name = filepath.ToSlash(strings.TrimSpace(name))
+	return nonAlphaAnchorRegex.ReplaceAllString(name, "_")
+}
+
 // CategoryName returns a clean category name.
 func CategoryName(name string) string {
 	name = PageNameLink(name)
@@ -122,6 +122,11 @@ func (scope *variableScope) GetStr(key string) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	return strValue(val)
+}
+
+// strValue converts a value fetched with Get to a string.
+func strValue(val interface{}) (string, error) {
 
 	// there is nothing here
 	if val == nil {
@@ -145,6 +150,11 @@ func (scope *variableScope) GetBool(key string) (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	return boolValue(val)
+}
+
+// boolValue converts a value fetched with Get to a boolean.
+func boolValue(val interface{}) (bool, error) {
 
 	// there is nothing here
 	if val == nil {
@@ -166,6 +176,11 @@ func (scope *variableScope) GetObj(key string) (AttributedObject, error) {
 	if err != nil {
 		return nil, err
 	}
+	return objValue(obj)
+}
+
+// objValue converts a value fetched with Get to an AttributedObject.
+func objValue(obj interface{}) (AttributedObject, error) {
 
 	// there is nothing here
 	if obj == nil {
@@ -187,6 +202,11 @@ func (scope *variableScope) GetBlock(key string) (block, error) {
 	if err != nil {
 		return nil, err
 	}
+	return blockValue(obj)
+}
+
+// blockValue converts a value fetched with Get to a block.
+func blockValue(obj interface{}) (block, error) {
 
 	// there is nothing here
 	if obj == nil {
@@ -262,8 +282,67 @@ func (scope *variableScope) GetStrList(key string) ([]string, error) {
 	return nil, errors.New("not a list{} or comma-separated list")
 }
 
+// Vars returns all variables in the scope as plain values which can be
+// encoded, such as with encoding/json.
+//
+// Strings and formatted text are strings, booleans are bools, map{} and
+// list{} blocks are maps and slices, and other blocks are described by a map
+// with their type and name.
+//
+func (scope *variableScope) Vars() map[string]interface{} {
+	vars := make(map[string]interface{}, len(scope.vars))
+	for key, val := range scope.vars {
+		vars[key] = plainValue(val)
+	}
+	return vars
+}
+
+// CopyVars returns a new Map with copies of the variables in the scope
+// which are strings, formatted text, or booleans, including those within
+// map{} blocks. Other values, such as other blocks, are not copied.
+//
+// This is for sharing variables across pages, such as the @site variables of
+// a wiki, since pages may modify their variables.
+func (scope *variableScope) CopyVars() *Map {
+	m := NewMap(nil)
+	for key, val := range scope.vars {
+		switch v := val.(type) {
+		case string, HTML, bool:
+			m.setOwn(key, v)
+		case *Map:
+			m.setOwn(key, v.CopyVars())
+		}
+	}
+	return m
+}
+
 // INTERNAL
 
+// converts a variable value to a plain value for Vars
+func plainValue(i interface{}) interface{} {
+	switch v := i.(type) {
+	case HTML:
+		return string(v)
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, val := range v {
+			values[i] = plainValue(val)
+		}
+		return values
+	case *Map:
+		return v.Vars()
+	case *List:
+		values := make([]interface{}, len(v.list))
+		for i, entry := range v.list {
+			values[i] = plainValue(entry.value)
+		}
+		return values
+	case block:
+		return map[string]string{"type": v.blockType(), "name": v.blockName()}
+	}
+	return i
+}
+
 // set own property
 func (scope *variableScope) setOwn(key string, value interface{}) {
 	scope.vars[key] = value